@@ -0,0 +1,601 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  ccd.go - Format CloneCD (CCD/IMG/SUB).
+ */
+
+package cdread
+
+import (
+  "bufio"
+  "errors"
+  "fmt"
+  "os"
+  "path"
+  "sort"
+  "strconv"
+  "strings"
+)
+
+
+
+
+/*********/
+/* UTILS */
+/*********/
+
+// Llig un fitxer INI. Els noms de les seccions i de les claus es
+// tornen en minúscules.
+func readIniCcd( file_name string ) (map[string]map[string]string,error) {
+
+  // Obri
+  f,err:= os.Open ( file_name )
+  if err != nil { return nil,err }
+  defer f.Close ()
+
+  // Llig
+  ret:= make(map[string]map[string]string)
+  var section map[string]string= nil
+  s:= bufio.NewScanner ( f )
+  for nline:= 1; s.Scan (); nline++ {
+    line:= strings.TrimSpace ( s.Text () )
+    if nline == 1 { line= strings.TrimPrefix ( line, "\uFEFF" ) }
+    if len(line) == 0 || line[0] == ';' { continue }
+    if line[0] == '[' {
+      if line[len(line)-1] != ']' {
+        return nil,fmt.Errorf ( "wrong section format in line %d: %s",
+          nline, line )
+      }
+      name:= strings.ToLower ( strings.TrimSpace ( line[1:len(line)-1] ) )
+      section= make(map[string]string)
+      ret[name]= section
+    } else {
+      pos:= strings.IndexByte ( line, '=' )
+      if pos == -1 || section == nil {
+        return nil,fmt.Errorf ( "wrong format in line %d: %s", nline, line )
+      }
+      key:= strings.ToLower ( strings.TrimSpace ( line[:pos] ) )
+      section[key]= strings.TrimSpace ( line[pos+1:] )
+    }
+  }
+  if err:= s.Err (); err != nil { return nil,err }
+
+  return ret,nil
+
+} // end readIniCcd
+
+
+// Obté un valor enter (decimal o hexadecimal amb 0x).
+func getIntCcd(
+
+  section map[string]string,
+  name    string,
+  key     string,
+
+) (int64,error) {
+
+  val,ok:= section[key]
+  if !ok {
+    return 0,fmt.Errorf ( "key '%s' not found in section [%s]", key, name )
+  }
+  ret,err:= strconv.ParseInt ( val, 0, 64 )
+  if err != nil {
+    return 0,fmt.Errorf ( "wrong value for key '%s' in section [%s]: %s",
+      key, name, val )
+  }
+
+  return ret,nil
+
+} // end getIntCcd
+
+
+
+
+/******/
+/* CD */
+/******/
+
+type _CD_Ccd_Track struct {
+
+  number     int
+  session    int
+  control    uint8
+  mode       int64
+  track_type int
+  index0     int64 // LBA. -1 si no té
+  index1     int64 // LBA
+  end        int64 // LBA del primer sector que no és del track
+  img_sector int64 // Sector dins de l'IMG del primer sector del track
+  isrc       string
+
+}
+
+type _CD_Ccd_Session struct {
+
+  tracks  []_CD_Ccd_Track
+  leadout int64
+
+}
+
+type _CD_Ccd struct {
+
+  file_name string
+  img_file  string
+  sub_file  string // Buit si no hi ha
+  sessions  []_CD_Ccd_Session
+  catalog   string
+  cdtext    []CDText // 0 és el disc
+
+}
+
+
+// Llig el TOC.
+func (self *_CD_Ccd) readToc( ini map[string]map[string]string ) error {
+
+  // Disc
+  disc,ok:= ini["disc"]
+  if !ok { return errors.New ( "section [Disc] not found" ) }
+  num_entries,err:= getIntCcd ( disc, "Disc", "tocentries" )
+  if err != nil { return err }
+  num_sessions,err:= getIntCcd ( disc, "Disc", "sessions" )
+  if err != nil { return err }
+  if num_sessions < 1 || num_sessions > 99 {
+    return fmt.Errorf ( "wrong number of sessions: %d", num_sessions )
+  }
+  if scrambled,err:= getIntCcd ( disc, "Disc",
+    "datatracksscrambled" ); err == nil && scrambled != 0 {
+    return errors.New ( "scrambled data tracks not supported" )
+  }
+  self.catalog= disc["catalog"]
+
+  // Entrades
+  self.sessions= make([]_CD_Ccd_Session,num_sessions)
+  for i:= range self.sessions {
+    self.sessions[i].leadout= -1
+  }
+  for i:= int64(0); i < num_entries; i++ {
+    name:= fmt.Sprintf ( "Entry %d", i )
+    entry,ok:= ini[strings.ToLower ( name )]
+    if !ok { return fmt.Errorf ( "section [%s] not found", name ) }
+    session,err:= getIntCcd ( entry, name, "session" )
+    if err != nil { return err }
+    if session < 1 || session > num_sessions {
+      return fmt.Errorf ( "wrong session in section [%s]: %d", name, session )
+    }
+    point,err:= getIntCcd ( entry, name, "point" )
+    if err != nil { return err }
+    control,err:= getIntCcd ( entry, name, "control" )
+    if err != nil { return err }
+    plba,err:= getIntCcd ( entry, name, "plba" )
+    if err != nil { return err }
+    sess:= &self.sessions[session-1]
+    if point >= 1 && point <= 99 {
+      sess.tracks= append(sess.tracks,_CD_Ccd_Track{
+        number : int(point),
+        session : int(session),
+        control : uint8(control),
+        index0 : -1,
+        index1 : plba,
+      })
+    } else if point == 0xa2 {
+      sess.leadout= plba
+    }
+  }
+
+  // Informació dels tracks
+  for s:= range self.sessions {
+    sess:= &self.sessions[s]
+    if len(sess.tracks) == 0 {
+      return fmt.Errorf ( "session %d without tracks", s+1 )
+    }
+    if sess.leadout == -1 {
+      return fmt.Errorf ( "lead-out of session %d not found", s+1 )
+    }
+    sort.Slice ( sess.tracks, func(i,j int) bool {
+      return sess.tracks[i].number < sess.tracks[j].number
+    })
+    for t:= range sess.tracks {
+      if err:= self.readTrack ( ini, &sess.tracks[t] ); err != nil {
+        return err
+      }
+    }
+  }
+
+  return nil
+
+} // end readToc
+
+
+func (self *_CD_Ccd) readTrack(
+
+  ini   map[string]map[string]string,
+  track *_CD_Ccd_Track,
+
+) error {
+
+  // Secció del track
+  name:= fmt.Sprintf ( "TRACK %d", track.number )
+  sec,ok:= ini[strings.ToLower ( name )]
+  if !ok { return fmt.Errorf ( "section [%s] not found", name ) }
+  var err error
+  if track.mode,err= getIntCcd ( sec, name, "mode" ); err != nil {
+    return err
+  }
+  if _,ok:= sec["index 0"]; ok {
+    if track.index0,err= getIntCcd ( sec, name, "index 0" ); err != nil {
+      return err
+    }
+  }
+  if _,ok:= sec["index 1"]; ok {
+    if track.index1,err= getIntCcd ( sec, name, "index 1" ); err != nil {
+      return err
+    }
+  }
+  if track.index0 >= track.index1 { track.index0= -1 }
+  track.isrc= sec["isrc"]
+
+  // Tipus
+  if (track.control&TRACK_FLAGS_DATA) == 0 {
+    track.track_type= TRACK_TYPE_AUDIO
+  } else {
+    switch track.mode {
+    case 1:
+      track.track_type= TRACK_TYPE_MODE1_RAW
+    case 2:
+      track.track_type= TRACK_TYPE_MODE2_RAW
+    default:
+      track.track_type= TRACK_TYPE_UNK
+    }
+  }
+
+  return nil
+
+} // end readTrack
+
+
+// Calcula els límits de cada track i la seua posició en l'IMG. L'IMG
+// conté els tracks de manera consecutiva, sense les àrees entre
+// sessions (lead-out/lead-in) ni el pregap del primer track.
+func (self *_CD_Ccd) mapSectors() error {
+
+  // Límits
+  var img_sector int64= 0
+  for s:= range self.sessions {
+    sess:= &self.sessions[s]
+    for t:= range sess.tracks {
+      track:= &sess.tracks[t]
+      if t == len(sess.tracks)-1 {
+        track.end= sess.leadout
+      } else {
+        next:= &sess.tracks[t+1]
+        if next.index0 != -1 {
+          track.end= next.index0
+        } else {
+          track.end= next.index1
+        }
+      }
+      start:= track.start ()
+      if track.end <= track.index1 || start < 0 {
+        return fmt.Errorf ( "wrong limits for track %d", track.number )
+      }
+      track.img_sector= img_sector
+      img_sector+= track.end-start
+    }
+  }
+
+  // Comprova grandària
+  info,err:= os.Stat ( self.img_file )
+  if err != nil { return err }
+  if info.Size () < img_sector*SECTOR_SIZE {
+    return fmt.Errorf ( "image file '%s' is too small (expected %d bytes)",
+      self.img_file, img_sector*SECTOR_SIZE )
+  }
+
+  return nil
+
+} // end mapSectors
+
+
+// Llig el CD-Text.
+func (self *_CD_Ccd) readCDText( ini map[string]map[string]string ) error {
+
+  sec,ok:= ini["cdtext"]
+  if !ok { return nil }
+  num_entries,err:= getIntCcd ( sec, "CDText", "entries" )
+  if err != nil { return err }
+  packs:= make([][]byte,0,num_entries)
+  for i:= int64(0); i < num_entries; i++ {
+    val,ok:= sec[fmt.Sprintf ( "entry %d", i )]
+    if !ok {
+      return fmt.Errorf ( "CD-Text entry %d not found", i )
+    }
+    var pack []byte
+    for _,tok:= range strings.Fields ( val ) {
+      b,err:= strconv.ParseUint ( tok, 16, 8 )
+      if err != nil {
+        return fmt.Errorf ( "wrong CD-Text entry %d: %s", i, val )
+      }
+      pack= append(pack,uint8(b))
+    }
+    packs= append(packs,pack)
+  }
+  self.cdtext= cdtext_parse_packs ( packs )
+
+  return nil
+
+} // end readCDText
+
+
+func (self *_CD_Ccd) relabelCDXATracks() error {
+
+  for s:= range self.sessions {
+    for t:= range self.sessions[s].tracks {
+      track:= &self.sessions[s].tracks[t]
+      if track.track_type != TRACK_TYPE_MODE2_RAW { continue }
+      tr,err:= self.TrackReader ( s, t, 0 )
+      if err != nil { return err }
+      is_cdxa,err:= CheckTrackIsMode2CDXA ( tr )
+      tr.Close ()
+      if err != nil { return err }
+      if is_cdxa {
+        track.track_type= TRACK_TYPE_MODE2_CDXA_RAW
+      }
+    }
+  }
+
+  return nil
+
+} // end relabelCDXATracks
+
+
+func (self *_CD_Ccd) getTrack(
+
+  session_id int,
+  track_id   int,
+
+) (*_CD_Ccd_Track,error) {
+
+  if session_id < 0 || session_id >= len(self.sessions) {
+    return nil,fmt.Errorf ( "session (%d) out of range", session_id )
+  }
+  sess:= &self.sessions[session_id]
+  if track_id < 0 || track_id >= len(sess.tracks) {
+    return nil,fmt.Errorf ( "track (%d) out of range", track_id )
+  }
+
+  return &sess.tracks[track_id],nil
+
+} // end getTrack
+
+
+func (self *_CD_Ccd_Track) start() int64 {
+
+  if self.index0 != -1 {
+    return self.index0
+  } else {
+    return self.index1
+  }
+
+} // end start
+
+
+func (self *_CD_Ccd) Format() string { return "CCD/IMG (CloneCD)" }
+
+
+func (self *_CD_Ccd) Info() *Info {
+
+  // Calcula tracks totals
+  num_tracks:= 0
+  for s:= range self.sessions {
+    num_tracks+= len(self.sessions[s].tracks)
+  }
+
+  // Crea
+  ret:= Info{
+    Catalog : self.catalog,
+  }
+  if len(self.cdtext) > 0 {
+    ret.CDText= self.cdtext[0]
+  }
+  ret.Sessions= make([]SessionInfo,len(self.sessions))
+  ret.Tracks= make([]TrackInfo,num_tracks)
+  pos:= 0
+  for s:= range self.sessions {
+    sess:= &self.sessions[s]
+    beg_pos:= pos
+    for t:= range sess.tracks {
+      track:= &sess.tracks[t]
+      info:= &ret.Tracks[pos]
+      pos++
+      info.Id= BCD ( track.number )
+      info.Type= track.track_type
+      info.Flags= track.control&0x0f
+      info.ISRC= track.isrc
+      if track.number < len(self.cdtext) {
+        info.CDText= self.cdtext[track.number]
+      }
+      if track.index0 != -1 {
+        info.Indexes= []IndexInfo{
+          {Id : 0, Pos : GetPosition ( track.index0 + 2*75 )},
+          {Id : 1, Pos : GetPosition ( track.index1 + 2*75 )},
+        }
+      } else {
+        info.Indexes= []IndexInfo{
+          {Id : 1, Pos : GetPosition ( track.index1 + 2*75 )},
+        }
+      }
+      info.PosLastSector= GetPosition ( track.end - 1 + 2*75 )
+    }
+    ret.Sessions[s].Tracks= ret.Tracks[beg_pos:pos]
+  }
+
+  return &ret
+
+} // end Info
+
+
+func (self *_CD_Ccd) TrackReader(
+
+  session_id int,
+  track_id   int,
+  mode       int,
+
+) (TrackReader,error) {
+
+  track,err:= self.getTrack ( session_id, track_id )
+  if err != nil { return nil,err }
+  first:= track.img_sector + track.index1 - track.start ()
+
+  return newFileTrackReader ( self.img_file, first*SECTOR_SIZE,
+    SECTOR_SIZE, SECTOR_SIZE, 0, track.end-track.index1,
+    track.index1 + 2*75, track.track_type, mode )
+
+} // end TrackReader
+
+
+func (self *_CD_Ccd) PregapReader(
+
+  session_id int,
+  track_id   int,
+  mode       int,
+
+) (TrackReader,error) {
+
+  track,err:= self.getTrack ( session_id, track_id )
+  if err != nil { return nil,err }
+  if track.index0 == -1 || track.index0 >= track.index1 { return nil,nil }
+
+  return newFileTrackReader ( self.img_file, track.img_sector*SECTOR_SIZE,
+    SECTOR_SIZE, SECTOR_SIZE, 0, track.index1-track.index0,
+    track.index0 + 2*75, track.track_type, mode )
+
+} // end PregapReader
+
+
+func (self *_CD_Ccd) Files() []string {
+
+  ret:= []string{self.file_name,self.img_file}
+  if self.sub_file != "" {
+    ret= append(ret,self.sub_file)
+  }
+
+  return ret
+
+} // end Files
+
+
+func (self *_CD_Ccd) SubchannelReader(
+
+  session_id int,
+  track_id   int,
+
+) (TrackReader,error) {
+
+  if self.sub_file == "" {
+    return nil,errors.New ( "subchannel file not available" )
+  }
+  track,err:= self.getTrack ( session_id, track_id )
+  if err != nil { return nil,err }
+  first:= track.img_sector + track.index1 - track.start ()
+
+  return newFileTrackReader ( self.sub_file, first*96, 96, 96, 0,
+    track.end-track.index1, track.index1 + 2*75, _TRACK_TYPE_FILE_RAW,
+    MODE_DATA )
+
+} // end SubchannelReader
+
+
+
+
+/**********************/
+/* FUNCIONS PÚBLIQUES */
+/**********************/
+
+func OpenCcd( file_name string ) (CD,error) {
+
+  // Si ens passen l'IMG o el SUB busca el CCD.
+  ext:= path.Ext ( file_name )
+  base:= strings.TrimSuffix ( file_name, ext )
+  switch strings.ToLower ( ext ) {
+  case ".img", ".sub":
+    found:= false
+    for _,e:= range []string{".ccd",".CCD"} {
+      if _,err:= os.Stat ( base+e ); err == nil {
+        file_name= base+e
+        found= true
+        break
+      }
+    }
+    if !found {
+      return nil,fmt.Errorf ( "'%s' is not a CloneCD file", file_name )
+    }
+  }
+
+  // Comprova signatura
+  f,err:= os.Open ( file_name )
+  if err != nil { return nil,err }
+  var buf [12]byte
+  n,_:= f.Read ( buf[:] )
+  f.Close ()
+  if !strings.Contains ( strings.ToLower ( string(buf[:n]) ), "[clonecd]" ) {
+    return nil,fmt.Errorf ( "'%s' is not a CloneCD file", file_name )
+  }
+
+  // Llig
+  ini,err:= readIniCcd ( file_name )
+  if err != nil { return nil,err }
+  ret:= _CD_Ccd{
+    file_name : file_name,
+  }
+
+  // Fitxers de dades
+  for _,e:= range []string{".img",".IMG"} {
+    if _,err:= os.Stat ( base+e ); err == nil {
+      ret.img_file= base+e
+      break
+    }
+  }
+  if ret.img_file == "" {
+    return nil,fmt.Errorf ( "image file of '%s' not found", file_name )
+  }
+  for _,e:= range []string{".sub",".SUB"} {
+    if _,err:= os.Stat ( base+e ); err == nil {
+      ret.sub_file= base+e
+      break
+    }
+  }
+
+  // Processa
+  if err:= ret.readToc ( ini ); err != nil {
+    return nil,err
+  }
+  if err:= ret.mapSectors (); err != nil {
+    return nil,err
+  }
+  if err:= ret.readCDText ( ini ); err != nil {
+    return nil,err
+  }
+  if err:= ret.relabelCDXATracks (); err != nil {
+    return nil,err
+  }
+
+  return &ret,nil
+
+} // end OpenCcd
@@ -0,0 +1,518 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  cdi.go - Format CDI (DiscJuggler).
+ *
+ *  Les dades dels tracks estan al principi del fitxer, de manera
+ *  consecutiva. Al final hi ha la versió i la posició (o distància
+ *  des del final en la v3.5) del descriptor. El descriptor s'ha
+ *  interpretat seguint el que fa cdirip.
+ */
+
+package cdread
+
+import (
+  "errors"
+  "fmt"
+  "io"
+  "os"
+)
+
+
+
+
+/****************/
+/* PART PRIVADA */
+/****************/
+
+const (
+  _CDI_VERSION_2   = 0x80000004
+  _CDI_VERSION_3   = 0x80000005
+  _CDI_VERSION_3_5 = 0x80000006
+)
+
+var _CDI_TRACK_START_MARK= [10]byte{0,0,0x01,0,0,0,0xff,0xff,0xff,0xff}
+
+
+// DESCRIPTOR READER ///////////////////////////////////////////////////////////
+
+type _Cdi_Reader struct {
+
+  data []byte
+  pos  int
+
+}
+
+
+func (self *_Cdi_Reader) skip( n int ) error {
+
+  if self.pos+n > len(self.data) {
+    return errors.New ( "unexpected end of CDI descriptor" )
+  }
+  self.pos+= n
+
+  return nil
+
+} // end skip
+
+
+func (self *_Cdi_Reader) bytes( n int ) ([]byte,error) {
+
+  beg:= self.pos
+  if err:= self.skip ( n ); err != nil { return nil,err }
+
+  return self.data[beg:self.pos],nil
+
+} // end bytes
+
+
+func (self *_Cdi_Reader) u8() (uint8,error) {
+
+  b,err:= self.bytes ( 1 )
+  if err != nil { return 0,err }
+
+  return b[0],nil
+
+} // end u8
+
+
+func (self *_Cdi_Reader) u16() (uint16,error) {
+
+  b,err:= self.bytes ( 2 )
+  if err != nil { return 0,err }
+
+  return parse_int16_LSB ( b ),nil
+
+} // end u16
+
+
+func (self *_Cdi_Reader) u32() (uint32,error) {
+
+  b,err:= self.bytes ( 4 )
+  if err != nil { return 0,err }
+
+  return parse_int32_LSB ( b ),nil
+
+} // end u32
+
+
+// CD //////////////////////////////////////////////////////////////////////////
+
+type _CD_Cdi_Track struct {
+
+  number      int
+  control     uint8
+  track_type  int
+  index0      int64 // LBA. -1 si no té
+  index1      int64 // LBA
+  num_sectors int64 // A partir de l'índex 1
+  offset      int64 // Posició en el fitxer del sector de l'índex 1
+  stride      int64
+  read_size   int64
+  data_offset int64
+
+}
+
+type _CD_Cdi struct {
+
+  file_name string
+  version   uint32
+  sessions  [][]_CD_Cdi_Track
+
+}
+
+
+// Llig la descripció d'un track. Torna el nombre total de sectors
+// que ocupa en el fitxer.
+func (self *_CD_Cdi) readTrack(
+
+  r     *_Cdi_Reader,
+  track *_CD_Cdi_Track,
+
+) (int64,error) {
+
+  // Marques
+  tmp,err:= r.u32 ()
+  if err != nil { return 0,err }
+  if tmp != 0 {
+    if err:= r.skip ( 8 ); err != nil { return 0,err } // DJ 3.00.780
+  }
+  for i:= 0; i < 2; i++ {
+    mark,err:= r.bytes ( 10 )
+    if err != nil { return 0,err }
+    if string(mark) != string(_CDI_TRACK_START_MARK[:]) {
+      return 0,errors.New ( "CDI track start mark not found" )
+    }
+  }
+
+  // Nom del fitxer i altres camps ignorats
+  if err:= r.skip ( 4 ); err != nil { return 0,err }
+  name_length,err:= r.u8 ()
+  if err != nil { return 0,err }
+  if err:= r.skip ( int(name_length)+11+4+4 ); err != nil { return 0,err }
+  if tmp,err= r.u32 (); err != nil {
+    return 0,err
+  } else if tmp == 0x80000000 {
+    if err:= r.skip ( 8 ); err != nil { return 0,err } // DJ4
+  }
+  if err:= r.skip ( 2 ); err != nil { return 0,err }
+
+  // Grandàries
+  pregap,err:= r.u32 ()
+  if err != nil { return 0,err }
+  length,err:= r.u32 ()
+  if err != nil { return 0,err }
+  if err:= r.skip ( 6 ); err != nil { return 0,err }
+  mode,err:= r.u32 ()
+  if err != nil { return 0,err }
+  if err:= r.skip ( 12 ); err != nil { return 0,err }
+  start_lba,err:= r.u32 ()
+  if err != nil { return 0,err }
+  total_length,err:= r.u32 ()
+  if err != nil { return 0,err }
+  if err:= r.skip ( 16 ); err != nil { return 0,err }
+  sector_size_value,err:= r.u32 ()
+  if err != nil { return 0,err }
+  if err:= r.skip ( 29 ); err != nil { return 0,err }
+  if self.version != _CDI_VERSION_2 {
+    if err:= r.skip ( 5 ); err != nil { return 0,err }
+    if tmp,err= r.u32 (); err != nil {
+      return 0,err
+    } else if tmp == 0xffffffff {
+      if err:= r.skip ( 78 ); err != nil { return 0,err } // DJ 3.00.780
+    }
+  }
+
+  // Tipus
+  switch sector_size_value {
+  case 0:
+    track.stride= 2048
+  case 1:
+    track.stride= 2336
+  case 2:
+    track.stride= SECTOR_SIZE
+  case 4:
+    track.stride= SECTOR_SIZE+96
+  default:
+    return 0,fmt.Errorf ( "unsupported CDI sector size: %d",
+      sector_size_value )
+  }
+  switch {
+  case mode == 0 && track.stride >= SECTOR_SIZE:
+    track.track_type= TRACK_TYPE_AUDIO
+    track.read_size= SECTOR_SIZE
+  case mode == 0:
+    return 0,fmt.Errorf ( "unsupported CDI audio sector size: %d",
+      track.stride )
+  case track.stride == 2048:
+    track.track_type= TRACK_TYPE_ISO
+    track.read_size= 2048
+  case mode == 1 && track.stride == 2336:
+    return 0,errors.New ( "unsupported CDI Mode1 sector size: 2336" )
+  case mode == 1:
+    track.track_type= TRACK_TYPE_MODE1_RAW
+    track.read_size= SECTOR_SIZE
+  case mode == 2 && track.stride == 2336:
+    track.track_type= TRACK_TYPE_MODE2_RAW
+    track.read_size= 2336
+    track.data_offset= 16
+  case mode == 2:
+    track.track_type= TRACK_TYPE_MODE2_RAW
+    track.read_size= SECTOR_SIZE
+  default:
+    return 0,fmt.Errorf ( "unsupported CDI track mode: %d", mode )
+  }
+  if track.track_type != TRACK_TYPE_AUDIO {
+    track.control= TRACK_FLAGS_DATA
+  }
+
+  // Posició
+  track.index1= int64(int32(start_lba))
+  if pregap > 0 {
+    track.index0= track.index1-int64(pregap)
+  } else {
+    track.index0= -1
+  }
+  track.num_sectors= int64(length)
+  track.offset= int64(pregap)*track.stride
+  if int64(total_length) < int64(pregap)+int64(length) {
+    total_length= pregap+length
+  }
+
+  return int64(total_length),nil
+
+} // end readTrack
+
+
+func (self *_CD_Cdi) readDescriptor( r *_Cdi_Reader, data_size int64 ) error {
+
+  num_sessions,err:= r.u16 ()
+  if err != nil { return err }
+  var offset int64= 0
+  number:= 1
+  for s:= 0; s < int(num_sessions); s++ {
+
+    // Tracks
+    num_tracks,err:= r.u16 ()
+    if err != nil { return err }
+    if num_tracks == 0 { continue } // Sessió oberta
+    tracks:= make([]_CD_Cdi_Track,num_tracks)
+    for t:= range tracks {
+      tracks[t].number= number
+      number++
+      total,err:= self.readTrack ( r, &tracks[t] )
+      if err != nil { return err }
+      tracks[t].offset+= offset
+      offset+= total*tracks[t].stride
+    }
+    self.sessions= append(self.sessions,tracks)
+
+    // Final de sessió
+    skip:= 12
+    if self.version != _CDI_VERSION_2 { skip++ }
+    if err:= r.skip ( skip ); err != nil { return err }
+
+  }
+  if len(self.sessions) == 0 {
+    return errors.New ( "CDI file without tracks" )
+  }
+  if offset > data_size {
+    return errors.New ( "CDI tracks out of file" )
+  }
+
+  return nil
+
+} // end readDescriptor
+
+
+// Les imatges de Dreamcast no solen tindre la signatura CD-XA001,
+// però els sectors del sistema de fitxers són Mode2 Form1. Comprova
+// que el sector 16 és un descriptor de volum en un sector Form1.
+func checkMode2Form1Cdi( tr TrackReader ) (bool,error) {
+
+  var buf [2336]byte
+  if err:= tr.Seek ( 0x10 ); err != nil {
+    return false,nil
+  }
+  if n,err:= tr.Read ( buf[:] ); err != nil && err != io.EOF {
+    return false,err
+  } else if n != len(buf) {
+    return false,nil
+  }
+  if buf[2] != buf[6] || (buf[2]&0x20) != 0 {
+    return false,nil
+  }
+  data:= buf[8:]
+  
+  return data[1]=='C' && data[2]=='D' && data[3]=='0' &&
+    data[4]=='0' && data[5]=='1',nil
+
+} // end checkMode2Form1Cdi
+
+
+func (self *_CD_Cdi) relabelCDXATracks() error {
+
+  for s:= range self.sessions {
+    for t:= range self.sessions[s] {
+      track:= &self.sessions[s][t]
+      if track.track_type != TRACK_TYPE_MODE2_RAW { continue }
+      tr,err:= self.TrackReader ( s, t, 0 )
+      if err != nil { return err }
+      is_cdxa,err:= CheckTrackIsMode2CDXA ( tr )
+      if err == nil && !is_cdxa {
+        is_cdxa,err= checkMode2Form1Cdi ( tr )
+      }
+      tr.Close ()
+      if err != nil { return err }
+      if is_cdxa {
+        track.track_type= TRACK_TYPE_MODE2_CDXA_RAW
+      }
+    }
+  }
+
+  return nil
+
+} // end relabelCDXATracks
+
+
+func (self *_CD_Cdi) Format() string {
+
+  switch self.version {
+  case _CDI_VERSION_2:
+    return "CDI (DiscJuggler v2)"
+  case _CDI_VERSION_3:
+    return "CDI (DiscJuggler v3)"
+  default:
+    return "CDI (DiscJuggler v3.5)"
+  }
+
+} // end Format
+
+
+func (self *_CD_Cdi) Info() *Info {
+
+  // Calcula tracks totals
+  num_tracks:= 0
+  for s:= range self.sessions {
+    num_tracks+= len(self.sessions[s])
+  }
+
+  // Crea
+  ret:= Info{}
+  ret.Sessions= make([]SessionInfo,len(self.sessions))
+  ret.Tracks= make([]TrackInfo,num_tracks)
+  pos:= 0
+  for s,tracks:= range self.sessions {
+    beg_pos:= pos
+    for t:= range tracks {
+      track:= &tracks[t]
+      info:= &ret.Tracks[pos]
+      pos++
+      info.Id= BCD ( track.number )
+      info.Type= track.track_type
+      info.Cooked= track.data_offset > 0
+      info.Flags= track.control
+      if track.index0 != -1 {
+        info.Indexes= []IndexInfo{
+          {Id : 0, Pos : GetPosition ( track.index0 + 2*75 )},
+          {Id : 1, Pos : GetPosition ( track.index1 + 2*75 )},
+        }
+      } else {
+        info.Indexes= []IndexInfo{
+          {Id : 1, Pos : GetPosition ( track.index1 + 2*75 )},
+        }
+      }
+      info.PosLastSector= GetPosition ( track.index1 +
+        track.num_sectors - 1 + 2*75 )
+    }
+    ret.Sessions[s].Tracks= ret.Tracks[beg_pos:pos]
+  }
+
+  return &ret
+
+} // end Info
+
+
+func (self *_CD_Cdi) TrackReader(
+
+  session_id int,
+  track_id   int,
+  mode       int,
+
+) (TrackReader,error) {
+
+  if session_id < 0 || session_id >= len(self.sessions) {
+    return nil,fmt.Errorf ( "session (%d) out of range", session_id )
+  }
+  tracks:= self.sessions[session_id]
+  if track_id < 0 || track_id >= len(tracks) {
+    return nil,fmt.Errorf ( "track (%d) out of range", track_id )
+  }
+  t:= &tracks[track_id]
+
+  return newFileTrackReader ( self.file_name, t.offset, t.stride,
+    t.read_size, t.data_offset, t.num_sectors, t.index1 + 2*75,
+    t.track_type, mode )
+
+} // end TrackReader
+
+
+func (self *_CD_Cdi) PregapReader(
+
+  session_id int,
+  track_id   int,
+  mode       int,
+
+) (TrackReader,error) {
+
+  if session_id < 0 || session_id >= len(self.sessions) {
+    return nil,fmt.Errorf ( "session (%d) out of range", session_id )
+  }
+  tracks:= self.sessions[session_id]
+  if track_id < 0 || track_id >= len(tracks) {
+    return nil,fmt.Errorf ( "track (%d) out of range", track_id )
+  }
+  t:= &tracks[track_id]
+  if t.index0 == -1 { return nil,nil }
+
+  // El pregap està just abans de l'índex 1
+  pregap:= t.index1-t.index0
+  return newFileTrackReader ( self.file_name, t.offset-pregap*t.stride,
+    t.stride, t.read_size, t.data_offset, pregap, t.index0 + 2*75,
+    t.track_type, mode )
+
+} // end PregapReader
+
+
+
+
+/**********************/
+/* FUNCIONS PÚBLIQUES */
+/**********************/
+
+func OpenCdi( file_name string ) (CD,error) {
+
+  // Obri
+  f,err:= os.Open ( file_name )
+  if err != nil { return nil,err }
+  defer f.Close ()
+  size,err:= f.Seek ( 0, io.SeekEnd )
+  if err != nil { return nil,err }
+
+  // Peu
+  var footer [8]byte
+  if size < 8 {
+    return nil,fmt.Errorf ( "'%s' is not a CDI file", file_name )
+  }
+  if _,err:= f.ReadAt ( footer[:], size-8 ); err != nil {
+    return nil,err
+  }
+  ret:= _CD_Cdi{
+    file_name : file_name,
+    version : parse_int32_LSB ( footer[:4] ),
+  }
+  header_offset:= int64(parse_int32_LSB ( footer[4:] ))
+  switch ret.version {
+  case _CDI_VERSION_2, _CDI_VERSION_3:
+  case _CDI_VERSION_3_5:
+    header_offset= size-header_offset
+  default:
+    return nil,fmt.Errorf ( "'%s' is not a CDI file", file_name )
+  }
+  if header_offset < 0 || header_offset >= size-8 {
+    return nil,fmt.Errorf ( "wrong CDI descriptor offset: %d", header_offset )
+  }
+
+  // Descriptor
+  data:= make([]byte,size-8-header_offset)
+  if _,err:= f.ReadAt ( data, header_offset ); err != nil {
+    return nil,err
+  }
+  if err:= ret.readDescriptor ( &_Cdi_Reader{data:data},
+    header_offset ); err != nil {
+    return nil,err
+  }
+  if err:= ret.relabelCDXATracks (); err != nil {
+    return nil,err
+  }
+
+  return &ret,nil
+
+} // end OpenCdi
@@ -0,0 +1,163 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  cdtext.go - Descodifica els paquets CD-Text del lead-in.
+ */
+
+package cdread
+
+
+
+
+/****************/
+/* PART PRIVADA */
+/****************/
+
+// Tipus de paquets CD-Text amb text.
+const (
+  _CDTEXT_PACK_TITLE      = 0x80
+  _CDTEXT_PACK_PERFORMER  = 0x81
+  _CDTEXT_PACK_SONGWRITER = 0x82
+  _CDTEXT_PACK_COMPOSER   = 0x83
+  _CDTEXT_PACK_ARRANGER   = 0x84
+  _CDTEXT_PACK_MESSAGE    = 0x85
+  _CDTEXT_PACK_DISC_ID    = 0x86
+  _CDTEXT_PACK_GENRE      = 0x87
+  _CDTEXT_PACK_UPC_ISRC   = 0x8E
+)
+
+
+func cdtext_get_field( cdtext *CDText, pack_type uint8 ) *string {
+
+  switch pack_type {
+  case _CDTEXT_PACK_TITLE:
+    return &cdtext.Title
+  case _CDTEXT_PACK_PERFORMER:
+    return &cdtext.Performer
+  case _CDTEXT_PACK_SONGWRITER:
+    return &cdtext.Songwriter
+  case _CDTEXT_PACK_COMPOSER:
+    return &cdtext.Composer
+  case _CDTEXT_PACK_ARRANGER:
+    return &cdtext.Arranger
+  case _CDTEXT_PACK_MESSAGE:
+    return &cdtext.Message
+  case _CDTEXT_PACK_DISC_ID:
+    return &cdtext.DiscId
+  case _CDTEXT_PACK_GENRE:
+    return &cdtext.Genre
+  case _CDTEXT_PACK_UPC_ISRC:
+    return &cdtext.UPC_ISRC
+  default:
+    return nil
+  }
+
+} // end cdtext_get_field
+
+
+func cdtext_all_zeros( data []byte ) bool {
+
+  for _,b:= range data {
+    if b != 0 { return false }
+  }
+
+  return true
+
+} // end cdtext_all_zeros
+
+
+// Descodifica una seqüència de paquets CD-Text (de 16 o 18 bytes,
+// els 2 últims són el CRC i s'ignoren). Sols es té en compte el
+// primer bloc (normalment en anglés) amb caràcters d'un byte
+// (ISO 8859-1). Torna un CDText per a cada track, la posició 0 és el
+// disc.
+func cdtext_parse_packs( packs [][]byte ) []CDText {
+
+  // Agrupa el text per tipus
+  type _Text struct {
+    first_track int
+    data        []byte
+  }
+  texts:= make(map[uint8]*_Text)
+  var order []uint8
+  for _,pack:= range packs {
+    if len(pack) < 16 { continue }
+    pack_type:= pack[0]
+    block:= (pack[3]>>4)&0x7
+    dbcc:= (pack[3]&0x80) != 0
+    if block != 0 || dbcc || (pack[1]&0x80) != 0 { continue }
+    if cdtext_get_field ( &CDText{}, pack_type ) == nil { continue }
+    t,ok:= texts[pack_type]
+    if !ok {
+      t= &_Text{ first_track : int(pack[1]&0x7f) }
+      texts[pack_type]= t
+      order= append(order,pack_type)
+    }
+    t.data= append(t.data,pack[4:16]...)
+  }
+
+  // Separa els strings
+  ret:= make([]CDText,1)
+  for _,pack_type:= range order {
+    t:= texts[pack_type]
+    track:= t.first_track
+    prev,beg:= "",0
+    for i:= 0; i < len(t.data); i++ {
+      if t.data[i] != 0 { continue }
+      if i == beg && cdtext_all_zeros ( t.data[i:] ) {
+        break // Farciment final
+      }
+
+      // Latin-1 -> UTF-8
+      runes:= make([]rune,i-beg)
+      for j,c:= range t.data[beg:i] {
+        runes[j]= rune(c)
+      }
+      str:= string(runes)
+      if str == "\t" { str= prev } // TAB: igual que l'anterior
+      prev= str
+      beg= i+1
+
+      // Assigna
+      for track >= len(ret) {
+        ret= append(ret,CDText{})
+      }
+      *cdtext_get_field ( &ret[track], pack_type )= str
+      track++
+
+    }
+  }
+
+  return ret
+
+} // end cdtext_parse_packs
+
+
+// Completa els camps buits de dst amb els de src.
+func cdtext_merge( dst *CDText, src *CDText ) {
+
+  for i:= _CDTEXT_PACK_TITLE; i <= _CDTEXT_PACK_UPC_ISRC; i++ {
+    d:= cdtext_get_field ( dst, uint8(i) )
+    if d != nil && *d == "" {
+      *d= *cdtext_get_field ( src, uint8(i) )
+    }
+  }
+
+} // end cdtext_merge
@@ -76,9 +76,7 @@ func newCueTokenizer( line string ) *bufio.Scanner {
         } else {
           advance,token,err= pos,nil,nil // Demana més dades
         }
-      } else if i == pos+1 { // String buit
-        advance,token,err= 0,nil,fmt.Errorf ( "empty string" )
-      } else { // Torna string
+      } else { // Torna string (pot ser buit)
         advance,token,err= i+1,data[pos+1:i],nil
       }
 
@@ -148,6 +146,73 @@ func processTimeCue( str string ) (int64,error) {
 } // end processTimeCue
 
 
+// Llig la capçalera RIFF WAVE. Torna la posició i la grandària en
+// bytes de les dades. Sols s'accepta PCM de 16 bits estèreo a 44100Hz.
+func readWaveHeaderCue( file_name string ) (int64,int64,error) {
+
+  // Obri
+  f,err:= os.Open ( file_name )
+  if err != nil { return -1,-1,err }
+  defer f.Close ()
+
+  // Capçalera
+  var buf [12]byte
+  if _,err:= io.ReadFull ( f, buf[:] ); err != nil {
+    return -1,-1,fmt.Errorf ( "'%s' is not a WAVE file", file_name )
+  }
+  if string(buf[0:4]) != "RIFF" || string(buf[8:12]) != "WAVE" {
+    return -1,-1,fmt.Errorf ( "'%s' is not a WAVE file", file_name )
+  }
+
+  // Busca chunks
+  offset,fmt_ok:= int64(12),false
+  for {
+
+    // Capçalera chunk
+    if _,err:= io.ReadFull ( f, buf[:8] ); err != nil {
+      return -1,-1,fmt.Errorf ( "data chunk not found in WAVE file '%s'",
+        file_name )
+    }
+    size:= int64(uint32(buf[4]) | (uint32(buf[5])<<8) |
+      (uint32(buf[6])<<16) | (uint32(buf[7])<<24))
+    offset+= 8
+    
+    switch string(buf[0:4]) {
+    case "fmt ":
+      if size < 16 {
+        return -1,-1,fmt.Errorf ( "wrong fmt chunk in WAVE file '%s'",
+          file_name )
+      }
+      var data [16]byte
+      if _,err:= io.ReadFull ( f, data[:] ); err != nil { return -1,-1,err }
+      format:= uint16(data[0]) | (uint16(data[1])<<8)
+      channels:= uint16(data[2]) | (uint16(data[3])<<8)
+      rate:= uint32(data[4]) | (uint32(data[5])<<8) |
+        (uint32(data[6])<<16) | (uint32(data[7])<<24)
+      bits:= uint16(data[14]) | (uint16(data[15])<<8)
+      if format != 1 || channels != 2 || rate != 44100 || bits != 16 {
+        return -1,-1,fmt.Errorf ( "unsupported WAVE format in '%s' (format:"+
+          " %d, channels: %d, rate: %d, bits: %d)", file_name,
+          format, channels, rate, bits )
+      }
+      fmt_ok= true
+    case "data":
+      if !fmt_ok {
+        return -1,-1,fmt.Errorf ( "fmt chunk not found in WAVE file '%s'",
+          file_name )
+      }
+      return offset,size,nil
+    }
+
+    // Següent (els chunks estan alineats a 2 bytes)
+    offset+= size + (size&1)
+    if _,err:= f.Seek ( offset, 0 ); err != nil { return -1,-1,err }
+    
+  }
+  
+} // end readWaveHeaderCue
+
+
 
 
 /****************/
@@ -162,17 +227,20 @@ type _Cue_TrackReader struct {
   track_id int
   
   // Situació sectors
-  next_sector int64
-  eof         bool
+  first_sector int64 // Sector 0 del lector
+  end_sector   int64 // Primer sector que no es llig
+  next_sector  int64
+  eof          bool
   
   // Sector actual.
-  // NOTA!!! SECTOR_SIZE té trellat en modes RAW.
-  sec_data  [SECTOR_SIZE]byte
+  // NOTA!!! SECTOR_SIZE té trellat en modes RAW. Els sectors
+  // cuinats es copien en la posició que ocuparien en un sector RAW.
+  sec_data  [_CUE_MAX_SECTOR_SIZE]byte
   data      []byte // Slice de sec_data
   data_size int
   pos       int
   bin_file  *_CD_Cue_BinFile
-  file      *os.File
+  file      _Image_File
   
 }
 
@@ -182,55 +250,60 @@ func (self *_Cue_TrackReader) loadNextSector() error {
   
   // Eof
   if self.eof ||
-    self.next_sector >= int64(len(self.cd.maps)) ||
+    self.next_sector >= self.end_sector ||
     self.cd.maps[self.next_sector].track_id != self.track_id {
     self.eof= true
     return nil
   }
 
-  // Prepara fitxer.
+  // Sectors sense fitxer (pregap o postgap): es tornen zeros.
   bin_file:= self.cd.maps[self.next_sector].file
-  if bin_file != self.bin_file {
-    
-    self.bin_file= bin_file
-
-    // Tanca si tenim algun fitxer obert
-    if self.file != nil {
-      if err:= self.file.Close (); err != nil { return err }
+  if bin_file == nil {
+    for i:= range self.sec_data {
+      self.sec_data[i]= 0
     }
-    
-    // Obri nou fitxer
-    var err error
-    self.file,err= os.Open ( bin_file.file_name )
-    if err != nil { return err }
-    
-  }
-
-  // Mou a la posició del sector
-  new_off,err:= self.file.Seek ( self.cd.maps[self.next_sector].offset, 0 )
-  if err != nil { return err }
-  if new_off != self.cd.maps[self.next_sector].offset {
-    return fmt.Errorf ( "failed to read sector %d", self.next_sector )
-  }
-
-  // Llig el sector.
-  n,err:= self.file.Read ( self.sec_data[:] )
-  if err != nil { return err }
-  if n != SECTOR_SIZE {
-    return fmt.Errorf ( "failed to read sector %d", self.next_sector )
+  } else if err:= self.readSector ( bin_file ); err != nil {
+    return err
   }
 
   // Actualitza estat.
   self.pos= 0
+  if self.mode == MODE_RAW && self.track.track_type != TRACK_TYPE_ISO {
+    // Els sectors de dades sense fitxer es completen com a sectors
+    // vàlids, i els cuinats de 2336 bytes (sempre Mode 2) sense
+    // sincronització ni capçalera la reben.
+    switch {
+    case self.track.track_type == TRACK_TYPE_AUDIO:
+    case bin_file == nil:
+      var mode uint8= 2
+      switch self.track.track_type {
+      case TRACK_TYPE_MODE1_RAW:
+        mode= 1
+      case TRACK_TYPE_MODE2_CDXA_RAW:
+        self.sec_data[0x12],self.sec_data[0x16]= 0x20,0x20
+      }
+      FillRawSector ( self.sec_data[:], self.next_sector, mode,
+        self.track.track_type )
+    case self.track.sector_offset > 0:
+      setRawSectorHeader ( self.sec_data[:], self.next_sector, 2 )
+    }
+    self.data= self.sec_data[:SECTOR_SIZE]
+    self.data_size= SECTOR_SIZE
+    self.next_sector++
+    return nil
+  }
   switch self.track.track_type {
   case TRACK_TYPE_AUDIO:
-    self.data= self.sec_data[:]
+    self.data= self.sec_data[:SECTOR_SIZE]
     self.data_size= SECTOR_SIZE
+  case TRACK_TYPE_ISO:
+    self.data= self.sec_data[:2048]
+    self.data_size= 2048
   case TRACK_TYPE_MODE1_RAW:
     self.data= self.sec_data[16:2064]
     self.data_size= 2048
   case TRACK_TYPE_MODE2_RAW:
-    self.data= self.sec_data[16:]
+    self.data= self.sec_data[16:SECTOR_SIZE]
     self.data_size= 2336
   case TRACK_TYPE_MODE2_CDXA_RAW:
     if self.sec_data[0x12]&0x20 == 0 { // Form1
@@ -257,6 +330,57 @@ func (self *_Cue_TrackReader) loadNextSector() error {
 } // end loadNextSector
 
 
+// Llig del fitxer el sector next_sector.
+func (self *_Cue_TrackReader) readSector( bin_file *_CD_Cue_BinFile ) error {
+
+  // Prepara fitxer.
+  if bin_file != self.bin_file {
+    
+    self.bin_file= bin_file
+
+    // Tanca si tenim algun fitxer obert
+    if self.file != nil {
+      if err:= self.file.Close (); err != nil { return err }
+    }
+    
+    // Obri nou fitxer
+    var err error
+    self.file,err= bin_file.open ()
+    if err != nil { return err }
+    
+  }
+
+  // Llig el sector. Els fitxers WAVE poden acabar amb un sector
+  // incomplet, en eixe cas sols es llig fins al final del chunk de
+  // dades i es completa amb zeros.
+  for i:= range self.sec_data {
+    self.sec_data[i]= 0
+  }
+  buf:= self.sec_data[self.track.sector_offset:
+    self.track.sector_offset+bin_file.sector_size]
+  offset:= self.cd.maps[self.next_sector].offset
+  rbuf:= buf
+  remain:= bin_file.data_offset+bin_file.data_size-offset
+  if remain < int64(len(rbuf)) {
+    rbuf= rbuf[:remain]
+  }
+  if _,err:= self.file.ReadAt ( rbuf, offset ); err != nil {
+    return fmt.Errorf ( "failed to read sector %d: %s", self.next_sector, err )
+  }
+
+  // Fitxers big-endian
+  if bin_file.file_type == _CUE_FILE_TYPE_MOTOROLA &&
+    self.track.track_type == TRACK_TYPE_AUDIO {
+    for i:= 0; i < SECTOR_SIZE; i+= 2 {
+      buf[i],buf[i+1]= buf[i+1],buf[i]
+    }
+  }
+
+  return nil
+  
+} // end readSector
+
+
 func (self *_Cue_TrackReader) Close() (err error) {
 
   if self.file != nil {
@@ -327,7 +451,7 @@ func (self *_Cue_TrackReader) Seek( sector int64 ) error {
   // Actualitza estat
   self.eof= false
   self.pos= SECTOR_SIZE
-  self.next_sector= self.track.sector_index01 + sector
+  self.next_sector= self.first_sector + sector
 
   // Intenta carregar
   if err:= self.loadNextSector (); err != nil {
@@ -345,19 +469,49 @@ func (self *_Cue_TrackReader) Seek( sector int64 ) error {
 /* CD */
 /******/
 
+const _CUE_MAX_SECTOR_SIZE = 2448
+
+const (
+  _CUE_FILE_TYPE_BINARY   = 0
+  _CUE_FILE_TYPE_MOTOROLA = 1
+  _CUE_FILE_TYPE_WAVE     = 2
+)
+
 type _CD_Cue_BinFile struct {
-  file_name string
-  size      int64 // Grandària en sectors
-  asize     int64 // Sectors acumulats dels fitxers anteriors sense
-                  // incloure l'actual.
-  next      *_CD_Cue_BinFile
+  file_name   string
+  file_type   int
+  data_offset int64 // Posició de les dades dins del fitxer (WAVE)
+  data_size   int64 // Grandària de les dades en bytes
+  sector_size int64 // Grandària d'un sector (la fixa el primer track)
+  size        int64 // Grandària en sectors
+  asize       int64 // Sectors acumulats dels fitxers anteriors sense
+                    // incloure l'actual.
+  ecm         *_Ecm_Index // Índex si el fitxer és ECM
+  next        *_CD_Cue_BinFile
 }
 
+
+// Obri el fitxer per a llegir.
+func (self *_CD_Cue_BinFile) open() (_Image_File,error) {
+  if self.ecm != nil {
+    return openEcmFile ( self.file_name, self.ecm )
+  } else {
+    return os.Open ( self.file_name )
+  }
+} // end open
+
 type _CD_Cue_Track struct {
   track_type     int
   p              int // Posició de la primera entrada en entries
   N              int // Nombre d'entrades
   sector_index01 int64 // Primer sector de l'índex 01.
+  sector_size    int64 // Bytes de cada sector en el fitxer
+  sector_offset  int64 // Posició dins d'un sector RAW on es
+                       // copien els bytes del fitxer
+  postgap        int64 // Sectors de postgap
+  flags          uint8
+  isrc           string
+  cdtext         CDText
 }
 
 const (
@@ -398,6 +552,15 @@ type _CD_Cue struct {
   // Mapa sectors
   maps []_CD_Cue_SectorMap
   
+  // Metadades
+  catalog     string
+  cdtext      CDText
+  comments    []string
+  cdtext_file string // Fitxer CDTEXTFILE
+
+  // Fitxer ECM obert directament (sense CUE)
+  ecm bool
+  
   // Sectors subcanal_q erronis.
   // El format és literalment el del fitxer LSD:
   // 3B MIN SEC FRA || 12B QSUb (inclou CRC)
@@ -406,34 +569,77 @@ type _CD_Cue struct {
 }
 
 
-func (self *_CD_Cue) addBinary( file_name string ) error {
+func (self *_CD_Cue) addFile( file_name string, file_type int ) error {
 
   // Intenta obrir
-  f,err:= os.Open ( file_name )
-  if err != nil { return err }
-  info,err:= f.Stat ()
-  if err != nil { return err }
-  f.Close ()
-
-  // Comprova grandària
-  if info.Size ()%SECTOR_SIZE != 0 {
-    return fmt.Errorf ( "binary file '%s' has a wrong size", file_name )
-  }
-
-  // Afegeix
+  file_name= findEcmFile ( file_name )
   bf:= &_CD_Cue_BinFile{
     file_name : file_name,
-    size      : info.Size ()/SECTOR_SIZE,
+    file_type : file_type,
+  }
+  if isEcmFileName ( file_name ) {
+    if file_type == _CUE_FILE_TYPE_WAVE {
+      return fmt.Errorf ( "ECM compressed WAVE files are not supported: %s",
+        file_name )
+    }
+    var err error
+    if bf.ecm,err= readEcmIndex ( file_name ); err != nil { return err }
+    bf.data_size= bf.ecm.size
+  } else if file_type == _CUE_FILE_TYPE_WAVE {
+    var err error
+    bf.data_offset,bf.data_size,err= readWaveHeaderCue ( file_name )
+    if err != nil { return err }
+  } else {
+    f,err:= os.Open ( file_name )
+    if err != nil { return err }
+    info,err:= f.Stat ()
+    if err != nil { return err }
+    f.Close ()
+    bf.data_size= info.Size ()
   }
-  if self.files != nil {
-    bf.next= self.files
-    bf.asize= bf.next.asize + bf.next.size
-  } 
+  
+  // Afegeix
+  bf.next= self.files
   self.files= bf
 
   return nil
   
-} // end addBinary
+} // end addFile
+
+
+// Calcula la grandària en sectors dels fitxers una vegada es coneix
+// la grandària dels sectors.
+func (self *_CD_Cue) initFiles() error {
+
+  // Ordena
+  var files []*_CD_Cue_BinFile
+  for p:= self.files; p != nil; p= p.next {
+    files= append([]*_CD_Cue_BinFile{p},files...)
+  }
+
+  // Calcula
+  var asize int64= 0
+  for _,bf:= range files {
+    if bf.sector_size == 0 {
+      return fmt.Errorf ( "file '%s' does not contain any track",
+        bf.file_name )
+    }
+    if bf.file_type == _CUE_FILE_TYPE_WAVE {
+      bf.size= (bf.data_size+bf.sector_size-1)/bf.sector_size
+    } else {
+      if bf.data_size%bf.sector_size != 0 {
+        return fmt.Errorf ( "binary file '%s' has a wrong size",
+          bf.file_name )
+      }
+      bf.size= bf.data_size/bf.sector_size
+    }
+    bf.asize= asize
+    asize+= bf.size
+  }
+
+  return nil
+  
+} // end initFiles
 
 
 func (self *_CD_Cue) readFile( tok *bufio.Scanner ) error {
@@ -449,18 +655,25 @@ func (self *_CD_Cue) readFile( tok *bufio.Scanner ) error {
   }
   file_name:= tok.Text ()
 
-  // Comprova que el tipus és BINARY
+  // Tipus de fitxer
   if !tok.Scan () {
     err:= tok.Err ()
     if err == nil {
-      return errors.New ( "wrong file format: unable to read token BINARY" )
+      return errors.New ( "wrong file format: unable to read file type" )
     } else {
       return fmt.Errorf ( "wrong file format: %s", err )
     }
   }
-  if aux:= tok.Text (); aux != "BINARY" {
-    return fmt.Errorf ( "wrong file format: expected token BINARY, "+
-      "instead '%s' was read", aux )
+  var file_type int
+  switch aux:= strings.ToUpper ( tok.Text () ); aux {
+  case "BINARY":
+    file_type= _CUE_FILE_TYPE_BINARY
+  case "MOTOROLA":
+    file_type= _CUE_FILE_TYPE_MOTOROLA
+  case "WAVE":
+    file_type= _CUE_FILE_TYPE_WAVE
+  default:
+    return fmt.Errorf ( "unsupported file type: %s", aux )
   }
   
   // Obté el nom del fitxer binary
@@ -469,7 +682,7 @@ func (self *_CD_Cue) readFile( tok *bufio.Scanner ) error {
     file_name= path.Join ( dir_name, file_name )
   }
   
-  return self.addBinary ( file_name )
+  return self.addFile ( file_name, file_type )
   
 } // end readFile
 
@@ -498,6 +711,9 @@ func (self *_CD_Cue) readTrack( tok *bufio.Scanner ) error {
   }
 
   // Inicialitza track
+  if self.files == nil {
+    return errors.New ( "track defined before specifying a file" )
+  }
   track:= _CD_Cue_Track{
     p : len(self.entries),
     N : 0,
@@ -513,16 +729,40 @@ func (self *_CD_Cue) readTrack( tok *bufio.Scanner ) error {
       return fmt.Errorf ( "wrong track format: %s", err )
     }
   }
-  switch mode:= tok.Text (); mode {
+  switch mode:= strings.ToUpper ( tok.Text () ); mode {
   case "AUDIO":
     track.track_type= TRACK_TYPE_AUDIO
+    track.sector_size= SECTOR_SIZE
+  case "CDG":
+    track.track_type= TRACK_TYPE_AUDIO
+    track.sector_size= 2448 // Àudio + subcanal
+  case "MODE1/2048":
+    track.track_type= TRACK_TYPE_ISO
+    track.sector_size= 2048
   case "MODE1/2352":
     track.track_type= TRACK_TYPE_MODE1_RAW
-  case "MODE2/2352":
+    track.sector_size= SECTOR_SIZE
+  case "MODE2/2336", "CDI/2336":
+    track.track_type= TRACK_TYPE_MODE2_RAW
+    track.sector_size= 2336
+    track.sector_offset= 16
+  case "MODE2/2352", "CDI/2352":
     track.track_type= TRACK_TYPE_MODE2_RAW
+    track.sector_size= SECTOR_SIZE
   default:
     return fmt.Errorf ( "TRACK format unknown: %s", mode )
   }
+  if track.track_type != TRACK_TYPE_AUDIO {
+    track.flags= TRACK_FLAGS_DATA
+  }
+
+  // Grandària de sector del fitxer
+  if self.files.sector_size == 0 {
+    self.files.sector_size= track.sector_size
+  } else if self.files.sector_size != track.sector_size {
+    return fmt.Errorf ( "tracks with different sector sizes in file '%s'"+
+      " not supported", self.files.file_name )
+  }
   
   // Afegeix.
   self.tracks= append(self.tracks,track)
@@ -624,6 +864,220 @@ func (self *_CD_Cue) readPregap( tok *bufio.Scanner ) error {
 } // end readPregap
 
 
+func (self *_CD_Cue) readPostgap( tok *bufio.Scanner ) error {
+
+  // Prepara
+  if len(self.tracks) == 0 {
+    return errors.New ( "postgap defined before specifying a track" )
+  }
+  track:= &self.tracks[len(self.tracks)-1]
+
+  // Obté time.
+  if !tok.Scan () {
+    err:= tok.Err ()
+    if err == nil {
+      return errors.New (
+        "wrong postgap format: unable to read time" )
+    } else {
+      return fmt.Errorf ( "wrong postgap format: %s", err )
+    }
+  }
+  var err error
+  track.postgap,err= processTimeCue ( tok.Text () )
+  
+  return err
+  
+} // end readPostgap
+
+
+func (self *_CD_Cue) readFlags( tok *bufio.Scanner ) error {
+
+  // Prepara
+  if len(self.tracks) == 0 {
+    return errors.New ( "flags defined before specifying a track" )
+  }
+  track:= &self.tracks[len(self.tracks)-1]
+
+  // Llig flags
+  for tok.Scan () {
+    switch flag:= strings.ToUpper ( tok.Text () ); flag {
+    case "DCP":
+      track.flags|= TRACK_FLAGS_DCP
+    case "4CH":
+      track.flags|= TRACK_FLAGS_4CH
+    case "PRE":
+      track.flags|= TRACK_FLAGS_PRE
+    case "SCMS":
+      track.flags|= TRACK_FLAGS_SCMS
+    case "DATA":
+      track.flags|= TRACK_FLAGS_DATA
+    default:
+      return fmt.Errorf ( "unknown flag: %s", flag )
+    }
+  }
+  
+  return tok.Err ()
+  
+} // end readFlags
+
+
+// Llig l'argument d'una comanda amb un únic argument.
+func (self *_CD_Cue) readArgument(
+
+  tok *bufio.Scanner,
+  cmd string,
+
+) (string,error) {
+
+  if !tok.Scan () {
+    err:= tok.Err ()
+    if err == nil {
+      return "",fmt.Errorf ( "wrong %s format: unable to read argument", cmd )
+    } else {
+      return "",fmt.Errorf ( "wrong %s format: %s", cmd, err )
+    }
+  }
+  
+  return tok.Text (),nil
+  
+} // end readArgument
+
+
+func (self *_CD_Cue) readCatalog( tok *bufio.Scanner ) error {
+
+  catalog,err:= self.readArgument ( tok, "CATALOG" )
+  if err != nil { return err }
+  if len(catalog) != 13 {
+    return fmt.Errorf ( "wrong catalog number: %s", catalog )
+  }
+  for _,c:= range catalog {
+    if c < '0' || c > '9' {
+      return fmt.Errorf ( "wrong catalog number: %s", catalog )
+    }
+  }
+  self.catalog= catalog
+
+  return nil
+  
+} // end readCatalog
+
+
+func (self *_CD_Cue) readISRC( tok *bufio.Scanner ) error {
+
+  if len(self.tracks) == 0 {
+    return errors.New ( "ISRC defined before specifying a track" )
+  }
+  isrc,err:= self.readArgument ( tok, "ISRC" )
+  if err != nil { return err }
+  if len(isrc) != 12 {
+    return fmt.Errorf ( "wrong ISRC: %s", isrc )
+  }
+  self.tracks[len(self.tracks)-1].isrc= isrc
+
+  return nil
+  
+} // end readISRC
+
+
+// Comandes CD-Text. Abans del primer TRACK s'apliquen al disc.
+func (self *_CD_Cue) readCDText( tok *bufio.Scanner, cmd string ) error {
+
+  // Selecciona
+  var cdtext *CDText
+  if len(self.tracks) == 0 {
+    cdtext= &self.cdtext
+  } else {
+    cdtext= &self.tracks[len(self.tracks)-1].cdtext
+  }
+
+  // Llig
+  val,err:= self.readArgument ( tok, cmd )
+  if err != nil { return err }
+  switch cmd {
+  case "TITLE":
+    cdtext.Title= val
+  case "PERFORMER":
+    cdtext.Performer= val
+  case "SONGWRITER":
+    cdtext.Songwriter= val
+  case "COMPOSER":
+    cdtext.Composer= val
+  case "ARRANGER":
+    cdtext.Arranger= val
+  case "MESSAGE":
+    cdtext.Message= val
+  case "DISC_ID":
+    cdtext.DiscId= val
+  case "GENRE":
+    cdtext.Genre= val
+  case "UPC_EAN":
+    cdtext.UPC_ISRC= val
+  }
+
+  return nil
+  
+} // end readCDText
+
+
+func (self *_CD_Cue) readCDTextFile( tok *bufio.Scanner ) error {
+
+  file_name,err:= self.readArgument ( tok, "CDTEXTFILE" )
+  if err != nil { return err }
+  if !path.IsAbs ( file_name ) {
+    file_name= path.Join ( path.Dir ( self.file_name ), file_name )
+  }
+  self.cdtext_file= file_name
+
+  return nil
+  
+} // end readCDTextFile
+
+
+// Llig el fitxer CDTEXTFILE (paquets CD-Text de 18 bytes,
+// opcionalment precedits per la capçalera de 4 bytes de READ TOC) i
+// completa el CD-Text que no s'ha especificat en el CUE.
+func (self *_CD_Cue) loadCDTextFile() error {
+
+  data,err:= os.ReadFile ( self.cdtext_file )
+  if err != nil {
+    return fmt.Errorf ( "failed to read CDTEXTFILE '%s': %s",
+      self.cdtext_file, err )
+  }
+
+  // Capçalera i byte final opcionals
+  if len(data) >= 4 && (len(data)-4)%18 <= 1 && data[4]&0x80 != 0 {
+    data= data[4:]
+  }
+  if len(data)%18 == 1 {
+    data= data[:len(data)-1]
+  }
+  if len(data) == 0 || len(data)%18 != 0 {
+    return fmt.Errorf ( "wrong CDTEXTFILE format: '%s'", self.cdtext_file )
+  }
+
+  // Paquets
+  packs:= make([][]byte,0,len(data)/18)
+  for i:= 0; i < len(data); i+= 18 {
+    if data[i]&0x80 == 0 {
+      return fmt.Errorf ( "wrong CDTEXTFILE format: '%s'", self.cdtext_file )
+    }
+    packs= append(packs,data[i:i+18])
+  }
+  cdtext:= cdtext_parse_packs ( packs )
+
+  // El CUE té prioritat
+  cdtext_merge ( &self.cdtext, &cdtext[0] )
+  for t:= range self.tracks {
+    if t+1 < len(cdtext) {
+      cdtext_merge ( &self.tracks[t].cdtext, &cdtext[t+1] )
+    }
+  }
+
+  return nil
+  
+} // end loadCDTextFile
+
+
 func (self *_CD_Cue) readCommand( s *bufio.Scanner ) (cont bool,err error) {
 
   // Busca la primera línia no buida
@@ -641,7 +1095,9 @@ func (self *_CD_Cue) readCommand( s *bufio.Scanner ) (cont bool,err error) {
   if !tok.Scan () {
     return false,errors.New ( "wrong command format: command not found" )
   }
-  switch cmd:= tok.Text (); cmd {
+  switch cmd:= strings.ToUpper ( tok.Text () ); cmd {
+  case "REM":
+    self.comments= append(self.comments,strings.TrimSpace ( line[3:] ))
   case "FILE":
     err= self.readFile ( tok )
   case "TRACK":
@@ -650,6 +1106,19 @@ func (self *_CD_Cue) readCommand( s *bufio.Scanner ) (cont bool,err error) {
     err= self.readIndex ( tok )
   case "PREGAP":
     err= self.readPregap ( tok )
+  case "POSTGAP":
+    err= self.readPostgap ( tok )
+  case "FLAGS":
+    err= self.readFlags ( tok )
+  case "CATALOG":
+    err= self.readCatalog ( tok )
+  case "ISRC":
+    err= self.readISRC ( tok )
+  case "CDTEXTFILE":
+    err= self.readCDTextFile ( tok )
+  case "TITLE", "PERFORMER", "SONGWRITER", "COMPOSER", "ARRANGER",
+    "MESSAGE", "DISC_ID", "GENRE", "UPC_EAN":
+    err= self.readCDText ( tok, cmd )
   default:
     return false,fmt.Errorf ( "unknown command: %s", cmd )
   }
@@ -659,7 +1128,7 @@ func (self *_CD_Cue) readCommand( s *bufio.Scanner ) (cont bool,err error) {
 } // end readContent
 
 
-func readCDCue( cd *_CD_Cue, f *os.File ) error {
+func readCDCue( cd *_CD_Cue, f io.Reader ) error {
 
   var cont bool
   var err error
@@ -680,6 +1149,9 @@ func (self *_CD_Cue) calcTotalGap() int64 {
       gap+= self.entries[n].time
     }
   }
+  for t:= 0; t < len(self.tracks); t++ {
+    gap+= self.tracks[t].postgap
+  }
   
   return gap
   
@@ -776,14 +1248,9 @@ func (self *_CD_Cue) createMapSectors() error {
         if entry.id != 0 { prev_ind++ }
         // Fixa sector_index01
         if entry.id == 1 { track.sector_index01= n }
-        // Inicialitza offset si canvia de fitxer.
-        if entry.file != prev_file {
-          offset= 0
-          prev_file= entry.file
-        }
         // Calc end
         if e == len(self.entries)-1 {
-          end= int64(len(self.maps))
+          end= int64(len(self.maps))-track.postgap
         } else if self.entries[e+1].entry_type == CD_CUE_ENTRY_TYPE_INDEX {
           end= self.entries[e+1].file.asize + self.entries[e+1].time + gap
         } else {
@@ -796,16 +1263,32 @@ func (self *_CD_Cue) createMapSectors() error {
         if end <= n { return err }
         // Recalculate time and fill
         entry.time= n
+        if entry.file != prev_file {
+          offset= entry.file.data_offset
+          prev_file= entry.file
+        }
         for ; n != end; n++ {
           self.maps[n].offset= offset
           self.maps[n].track_id= t
           self.maps[n].index_id= BCD ( entry.id )
           self.maps[n].file= entry.file
           self.maps[n].subq_ptr= -1
-          offset+= SECTOR_SIZE
+          offset+= entry.file.sector_size
         }
       }
     }
+    // Postgap
+    if track.postgap > 0 {
+      end= n + track.postgap
+      for ; n != end; n++ {
+        self.maps[n].offset= -1
+        self.maps[n].track_id= t
+        self.maps[n].index_id= self.maps[n-1].index_id
+        self.maps[n].file= nil
+        self.maps[n].subq_ptr= -1
+      }
+      gap+= track.postgap
+    }
   }
 
   return nil
@@ -838,7 +1321,21 @@ func (self *_CD_Cue) relabelCDXATracks() error {
 } // end relabelCDXATracks
 
 
-func (self *_CD_Cue) Format() string { return "CUE/BIN" }
+func (self *_CD_Cue) Format() string {
+
+  if self.ecm {
+    return "BIN/ECM"
+  }
+  for p:= self.files; p != nil; p= p.next {
+    if p.ecm != nil {
+      return "CUE/BIN (ECM)"
+    }
+  }
+
+  return "CUE/BIN"
+  
+} // end Format
+
 
 func (self *_CD_Cue) Info() *Info {
 
@@ -858,7 +1355,11 @@ func (self *_CD_Cue) Info() *Info {
   for t:= 0; t < len(self.tracks); t++ {
     tp= &self.tracks[t]
     tracks[t].Type= tp.track_type
+    tracks[t].Cooked= tp.sector_offset > 0
     tracks[t].Id= BCD ( t+1 )
+    tracks[t].Flags= tp.flags
+    tracks[t].ISRC= tp.isrc
+    tracks[t].CDText= tp.cdtext
     tracks[t].Indexes= indexes[tp.p:tp.p+tp.N]
     if t > 0 {
       tracks[t-1].PosLastSector= GetPosition ( self.entries[tp.p].time - 1 )
@@ -876,6 +1377,11 @@ func (self *_CD_Cue) Info() *Info {
   tracks[len(self.tracks)-1].PosLastSector=
     GetPosition ( int64(len(self.maps))-1 )
 
+  // Metadades
+  ret.Catalog= self.catalog
+  ret.CDText= self.cdtext
+  ret.Comments= self.comments
+
   return &ret
   
 } // end Info
@@ -900,17 +1406,63 @@ func (self *_CD_Cue) TrackReader(
   }
   track:= &self.tracks[track_id]
 
-  // Crea trackreader
+  return self.newTrackReader ( track, track.sector_index01,
+    int64(len(self.maps)), mode )
+  
+} // end TrackReader
+
+
+func (self *_CD_Cue) PregapReader(
+
+  session_id int,
+  track_id   int,
+  mode       int,
+  
+) (TrackReader,error) {
+  
+  // Selecciona sessió
+  if session_id != 0 {
+    return nil,fmt.Errorf ( "session (%d) out of range", session_id )
+  }
+  
+  // Selecciona track
+  if track_id < 0 || track_id >= len(self.tracks) {
+    return nil,fmt.Errorf ( "track (%d) out of range", track_id )
+  }
+  track:= &self.tracks[track_id]
+
+  // El pregap (PREGAP i INDEX 00) són les entrades anteriors a
+  // l'índex 01.
+  first:= self.entries[track.p].time
+  if first >= track.sector_index01 { return nil,nil }
+  
+  return self.newTrackReader ( track, first, track.sector_index01, mode )
+  
+} // end PregapReader
+
+
+// Crea un lector dels sectors [first,end) d'un track.
+func (self *_CD_Cue) newTrackReader(
+
+  track *_CD_Cue_Track,
+  first int64,
+  end   int64,
+  mode  int,
+  
+) (*_Cue_TrackReader,error) {
+
   ret:= _Cue_TrackReader{
-    mode        : mode,
-    cd          : self,
-    track       : track,
-    track_id    : self.maps[track.sector_index01].track_id,
-    next_sector : track.sector_index01,
-    eof         : false,
-    bin_file    : nil,
-    file        : nil,
-    pos         : SECTOR_SIZE,
+    mode         : mode,
+    cd           : self,
+    track        : track,
+    track_id     : self.maps[track.sector_index01].track_id,
+    first_sector : first,
+    end_sector   : end,
+    next_sector  : first,
+    eof          : false,
+    bin_file     : nil,
+    file         : nil,
+    pos          : SECTOR_SIZE,
   }
   if err:= ret.loadNextSector (); err != nil {
     return nil,err
@@ -918,7 +1470,22 @@ func (self *_CD_Cue) TrackReader(
   
   return &ret,nil
   
-} // end TrackReader
+} // end newTrackReader
+
+
+func (self *_CD_Cue) Files() []string {
+
+  var ret []string
+  for p:= self.files; p != nil; p= p.next {
+    ret= append([]string{p.file_name},ret...)
+  }
+  if !self.ecm {
+    ret= append([]string{self.file_name},ret...)
+  }
+
+  return ret
+  
+} // end Files
 
 
 
@@ -941,8 +1508,16 @@ func OpenCue( file_name string ) (CD,error) {
   if err:= readCDCue ( &ret, f ); err != nil {
     return nil,err
   }
+  if ret.cdtext_file != "" {
+    if err:= ret.loadCDTextFile (); err != nil {
+      return nil,err
+    }
+  }
 
   // Crea el mapa de sectors
+  if err:= ret.initFiles (); err != nil {
+    return nil,err
+  }
   if err:= ret.createMapSectors (); err != nil {
     return nil,err
   }
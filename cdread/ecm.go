@@ -0,0 +1,400 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  ecm.go - Fitxers ECM (Error Code Modeler). Els sectors es
+ *           reconstrueixen al vol regenerant l'EDC i l'ECC.
+ */
+
+package cdread
+
+import (
+  "bufio"
+  "errors"
+  "fmt"
+  "io"
+  "os"
+  "path"
+  "sort"
+  "strings"
+)
+
+
+
+
+/****************/
+/* PART PRIVADA */
+/****************/
+
+const (
+  _ECM_TYPE_RAW         = 0
+  _ECM_TYPE_MODE1       = 1
+  _ECM_TYPE_MODE2_FORM1 = 2
+  _ECM_TYPE_MODE2_FORM2 = 3
+)
+
+// Bytes en el fitxer ECM i bytes descomprimits de cada unitat
+// (byte o sector) per tipus de bloc.
+var _ECM_IN_SIZE= [4]int64{1,0x803,0x804,0x918}
+var _ECM_OUT_SIZE= [4]int64{1,SECTOR_SIZE,2336,2336}
+
+
+// INDEX ///////////////////////////////////////////////////////////////////////
+
+type _Ecm_Block struct {
+  block_type int
+  count      int64 // Nombre d'unitats (bytes o sectors)
+  in_off     int64 // Posició de les dades en el fitxer ECM
+  out_off    int64 // Posició en el fitxer descomprimit
+}
+
+type _Ecm_Index struct {
+  blocks []_Ecm_Block
+  size   int64 // Grandària descomprimida
+}
+
+
+// Llig la capçalera d'un bloc. Torna end a cert si és el final.
+func readEcmBlockHeader(
+
+  r *bufio.Reader,
+
+) (block_type int,count int64,nbytes int64,end bool,err error) {
+
+  var c byte
+  if c,err= r.ReadByte (); err != nil { return }
+  nbytes= 1
+  block_type= int(c&0x3)
+  num:= uint32(c>>2)&0x1f
+  for bits:= 5; c&0x80 != 0; bits+= 7 {
+    if c,err= r.ReadByte (); err != nil { return }
+    nbytes++
+    if bits >= 32 {
+      err= errors.New ( "wrong ECM block header" )
+      return
+    }
+    num|= uint32(c&0x7f)<<bits
+  }
+  if num == 0xffffffff {
+    end= true
+    return
+  }
+  if num >= 0x80000000 {
+    err= errors.New ( "wrong ECM block header" )
+    return
+  }
+  count= int64(num)+1
+
+  return
+
+} // end readEcmBlockHeader
+
+
+// Llig tots els blocs del fitxer i construeix l'índex.
+func readEcmIndex( file_name string ) (*_Ecm_Index,error) {
+
+  // Obri i comprova signatura
+  f,err:= os.Open ( file_name )
+  if err != nil { return nil,err }
+  defer f.Close ()
+  r:= bufio.NewReader ( f )
+  var magic [4]byte
+  if _,err:= io.ReadFull ( r, magic[:] ); err != nil {
+    return nil,fmt.Errorf ( "'%s' is not an ECM file", file_name )
+  }
+  if string(magic[:]) != "ECM\x00" {
+    return nil,fmt.Errorf ( "'%s' is not an ECM file", file_name )
+  }
+
+  // Llig blocs
+  ret:= _Ecm_Index{}
+  var pos int64= 4
+  for {
+
+    // Capçalera
+    block_type,count,nbytes,end,err:= readEcmBlockHeader ( r )
+    if err != nil {
+      return nil,fmt.Errorf ( "failed to read ECM file '%s': %s",
+        file_name, err )
+    }
+    pos+= nbytes
+    if end { break }
+
+    // Afegeix
+    ret.blocks= append(ret.blocks,_Ecm_Block{
+      block_type : block_type,
+      count      : count,
+      in_off     : pos,
+      out_off    : ret.size,
+    })
+    ret.size+= count*_ECM_OUT_SIZE[block_type]
+
+    // Bota les dades
+    skip:= count*_ECM_IN_SIZE[block_type]
+    if skip <= int64(r.Buffered ()) {
+      r.Discard ( int(skip) )
+    } else {
+      if _,err:= f.Seek ( pos+skip, 0 ); err != nil {
+        return nil,err
+      }
+      r.Reset ( f )
+    }
+    pos+= skip
+
+  }
+
+  return &ret,nil
+
+} // end readEcmIndex
+
+
+// FILE ////////////////////////////////////////////////////////////////////////
+
+// Fitxer ECM vist com el fitxer descomprimit.
+type _Ecm_File struct {
+
+  file  *os.File
+  index *_Ecm_Index
+
+  // Últim sector reconstruït
+  cache_block int
+  cache_sec   int64
+  sector      [SECTOR_SIZE]byte
+  in_buf      [0x918]byte
+
+}
+
+
+func openEcmFile( file_name string, index *_Ecm_Index ) (*_Ecm_File,error) {
+
+  f,err:= os.Open ( file_name )
+  if err != nil { return nil,err }
+  ret:= _Ecm_File{
+    file        : f,
+    index       : index,
+    cache_block : -1,
+  }
+
+  return &ret,nil
+
+} // end openEcmFile
+
+
+// Reconstrueix el sector indicat d'un bloc.
+func (self *_Ecm_File) loadSector( block int, sec int64 ) error {
+
+  if self.cache_block == block && self.cache_sec == sec { return nil }
+
+  // Llig dades
+  b:= &self.index.blocks[block]
+  in_size:= _ECM_IN_SIZE[b.block_type]
+  in:= self.in_buf[:in_size]
+  if _,err:= self.file.ReadAt ( in, b.in_off + sec*in_size ); err != nil {
+    return fmt.Errorf ( "failed to read ECM data: %s", err )
+  }
+  for i:= range self.sector {
+    self.sector[i]= 0
+  }
+  if b.block_type == _ECM_TYPE_MODE1 {
+    copy ( self.sector[0xc:0xf], in[:3] )
+    copy ( self.sector[0x10:0x810], in[3:] )
+  } else {
+    copy ( self.sector[0x14:], in )
+  }
+
+  // Reconstrueix
+  switch b.block_type {
+  case _ECM_TYPE_MODE1:
+    eccEdcGenerate ( self.sector[:], 1, 1 )
+  case _ECM_TYPE_MODE2_FORM1:
+    eccEdcGenerate ( self.sector[:], 2, 1 )
+  case _ECM_TYPE_MODE2_FORM2:
+    eccEdcGenerate ( self.sector[:], 2, 2 )
+  }
+  self.cache_block= block
+  self.cache_sec= sec
+
+  return nil
+
+} // end loadSector
+
+
+func (self *_Ecm_File) Close() error {
+  return self.file.Close ()
+} // end Close
+
+
+func (self *_Ecm_File) ReadAt( b []byte, off int64 ) (int,error) {
+
+  blocks:= self.index.blocks
+  n:= 0
+  for n < len(b) {
+
+    // Busca bloc
+    cur:= off+int64(n)
+    if cur >= self.index.size { return n,io.EOF }
+    i:= sort.Search ( len(blocks), func(i int) bool {
+      return blocks[i].out_off+
+        blocks[i].count*_ECM_OUT_SIZE[blocks[i].block_type] > cur
+    })
+    blk:= &blocks[i]
+    rel:= cur-blk.out_off
+
+    // Copia
+    if blk.block_type == _ECM_TYPE_RAW {
+      nbytes:= int64(len(b)-n)
+      if nbytes > blk.count-rel {
+        nbytes= blk.count-rel
+      }
+      nr,err:= self.file.ReadAt ( b[n:n+int(nbytes)], blk.in_off+rel )
+      n+= nr
+      if err != nil { return n,err }
+    } else {
+      out_size:= _ECM_OUT_SIZE[blk.block_type]
+      sec,sec_off:= rel/out_size,rel%out_size
+      if err:= self.loadSector ( i, sec ); err != nil {
+        return n,err
+      }
+      // Mode 2 no inclou sincronització ni capçalera
+      data:= self.sector[:]
+      if blk.block_type != _ECM_TYPE_MODE1 {
+        data= data[16:]
+      }
+      n+= copy ( b[n:], data[sec_off:] )
+    }
+
+  }
+
+  return n,nil
+
+} // end ReadAt
+
+
+// Si el fitxer no existeix però sí existeix la versió ECM torna el
+// nom d'aquesta.
+func findEcmFile( file_name string ) string {
+
+  if _,err:= os.Stat ( file_name ); err != nil && os.IsNotExist ( err ) {
+    if _,err:= os.Stat ( file_name+".ecm" ); err == nil {
+      return file_name+".ecm"
+    }
+  }
+
+  return file_name
+
+} // end findEcmFile
+
+
+func isEcmFileName( file_name string ) bool {
+  return strings.HasSuffix ( strings.ToLower ( file_name ), ".ecm" )
+} // end isEcmFileName
+
+
+type _Section_ReadCloser struct {
+  *io.SectionReader
+  io.Closer
+}
+
+
+
+
+/**********************/
+/* FUNCIONS PÚBLIQUES */
+/**********************/
+
+// Obri directament un fitxer BIN comprimit amb ECM. Es tracta com un
+// CUE/BIN amb un únic track de dades.
+func OpenEcm( file_name string ) (CD,error) {
+
+  // Llig índex
+  index,err:= readEcmIndex ( file_name )
+  if err != nil { return nil,err }
+
+  // Determina el tipus de track a partir del primer sector.
+  f,err:= openEcmFile ( file_name, index )
+  if err != nil { return nil,err }
+  var header [16]byte
+  _,err= f.ReadAt ( header[:], 0 )
+  f.Close ()
+  if err != nil {
+    return nil,fmt.Errorf ( "failed to read ECM file '%s': %s",
+      file_name, err )
+  }
+  track_type:= "MODE1/2048"
+  if header[0] == 0x00 && header[11] == 0x00 &&
+    string(header[1:11]) == strings.Repeat ( "\xff", 10 ) {
+    switch header[15] {
+    case 1:
+      track_type= "MODE1/2352"
+    case 2:
+      track_type= "MODE2/2352"
+    default:
+      return nil,fmt.Errorf ( "unsupported sector mode in ECM file '%s': %d",
+        file_name, header[15] )
+    }
+  }
+
+  // Crea un CUE virtual
+  cue:= fmt.Sprintf ( "FILE \"%s\" BINARY\n  TRACK 01 %s\n"+
+    "    INDEX 01 00:00:00\n", path.Base ( file_name ), track_type )
+  ret:= _CD_Cue{
+    file_name : file_name,
+    ecm       : true,
+  }
+  if err:= readCDCue ( &ret, strings.NewReader ( cue ) ); err != nil {
+    return nil,err
+  }
+  if err:= ret.initFiles (); err != nil {
+    return nil,err
+  }
+  if err:= ret.createMapSectors (); err != nil {
+    return nil,err
+  }
+  if err:= ret.relabelCDXATracks(); err != nil {
+    return nil,err
+  }
+
+  return &ret,nil
+
+} // end OpenEcm
+
+
+// Obri un fitxer per a llegir el seu contingut. Si és un fitxer ECM
+// es torna el contingut descomprimit. També torna la grandària.
+func OpenDecodedFile( file_name string ) (io.ReadCloser,int64,error) {
+
+  if !isEcmFileName ( file_name ) {
+    f,err:= os.Open ( file_name )
+    if err != nil { return nil,-1,err }
+    info,err:= f.Stat ()
+    if err != nil {
+      f.Close ()
+      return nil,-1,err
+    }
+    return f,info.Size (),nil
+  }
+  index,err:= readEcmIndex ( file_name )
+  if err != nil { return nil,-1,err }
+  f,err:= openEcmFile ( file_name, index )
+  if err != nil { return nil,-1,err }
+  ret:= _Section_ReadCloser{io.NewSectionReader ( f, 0, index.size ),f}
+
+  return &ret,index.size,nil
+  
+} // end OpenDecodedFile
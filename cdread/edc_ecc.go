@@ -0,0 +1,329 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  edc_ecc.go - Càlcul i verificació de l'EDC i l'ECC (P i Q) dels
+ *               sectors RAW.
+ */
+
+package cdread
+
+import (
+  "bytes"
+)
+
+
+
+
+/****************/
+/* PART PRIVADA */
+/****************/
+
+// Taules.
+var _ecc_f_lut,_ecc_b_lut,_edc_lut= initEdcEccTables ()
+
+
+func initEdcEccTables() (f [256]uint8,b [256]uint8,edc [256]uint32) {
+
+  for i:= 0; i < 256; i++ {
+    j:= i<<1
+    if i&0x80 != 0 {
+      j^= 0x11d
+    }
+    f[i]= uint8(j)
+    b[i^j]= uint8(i)
+    e:= uint32(i)
+    for k:= 0; k < 8; k++ {
+      if e&1 != 0 {
+        e= (e>>1)^0xd8018001
+      } else {
+        e>>= 1
+      }
+    }
+    edc[i]= e
+  }
+
+  return
+
+} // end initEdcEccTables
+
+
+// Calcula un bloc de paritat (P o Q).
+func eccComputeBlock(
+
+  src         []byte,
+  major_count int,
+  minor_count int,
+  major_mult  int,
+  minor_inc   int,
+  dst         []byte,
+
+) {
+
+  size:= major_count*minor_count
+  for major:= 0; major < major_count; major++ {
+    index:= (major>>1)*major_mult + (major&1)
+    var ecc_a,ecc_b uint8= 0,0
+    for minor:= 0; minor < minor_count; minor++ {
+      tmp:= src[index]
+      index+= minor_inc
+      if index >= size {
+        index-= size
+      }
+      ecc_a^= tmp
+      ecc_b^= tmp
+      ecc_a= _ecc_f_lut[ecc_a]
+    }
+    ecc_a= _ecc_b_lut[_ecc_f_lut[ecc_a]^ecc_b]
+    dst[major]= ecc_a
+    dst[major+major_count]= ecc_a^ecc_b
+  }
+
+} // end eccComputeBlock
+
+
+// Calcula l'ECC (P i Q) d'un sector de 2352 bytes i el desa en
+// 0x81C. En Mode 2 Form 1 la capçalera es considera zero.
+func eccGenerate( sector []byte, zero_address bool ) {
+
+  var addr [4]byte
+  if zero_address {
+    copy ( addr[:], sector[0xc:0x10] )
+    for i:= 0xc; i < 0x10; i++ {
+      sector[i]= 0
+    }
+  }
+  eccComputeBlock ( sector[0xc:], 86, 24, 2, 86, sector[0x81c:] )
+  eccComputeBlock ( sector[0xc:], 52, 43, 86, 88, sector[0x8c8:] )
+  if zero_address {
+    copy ( sector[0xc:0x10], addr[:] )
+  }
+
+} // end eccGenerate
+
+
+// Desa l'EDC en little-endian.
+func edcPut( edc uint32, dst []byte ) {
+  dst[0]= uint8(edc)
+  dst[1]= uint8(edc>>8)
+  dst[2]= uint8(edc>>16)
+  dst[3]= uint8(edc>>24)
+} // end edcPut
+
+
+// Reconstrueix l'EDC i l'ECC d'un sector de 2352 bytes. Mode 1
+// també reconstrueix la sincronització i el mode. Mode 2 Form 1 i 2
+// reconstrueixen la còpia del subheader.
+func eccEdcGenerate( sector []byte, mode int, form int ) {
+
+  if mode == 1 {
+    copy ( sector, SECTOR_SYNC )
+    sector[0xf]= 0x01
+    edcPut ( EDC ( sector[:0x810] ), sector[0x810:] )
+    for i:= 0x814; i < 0x81c; i++ {
+      sector[i]= 0
+    }
+    eccGenerate ( sector, false )
+  } else {
+    copy ( sector[0x10:0x14], sector[0x14:0x18] )
+    if form == 1 {
+      edcPut ( EDC ( sector[0x10:0x818] ), sector[0x818:] )
+      eccGenerate ( sector, true )
+    } else {
+      edcPut ( EDC ( sector[0x10:0x92c] ), sector[0x92c:] )
+    }
+  }
+
+} // end eccEdcGenerate
+
+
+// Fica la sincronització i la capçalera (MSF de l'índex absolut
+// sec_ind i el mode indicat) d'un sector RAW de 2352 bytes.
+func setRawSectorHeader( sector []byte, sec_ind int64, mode uint8 ) {
+
+  copy ( sector, SECTOR_SYNC )
+  pos:= GetPosition ( sec_ind )
+  sector[0xc]= pos.Minutes
+  sector[0xd]= pos.Seconds
+  sector[0xe]= pos.Sector
+  sector[0xf]= mode
+
+} // end setRawSectorHeader
+
+
+// Torna el Form (1 o 2) d'un sector Mode 2 CD-XA.
+func getSectorForm( sector []byte ) int {
+  if sector[0x12]&0x20 == 0 {
+    return 1
+  } else {
+    return 2
+  }
+} // end getSectorForm
+
+
+func edcGet( src []byte ) uint32 {
+  return uint32(src[0]) | (uint32(src[1])<<8) |
+    (uint32(src[2])<<16) | (uint32(src[3])<<24)
+} // end edcGet
+
+
+// Comprova que l'ECC (P i Q) del sector és correcte.
+func eccCheck( sector []byte, zero_address bool ) bool {
+
+  var tmp [SECTOR_SIZE]byte
+  copy ( tmp[:], sector )
+  eccGenerate ( tmp[:], zero_address )
+
+  return bytes.Equal ( tmp[0x81c:SECTOR_SIZE], sector[0x81c:SECTOR_SIZE] )
+
+} // end eccCheck
+
+
+
+
+/*************/
+/* CONSTANTS */
+/*************/
+
+// Patró de sincronització dels sectors RAW.
+var SECTOR_SYNC= []byte{0x00,0xff,0xff,0xff,0xff,0xff,
+  0xff,0xff,0xff,0xff,0xff,0x00}
+
+// Errors detectats per CheckRawSector.
+const (
+  SECTOR_ERR_SYNC   = 0x01 // Patró de sincronització incorrecte
+  SECTOR_ERR_HEADER = 0x02 // Adreça (MSF) o mode incorrectes
+  SECTOR_ERR_EDC    = 0x04
+  SECTOR_ERR_ECC    = 0x08
+)
+
+
+
+
+/**********************/
+/* FUNCIONS PÚBLIQUES */
+/**********************/
+
+// Comprova un sector RAW de 2352 bytes. sec_ind és l'índex absolut
+// del sector (incloent els 2 segons inicials) i track_type el tipus
+// de track (TRACK_TYPE_MODE1_RAW, TRACK_TYPE_MODE2_RAW o
+// TRACK_TYPE_MODE2_CDXA_RAW). Torna una combinació de SECTOR_ERR_*.
+func CheckRawSector( sector []byte, sec_ind int64, track_type int ) int {
+
+  ret:= 0
+
+  // Sincronització i capçalera
+  if !bytes.Equal ( sector[:12], SECTOR_SYNC ) {
+    ret|= SECTOR_ERR_SYNC
+  }
+  pos:= GetPosition ( sec_ind )
+  mode:= sector[0xf]
+  if sector[0xc] != pos.Minutes || sector[0xd] != pos.Seconds ||
+    sector[0xe] != pos.Sector || mode > 2 {
+    ret|= SECTOR_ERR_HEADER
+  }
+
+  // EDC i ECC
+  switch {
+  case mode == 1:
+    if edcGet ( sector[0x810:] ) != EDC ( sector[:0x810] ) {
+      ret|= SECTOR_ERR_EDC
+    }
+    if !eccCheck ( sector, false ) {
+      ret|= SECTOR_ERR_ECC
+    }
+  case mode == 2 && track_type == TRACK_TYPE_MODE2_CDXA_RAW:
+    if !bytes.Equal ( sector[0x10:0x14], sector[0x14:0x18] ) {
+      ret|= SECTOR_ERR_HEADER
+    }
+    if getSectorForm ( sector ) == 1 {
+      if edcGet ( sector[0x818:] ) != EDC ( sector[0x10:0x818] ) {
+        ret|= SECTOR_ERR_EDC
+      }
+      if !eccCheck ( sector, true ) {
+        ret|= SECTOR_ERR_ECC
+      }
+    } else {
+      // En Form 2 l'EDC és opcional (0 si no s'ha calculat).
+      edc:= edcGet ( sector[0x92c:] )
+      if edc != 0 && edc != EDC ( sector[0x10:0x92c] ) {
+        ret|= SECTOR_ERR_EDC
+      }
+    }
+  }
+
+  return ret
+
+} // end CheckRawSector
+
+
+// Regenera la sincronització, l'EDC i l'ECC d'un sector RAW de 2352
+// bytes segons el mode indicat en la capçalera. Els sectors Mode 2
+// sense subheader CD-XA (track_type TRACK_TYPE_MODE2_RAW) sols
+// regeneren la sincronització.
+func RegenerateRawSector( sector []byte, track_type int ) {
+
+  copy ( sector, SECTOR_SYNC )
+  switch sector[0xf] {
+  case 1:
+    eccEdcGenerate ( sector, 1, 1 )
+  case 2:
+    // Es pren el Form de la segona còpia del subheader.
+    if track_type == TRACK_TYPE_MODE2_CDXA_RAW {
+      form:= 1
+      if sector[0x16]&0x20 != 0 {
+        form= 2
+      }
+      eccEdcGenerate ( sector, 2, form )
+    }
+  }
+
+} // end RegenerateRawSector
+
+
+// Completa un sector RAW de 2352 bytes del qual ja s'han copiat les
+// dades d'usuari (i en CD-XA el subheader). Fica la sincronització i
+// la capçalera (MSF de l'índex absolut sec_ind i el mode indicat) i
+// regenera l'EDC i l'ECC com RegenerateRawSector.
+func FillRawSector(
+
+  sector     []byte,
+  sec_ind    int64,
+  mode       uint8,
+  track_type int,
+
+) {
+
+  setRawSectorHeader ( sector, sec_ind, mode )
+  RegenerateRawSector ( sector, track_type )
+
+} // end FillRawSector
+
+
+// Calcula l'EDC (CRC-32 de CD-ROM) de les dades proporcionades.
+func EDC( data []byte ) uint32 {
+
+  var edc uint32= 0
+  for _,b:= range data {
+    edc= (edc>>8)^_edc_lut[(edc^uint32(b))&0xff]
+  }
+
+  return edc
+
+} // end EDC
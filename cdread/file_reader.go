@@ -0,0 +1,383 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  file_reader.go - TrackReader genèric per a tracks emmagatzemats
+ *                   de manera contigua en un fitxer.
+ */
+
+package cdread
+
+import (
+  "errors"
+  "fmt"
+  "io"
+  "os"
+)
+
+
+
+
+/****************/
+/* PART PRIVADA */
+/****************/
+
+// Tipus especial de track (sols per a _File_TrackReader) que torna
+// els bytes del fitxer tal qual. S'empra per al subcanal.
+const _TRACK_TYPE_FILE_RAW = -100
+
+// Grandària màxima d'un sector en el fitxer (RAW + subcanal).
+const _FILE_MAX_SECTOR_SIZE = SECTOR_SIZE + 96
+
+
+// IMAGE FILE //////////////////////////////////////////////////////////////////
+
+// Fitxer amb les dades de la imatge.
+type _Image_File interface {
+  io.ReaderAt
+  io.Closer
+}
+
+
+// Fitxer dividit en diversos fitxers consecutius.
+type _Split_File struct {
+
+  files []*os.File
+  begs  []int64 // Posició inicial de cada fitxer
+
+}
+
+
+func (self *_Split_File) Close() error {
+
+  var ret error= nil
+  for _,f:= range self.files {
+    if err:= f.Close (); err != nil && ret == nil {
+      ret= err
+    }
+  }
+
+  return ret
+
+} // end Close
+
+
+func (self *_Split_File) ReadAt( b []byte, off int64 ) (int,error) {
+
+  // Busca el fitxer
+  i:= len(self.files)-1
+  for ; i > 0 && self.begs[i] > off; i-- {
+  }
+
+  // Llig
+  n:= 0
+  for n < len(b) && i < len(self.files) {
+    nr,err:= self.files[i].ReadAt ( b[n:], off+int64(n)-self.begs[i] )
+    n+= nr
+    if err == io.EOF {
+      i++
+    } else if err != nil {
+      return n,err
+    }
+  }
+  if n < len(b) { return n,io.EOF }
+
+  return n,nil
+
+} // end ReadAt
+
+
+// Obri un fitxer d'imatge. Si es proporcionen diversos fitxers es
+// tracten com un únic fitxer.
+func openImageFile( file_names []string ) (_Image_File,error) {
+
+  if len(file_names) == 0 {
+    return nil,errors.New ( "no image file provided" )
+  } else if len(file_names) == 1 {
+    return os.Open ( file_names[0] )
+  }
+  ret:= _Split_File{
+    files : make([]*os.File,0,len(file_names)),
+    begs : make([]int64,0,len(file_names)),
+  }
+  var pos int64= 0
+  for _,name:= range file_names {
+    f,err:= os.Open ( name )
+    if err != nil {
+      ret.Close ()
+      return nil,err
+    }
+    ret.files= append(ret.files,f)
+    ret.begs= append(ret.begs,pos)
+    info,err:= f.Stat ()
+    if err != nil {
+      ret.Close ()
+      return nil,err
+    }
+    pos+= info.Size ()
+  }
+
+  return &ret,nil
+
+} // end openImageFile
+
+
+// TRACK READER ////////////////////////////////////////////////////////////////
+
+
+type _File_TrackReader struct {
+
+  mode        int
+  track_type  int
+  file        _Image_File
+  offset      int64 // Posició en el fitxer del primer sector
+  stride      int64 // Bytes que ocupa cada sector en el fitxer
+  read_size   int64 // Bytes a llegir de cada sector
+  data_offset int64 // Posició dins d'un sector RAW on es copien
+                    // els bytes llegits (16 per a sectors de 2336
+                    // i 0x18 per a sectors Mode 2 Form 1 de 2048)
+  num_sectors int64
+  first_sec   int64 // Índex absolut del primer sector
+
+  // Situació sectors
+  next_sector int64
+  eof         bool
+
+  // Sector actual
+  sec_data  [_FILE_MAX_SECTOR_SIZE]byte
+  data      []byte // Slice de sec_data
+  data_size int
+  pos       int
+
+}
+
+
+func newFileTrackReader(
+
+  file_name   string,
+  offset      int64,
+  stride      int64,
+  read_size   int64,
+  data_offset int64,
+  num_sectors int64,
+  first_sec   int64,
+  track_type  int,
+  mode        int,
+
+) (*_File_TrackReader,error) {
+
+  file,err:= os.Open ( file_name )
+  if err != nil { return nil,err }
+  ret,err:= newImageTrackReader ( file, offset, stride, read_size,
+    data_offset, num_sectors, first_sec, track_type, mode )
+  if err != nil {
+    file.Close ()
+    return nil,err
+  }
+
+  return ret,nil
+
+} // end newFileTrackReader
+
+
+// Crea un lector sobre un fitxer ja obert. El fitxer es tanca en
+// tancar el lector.
+func newImageTrackReader(
+
+  file        _Image_File,
+  offset      int64,
+  stride      int64,
+  read_size   int64,
+  data_offset int64,
+  num_sectors int64,
+  first_sec   int64,
+  track_type  int,
+  mode        int,
+
+) (*_File_TrackReader,error) {
+
+  // Comprovacions
+  if read_size > stride || data_offset+read_size > _FILE_MAX_SECTOR_SIZE {
+    return nil,fmt.Errorf ( "unsupported sector size: %d", read_size )
+  }
+
+  // Crea
+  ret:= _File_TrackReader{
+    file        : file,
+    mode        : mode,
+    track_type  : track_type,
+    offset      : offset,
+    stride      : stride,
+    read_size   : read_size,
+    data_offset : data_offset,
+    num_sectors : num_sectors,
+    first_sec   : first_sec,
+    next_sector : 0,
+    eof         : false,
+    pos         : _FILE_MAX_SECTOR_SIZE,
+  }
+  if err:= ret.loadNextSector (); err != nil {
+    return nil,err
+  }
+
+  return &ret,nil
+
+} // end newImageTrackReader
+
+
+func (self *_File_TrackReader) loadNextSector() error {
+
+  // Eof
+  if self.eof || self.next_sector >= self.num_sectors {
+    self.eof= true
+    return nil
+  }
+
+  // Llig el sector.
+  offset:= self.offset + self.next_sector*self.stride
+  buf:= self.sec_data[self.data_offset:self.data_offset+self.read_size]
+  if _,err:= self.file.ReadAt ( buf, offset ); err != nil {
+    return fmt.Errorf ( "failed to read sector %d: %s", self.next_sector, err )
+  }
+
+  // Actualitza estat.
+  self.pos= 0
+  if self.mode == MODE_RAW && self.track_type != _TRACK_TYPE_FILE_RAW &&
+    self.track_type != TRACK_TYPE_ISO {
+    // Els sectors cuinats de 2336 bytes no tenen sincronització ni
+    // capçalera (sempre són Mode 2). Els de 2048 bytes són Mode 2
+    // Form 1 sense subheader, EDC ni ECC, es deixa el subheader a
+    // zero i es calculen l'EDC i l'ECC.
+    if self.data_offset > 0 {
+      setRawSectorHeader ( self.sec_data[:],
+        self.first_sec+self.next_sector, 2 )
+      if self.data_offset == 0x18 {
+        for i:= 0x10; i < 0x18; i++ {
+          self.sec_data[i]= 0
+        }
+        eccEdcGenerate ( self.sec_data[:SECTOR_SIZE], 2, 1 )
+      }
+    }
+    self.data= self.sec_data[:SECTOR_SIZE]
+    self.data_size= SECTOR_SIZE
+    self.next_sector++
+    return nil
+  }
+  switch self.track_type {
+  case _TRACK_TYPE_FILE_RAW:
+    self.data= buf
+    self.data_size= len(buf)
+  case TRACK_TYPE_AUDIO:
+    self.data= self.sec_data[:SECTOR_SIZE]
+    self.data_size= SECTOR_SIZE
+  case TRACK_TYPE_ISO:
+    self.data= self.sec_data[:2048]
+    self.data_size= 2048
+  case TRACK_TYPE_MODE1_RAW:
+    self.data= self.sec_data[16:2064]
+    self.data_size= 2048
+  case TRACK_TYPE_MODE2_RAW:
+    self.data= self.sec_data[16:SECTOR_SIZE]
+    self.data_size= 2336
+  case TRACK_TYPE_MODE2_CDXA_RAW:
+    if self.sec_data[0x12]&0x20 == 0 { // Form1
+      self.data= self.sec_data[0x18:0x818]
+      self.data_size= 2048
+      if self.mode == MODE_CDXA_MEDIA_ONLY {
+        self.pos= self.data_size+1
+      }
+    } else { // Form2
+      self.data= self.sec_data[0x18:0x18+2324]
+      self.data_size= 2324
+      if self.mode == MODE_DATA {
+        self.pos= self.data_size+1
+      }
+    }
+  default:
+    return fmt.Errorf ( "load sectors of type %d not implemented",
+      self.track_type )
+  }
+  self.next_sector++
+
+  return nil
+
+} // end loadNextSector
+
+
+func (self *_File_TrackReader) Close() error {
+  return self.file.Close ()
+} // end Close
+
+
+func (self *_File_TrackReader) Read( b []byte ) (n int,err error) {
+
+  // EOF
+  if self.eof { return 0,io.EOF }
+
+  // Llig
+  pos,remain:= 0,len(b)
+  for remain > 0 && !self.eof {
+
+    // Recarrega si cal
+    for self.pos >= self.data_size && !self.eof {
+      if err:= self.loadNextSector (); err != nil {
+        return 0,err
+      }
+    }
+
+    // Llig
+    if !self.eof {
+      // --> Bytes a llegir
+      avail:= self.data_size-self.pos
+      var nbytes int
+      if remain > avail {
+        nbytes= avail
+      } else {
+        nbytes= remain
+      }
+      // --> Còpia
+      copy ( b[pos:pos+nbytes], self.data[self.pos:self.pos+nbytes])
+      // --> Actualitza
+      pos+= nbytes
+      remain-= nbytes
+      self.pos+= nbytes
+    }
+
+  }
+
+  return pos,nil
+
+} // end Read
+
+
+func (self *_File_TrackReader) Seek( sector int64 ) error {
+
+  // Actualitza estat
+  self.eof= false
+  self.pos= _FILE_MAX_SECTOR_SIZE
+  self.next_sector= sector
+
+  // Intenta carregar
+  if err:= self.loadNextSector (); err != nil {
+    return err
+  }
+
+  return nil
+
+} // end Seek
@@ -0,0 +1,442 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  gdi.go - Format GDI (Dreamcast GD-ROM).
+ *
+ *  Fitxer de text amb el nombre de tracks i una línia per track:
+ *
+ *    <número> <LBA> <tipus (0 àudio, 4 dades)> <grandària sector>
+ *    <fitxer> <desplaçament>
+ *
+ *  Els tracks amb LBA >= 45000 formen part de l'àrea d'alta densitat,
+ *  que es tracta com una segona sessió.
+ */
+
+package cdread
+
+import (
+  "bufio"
+  "errors"
+  "fmt"
+  "os"
+  "path"
+  "strconv"
+  "strings"
+)
+
+
+
+
+/****************/
+/* PART PRIVADA */
+/****************/
+
+// Primer sector de l'àrea d'alta densitat.
+const _GDI_HD_AREA_LBA = 45000
+
+
+// Separa una línia en camps. Els noms de fitxer poden anar entre
+// cometes.
+func splitLineGdi( line string ) []string {
+
+  var ret []string
+  for {
+    line= strings.TrimLeft ( line, " \t" )
+    if len(line) == 0 { break }
+    var end int
+    if line[0] == '"' {
+      end= strings.IndexByte ( line[1:], '"' )
+      if end == -1 {
+        ret= append(ret,line[1:])
+        break
+      }
+      ret= append(ret,line[1:end+1])
+      line= line[end+2:]
+    } else {
+      end= strings.IndexAny ( line, " \t" )
+      if end == -1 {
+        ret= append(ret,line)
+        break
+      }
+      ret= append(ret,line[:end])
+      line= line[end:]
+    }
+  }
+
+  return ret
+
+} // end splitLineGdi
+
+
+type _CD_Gdi_Track struct {
+
+  number      int
+  track_type  int
+  lba         int64
+  num_sectors int64
+  file_name   string
+  offset      int64
+  stride      int64
+  read_size   int64
+  data_offset int64
+
+}
+
+type _CD_Gdi struct {
+
+  file_name string
+  sessions  [][]_CD_Gdi_Track
+
+}
+
+
+func (self *_CD_Gdi) readTrack(
+
+  fields []string,
+  dir    string,
+  track  *_CD_Gdi_Track,
+
+) error {
+
+  if len(fields) < 5 {
+    return fmt.Errorf ( "wrong GDI track line: %s",
+      strings.Join ( fields, " " ) )
+  }
+
+  // Camps
+  number,err:= strconv.Atoi ( fields[0] )
+  if err != nil { return fmt.Errorf ( "wrong track number: %s", fields[0] ) }
+  lba,err:= strconv.ParseInt ( fields[1], 10, 64 )
+  if err != nil || lba < 0 {
+    return fmt.Errorf ( "wrong LBA for track %d: %s", number, fields[1] )
+  }
+  ttype,err:= strconv.Atoi ( fields[2] )
+  if err != nil {
+    return fmt.Errorf ( "wrong type for track %d: %s", number, fields[2] )
+  }
+  sector_size,err:= strconv.ParseInt ( fields[3], 10, 64 )
+  if err != nil {
+    return fmt.Errorf ( "wrong sector size for track %d: %s",
+      number, fields[3] )
+  }
+  if len(fields) > 5 {
+    if track.offset,err= strconv.ParseInt ( fields[5], 10, 64 ); err != nil {
+      return fmt.Errorf ( "wrong offset for track %d: %s", number, fields[5] )
+    }
+  }
+  track.number= number
+  track.lba= lba
+  track.stride= sector_size
+  track.file_name= path.Join ( dir, fields[4] )
+
+  // Grandària
+  info,err:= os.Stat ( track.file_name )
+  if err != nil { return err }
+  if info.Size () < track.offset {
+    return fmt.Errorf ( "wrong offset for track %d: %d", number, track.offset )
+  }
+  track.num_sectors= (info.Size ()-track.offset)/sector_size
+
+  // Tipus
+  switch {
+  case ttype == 0 && sector_size == SECTOR_SIZE:
+    track.track_type= TRACK_TYPE_AUDIO
+    track.read_size= SECTOR_SIZE
+  case ttype == 4 && sector_size == 2048:
+    track.track_type= TRACK_TYPE_ISO
+    track.read_size= 2048
+  case ttype == 4 && sector_size == 2336:
+    track.track_type= TRACK_TYPE_MODE2_RAW
+    track.read_size= 2336
+    track.data_offset= 16
+  case ttype == 4 && sector_size == SECTOR_SIZE:
+    // El mode està en la capçalera del sector
+    f,err:= os.Open ( track.file_name )
+    if err != nil { return err }
+    var header [16]byte
+    _,err= f.ReadAt ( header[:], track.offset )
+    f.Close ()
+    if err != nil { return err }
+    if header[15] == 2 {
+      track.track_type= TRACK_TYPE_MODE2_RAW
+    } else {
+      track.track_type= TRACK_TYPE_MODE1_RAW
+    }
+    track.read_size= SECTOR_SIZE
+  default:
+    return fmt.Errorf ( "unsupported GDI track %d: type %d, sector size %d",
+      number, ttype, sector_size )
+  }
+
+  return nil
+
+} // end readTrack
+
+
+func (self *_CD_Gdi) relabelCDXATracks() error {
+
+  for s:= range self.sessions {
+    for t:= range self.sessions[s] {
+      track:= &self.sessions[s][t]
+      if track.track_type != TRACK_TYPE_MODE2_RAW { continue }
+      tr,err:= self.newTrackFileReader ( track, MODE_DATA )
+      if err != nil { return err }
+      is_cdxa,err:= CheckTrackIsMode2CDXA ( tr )
+      tr.Close ()
+      if err != nil { return err }
+      if is_cdxa {
+        track.track_type= TRACK_TYPE_MODE2_CDXA_RAW
+      }
+    }
+  }
+
+  return nil
+
+} // end relabelCDXATracks
+
+
+func (self *_CD_Gdi) newTrackFileReader(
+
+  t    *_CD_Gdi_Track,
+  mode int,
+
+) (*_File_TrackReader,error) {
+  return newFileTrackReader ( t.file_name, t.offset, t.stride, t.read_size,
+    t.data_offset, t.num_sectors, t.lba + 2*75, t.track_type, mode )
+} // end newTrackFileReader
+
+
+func (self *_CD_Gdi) Format() string { return "GDI (Dreamcast GD-ROM)" }
+
+
+func (self *_CD_Gdi) Info() *Info {
+
+  // Calcula tracks totals
+  num_tracks:= 0
+  for s:= range self.sessions {
+    num_tracks+= len(self.sessions[s])
+  }
+
+  // Crea
+  ret:= Info{}
+  ret.Sessions= make([]SessionInfo,len(self.sessions))
+  ret.Tracks= make([]TrackInfo,num_tracks)
+  pos:= 0
+  for s,tracks:= range self.sessions {
+    beg_pos:= pos
+    for t:= range tracks {
+      track:= &tracks[t]
+      info:= &ret.Tracks[pos]
+      pos++
+      info.Id= BCD ( track.number )
+      info.Type= track.track_type
+      info.Cooked= track.data_offset > 0
+      if track.track_type != TRACK_TYPE_AUDIO {
+        info.Flags= TRACK_FLAGS_DATA
+      }
+      info.Indexes= []IndexInfo{
+        {Id : 1, Pos : GetPosition ( track.lba + 2*75 )},
+      }
+      info.PosLastSector= GetPosition ( track.lba +
+        track.num_sectors - 1 + 2*75 )
+    }
+    ret.Sessions[s].Tracks= ret.Tracks[beg_pos:pos]
+  }
+
+  return &ret
+
+} // end Info
+
+
+func (self *_CD_Gdi) TrackReader(
+
+  session_id int,
+  track_id   int,
+  mode       int,
+
+) (TrackReader,error) {
+
+  if session_id < 0 || session_id >= len(self.sessions) {
+    return nil,fmt.Errorf ( "session (%d) out of range", session_id )
+  }
+  tracks:= self.sessions[session_id]
+  if track_id < 0 || track_id >= len(tracks) {
+    return nil,fmt.Errorf ( "track (%d) out of range", track_id )
+  }
+  ret:= _GDI_TrackReader{
+    gdi : self,
+    tracks : tracks,
+    track : track_id,
+    mode : mode,
+    current : track_id,
+  }
+  var err error
+  if ret.f,err= self.newTrackFileReader ( &tracks[track_id], mode ); err != nil {
+    return nil,err
+  }
+
+  return &ret,nil
+
+} // end TrackReader
+
+
+func (self *_CD_Gdi) Files() []string {
+
+  ret:= []string{self.file_name}
+  for _,tracks:= range self.sessions {
+    for _,t:= range tracks {
+      ret= appendFileName ( ret, t.file_name )
+    }
+  }
+
+  return ret
+
+} // end Files
+
+
+// TRACK READER ////////////////////////////////////////////////////////////////
+
+// Llig un track. El sistema de fitxers de l'àrea d'alta densitat pot
+// ocupar diversos tracks de dades, per això Seek accepta sectors fora
+// del track, que es busquen per LBA absoluta en la resta de tracks de
+// la sessió.
+type _GDI_TrackReader struct {
+
+  gdi     *_CD_Gdi
+  tracks  []_CD_Gdi_Track
+  track   int // Track llegit
+  mode    int
+  current int // Track del qual s'està llegint
+  f       *_File_TrackReader
+
+}
+
+
+func (self *_GDI_TrackReader) Close() error {
+  return self.f.Close ()
+} // end Close
+
+
+func (self *_GDI_TrackReader) Read( b []byte ) (n int,err error) {
+  return self.f.Read ( b )
+} // end Read
+
+
+func (self *_GDI_TrackReader) Seek( sector int64 ) error {
+
+  // Busca el track
+  t:= &self.tracks[self.track]
+  lba:= t.lba + sector
+  var i int
+  for i= 0; i < len(self.tracks); i++ {
+    tmp:= &self.tracks[i]
+    if lba >= tmp.lba && lba < tmp.lba+tmp.num_sectors { break }
+  }
+  if i == len(self.tracks) {
+    if sector >= 0 && sector <= t.num_sectors {
+      i= self.track // EOF del track
+    } else {
+      return fmt.Errorf ( "sector %d out of range", lba )
+    }
+  }
+  if self.tracks[i].track_type == TRACK_TYPE_AUDIO &&
+    t.track_type != TRACK_TYPE_AUDIO {
+    return fmt.Errorf ( "sector %d belongs to an audio track", lba )
+  }
+
+  // Canvia el lector
+  if i != self.current {
+    f,err:= self.gdi.newTrackFileReader ( &self.tracks[i], self.mode )
+    if err != nil { return err }
+    self.f.Close ()
+    self.f= f
+    self.current= i
+  }
+
+  return self.f.Seek ( lba - self.tracks[i].lba )
+
+} // end Seek
+
+
+
+
+/**********************/
+/* FUNCIONS PÚBLIQUES */
+/**********************/
+
+func OpenGdi( file_name string ) (CD,error) {
+
+  if strings.ToLower ( path.Ext ( file_name ) ) != ".gdi" {
+    return nil,fmt.Errorf ( "'%s' is not a GDI file", file_name )
+  }
+
+  // Llig línies
+  f,err:= os.Open ( file_name )
+  if err != nil { return nil,err }
+  defer f.Close ()
+  var lines [][]string
+  s:= bufio.NewScanner ( f )
+  for s.Scan () {
+    if fields:= splitLineGdi ( strings.TrimSpace ( s.Text () ) );
+    len(fields) > 0 {
+      lines= append(lines,fields)
+    }
+  }
+  if err:= s.Err (); err != nil { return nil,err }
+  if len(lines) == 0 {
+    return nil,errors.New ( "empty GDI file" )
+  }
+  num_tracks,err:= strconv.Atoi ( lines[0][0] )
+  if err != nil || num_tracks <= 0 || num_tracks != len(lines)-1 {
+    return nil,fmt.Errorf ( "wrong number of tracks in '%s'", file_name )
+  }
+
+  // Tracks
+  ret:= _CD_Gdi{
+    file_name : file_name,
+  }
+  dir:= path.Dir ( file_name )
+  var sd,hd []_CD_Gdi_Track
+  prev_number,prev_lba:= 0,int64(-1)
+  for _,fields:= range lines[1:] {
+    var track _CD_Gdi_Track
+    if err:= ret.readTrack ( fields, dir, &track ); err != nil {
+      return nil,err
+    }
+    if (prev_lba != -1 && track.number != prev_number+1) ||
+      track.lba < prev_lba {
+      return nil,fmt.Errorf ( "wrong order of tracks in '%s'", file_name )
+    }
+    if track.lba >= _GDI_HD_AREA_LBA {
+      hd= append(hd,track)
+    } else {
+      sd= append(sd,track)
+    }
+    prev_number,prev_lba= track.number,track.lba
+  }
+  if len(sd) > 0 { ret.sessions= append(ret.sessions,sd) }
+  if len(hd) > 0 { ret.sessions= append(ret.sessions,hd) }
+  if err:= ret.relabelCDXATracks (); err != nil {
+    return nil,err
+  }
+
+  return &ret,nil
+
+} // end OpenGdi
@@ -0,0 +1,103 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  ipbin.go - Capçalera IP.BIN dels discs de Dreamcast.
+ */
+
+package cdread
+
+import (
+  "errors"
+  "strings"
+)
+
+
+
+
+/****************/
+/* PART PRIVADA */
+/****************/
+
+const _IPBIN_HARDWARE_ID = "SEGA SEGAKATANA "
+
+
+
+
+/****************/
+/* PART PÚBLICA */
+/****************/
+
+// Capçalera del IP.BIN (primer sector del track de dades).
+type IPBin struct {
+
+  HardwareId     string
+  MakerId        string
+  DeviceInfo     string
+  AreaSymbols    string
+  Peripherals    string
+  ProductNumber  string
+  ProductVersion string
+  ReleaseDate    string
+  BootFileName   string
+  SoftwareMaker  string
+  Title          string
+
+}
+
+
+// Llig la capçalera IP.BIN del track indicat. Torna error si el
+// track no és d'un disc de Dreamcast.
+func ReadIPBin( cd CD, session int, track int ) (*IPBin,error) {
+
+  // Llig el primer sector
+  f,err:= cd.TrackReader ( session, track, 0 )
+  if err != nil { return nil,err }
+  defer f.Close ()
+  var buf [0x100]byte
+  if n,err:= f.Read ( buf[:] ); err != nil {
+    return nil,err
+  } else if n != len(buf) {
+    return nil,errors.New ( "failed to read IP.BIN" )
+  }
+  if string(buf[:16]) != _IPBIN_HARDWARE_ID {
+    return nil,errors.New ( "IP.BIN not found" )
+  }
+
+  // Camps
+  S:= func(beg,end int) string {
+    return strings.TrimRight ( string(buf[beg:end]), " \x00" )
+  }
+  ret:= IPBin{
+    HardwareId : S(0x00,0x10),
+    MakerId : S(0x10,0x20),
+    DeviceInfo : S(0x20,0x30),
+    AreaSymbols : S(0x30,0x38),
+    Peripherals : S(0x38,0x40),
+    ProductNumber : S(0x40,0x4a),
+    ProductVersion : S(0x4a,0x50),
+    ReleaseDate : S(0x50,0x60),
+    BootFileName : S(0x60,0x70),
+    SoftwareMaker : S(0x70,0x80),
+    Title : S(0x80,0x100),
+  }
+
+  return &ret,nil
+
+} // end ReadIPBin
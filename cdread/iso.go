@@ -158,17 +158,25 @@ func OpenIso( file_name string ) (CD,error) {
   }
 
   // Llig la signatura del primer decriptor de volum.
-  var data [5]byte
-  if _,err:= f.Seek ( 16*_ISO_SECTOR_SIZE + 1, 0 ); err != nil {
+  var data [14]byte
+  if _,err:= f.Seek ( 16*_ISO_SECTOR_SIZE, 0 ); err != nil {
     return nil,err
   }
   nread,err:= f.Read ( data[:] )
   if err != nil { return nil,err }
 
-  // Comprova
-  if nread != 5 || data[0]!='C' || data[1]!='D' || data[2]!='0' ||
-    data[3]!='0' || data[4]!='1' {
-    return nil,fmt.Errorf ( "'%s' is not a ISO file" )
+  // Comprova. També s'accepten imatges UDF, les quals comencen la
+  // seqüència de reconeixement de volum amb BEA01 (o directament amb
+  // NSR0x), i imatges High Sierra (CDROM en el byte 9).
+  if nread != 14 {
+    return nil,fmt.Errorf ( "'%s' is not a ISO file", file_name )
+  }
+  switch string(data[1:6]) {
+  case "CD001", "BEA01", "NSR02", "NSR03":
+  default:
+    if string(data[9:14]) != "CDROM" {
+      return nil,fmt.Errorf ( "'%s' is not a ISO file", file_name )
+    }
   }
 
   // Crea CD
@@ -19,6 +19,10 @@
  */
 /*
  *  mds.go - Format  MDS/MDF (Alcohol 120%) 
+ *
+ *  També suporta imatges partides en diversos fitxers (.mdf, .md1,
+ *  .md2...), DVDs i imatges MDX v2 (Daemon Tools) amb el descriptor
+ *  sense xifrar.
  */
 
 package cdread
@@ -35,137 +39,15 @@ import (
 
 
 
-/****************/
-/* TRACK READER */
-/****************/
-
-type _Mds_TrackReader struct {
-
-  mode int
-  cd   *_CD_Mds
-  db   *_CD_Mds_DataBlock
-
-  // Situació sectors
-  next_sector int64
-  eof         bool
-
-  // Sector actual
-  sec_data  []byte
-  data      []byte // Slice de sec_data
-  data_size int
-  pos       int
-  file      *os.File
-  
-}
-
-
-func (self *_Mds_TrackReader) loadNextSector() error {
-
-  // Eof
-  if self.eof ||
-    self.next_sector >= int64(uint64(self.db.index.index1_sectors)) {
-    self.eof= true
-    return nil
-  }
-
-  // Mou a la posició del sector
-  offset:= self.db.offset + self.next_sector*int64(len(self.sec_data))
-  if new_off,err:= self.file.Seek ( offset, 0 ); err != nil {
-    return err
-  } else if new_off != offset {
-    return fmt.Errorf ( "failed to read sector %d", self.next_sector )
-  }
-
-  // Llig el sector.
-  if n,err:= self.file.Read ( self.sec_data[:] ); err != nil {
-    return err
-  } else if n != len(self.sec_data) {
-    return fmt.Errorf ( "failed to read sector %d", self.next_sector )
-  }
-
-  // Actualitza estat.
-  self.pos= 0
-  switch self.db.trackmode {
-  case _CD_MDS_TRACKMODE_AUDIO:
-    self.data= self.sec_data[:SECTOR_SIZE]
-    self.data_size= SECTOR_SIZE
-  case _CD_MDS_TRACKMODE_MODE1:
-    self.data= self.sec_data[16:2064]
-    self.data_size= 2048
-  default:
-    return fmt.Errorf ( "load sectors of type %d not implemented",
-      self.db.trackmode )
-  }
-  self.next_sector++
-  
-  return nil
-  
-} // end loadNextSector
-
-
-func (self *_Mds_TrackReader) Close() error {
-  return self.file.Close ()
-} // end Close
-
+/*********/
+/* UTILS */
+/*********/
 
-func (self *_Mds_TrackReader) Read( b []byte ) (n int,err error) {
-  
-  // EOF
-  if self.eof { return 0,io.EOF }
-  
-  // Llig
-  pos,remain:= 0,len(b)
-  for remain > 0 && !self.eof {
-    
-    // Recarrega si cal
-    // ATENCIÓ!! Si els sectors no són d'aquesta grandària podria
-    // fallar. Ara sols suporte RAW sectors.
-    for self.pos >= self.data_size && !self.eof {
-      if err:= self.loadNextSector (); err != nil {
-        return 0,err
-      }
-    }
-    
-    // Llig
-    if !self.eof {
-      // --> Bytes a llegir
-      avail:= self.data_size-self.pos
-      var nbytes int
-      if remain > avail {
-        nbytes= avail
-      } else {
-        nbytes= remain
-      }
-      // --> Còpia
-      copy ( b[pos:pos+nbytes], self.data[self.pos:self.pos+nbytes])
-      // --> Actualitza
-      pos+= nbytes
-      remain-= nbytes
-      self.pos+= nbytes
-    }
-    
-  }
-  
-  return pos,nil
-  
-} // end Read
-
-
-func (self *_Mds_TrackReader) Seek( sector int64 ) error {
-
-  // Actualitza estat
-  self.eof= false
-  self.pos= len(self.sec_data)
-  self.next_sector= sector
-  
-  // Intenta carregar
-  if err:= self.loadNextSector (); err != nil {
-    return err
-  }
-  
-  return nil
-  
-} // end Seek
+// Posició en la capçalera MDX v2 del descriptor.
+const (
+  _MDX_DESCRIPTOR_OFFSET = 0x30 // 64 bits
+  _MDX_DESCRIPTOR_SIZE   = 0x38 // 32 bits
+)
 
 
 
@@ -207,6 +89,7 @@ type _CD_Mds_DataBlock struct {
                      // sector és el 00:02:00 ???)
   offset      int64 // Offset dins del fitxer
   file_names  []string // Fitxers on llegir per ordre
+  cdxa        bool // Track Mode2 amb sectors CD-XA
   
 }
 
@@ -234,14 +117,16 @@ const (
 type _CD_Mds struct {
 
   file_name   string
+  version     uint8
   media_type  int
   sessions    []_CD_Mds_Session
   default_mdf string // MDF per defecte
+  dvd         *DVDInfo
 
 }
 
 
-func (self *_CD_Mds_Index) read( f *os.File, offset uint32 ) error {
+func (self *_CD_Mds_Index) read( f io.ReadSeeker, offset uint32 ) error {
 
   // Llig block.
   var buf [0x8]byte
@@ -271,7 +156,7 @@ func (self *_CD_Mds_Index) read( f *os.File, offset uint32 ) error {
 
 func (self *_CD_Mds_DataBlock) readFileName(
   
-  f      *os.File,
+  f      io.ReadSeeker,
   offset uint32,
   
 ) error {
@@ -346,7 +231,13 @@ func (self *_CD_Mds_DataBlock) readFileName(
 } // end readFileName
 
 
-func (self *_CD_Mds_Session) readDataBlocks( f *os.File, offset uint32 ) error {
+func (self *_CD_Mds_Session) readDataBlocks(
+
+  f      io.ReadSeeker,
+  offset uint32,
+  is_dvd bool,
+
+) error {
 
   var buf [0x50]byte
   var d _CD_Mds_DataBlock
@@ -369,6 +260,8 @@ func (self *_CD_Mds_Session) readDataBlocks( f *os.File, offset uint32 ) error {
     switch buf[0] {
     case 0x00:
       d.trackmode= _CD_MDS_TRACKMODE_NONE
+    case 0x02: // DVD
+      d.trackmode= _CD_MDS_TRACKMODE_MODE1
     case 0xa9:
       d.trackmode= _CD_MDS_TRACKMODE_AUDIO
     case 0xaa:
@@ -399,12 +292,14 @@ func (self *_CD_Mds_Session) readDataBlocks( f *os.File, offset uint32 ) error {
     d.file_names= nil
     if d.point < 0xa0 {
 
-      // Index offset
+      // Index offset. En els DVD és directament el nombre de sectors.
       index_offset= uint32(buf[0xc]) |
         (uint32(buf[0xd])<<8) |
         (uint32(buf[0xe])<<16) |
         (uint32(buf[0xf])<<24)
-      if err:= d.index.read ( f, index_offset ); err != nil {
+      if is_dvd {
+        d.index.index1_sectors= index_offset
+      } else if err:= d.index.read ( f, index_offset ); err != nil {
         return err
       }
       
@@ -412,7 +307,7 @@ func (self *_CD_Mds_Session) readDataBlocks( f *os.File, offset uint32 ) error {
       d.sector_size= uint16(buf[0x10]) | (uint16(buf[0x11])<<8)
       if d.sector_size < 0x800 || d.sector_size > 0x990 {
         return fmt.Errorf ( "failed to read data block %d for session %d:"+
-          " wrong sector size", i+1, self.id, d.sector_size )
+          " wrong sector size %d", i+1, self.id, d.sector_size )
       }
 
       // Start i offset
@@ -460,7 +355,7 @@ func (self *_CD_Mds_Session) readDataBlocks( f *os.File, offset uint32 ) error {
 } // end readDataBlocks
 
 
-func (self *_CD_Mds) readSessions( f *os.File, offset uint32 ) error {
+func (self *_CD_Mds) readSessions( f io.ReadSeeker, offset uint32 ) error {
 
   var buf [0x18]byte
   var s *_CD_Mds_Session
@@ -520,7 +415,7 @@ func (self *_CD_Mds) readSessions( f *os.File, offset uint32 ) error {
       (uint32(buf[0x15])<<8) |
       (uint32(buf[0x16])<<16) |
       (uint32(buf[0x17])<<24)
-    if err:= s.readDataBlocks ( f, data_offset ); err != nil {
+    if err:= s.readDataBlocks ( f, data_offset, self.isDVD () ); err != nil {
       return err
     }
     
@@ -531,6 +426,38 @@ func (self *_CD_Mds) readSessions( f *os.File, offset uint32 ) error {
 } // end readSessions
 
 
+// Llig la Physical Format Information del DVD. Abans hi ha 4 bytes
+// d'informació de copyright i 2048 de Disc Manufacturing Information.
+func (self *_CD_Mds) readDiscStructures( f io.ReadSeeker, offset uint32 ) error {
+
+  // Llig
+  var buf [4+2048+2048]byte
+  if _,err:= f.Seek ( int64(uint64(offset)), 0 ); err != nil { return err }
+  if _,err:= io.ReadFull ( f, buf[:] ); err != nil {
+    return fmt.Errorf ( "failed to read DVD structures in %X: %s",
+      offset, err )
+  }
+  pfi:= buf[4+2048:]
+
+  // Parseja
+  self.dvd= &DVDInfo{
+    BookType : pfi[0]>>4,
+    PartVersion : pfi[0]&0xf,
+    DiscSize : pfi[1]>>4,
+    MaxRate : pfi[1]&0xf,
+    NumLayers : int((pfi[2]>>5)&0x3)+1,
+    TrackPath : (pfi[2]&0x10) != 0,
+    LayerType : pfi[2]&0xf,
+    StartSector : uint32(pfi[5])<<16 | uint32(pfi[6])<<8 | uint32(pfi[7]),
+    EndSector : uint32(pfi[9])<<16 | uint32(pfi[10])<<8 | uint32(pfi[11]),
+    EndSectorL0 : uint32(pfi[13])<<16 | uint32(pfi[14])<<8 | uint32(pfi[15]),
+  }
+  
+  return nil
+  
+} // end readDiscStructures
+
+
 func (self *_CD_Mds) init( f *os.File ) error {
 
   // Llig capçalera
@@ -543,10 +470,43 @@ func (self *_CD_Mds) init( f *os.File ) error {
   }
 
   // Comprova capçalera
-  id:= string(buf[:16])
-  if id != "MEDIA DESCRIPTOR" && buf[11]!=1 &&
-    (buf[12]!=3 || buf[12]!=4 || buf[12]!=5) {
-    return errors.New ( "%s is not a MDS/MDF file" )
+  if string(buf[:16]) != "MEDIA DESCRIPTOR" {
+    return fmt.Errorf ( "%s is not a MDS/MDF file", self.file_name )
+  }
+  self.version= buf[0x10]
+  var desc io.ReadSeeker= f
+  switch self.version {
+  case 1:
+  case 2:
+    // En la v2 el descriptor està en una altra posició del fitxer i
+    // normalment està xifrat.
+    offset:= int64(uint64(buf[_MDX_DESCRIPTOR_OFFSET]) |
+      (uint64(buf[_MDX_DESCRIPTOR_OFFSET+1])<<8) |
+      (uint64(buf[_MDX_DESCRIPTOR_OFFSET+2])<<16) |
+      (uint64(buf[_MDX_DESCRIPTOR_OFFSET+3])<<24) |
+      (uint64(buf[_MDX_DESCRIPTOR_OFFSET+4])<<32) |
+      (uint64(buf[_MDX_DESCRIPTOR_OFFSET+5])<<40) |
+      (uint64(buf[_MDX_DESCRIPTOR_OFFSET+6])<<48) |
+      (uint64(buf[_MDX_DESCRIPTOR_OFFSET+7])<<56))
+    size:= int64(uint32(buf[_MDX_DESCRIPTOR_SIZE]) |
+      (uint32(buf[_MDX_DESCRIPTOR_SIZE+1])<<8) |
+      (uint32(buf[_MDX_DESCRIPTOR_SIZE+2])<<16) |
+      (uint32(buf[_MDX_DESCRIPTOR_SIZE+3])<<24))
+    if offset < 0x58 || size < 0x58 {
+      return fmt.Errorf ( "wrong MDX descriptor: offset %d, size %d",
+        offset, size )
+    }
+    desc= io.NewSectionReader ( f, offset, size )
+    if _,err:= io.ReadFull ( desc, buf[:] ); err != nil {
+      return fmt.Errorf ( "unable to read MDX descriptor from file %s: %s",
+        self.file_name, err )
+    }
+    if string(buf[:16]) != "MEDIA DESCRIPTOR" || buf[0x10] != 1 {
+      return errors.New ( "encrypted MDX descriptors are not supported" )
+    }
+  default:
+    return fmt.Errorf ( "unsupported MDS version: %d.%d",
+      buf[0x10], buf[0x11] )
   }
 
   // Llig valors capçalera
@@ -563,36 +523,166 @@ func (self *_CD_Mds) init( f *os.File ) error {
   case 0x12:
     self.media_type= _CD_MDS_MEDIA_TYPE_DCDR
   default:
-    return fmt.Errorf ( "unknown media type: %02", buf[0x12] )
+    return fmt.Errorf ( "unknown media type: %02X", media_type )
   }
   num_sessions:= uint16(buf[0x14]) | (uint16(buf[0x15])<<8)
   if num_sessions == 0 {
     return errors.New ( "number of sessions is 0" )
   }
 
+  // Estructures del DVD
+  if self.isDVD () {
+    offset:= uint32(buf[0x40]) |
+      (uint32(buf[0x41])<<8) |
+      (uint32(buf[0x42])<<16) |
+      (uint32(buf[0x43])<<24)
+    if offset != 0 {
+      if err:= self.readDiscStructures ( desc, offset ); err != nil {
+        return err
+      }
+    }
+  }
+
   // Llig sessions
   offset:= uint32(buf[0x50]) |
     (uint32(buf[0x51])<<8) |
     (uint32(buf[0x52])<<16) |
     (uint32(buf[0x53])<<24)
   self.sessions= make([]_CD_Mds_Session,num_sessions)
-  if err:= self.readSessions ( f, offset ); err != nil { return err }
+  if err:= self.readSessions ( desc, offset ); err != nil { return err }
 
   // Default mdf
   ext:= path.Ext(self.file_name)
   if strings.ToLower(ext) == ".mds" {
     self.default_mdf,_= strings.CutSuffix(self.file_name,ext)
     self.default_mdf+= ".mdf"
+  } else if strings.ToLower(ext) == ".mdx" {
+    self.default_mdf= self.file_name
   } else {
     self.default_mdf= self.file_name+".mdf"
   }
+
+  // Tracks CD-XA
+  if err:= self.checkCDXA (); err != nil { return err }
   
   return nil
   
 } // end init
 
 
-func (self *_CD_Mds) Format() string { return "MDS/MDF (Alcohol 120%)" }
+func (self *_CD_Mds) isDVD() bool {
+  return self.media_type == _CD_MDS_MEDIA_TYPE_DVDROM ||
+    self.media_type == _CD_MDS_MEDIA_TYPE_DCDR
+} // end isDVD
+
+
+// Torna els fitxers on estan les dades d'un bloc. El caràcter '*' es
+// substitueix pel nom del MDS sense extensió. Si sols hi ha un
+// fitxer (.mdf o .md0) i existeixen fitxers partits (.md1, .md2...)
+// també s'afegeixen.
+func (self *_CD_Mds) getDataFiles( db *_CD_Mds_DataBlock ) []string {
+
+  // Expandeix noms
+  base:= strings.TrimSuffix ( self.file_name, path.Ext ( self.file_name ) )
+  dir:= path.Dir ( self.file_name )
+  ret:= make([]string,0,len(db.file_names))
+  for _,name:= range db.file_names {
+    if name == "" {
+      name= self.default_mdf
+    } else {
+      name= strings.ReplaceAll ( name, "*", path.Base ( base ) )
+      if !path.IsAbs ( name ) { name= path.Join ( dir, name ) }
+    }
+    ret= append(ret,name)
+  }
+
+  // Fitxers partits
+  if len(ret) == 1 {
+    ext:= strings.ToLower ( path.Ext ( ret[0] ) )
+    if ext == ".mdf" || ext == ".md0" {
+      prefix:= strings.TrimSuffix ( ret[0], path.Ext ( ret[0] ) )
+      for i:= 1; ; i++ {
+        name:= fmt.Sprintf ( "%s.md%d", prefix, i )
+        if _,err:= os.Stat ( name ); err != nil { break }
+        ret= append(ret,name)
+      }
+    }
+  }
+
+  return ret
+  
+} // end getDataFiles
+
+
+// Torna el tipus de track, els bytes útils de cada sector i la
+// posició dins del sector RAW on van eixos bytes.
+func (self *_CD_Mds_DataBlock) getTrackType() (int,int64,int64) {
+
+  size:= int64(self.sector_size)
+  switch self.trackmode {
+  case _CD_MDS_TRACKMODE_AUDIO:
+    if size >= SECTOR_SIZE {
+      return TRACK_TYPE_AUDIO,SECTOR_SIZE,0
+    }
+  case _CD_MDS_TRACKMODE_MODE1:
+    if size >= SECTOR_SIZE {
+      return TRACK_TYPE_MODE1_RAW,SECTOR_SIZE,0
+    } else if size == 2048 {
+      return TRACK_TYPE_ISO,2048,0
+    }
+  case _CD_MDS_TRACKMODE_MODE2, _CD_MDS_TRACKMODE_MODE2_SUBCHANNEL:
+    ttype:= TRACK_TYPE_MODE2_RAW
+    if self.cdxa { ttype= TRACK_TYPE_MODE2_CDXA_RAW }
+    if size >= SECTOR_SIZE {
+      return ttype,SECTOR_SIZE,0
+    } else if size == 2336 {
+      return ttype,2336,16
+    } else if size == 2048 {
+      return TRACK_TYPE_ISO,2048,0
+    }
+  }
+  
+  return TRACK_TYPE_UNK,0,0
+  
+} // end getTrackType
+
+
+func (self *_CD_Mds) checkCDXA() error {
+
+  for s:= range self.sessions {
+    track_id:= 0
+    for i:= range self.sessions[s].data {
+      db:= &self.sessions[s].data[i]
+      if db.trackmode == _CD_MDS_TRACKMODE_NONE { continue }
+      ttype,_,_:= db.getTrackType ()
+      if ttype == TRACK_TYPE_MODE2_RAW && len(db.file_names) > 0 &&
+        db.index.index1_sectors > 0 {
+        tr,err:= self.TrackReader ( s, track_id, 0 )
+        if err != nil { return err }
+        db.cdxa,err= CheckTrackIsMode2CDXA ( tr )
+        tr.Close ()
+        if err != nil { return err }
+      }
+      track_id++
+    }
+  }
+
+  return nil
+  
+} // end checkCDXA
+
+
+func (self *_CD_Mds) Format() string {
+
+  if self.version == 2 {
+    return "MDX (Daemon Tools)"
+  } else if self.isDVD () {
+    return "MDS/MDF (Alcohol 120%, DVD)"
+  } else {
+    return "MDS/MDF (Alcohol 120%)"
+  }
+  
+} // end Format
 
 
 func (self *_CD_Mds) Info() *Info {
@@ -611,7 +701,9 @@ func (self *_CD_Mds) Info() *Info {
   }
 
   // Crea
-  ret:= Info{}
+  ret:= Info{
+    DVD : self.dvd,
+  }
   ret.Sessions= make([]SessionInfo,len(self.sessions))
   ret.Tracks= make([]TrackInfo,num_tracks)
 
@@ -652,24 +744,16 @@ func (self *_CD_Mds) Info() *Info {
             track.Indexes[num_indexes-1].Id= 1
             track.Indexes[num_indexes-1].Pos=
               GetPosition ( int64(uint64(start_sect)) )
-            start_sect+= db.index.index0_sectors
+            start_sect+= db.index.index1_sectors
           }
         }
         track.PosLastSector= GetPosition( int64(uint64(start_sect-1)) )
 
         // Tipus
-        switch db.trackmode {
-        case _CD_MDS_TRACKMODE_AUDIO:
-          track.Type= TRACK_TYPE_AUDIO
-        case _CD_MDS_TRACKMODE_MODE1:
-          if db.sector_size >= SECTOR_SIZE {
-            track.Type= TRACK_TYPE_MODE1_RAW
-          } else {
-            track.Type= TRACK_TYPE_UNK
-          }
-        default:
-          track.Type= TRACK_TYPE_UNK
-        }
+        var data_offset int64
+        track.Type,_,data_offset= db.getTrackType ()
+        track.Cooked= data_offset > 0
+        track.Flags= db.addr_control>>4
         
       }
     }
@@ -715,34 +799,51 @@ func (self *_CD_Mds) TrackReader(
   if len(db.file_names) == 0 || db.index.index1_sectors == 0 {
     return nil,fmt.Errorf ( "track (%d) empty", track_id )
   }
-  if len(db.file_names) > 1 {
-    return nil,fmt.Errorf ( "multiple files (%d) not supported",
-      len(db.file_names) )
+  ttype,read_size,data_offset:= db.getTrackType ()
+  if ttype == TRACK_TYPE_UNK {
+    return nil,fmt.Errorf ( "track (%d) has an unsupported format", track_id )
+  }
+
+  // Primer sector (índex 1), calculat com en Info
+  first_sec:= int64(uint64(db.start))
+  if db.index.index0_sectors > 0 {
+    first_sec+= int64(uint64(db.index.index0_sectors))
+  } else {
+    first_sec+= 2*75
   }
 
   // Crea el TrackReader
-  ret:= _Mds_TrackReader{
-    mode        : mode,
-    cd          : self,
-    db          : db,
-    next_sector : 0,
-    eof         : false,
-    sec_data    : make([]byte,int(uint32(db.sector_size))),
-    data        : nil,
-    data_size   : 0,
-    pos         : int(uint32(db.sector_size)),
-  }
-  file_name:= db.file_names[0]
-  if file_name == "" { file_name= self.default_mdf }
-  var err error
-  ret.file,err= os.Open ( file_name )
+  file,err:= openImageFile ( self.getDataFiles ( db ) )
   if err != nil { return nil,err }
+  ret,err:= newImageTrackReader ( file, db.offset, int64(db.sector_size),
+    read_size, data_offset, int64(uint64(db.index.index1_sectors)),
+    first_sec, ttype, mode )
+  if err != nil {
+    file.Close ()
+    return nil,err
+  }
 
-  return &ret,nil
+  return ret,nil
   
 } // end TrackReader
 
 
+func (self *_CD_Mds) Files() []string {
+
+  ret:= []string{self.file_name}
+  for s:= range self.sessions {
+    for i:= range self.sessions[s].data {
+      for _,name:= range self.getDataFiles ( &self.sessions[s].data[i] ) {
+        ret= appendFileName ( ret, name )
+      }
+    }
+  }
+
+  return ret
+  
+} // end Files
+
+
 
 
 /**********************/
@@ -0,0 +1,660 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  nrg.go - Format NRG (Nero Burning ROM).
+ *
+ *  Tots els valors estan en big-endian. Al final del fitxer hi ha
+ *  'NERO' seguit d'un desplaçament de 32 bits (versió 1), o 'NER5'
+ *  seguit d'un desplaçament de 64 bits (versió 2), que apunta a una
+ *  seqüència de chunks acabada en 'END!'.
+ */
+
+package cdread
+
+import (
+  "errors"
+  "fmt"
+  "io"
+  "os"
+  "strings"
+)
+
+
+
+
+/*********/
+/* UTILS */
+/*********/
+
+func parse_int16_MSB( data []byte ) uint16 {
+  return (uint16(data[0])<<8) | uint16(data[1])
+} // end parse_int16_MSB
+
+
+func parse_int32_MSB( data []byte ) uint32 {
+  return (uint32(data[0])<<24) |
+    (uint32(data[1])<<16) |
+    (uint32(data[2])<<8) |
+    uint32(data[3])
+} // end parse_int32_MSB
+
+
+func parse_int64_MSB( data []byte ) uint64 {
+  return (uint64(parse_int32_MSB ( data[:4] ))<<32) |
+    uint64(parse_int32_MSB ( data[4:8] ))
+} // end parse_int64_MSB
+
+
+func bcd2int( val uint8 ) int {
+  return int(val>>4)*10 + int(val&0xf)
+} // end bcd2int
+
+
+// Torna el tipus de track, la grandària del sector en el fitxer, els
+// bytes útils de cada sector i la posició dins del sector RAW on van
+// eixos bytes.
+func getModeNrg( mode uint8 ) (int,int64,int64,int64,error) {
+
+  switch mode {
+  case 0x00: // Mode1 2048
+    return TRACK_TYPE_ISO,2048,2048,0,nil
+  case 0x02: // Mode2 Form1 2048
+    return TRACK_TYPE_MODE2_CDXA_RAW,2048,2048,0x18,nil
+  case 0x03: // Mode2 2336
+    return TRACK_TYPE_MODE2_RAW,2336,2336,16,nil
+  case 0x05: // Mode1 2352
+    return TRACK_TYPE_MODE1_RAW,SECTOR_SIZE,SECTOR_SIZE,0,nil
+  case 0x06: // Mode2 2352
+    return TRACK_TYPE_MODE2_RAW,SECTOR_SIZE,SECTOR_SIZE,0,nil
+  case 0x07: // Àudio 2352
+    return TRACK_TYPE_AUDIO,SECTOR_SIZE,SECTOR_SIZE,0,nil
+  case 0x0f: // Mode1 2352 + subcanal
+    return TRACK_TYPE_MODE1_RAW,SECTOR_SIZE+96,SECTOR_SIZE,0,nil
+  case 0x10: // Àudio 2352 + subcanal
+    return TRACK_TYPE_AUDIO,SECTOR_SIZE+96,SECTOR_SIZE,0,nil
+  case 0x11: // Mode2 2352 + subcanal
+    return TRACK_TYPE_MODE2_RAW,SECTOR_SIZE+96,SECTOR_SIZE,0,nil
+  default:
+    return TRACK_TYPE_UNK,0,0,0,fmt.Errorf ( "unknown NRG mode: %02X", mode )
+  }
+
+} // end getModeNrg
+
+
+
+
+/**********/
+/* CHUNKS */
+/**********/
+
+// Entrada d'un chunk CUES/CUEX.
+type _Nrg_CueEntry struct {
+
+  control uint8
+  track   int   // 0 lead-in, 0xAA lead-out
+  index   int
+  lba     int64
+
+}
+
+// Track d'un chunk DAOI/DAOX.
+type _Nrg_DaoTrack struct {
+
+  isrc          string
+  sector_size   int64
+  mode          uint8
+  pregap_offset int64
+  start_offset  int64
+  end_offset    int64
+
+}
+
+type _Nrg_Dao struct {
+
+  mcn         string
+  first_track int
+  tracks      []_Nrg_DaoTrack
+
+}
+
+// Track d'un chunk ETNF/ETN2 (imatges TAO).
+type _Nrg_EtnTrack struct {
+
+  offset int64
+  size   int64
+  mode   uint8
+  lba    int64
+
+}
+
+type _Nrg_Chunks struct {
+
+  cues [][]_Nrg_CueEntry // Un per sessió
+  daos []_Nrg_Dao        // Un per sessió
+  etns []_Nrg_EtnTrack
+  sinf []int             // Tracks per sessió
+  cdtx []byte
+
+}
+
+
+func (self *_Nrg_Chunks) parseCue( data []byte, v2 bool ) error {
+
+  if len(data)%8 != 0 {
+    return errors.New ( "wrong NRG CUE chunk size" )
+  }
+  entries:= make([]_Nrg_CueEntry,len(data)/8)
+  for i:= range entries {
+    e:= data[i*8:i*8+8]
+    entries[i].control= e[0]>>4
+    if e[1] == 0xaa {
+      entries[i].track= 0xaa
+    } else {
+      entries[i].track= bcd2int ( e[1] )
+    }
+    entries[i].index= bcd2int ( e[2] )
+    if v2 {
+      entries[i].lba= int64(int32(parse_int32_MSB ( e[4:] )))
+    } else {
+      entries[i].lba= (int64(e[5])*60 + int64(e[6]))*75 + int64(e[7]) - 150
+    }
+  }
+  self.cues= append(self.cues,entries)
+
+  return nil
+
+} // end parseCue
+
+
+func (self *_Nrg_Chunks) parseDao( data []byte, v2 bool ) error {
+
+  var block_size int
+  if v2 {
+    block_size= 42
+  } else {
+    block_size= 30
+  }
+  if len(data) < 22 || (len(data)-22)%block_size != 0 {
+    return errors.New ( "wrong NRG DAO chunk size" )
+  }
+  dao:= _Nrg_Dao{
+    mcn : strings.TrimRight ( string(data[4:17]), "\000 " ),
+    first_track : int(data[20]),
+  }
+  dao.tracks= make([]_Nrg_DaoTrack,(len(data)-22)/block_size)
+  for i:= range dao.tracks {
+    b:= data[22+i*block_size:22+(i+1)*block_size]
+    t:= &dao.tracks[i]
+    t.isrc= strings.TrimRight ( string(b[:12]), "\000 " )
+    t.sector_size= int64(parse_int16_MSB ( b[12:14] ))
+    t.mode= b[14]
+    if v2 {
+      t.pregap_offset= int64(parse_int64_MSB ( b[18:26] ))
+      t.start_offset= int64(parse_int64_MSB ( b[26:34] ))
+      t.end_offset= int64(parse_int64_MSB ( b[34:42] ))
+    } else {
+      t.pregap_offset= int64(parse_int32_MSB ( b[18:22] ))
+      t.start_offset= int64(parse_int32_MSB ( b[22:26] ))
+      t.end_offset= int64(parse_int32_MSB ( b[26:30] ))
+    }
+  }
+  self.daos= append(self.daos,dao)
+
+  return nil
+
+} // end parseDao
+
+
+func (self *_Nrg_Chunks) parseEtn( data []byte, v2 bool ) error {
+
+  var entry_size int
+  if v2 {
+    entry_size= 32
+  } else {
+    entry_size= 20
+  }
+  if len(data)%entry_size != 0 {
+    return errors.New ( "wrong NRG ETN chunk size" )
+  }
+  for i:= 0; i < len(data); i+= entry_size {
+    e:= data[i:i+entry_size]
+    var t _Nrg_EtnTrack
+    if v2 {
+      t.offset= int64(parse_int64_MSB ( e[0:8] ))
+      t.size= int64(parse_int64_MSB ( e[8:16] ))
+      t.mode= uint8(parse_int32_MSB ( e[16:20] ))
+      t.lba= int64(parse_int32_MSB ( e[20:24] ))
+    } else {
+      t.offset= int64(parse_int32_MSB ( e[0:4] ))
+      t.size= int64(parse_int32_MSB ( e[4:8] ))
+      t.mode= uint8(parse_int32_MSB ( e[8:12] ))
+      t.lba= int64(parse_int32_MSB ( e[12:16] ))
+    }
+    self.etns= append(self.etns,t)
+  }
+
+  return nil
+
+} // end parseEtn
+
+
+// Llig tots els chunks a partir de la posició indicada.
+func readChunksNrg(
+
+  f      *os.File,
+  offset int64,
+  size   int64,
+
+) (*_Nrg_Chunks,error) {
+
+  ret:= _Nrg_Chunks{}
+  var header [8]byte
+  for {
+
+    // Capçalera
+    if offset+8 > size {
+      return nil,errors.New ( "unexpected end of NRG chunks" )
+    }
+    if _,err:= f.ReadAt ( header[:], offset ); err != nil {
+      return nil,err
+    }
+    id:= string(header[:4])
+    length:= int64(parse_int32_MSB ( header[4:] ))
+    offset+= 8
+    if id == "END!" { break }
+    if offset+length > size {
+      return nil,fmt.Errorf ( "wrong size for NRG chunk '%s'", id )
+    }
+
+    // Dades
+    data:= make([]byte,length)
+    if _,err:= f.ReadAt ( data, offset ); err != nil {
+      return nil,err
+    }
+    offset+= length
+
+    // Processa
+    var err error
+    switch id {
+    case "CUES", "CUEX":
+      err= ret.parseCue ( data, id == "CUEX" )
+    case "DAOI", "DAOX":
+      err= ret.parseDao ( data, id == "DAOX" )
+    case "ETNF", "ETN2":
+      err= ret.parseEtn ( data, id == "ETN2" )
+    case "SINF":
+      if len(data) < 4 {
+        err= errors.New ( "wrong NRG SINF chunk size" )
+      } else {
+        ret.sinf= append(ret.sinf,int(parse_int32_MSB ( data )))
+      }
+    case "CDTX":
+      ret.cdtx= append(ret.cdtx,data...)
+    }
+    if err != nil { return nil,err }
+
+  }
+
+  return &ret,nil
+
+} // end readChunksNrg
+
+
+
+
+/******/
+/* CD */
+/******/
+
+type _CD_Nrg_Track struct {
+
+  number      int
+  control     uint8
+  track_type  int
+  index0      int64 // LBA. -1 si no té
+  index1      int64 // LBA
+  num_sectors int64 // A partir de l'índex 1
+  offset      int64 // Posició en el fitxer del sector de l'índex 1
+  pregap      int64 // Sectors del pregap emmagatzemats abans de offset
+  stride      int64
+  read_size   int64
+  data_offset int64
+  isrc        string
+
+}
+
+type _CD_Nrg struct {
+
+  file_name string
+  version   int
+  sessions  [][]_CD_Nrg_Track
+  catalog   string
+  cdtext    []CDText // 0 és el disc
+
+}
+
+
+// Construeix les sessions a partir dels chunks DAO (disc-at-once).
+func (self *_CD_Nrg) buildDao( chunks *_Nrg_Chunks ) error {
+
+  if len(chunks.cues) != len(chunks.daos) {
+    return errors.New ( "NRG CUE and DAO chunks do not match" )
+  }
+  self.sessions= make([][]_CD_Nrg_Track,len(chunks.daos))
+  for s,dao:= range chunks.daos {
+    if s == 0 { self.catalog= dao.mcn }
+    cue:= chunks.cues[s]
+    tracks:= make([]_CD_Nrg_Track,len(dao.tracks))
+    for i,dt:= range dao.tracks {
+      t:= &tracks[i]
+      t.number= dao.first_track+i
+      t.isrc= dt.isrc
+      var stride int64
+      var err error
+      t.track_type,stride,t.read_size,t.data_offset,err= getModeNrg ( dt.mode )
+      if err != nil { return err }
+      if dt.sector_size != 0 { stride= dt.sector_size }
+      if stride < t.read_size {
+        return fmt.Errorf ( "wrong sector size for track %d: %d",
+          t.number, stride )
+      }
+      t.stride= stride
+      if dt.end_offset < dt.start_offset || dt.start_offset < dt.pregap_offset {
+        return fmt.Errorf ( "wrong limits for track %d", t.number )
+      }
+      t.offset= dt.start_offset
+      t.num_sectors= (dt.end_offset-dt.start_offset)/stride
+
+      // Posició segons el CUE
+      t.index0,t.index1= -1,-1
+      for _,e:= range cue {
+        if e.track != t.number { continue }
+        if e.index == 0 {
+          t.index0= e.lba
+        } else if e.index == 1 {
+          t.index1= e.lba
+          t.control= e.control
+        }
+      }
+      if t.index1 == -1 {
+        return fmt.Errorf ( "index 1 of track %d not found", t.number )
+      }
+      if pregap:= (dt.start_offset-dt.pregap_offset)/stride; pregap > 0 {
+        t.index0= t.index1-pregap
+        t.pregap= pregap
+      }
+      if t.index0 >= t.index1 || t.index0 < -150 {
+        t.index0,t.pregap= -1,0
+      }
+    }
+    self.sessions[s]= tracks
+  }
+
+  return nil
+
+} // end buildDao
+
+
+// Construeix les sessions a partir dels chunks ETN (track-at-once).
+func (self *_CD_Nrg) buildEtn( chunks *_Nrg_Chunks ) error {
+
+  // Tracks per sessió
+  sinf:= chunks.sinf
+  if len(sinf) == 0 { sinf= []int{len(chunks.etns)} }
+  total:= 0
+  for _,n:= range sinf { total+= n }
+  if total != len(chunks.etns) {
+    return errors.New ( "NRG SINF and ETN chunks do not match" )
+  }
+
+  // Tracks
+  self.sessions= make([][]_CD_Nrg_Track,len(sinf))
+  pos:= 0
+  for s,n:= range sinf {
+    tracks:= make([]_CD_Nrg_Track,n)
+    for i:= range tracks {
+      et:= &chunks.etns[pos]
+      t:= &tracks[i]
+      t.number= pos+1
+      pos++
+      var err error
+      t.track_type,t.stride,t.read_size,t.data_offset,err= getModeNrg ( et.mode )
+      if err != nil { return err }
+      t.offset= et.offset
+      t.num_sectors= et.size/t.stride
+      t.index0= -1
+      t.index1= et.lba
+      if t.track_type != TRACK_TYPE_AUDIO { t.control= TRACK_FLAGS_DATA }
+    }
+    self.sessions[s]= tracks
+  }
+
+  return nil
+
+} // end buildEtn
+
+
+func (self *_CD_Nrg) relabelCDXATracks() error {
+
+  for s:= range self.sessions {
+    for t:= range self.sessions[s] {
+      track:= &self.sessions[s][t]
+      if track.track_type != TRACK_TYPE_MODE2_RAW { continue }
+      tr,err:= self.TrackReader ( s, t, 0 )
+      if err != nil { return err }
+      is_cdxa,err:= CheckTrackIsMode2CDXA ( tr )
+      tr.Close ()
+      if err != nil { return err }
+      if is_cdxa {
+        track.track_type= TRACK_TYPE_MODE2_CDXA_RAW
+      }
+    }
+  }
+
+  return nil
+
+} // end relabelCDXATracks
+
+
+func (self *_CD_Nrg) Format() string {
+  return fmt.Sprintf ( "NRG (Nero v%d)", self.version )
+} // end Format
+
+
+func (self *_CD_Nrg) Info() *Info {
+
+  // Calcula tracks totals
+  num_tracks:= 0
+  for s:= range self.sessions {
+    num_tracks+= len(self.sessions[s])
+  }
+
+  // Crea
+  ret:= Info{
+    Catalog : self.catalog,
+  }
+  if len(self.cdtext) > 0 {
+    ret.CDText= self.cdtext[0]
+  }
+  ret.Sessions= make([]SessionInfo,len(self.sessions))
+  ret.Tracks= make([]TrackInfo,num_tracks)
+  pos:= 0
+  for s,tracks:= range self.sessions {
+    beg_pos:= pos
+    for t:= range tracks {
+      track:= &tracks[t]
+      info:= &ret.Tracks[pos]
+      pos++
+      info.Id= BCD ( track.number )
+      info.Type= track.track_type
+      info.Cooked= track.data_offset > 0
+      info.Flags= track.control&0x0f
+      info.ISRC= track.isrc
+      if track.number < len(self.cdtext) {
+        info.CDText= self.cdtext[track.number]
+      }
+      if track.index0 != -1 {
+        info.Indexes= []IndexInfo{
+          {Id : 0, Pos : GetPosition ( track.index0 + 2*75 )},
+          {Id : 1, Pos : GetPosition ( track.index1 + 2*75 )},
+        }
+      } else {
+        info.Indexes= []IndexInfo{
+          {Id : 1, Pos : GetPosition ( track.index1 + 2*75 )},
+        }
+      }
+      info.PosLastSector= GetPosition ( track.index1 +
+        track.num_sectors - 1 + 2*75 )
+    }
+    ret.Sessions[s].Tracks= ret.Tracks[beg_pos:pos]
+  }
+
+  return &ret
+
+} // end Info
+
+
+func (self *_CD_Nrg) TrackReader(
+
+  session_id int,
+  track_id   int,
+  mode       int,
+
+) (TrackReader,error) {
+
+  if session_id < 0 || session_id >= len(self.sessions) {
+    return nil,fmt.Errorf ( "session (%d) out of range", session_id )
+  }
+  tracks:= self.sessions[session_id]
+  if track_id < 0 || track_id >= len(tracks) {
+    return nil,fmt.Errorf ( "track (%d) out of range", track_id )
+  }
+  t:= &tracks[track_id]
+
+  return newFileTrackReader ( self.file_name, t.offset, t.stride,
+    t.read_size, t.data_offset, t.num_sectors, t.index1 + 2*75,
+    t.track_type, mode )
+
+} // end TrackReader
+
+
+func (self *_CD_Nrg) PregapReader(
+
+  session_id int,
+  track_id   int,
+  mode       int,
+
+) (TrackReader,error) {
+
+  if session_id < 0 || session_id >= len(self.sessions) {
+    return nil,fmt.Errorf ( "session (%d) out of range", session_id )
+  }
+  tracks:= self.sessions[session_id]
+  if track_id < 0 || track_id >= len(tracks) {
+    return nil,fmt.Errorf ( "track (%d) out of range", track_id )
+  }
+  t:= &tracks[track_id]
+  if t.pregap == 0 { return nil,nil }
+
+  return newFileTrackReader ( self.file_name, t.offset-t.pregap*t.stride,
+    t.stride, t.read_size, t.data_offset, t.pregap, t.index0 + 2*75,
+    t.track_type, mode )
+
+} // end PregapReader
+
+
+
+
+/**********************/
+/* FUNCIONS PÚBLIQUES */
+/**********************/
+
+func OpenNrg( file_name string ) (CD,error) {
+
+  // Obri
+  f,err:= os.Open ( file_name )
+  if err != nil { return nil,err }
+  defer f.Close ()
+  size,err:= f.Seek ( 0, io.SeekEnd )
+  if err != nil { return nil,err }
+
+  // Peu
+  var footer [12]byte
+  if size < 12 {
+    return nil,fmt.Errorf ( "'%s' is not a NRG file", file_name )
+  }
+  if _,err:= f.ReadAt ( footer[:], size-12 ); err != nil {
+    return nil,err
+  }
+  ret:= _CD_Nrg{
+    file_name : file_name,
+  }
+  var offset int64
+  if string(footer[:4]) == "NER5" {
+    ret.version= 2
+    offset= int64(parse_int64_MSB ( footer[4:] ))
+  } else if string(footer[4:8]) == "NERO" {
+    ret.version= 1
+    offset= int64(parse_int32_MSB ( footer[8:] ))
+  } else {
+    return nil,fmt.Errorf ( "'%s' is not a NRG file", file_name )
+  }
+  if offset < 0 || offset >= size {
+    return nil,fmt.Errorf ( "wrong NRG chunks offset: %d", offset )
+  }
+
+  // Chunks
+  chunks,err:= readChunksNrg ( f, offset, size )
+  if err != nil { return nil,err }
+  if len(chunks.daos) > 0 {
+    err= ret.buildDao ( chunks )
+  } else if len(chunks.etns) > 0 {
+    err= ret.buildEtn ( chunks )
+  } else {
+    err= errors.New ( "NRG file without tracks" )
+  }
+  if err != nil { return nil,err }
+
+  // Comprova límits
+  for _,tracks:= range ret.sessions {
+    for _,t:= range tracks {
+      if t.offset+t.num_sectors*t.stride > offset {
+        return nil,fmt.Errorf ( "track %d out of NRG data", t.number )
+      }
+    }
+  }
+
+  // CD-Text (paquets de 18 bytes)
+  if len(chunks.cdtx) > 0 {
+    packs:= make([][]byte,0,len(chunks.cdtx)/18)
+    for i:= 0; i+18 <= len(chunks.cdtx); i+= 18 {
+      packs= append(packs,chunks.cdtx[i:i+18])
+    }
+    ret.cdtext= cdtext_parse_packs ( packs )
+  }
+
+  // Tracks CD-XA
+  if err:= ret.relabelCDXATracks (); err != nil {
+    return nil,err
+  }
+
+  return &ret,nil
+
+} // end OpenNrg
@@ -34,6 +34,16 @@ func Open( file_name string ) (CD,error) {
   // directament intentar llegir-los seguint el següent ordre.
   cd,err:= OpenMds ( file_name )
   if err == nil { return cd,nil }
+  cd,err= OpenCcd ( file_name )
+  if err == nil { return cd,nil }
+  cd,err= OpenNrg ( file_name )
+  if err == nil { return cd,nil }
+  cd,err= OpenCdi ( file_name )
+  if err == nil { return cd,nil }
+  cd,err= OpenGdi ( file_name )
+  if err == nil { return cd,nil }
+  cd,err= OpenEcm ( file_name )
+  if err == nil { return cd,nil }
   cd,err= OpenIso ( file_name )
   if err == nil { return cd,nil }
   cd,err= OpenCue ( file_name )
@@ -82,10 +82,12 @@ func parse_date_time( data []byte, dt *ISO_DateTime ) {
     dt.HSecond= string(data[14:16])
     empty= false
   }
-  // GMT Offset
-  tmp:= int(data[16])
-  if !empty || tmp != 0 {
-    dt.GMT= tmp-48
+  // GMT Offset (High Sierra no en té)
+  if len(data) > 16 {
+    tmp:= int(int8(data[16]))
+    if !empty || tmp != 0 {
+      dt.GMT= tmp
+    }
   }
   dt.Empty= empty
   
@@ -131,7 +133,11 @@ func parse_date_time_record( data []byte, dt *ISO_DateTimeRecord ) error {
     return fmt.Errorf ( "error while reading data and time record: "+
       "wrong second value (%d)", dt.Second )
   }
-  dt.GMT= int(data[6])-48
+  if len(data) > 6 { // High Sierra no té GMT
+    dt.GMT= int(int8(data[6]))
+  } else {
+    dt.GMT= 0
+  }
   
   return nil
   
@@ -154,6 +160,11 @@ func parse_int32_LSB_MSB(data []byte) uint32 {
 
 // ISO FILE ENTRY //////////////////////////////////////////////////////////////
 
+type _ISO_Extent struct {
+  offset uint32 // Logical block on comença
+  size   uint32
+}
+
 type _ISO_FileEntry struct {
   
   recording_date_time ISO_DateTimeRecord
@@ -164,10 +175,17 @@ type _ISO_FileEntry struct {
   gap_size            uint8 // Si interleave, grandària del gap
   volume              uint16
   id                  string
+  xa                  bool // Té l'extensió CD-XA en el System Use
+  xa_attr             uint16
+  xa_file             uint8
+  extents             []_ISO_Extent // Extents següents en fitxers
+                                    // multiextent
   
 }
 
-func (self *_ISO_FileEntry) read( data []byte ) error {
+// En High Sierra la data sols té 6 bytes i els flags estan en la
+// posició 24.
+func (self *_ISO_FileEntry) read( data []byte, high_sierra bool ) error {
 
   // Longitut
   if len(data) == 0 {
@@ -189,16 +207,20 @@ func (self *_ISO_FileEntry) read( data []byte ) error {
   self.size= parse_int32_LSB_MSB ( data[10:18] )
   
   // Recording Date and Time
-  if err:= parse_date_time_record ( data[18:25],
+  var date_end,flags_pos int
+  if high_sierra {
+    date_end,flags_pos= 24,24
+  } else {
+    date_end,flags_pos= 25,25
+  }
+  if err:= parse_date_time_record ( data[18:date_end],
     &self.recording_date_time ); err != nil {
     return err
   }
   
   // Flags
-  self.flags= uint8(data[25])
-  if (self.flags&FILE_FLAGS_MULTIEXTENT)!=0 {
-    return errors.New ( "TODO - Multiextent" )
-  }
+  self.flags= uint8(data[flags_pos])
+  self.extents= nil
   
   // Unit size i interlave
   self.file_unit_size= uint8(data[26])
@@ -223,12 +245,39 @@ func (self *_ISO_FileEntry) read( data []byte ) error {
   } else {
     self.id= string(data[33:33+file_size])
   }
+
+  // Extensió CD-XA. Està al principi del System Use, després del
+  // byte de farciment si l'identificador té grandària parell.
+  sys_use:= 33+int(file_size)
+  if file_size%2 == 0 {
+    sys_use++
+  }
+  self.xa= false
+  if int(len_dr) >= sys_use+14 && len(data) >= sys_use+14 &&
+    data[sys_use+6] == 'X' && data[sys_use+7] == 'A' {
+    self.xa= true
+    self.xa_attr= uint16(data[sys_use+4])<<8 | uint16(data[sys_use+5])
+    self.xa_file= data[sys_use+8]
+  }
   
   return nil
   
 } // end read
 
 
+// Grandària total del fitxer (incloent tots els extents).
+func (self *_ISO_FileEntry) totalSize() uint64 {
+
+  ret:= uint64(self.size)
+  for _,e:= range self.extents {
+    ret+= uint64(e.size)
+  }
+
+  return ret
+
+} // end totalSize
+
+
 // ISO FILE READER /////////////////////////////////////////////////////////////
 
 type _ISO_FileReader struct {
@@ -242,6 +291,7 @@ type _ISO_FileReader struct {
   current_file_lb uint8
   buf             []byte
   f               TrackReader
+  extents         []_ISO_Extent // Extents pendents (fitxers multiextent)
   
 }
 
@@ -272,6 +322,23 @@ func (self *_ISO_FileReader) loadBuf() error {
 } // end loadBuf
 
 
+// Passa al següent extent quan s'ha llegit l'actual. Torna fals si
+// no en queden.
+func (self *_ISO_FileReader) nextExtent() bool {
+
+  for self.remain == 0 {
+    if len(self.extents) == 0 { return false }
+    self.current_lb= self.extents[0].offset
+    self.remain= self.extents[0].size
+    self.extents= self.extents[1:]
+    self.buf= nil
+  }
+
+  return true
+  
+} // end nextExtent
+
+
 func (self *_ISO_FileReader) Close() error {
   return self.f.Close ()
 } // end Close
@@ -280,7 +347,7 @@ func (self *_ISO_FileReader) Close() error {
 func (self *_ISO_FileReader) Read( data []byte) (n int,err error) {
 
   // Prepara
-  if self.remain == 0 { return 0,io.EOF }
+  if !self.nextExtent () { return 0,io.EOF }
   n,err= 0,nil
   
   // Ignora offset
@@ -307,7 +374,7 @@ func (self *_ISO_FileReader) Read( data []byte) (n int,err error) {
   var nbytes uint32
   var buf_size uint32
   var want_size uint32
-  for len(data)>0 && self.remain>0 {
+  for len(data)>0 && self.nextExtent () {
 
     // Obté dades
     if len(self.buf)==0 {
@@ -442,6 +509,15 @@ type ISO_PrimaryVolume struct {
   
 }
 
+type ISO_BootRecord struct {
+
+  BootSystemIdentifier string
+  BootIdentifier       string
+  ElTorito             bool
+  CatalogSector        uint32 // Sols si ElTorito
+  
+}
+
 type ISO_SupplementaryVolume struct {
   ISO_PrimaryVolume
   
@@ -461,18 +537,34 @@ const (
   
 )
 
+// Atributs de l'extensió CD-XA
+const (
+
+  ISO_XA_ATTR_FORM1       = 0x0800
+  ISO_XA_ATTR_FORM2       = 0x1000
+  ISO_XA_ATTR_INTERLEAVED = 0x2000
+  ISO_XA_ATTR_CDDA        = 0x4000
+  ISO_XA_ATTR_DIRECTORY   = 0x8000
+  
+)
+
 // Segueix una aproximació greedy.
 type ISO struct {
 
   // Públic
   PrimaryVolume ISO_PrimaryVolume
   Supplementary *ISO_SupplementaryVolume // Pot ser nil
+  BootRecord    *ISO_BootRecord          // Pot ser nil
+  HighSierra    bool                     // Format High Sierra (previ
+                                         // a ISO 9660)
   
   // Privat
   cd          CD
   session     int
   track       int
   current_sec int64
+  base_sec    int64 // Sector absolut on comença el track si el
+                    // sistema de fitxers empra adreces absolutes
   buffer      [LOGICAL_SECTOR_SIZE]byte
   
 }
@@ -495,12 +587,46 @@ func ReadISO( cd CD, session int, track int ) (*ISO,error) {
   if err:= ret.readVolumeDescriptors ( f ); err != nil {
     return nil,err
   }
+  ret.computeBaseSector ()
   
   return &ret,nil
   
 } // end ReadISO
 
 
+// En discs multisessió (per exemple els de Dreamcast) el sistema de
+// fitxers d'una sessió que no és la primera empra adreces absolutes
+// del disc. Si el directori arrel apunta dins del track quan se li
+// resta la posició del track, s'assumeix que les adreces són
+// absolutes.
+func (self *ISO) computeBaseSector() {
+
+  // Posició del track
+  info:= self.cd.Info ()
+  if self.session < 0 || self.session >= len(info.Sessions) ||
+    self.track < 0 || self.track >= len(info.Sessions[self.session].Tracks) {
+    return
+  }
+  track:= &info.Sessions[self.session].Tracks[self.track]
+  var i int
+  for i= 0; i < len(track.Indexes) && track.Indexes[i].Id != 1; i++ {
+  }
+  if i == len(track.Indexes) { return }
+  beg:= GetSectorIndex ( track.Indexes[i].Pos ) - 2*75
+  end:= GetSectorIndex ( track.PosLastSector ) - 2*75
+  if beg <= 0 { return }
+
+  // Directori arrel
+  root_sec:= int64(parse_int32_LSB_MSB (
+    self.PrimaryVolume.root_dir_record[2:6] ))/
+    int64(self.PrimaryVolume.blocks_per_sec)
+  if root_sec >= beg && root_sec <= end {
+    self.base_sec= beg
+  }
+  
+} // end computeBaseSector
+
+
 func (self *ISO) readVolumeDescriptors( f TrackReader ) error {
 
   var buf [LOGICAL_SECTOR_SIZE]byte
@@ -518,21 +644,41 @@ func (self *ISO) readVolumeDescriptors( f TrackReader ) error {
         sector )
     }
     
+    // Comprova si és High Sierra (sols el primer descriptor)
+    if sector == 0x10 {
+      self.HighSierra= buf[9]=='C' && buf[10]=='D' && buf[11]=='R' &&
+        buf[12]=='O' && buf[13]=='M'
+    }
+    
     // Comprova tipus
-    switch dtype:= buf[0]; dtype {
+    var dtype uint8
+    if self.HighSierra {
+      dtype= buf[8]
+    } else {
+      dtype= buf[0]
+    }
+    switch dtype {
     case 0: // Boot record
-      if err:= self.readBootRecord ( buf[:] ); err != nil {
+      if self.HighSierra {
+        log.Printf ( "High Sierra boot record ignored" )
+      } else if err:= self.readBootRecord ( buf[:] ); err != nil {
         return err
       }
     case 1: // Primary volume (NOTA!! Com a mínim cal 1)
       if num_pv == 0 {
         num_pv= 1
-        if err:= self.readPrimaryVolume ( buf[:] ); err != nil {
-          return err
+        var err error
+        if self.HighSierra {
+          err= self.readPrimaryVolumeHighSierra ( buf[:] )
+        } else {
+          err= self.readPrimaryVolume ( buf[:] )
         }
+        if err != nil { return err }
       }
     case 2: // Supplementary volume
-      if num_sv == 0 {
+      if self.HighSierra {
+        log.Printf ( "High Sierra secondary volume descriptor ignored" )
+      } else if num_sv == 0 {
         num_sv= 1
         if err:= self.readSupplementaryVolume ( buf[:] ); err != nil {
           return err
@@ -566,10 +712,22 @@ func (self *ISO) readBootRecord( data []byte ) error {
   if data[1]!='C' || data[2]!='D' || data[3]!='0' ||
     data[4]!='0' || data[5]!='1' {
     return errors.New ( "Volume descriptor signature 'CD001' not "+
-      "found in primary descriptor" )
+      "found in boot record" )
   }
 
-  return errors.New ( "TODO - BOOT RECORD" )
+  // Sols es llig el primer
+  if self.BootRecord != nil { return nil }
+  br:= ISO_BootRecord{
+    BootSystemIdentifier : strings.TrimRight ( string(data[7:39]), "\x00 " ),
+    BootIdentifier : strings.TrimRight ( string(data[39:71]), "\x00 " ),
+  }
+  if br.BootSystemIdentifier == "EL TORITO SPECIFICATION" {
+    br.ElTorito= true
+    br.CatalogSector= parse_int32_LSB_MSB ( data[71:75] )
+  }
+  self.BootRecord= &br
+  
+  return nil
   
 } // end readBootRecord
 
@@ -639,6 +797,73 @@ func (self *ISO) readPrimaryVolume( data []byte ) error {
 } // end readPrimaryVolume
 
 
+// El descriptor High Sierra comença amb 8 bytes (LBN del descriptor)
+// abans del tipus, i la resta de camps estan desplaçats respecte a
+// ISO 9660. Les dates no tenen GMT.
+func (self *ISO) readPrimaryVolumeHighSierra( data []byte ) error {
+
+  // Signatura
+  if data[9]!='C' || data[10]!='D' || data[11]!='R' ||
+    data[12]!='O' || data[13]!='M' {
+    return errors.New ( "Volume descriptor signature 'CDROM' not "+
+      "found in primary descriptor" )
+  }
+
+  // Versió
+  self.PrimaryVolume.Version= uint8(data[14])
+
+  // Identificadors
+  self.PrimaryVolume.SystemIdentifier=
+    strings.TrimRight ( string(data[16:48]), " " )
+  self.PrimaryVolume.VolumeIdentifier=
+    strings.TrimRight ( string(data[48:80]), " " )
+
+  // Grandàries
+  self.PrimaryVolume.VolumeSpaceSize= parse_int32_LSB_MSB ( data[88:96] )
+  self.PrimaryVolume.VolumeSetSize= parse_int16_LSB_MSB ( data[128:132] )
+  self.PrimaryVolume.VolumeSequenceNumber= parse_int16_LSB_MSB ( data[132:136] )
+  self.PrimaryVolume.LogicalBlockSize= parse_int16_LSB_MSB ( data[136:140] )
+  if self.PrimaryVolume.LogicalBlockSize<512 ||
+    self.PrimaryVolume.LogicalBlockSize>LOGICAL_SECTOR_SIZE ||
+    LOGICAL_SECTOR_SIZE%self.PrimaryVolume.LogicalBlockSize!=0 {
+    return fmt.Errorf ( "wrong Logical Block Size: %d",
+      self.PrimaryVolume.LogicalBlockSize )
+  }
+  self.PrimaryVolume.blocks_per_sec= 
+    LOGICAL_SECTOR_SIZE/int(uint32(self.PrimaryVolume.LogicalBlockSize))
+
+  // Root directory record
+  copy(self.PrimaryVolume.root_dir_record[:],data[180:214])
+  
+  // Més identificadors
+  self.PrimaryVolume.VolumeSetIdentifier=
+    strings.TrimRight ( string(data[214:342]), " " )
+  self.PrimaryVolume.PublisherIdentifier=
+    strings.TrimRight ( string(data[342:470]), " " )
+  self.PrimaryVolume.DataPreparerIdentifier=
+    strings.TrimRight ( string(data[470:598]), " " )
+  self.PrimaryVolume.ApplicationIdentifier=
+    strings.TrimRight ( string(data[598:726]), " " )
+  self.PrimaryVolume.CopyrightFileIdentifier=
+    strings.TrimRight ( string(data[726:758]), " \x00" )
+  self.PrimaryVolume.AbstractFileIdentifier=
+    strings.TrimRight ( string(data[758:790]), " \x00" )
+  self.PrimaryVolume.BiblioFileIdentifier= ""
+
+  // Dates
+  parse_date_time ( data[790:806], &self.PrimaryVolume.VolumeCreation )
+  parse_date_time ( data[806:822], &self.PrimaryVolume.VolumeModification )
+  parse_date_time ( data[822:838], &self.PrimaryVolume.VolumeExpiration )
+  parse_date_time ( data[838:854], &self.PrimaryVolume.VolumeEffective )
+
+  // FileStructureVersion
+  self.PrimaryVolume.FileStructureVersion= uint8(data[854])
+  
+  return nil
+  
+} // end readPrimaryVolumeHighSierra
+
+
 func (self *ISO) readSupplementaryVolume( data []byte ) error {
 
   // Signatura
@@ -780,6 +1005,44 @@ func (self *ISO) getFileReader(
 } // end getFileReader
 
 
+// Torna cert si el track és CD-XA.
+func (self *ISO) isCDXA() bool {
+
+  info:= self.cd.Info ()
+  if self.session >= len(info.Sessions) ||
+    self.track >= len(info.Sessions[self.session].Tracks) {
+    return false
+  }
+
+  return info.Sessions[self.session].Tracks[self.track].Type ==
+    TRACK_TYPE_MODE2_CDXA_RAW
+  
+} // end isCDXA
+
+
+// Torna un lector de sectors RAW (MODE_RAW) situat al principi del
+// fitxer i el nombre de sectors del fitxer.
+func (self *ISO) getRawSectorReader(
+
+  entry *_ISO_FileEntry,
+
+) (TrackReader,int64,error) {
+
+  f,err:= self.cd.TrackReader ( self.session, self.track, MODE_RAW )
+  if err != nil { return nil,-1,err }
+  sector:= int64(entry.offset/uint32(self.PrimaryVolume.blocks_per_sec)) -
+    self.base_sec
+  if err:= f.Seek ( sector ); err != nil {
+    f.Close ()
+    return nil,-1,err
+  }
+  nsectors:= (int64(entry.size)+LOGICAL_SECTOR_SIZE-1)/LOGICAL_SECTOR_SIZE
+  
+  return f,nsectors,nil
+  
+} // end getRawSectorReader
+
+
 // Torna un punter al logical block llegit
 func (self *ISO) readLogicalBlock(
 
@@ -790,7 +1053,8 @@ func (self *ISO) readLogicalBlock(
 
   
   // Llig sector
-  sector:= int64(logical_block/uint32(self.PrimaryVolume.blocks_per_sec))
+  sector:= int64(logical_block/uint32(self.PrimaryVolume.blocks_per_sec)) -
+    self.base_sec
   if sector != self.current_sec {
     if err:= f.Seek ( sector ); err != nil {
       return nil,err
@@ -817,7 +1081,8 @@ func (self *ISO) readLogicalBlock(
 func (self *ISO) Root() (*ISO_Directory,error) {
 
   var entry _ISO_FileEntry
-  if err:= entry.read ( self.PrimaryVolume.root_dir_record[:] ); err != nil {
+  if err:= entry.read ( self.PrimaryVolume.root_dir_record[:],
+    self.HighSierra ); err != nil {
     return nil,err
   }
   
@@ -841,8 +1106,11 @@ func (self *ISO_Directory) Begin() (*ISO_DirectoryIter,error) {
     dir : self,
     p : self.content,
   }
-  if err:= ret.e.read ( ret.p ); err != nil {
-    return nil,err
+  ret.skipPadding ()
+  if !ret.End () {
+    if err:= ret.readEntry (); err != nil {
+      return nil,err
+    }
   }
   
   return &ret,nil
@@ -896,14 +1164,57 @@ func (self *ISO_DirectoryIter) GetDirectory() (*ISO_Directory,error) {
 
 
 func (self *ISO_DirectoryIter) GetFileReader() (*_ISO_FileReader,error) {
-  return self.dir.iso.getFileReader ( self.e.offset, self.e.size, 0,
+
+  ret,err:= self.dir.iso.getFileReader ( self.e.offset, self.e.size, 0,
     self.e.file_unit_size, self.e.gap_size )
+  if err != nil { return nil,err }
+  ret.extents= self.e.extents
+
+  return ret,nil
+  
 } // end GetFileReader
 
 
 func (self *ISO_DirectoryIter) Id() string { return self.e.id }
 
 
+// Torna cert si el fitxer conté sectors d'àudio CD-XA (Form 2 o
+// entrellaçat) i el track permet llegir sectors RAW.
+func (self *ISO_DirectoryIter) IsXAAudio() bool {
+  return self.e.xa &&
+    self.e.xa_attr&(ISO_XA_ATTR_FORM2|ISO_XA_ATTR_INTERLEAVED) != 0 &&
+    self.e.flags&FILE_FLAGS_DIRECTORY == 0 &&
+    self.dir.iso.isCDXA ()
+} // end IsXAAudio
+
+
+// Torna els canals d'àudio CD-XA del fitxer.
+func (self *ISO_DirectoryIter) GetXAChannels() ([]XA_Channel,error) {
+
+  f,nsectors,err:= self.dir.iso.getRawSectorReader ( &self.e )
+  if err != nil { return nil,err }
+  defer f.Close ()
+
+  return scanXAChannels ( f, nsectors )
+  
+} // end GetXAChannels
+
+
+// Torna un lector de l'àudio descodificat d'un canal CD-XA.
+func (self *ISO_DirectoryIter) GetXAReader(
+
+  channel *XA_Channel,
+
+) (*XA_Reader,error) {
+
+  f,nsectors,err:= self.dir.iso.getRawSectorReader ( &self.e )
+  if err != nil { return nil,err }
+  
+  return newXAReader ( f, nsectors, channel ),nil
+  
+} // end GetXAReader
+
+
 func (self *ISO_DirectoryIter) Next() error {
   
   if self.End () {
@@ -912,8 +1223,9 @@ func (self *ISO_DirectoryIter) Next() error {
   
   // Mou al següent
   self.p= self.p[uint8(self.p[0]):]
+  self.skipPadding ()
   if !self.End () {
-    if err:= self.e.read ( self.p ); err != nil {
+    if err:= self.readEntry (); err != nil {
       return err
     }
   }
@@ -923,4 +1235,59 @@ func (self *ISO_DirectoryIter) Next() error {
 } // end Next
 
 
-func (self *ISO_DirectoryIter) Size() uint32 { return self.e.size }
+// Llig l'entrada actual. En fitxers multiextent es lligen tots els
+// registres del fitxer i l'iterador es queda en l'últim.
+func (self *ISO_DirectoryIter) readEntry() error {
+
+  high_sierra:= self.dir.iso.HighSierra
+  if err:= self.e.read ( self.p, high_sierra ); err != nil {
+    return err
+  }
+  flags:= self.e.flags
+  for flags&FILE_FLAGS_MULTIEXTENT != 0 {
+    self.p= self.p[uint8(self.p[0]):]
+    self.skipPadding ()
+    if self.End () {
+      return fmt.Errorf ( "last extent of multiextent file '%s' not found",
+        self.e.id )
+    }
+    var tmp _ISO_FileEntry
+    if err:= tmp.read ( self.p, high_sierra ); err != nil {
+      return err
+    }
+    if tmp.id != self.e.id {
+      return fmt.Errorf ( "last extent of multiextent file '%s' not found",
+        self.e.id )
+    }
+    self.e.extents= append(self.e.extents,_ISO_Extent{
+      offset : tmp.offset,
+      size : tmp.size,
+    })
+    flags= tmp.flags
+  }
+  self.e.flags&^= FILE_FLAGS_MULTIEXTENT
+  
+  return nil
+  
+} // end readEntry
+
+
+func (self *ISO_DirectoryIter) Size() uint64 { return self.e.totalSize () }
+
+
+// Els registres no poden creuar la frontera d'un bloc lògic, per tant
+// el final de cada bloc pot estar farcit de zeros.
+func (self *ISO_DirectoryIter) skipPadding() {
+
+  bsize:= int(self.dir.iso.PrimaryVolume.LogicalBlockSize)
+  for len(self.p)>0 && self.p[0]==0 {
+    pos:= len(self.dir.content)-len(self.p)
+    next:= (pos/bsize+1)*bsize
+    if next >= len(self.dir.content) {
+      self.p= nil
+    } else {
+      self.p= self.dir.content[next:]
+    }
+  }
+  
+} // end skipPadding
@@ -0,0 +1,1350 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  read_udf.go - Funcions per llegir tracks de CD/DVD/BD en format
+ *                UDF (1.02 - 2.60).
+ */
+
+package cdread
+
+import (
+  "errors"
+  "fmt"
+  "io"
+  "log"
+  "strings"
+  "unicode/utf16"
+)
+
+
+
+
+/****************/
+/* PART PRIVADA */
+/****************/
+
+// Identificadors de descriptors (tags).
+const (
+  _UDF_TAG_PVD  = 1
+  _UDF_TAG_AVDP = 2
+  _UDF_TAG_VDP  = 3
+  _UDF_TAG_PD   = 5
+  _UDF_TAG_LVD  = 6
+  _UDF_TAG_TD   = 8
+  _UDF_TAG_FSD  = 256
+  _UDF_TAG_FID  = 257
+  _UDF_TAG_AED  = 258
+  _UDF_TAG_IE   = 259
+  _UDF_TAG_FE   = 261
+  _UDF_TAG_EFE  = 266
+)
+
+// Tipus de descriptors d'assignació (bits 0-2 dels flags de l'ICB).
+const (
+  _UDF_AD_SHORT    = 0
+  _UDF_AD_LONG     = 1
+  _UDF_AD_EXT      = 2
+  _UDF_AD_EMBEDDED = 3
+)
+
+// Tipus d'extents (bits 30-31 de la longitud).
+const (
+  _UDF_EXTENT_RECORDED       = 0
+  _UDF_EXTENT_NOT_RECORDED   = 1
+  _UDF_EXTENT_NOT_ALLOCATED  = 2
+  _UDF_EXTENT_NEXT           = 3
+)
+
+// Tipus de fitxers de l'ICB.
+const (
+  _UDF_FILE_TYPE_DIRECTORY = 4
+  _UDF_FILE_TYPE_REGULAR   = 5
+)
+
+// Límit per evitar bucles en cadenes d'entrades indirectes o de
+// descriptors d'assignació.
+const _UDF_MAX_CHAIN = 1024
+
+
+// FUNCIONS ////////////////////////////////////////////////////////////////////
+
+func parse_int16_LSB( data []byte ) uint16 {
+  return uint16(data[0]) | (uint16(data[1])<<8)
+} // end parse_int16_LSB
+
+
+func parse_int32_LSB( data []byte ) uint32 {
+  return uint32(data[0]) |
+    (uint32(data[1])<<8) |
+    (uint32(data[2])<<16) |
+    (uint32(data[3])<<24)
+} // end parse_int32_LSB
+
+
+func parse_int64_LSB( data []byte ) uint64 {
+  return uint64(parse_int32_LSB ( data[:4] )) |
+    (uint64(parse_int32_LSB ( data[4:8] ))<<32)
+} // end parse_int64_LSB
+
+
+// Comprova la capçalera (tag) d'un descriptor. Torna l'identificador.
+func udf_parse_tag( data []byte ) (uint16,error) {
+
+  if len(data) < 16 {
+    return 0,errors.New ( "UDF descriptor tag too short" )
+  }
+
+  // Checksum
+  var sum uint8= 0
+  for i:= 0; i < 16; i++ {
+    if i != 4 { sum+= data[i] }
+  }
+  if sum != data[4] {
+    return 0,errors.New ( "UDF descriptor tag checksum mismatch" )
+  }
+
+  return parse_int16_LSB ( data[0:2] ),nil
+
+} // end udf_parse_tag
+
+
+// Comprova que la capçalera és vàlida i té l'identificador indicat.
+func udf_check_tag( data []byte, id uint16 ) error {
+
+  tag_id,err:= udf_parse_tag ( data )
+  if err != nil { return err }
+  if tag_id != id {
+    return fmt.Errorf ( "unexpected UDF descriptor: expected tag %d but"+
+      " found %d", id, tag_id )
+  }
+
+  return nil
+
+} // end udf_check_tag
+
+
+// Descodifica una seqüència de caràcters OSTA CS0 (el primer byte és
+// l'identificador de compressió).
+func udf_decode_chars( data []byte ) string {
+
+  if len(data) == 0 { return "" }
+  switch data[0] {
+  case 8, 254:
+    ret:= make([]rune,len(data)-1)
+    for i,c:= range data[1:] {
+      ret[i]= rune(c)
+    }
+    return string(ret)
+  case 16, 255:
+    tmp:= make([]uint16,(len(data)-1)/2)
+    for i:= range tmp {
+      tmp[i]= (uint16(data[1+2*i])<<8) | uint16(data[2+2*i])
+    }
+    return string(utf16.Decode ( tmp ))
+  default:
+    log.Printf ( "unknown UDF compression identifier: %d", data[0] )
+    return ""
+  }
+
+} // end udf_decode_chars
+
+
+// Descodifica un camp dstring (l'últim byte és la longitud).
+func udf_decode_dstring( data []byte ) string {
+
+  length:= int(data[len(data)-1])
+  if length == 0 || length >= len(data) { return "" }
+
+  return udf_decode_chars ( data[:length] )
+
+} // end udf_decode_dstring
+
+
+// Descodifica l'identificador d'un regid (entity identifier).
+func udf_decode_regid( data []byte ) string {
+  return strings.TrimRight ( string(data[1:24]), "\x00 " )
+} // end udf_decode_regid
+
+
+func udf_parse_timestamp( data []byte, ts *UDF_Timestamp ) {
+
+  // Comprova si està buit
+  ts.Empty= true
+  for _,v:= range data[:12] {
+    if v != 0 {
+      ts.Empty= false
+      break
+    }
+  }
+  if ts.Empty { return }
+
+  // Zona horària (12 bits amb signe)
+  tz:= int(parse_int16_LSB ( data[0:2] )&0xfff)
+  if tz >= 0x800 { tz-= 0x1000 }
+  if tz == -2047 {
+    ts.TimeZone= 0
+    ts.TimeZoneOk= false
+  } else {
+    ts.TimeZone= tz
+    ts.TimeZoneOk= true
+  }
+
+  // Data i hora
+  ts.Year= int(int16(parse_int16_LSB ( data[2:4] )))
+  ts.Month= data[4]
+  ts.Day= data[5]
+  ts.Hour= data[6]
+  ts.Minute= data[7]
+  ts.Second= data[8]
+  ts.Centiseconds= data[9]
+
+} // end udf_parse_timestamp
+
+
+// EXTENTS /////////////////////////////////////////////////////////////////////
+
+type _UDF_Extent struct {
+
+  etype  uint8
+  length uint32 // Bytes
+  lb     uint32 // Bloc lògic dins de la partició
+  part   uint16 // Referència de partició
+
+}
+
+
+type _UDF_LongAD struct {
+
+  length uint32
+  lb     uint32
+  part   uint16
+
+}
+
+
+func (self *_UDF_LongAD) read( data []byte ) {
+  self.length= parse_int32_LSB ( data[0:4] )&0x3FFFFFFF
+  self.lb= parse_int32_LSB ( data[4:8] )
+  self.part= parse_int16_LSB ( data[8:10] )
+} // end read
+
+
+// PARTICIONS //////////////////////////////////////////////////////////////////
+
+type _UDF_PartitionMap struct {
+
+  ptype  int
+  number uint16
+  start  uint32 // Sector inicial (sols físiques)
+  length uint32 // Blocs (sols físiques)
+
+  // Sparable
+  packet_length uint32
+  sparing       map[uint32]uint32 // Paquet original -> sector físic
+
+  // Metadata
+  phys_ref     uint16 // Referència a la partició física
+  meta_file    uint32
+  meta_mirror  uint32
+  meta_extents []_UDF_Extent
+
+}
+
+
+// FILE ENTRY //////////////////////////////////////////////////////////////////
+
+type _UDF_FileEntry struct {
+
+  file_type    uint8
+  size         uint64
+  modification UDF_Timestamp
+  extents      []_UDF_Extent
+  embedded     []byte // nil si les dades no estan incrustades
+
+}
+
+
+// FILE IDENTIFIER /////////////////////////////////////////////////////////////
+
+type _UDF_FileId struct {
+
+  characteristics uint8
+  name            string
+  icb             _UDF_LongAD
+
+}
+
+
+// UDF FILE READER /////////////////////////////////////////////////////////////
+
+type _UDF_FileReader struct {
+
+  udf      *UDF
+  f        TrackReader
+  extents  []_UDF_Extent
+  current  int    // Extent actual
+  ext_pos  uint32 // Bytes consumits de l'extent actual
+  remain   uint64 // Bytes per llegir
+  buf      []byte
+  sector   [LOGICAL_SECTOR_SIZE]byte
+  next_sec int64
+
+}
+
+
+func (self *_UDF_FileReader) loadBuf() error {
+
+  // Busca el següent extent amb dades
+  for self.current < len(self.extents) &&
+    self.ext_pos >= self.extents[self.current].length {
+    self.current++
+    self.ext_pos= 0
+  }
+  if self.current >= len(self.extents) {
+    return errors.New ( "unexpected end of UDF file data" )
+  }
+  ext:= &self.extents[self.current]
+
+  // Llig el bloc
+  nbytes:= ext.length-self.ext_pos
+  if nbytes > LOGICAL_SECTOR_SIZE { nbytes= LOGICAL_SECTOR_SIZE }
+  if ext.etype == _UDF_EXTENT_RECORDED {
+    sector,err:= self.udf.getSector ( ext.part,
+      ext.lb+self.ext_pos/LOGICAL_SECTOR_SIZE )
+    if err != nil { return err }
+    if err:= self.udf.readSector ( self.f, sector, self.sector[:],
+      &self.next_sec ); err != nil {
+      return err
+    }
+  } else {
+    for i:= range self.sector { self.sector[i]= 0 }
+  }
+  self.buf= self.sector[:nbytes]
+  self.ext_pos+= nbytes
+
+  return nil
+
+} // end loadBuf
+
+
+func (self *_UDF_FileReader) Close() error {
+  return self.f.Close ()
+} // end Close
+
+
+func (self *_UDF_FileReader) Read( data []byte ) (n int,err error) {
+
+  // Prepara
+  if self.remain == 0 { return 0,io.EOF }
+  n,err= 0,nil
+
+  // Llig
+  var nbytes uint64
+  for len(data)>0 && self.remain>0 {
+
+    // Obté dades
+    if len(self.buf)==0 {
+      err= self.loadBuf ()
+      if err != nil { return }
+    }
+
+    // Bytes a llegir
+    nbytes= uint64(len(self.buf))
+    if self.remain<nbytes { nbytes= self.remain }
+    if uint64(len(data))<nbytes { nbytes= uint64(len(data)) }
+
+    // Llig
+    copy(data[:nbytes],self.buf[:nbytes])
+    data= data[nbytes:]
+    self.buf= self.buf[nbytes:]
+    self.remain-= nbytes
+    n+= int(nbytes)
+
+  }
+
+  return
+
+} // end Read
+
+
+
+
+/****************/
+/* PART PÚBLICA */
+/****************/
+
+// UDF /////////////////////////////////////////////////////////////////////////
+
+const (
+  UDF_PARTITION_TYPE_PHYSICAL = 0
+  UDF_PARTITION_TYPE_SPARABLE = 1
+  UDF_PARTITION_TYPE_METADATA = 2
+)
+
+const (
+  UDF_FILE_CHAR_HIDDEN    = 0x01
+  UDF_FILE_CHAR_DIRECTORY = 0x02
+  UDF_FILE_CHAR_DELETED   = 0x04
+  UDF_FILE_CHAR_PARENT    = 0x08
+  UDF_FILE_CHAR_METADATA  = 0x10
+)
+
+type UDF_Timestamp struct {
+
+  Year         int
+  Month        uint8
+  Day          uint8
+  Hour         uint8
+  Minute       uint8
+  Second       uint8
+  Centiseconds uint8
+  TimeZone     int  // Minuts respecte UTC
+  TimeZoneOk   bool // Fals si no s'especifica la zona horària
+  Empty        bool
+
+}
+
+type UDF_PrimaryVolume struct {
+
+  VolumeIdentifier         string
+  VolumeSequenceNumber     uint16
+  MaxVolumeSequenceNumber  uint16
+  VolumeSetIdentifier      string
+  ApplicationIdentifier    string
+  ImplementationIdentifier string
+  RecordingDateTime        UDF_Timestamp
+
+}
+
+type UDF_LogicalVolume struct {
+
+  LogicalVolumeIdentifier  string
+  LogicalBlockSize         uint32
+  DomainIdentifier         string
+  UDFRevision              uint16 // En BCD (0x0102, 0x0250, ...)
+  ImplementationIdentifier string
+
+}
+
+type UDF_Partition struct {
+
+  Type     int
+  Number   uint16
+  Contents string // +NSR02, +NSR03
+  Start    uint32 // Sector inicial de la partició física
+  Length   uint32 // Grandària de la partició física en blocs
+
+}
+
+type UDF_FileSet struct {
+
+  RecordingDateTime       UDF_Timestamp
+  LogicalVolumeIdentifier string
+  FileSetIdentifier       string
+  CopyrightFileIdentifier string
+  AbstractFileIdentifier  string
+
+}
+
+type UDF struct {
+
+  // Públic
+  PrimaryVolume UDF_PrimaryVolume
+  LogicalVolume UDF_LogicalVolume
+  Partitions    []UDF_Partition // Una per cada mapa de partició
+  FileSet       UDF_FileSet
+
+  // Privat
+  cd       CD
+  session  int
+  track    int
+  maps     []_UDF_PartitionMap
+  root_icb _UDF_LongAD
+
+}
+
+
+func ReadUDF( cd CD, session int, track int ) (*UDF,error) {
+
+  ret:= UDF{
+    cd : cd,
+    session : session,
+    track : track,
+  }
+
+  // Obté la grandària del track
+  info:= cd.Info ()
+  if session < 0 || session >= len(info.Sessions) {
+    return nil,fmt.Errorf ( "session (%d) out of range", session )
+  }
+  if track < 0 || track >= len(info.Sessions[session].Tracks) {
+    return nil,fmt.Errorf ( "track (%d) out of range", track )
+  }
+  tinfo:= &info.Sessions[session].Tracks[track]
+  var i int
+  for i= 0; i < len(tinfo.Indexes) && tinfo.Indexes[i].Id != 1; i++ {
+  }
+  num_sectors:= int64(0)
+  if i < len(tinfo.Indexes) {
+    num_sectors= GetSectorIndex ( tinfo.PosLastSector ) -
+      GetSectorIndex ( tinfo.Indexes[i].Pos ) + 1
+  }
+
+  // Llig estructures
+  f,err:= cd.TrackReader ( session, track, 0 )
+  if err != nil { return nil,err }
+  defer f.Close ()
+  if err:= ret.checkVolumeRecognitionSequence ( f ); err != nil {
+    return nil,err
+  }
+  if err:= ret.readVolumeDescriptors ( f, num_sectors ); err != nil {
+    return nil,err
+  }
+  if err:= ret.readFileSetDescriptor ( f ); err != nil {
+    return nil,err
+  }
+
+  return &ret,nil
+
+} // end ReadUDF
+
+
+// Torna el sector físic (dins del track) d'un bloc lògic d'una
+// partició.
+func (self *UDF) getSector( part uint16, lb uint32 ) (int64,error) {
+
+  if int(part) >= len(self.maps) {
+    return -1,fmt.Errorf ( "UDF partition reference (%d) out of range", part )
+  }
+  m:= &self.maps[part]
+  switch m.ptype {
+
+  case UDF_PARTITION_TYPE_SPARABLE:
+    if m.packet_length > 0 {
+      packet:= lb-lb%m.packet_length
+      if mapped,ok:= m.sparing[packet]; ok {
+        return int64(mapped)+int64(lb-packet),nil
+      }
+    }
+    fallthrough
+
+  case UDF_PARTITION_TYPE_PHYSICAL:
+    if lb >= m.length {
+      return -1,fmt.Errorf ( "logical block (%d) out of UDF partition %d",
+        lb, m.number )
+    }
+    return int64(m.start)+int64(lb),nil
+
+  case UDF_PARTITION_TYPE_METADATA:
+    offset:= uint64(lb)*LOGICAL_SECTOR_SIZE
+    for _,ext:= range m.meta_extents {
+      ext_size:= ((uint64(ext.length)+LOGICAL_SECTOR_SIZE-1)/
+        LOGICAL_SECTOR_SIZE)*LOGICAL_SECTOR_SIZE
+      if offset < ext_size {
+        if ext.etype != _UDF_EXTENT_RECORDED {
+          return -1,fmt.Errorf ( "logical block (%d) of UDF metadata"+
+            " partition is not recorded", lb )
+        }
+        return self.getSector ( ext.part,
+          ext.lb+uint32(offset/LOGICAL_SECTOR_SIZE) )
+      }
+      offset-= ext_size
+    }
+    return -1,fmt.Errorf ( "logical block (%d) out of UDF metadata"+
+      " partition", lb )
+
+  default:
+    return -1,fmt.Errorf ( "unsupported UDF partition type: %d", m.ptype )
+  }
+
+} // end getSector
+
+
+// Llig un sector. Si next_sec no és nil s'empra per a evitar moure
+// el lector quan els sectors són consecutius.
+func (self *UDF) readSector(
+
+  f        TrackReader,
+  sector   int64,
+  buf      []byte,
+  next_sec *int64,
+
+) error {
+
+  if next_sec == nil || *next_sec != sector {
+    if err:= f.Seek ( sector ); err != nil {
+      return err
+    }
+  }
+  if nb,err:= f.Read ( buf[:LOGICAL_SECTOR_SIZE] ); err != nil {
+    if next_sec != nil { *next_sec= -1 }
+    return err
+  } else if nb != LOGICAL_SECTOR_SIZE {
+    if next_sec != nil { *next_sec= -1 }
+    return fmt.Errorf ( "failed to read sector %d", sector )
+  }
+  if next_sec != nil { *next_sec= sector+1 }
+
+  return nil
+
+} // end readSector
+
+
+func (self *UDF) readBlock(
+
+  f    TrackReader,
+  part uint16,
+  lb   uint32,
+  buf  []byte,
+
+) error {
+
+  sector,err:= self.getSector ( part, lb )
+  if err != nil { return err }
+
+  return self.readSector ( f, sector, buf, nil )
+
+} // end readBlock
+
+
+// Comprova que existeix una seqüència de reconeixement de volum amb
+// un descriptor NSR02 o NSR03.
+func (self *UDF) checkVolumeRecognitionSequence( f TrackReader ) error {
+
+  var buf [LOGICAL_SECTOR_SIZE]byte
+  for sector:= int64(16); sector < 16+64; sector++ {
+    if err:= self.readSector ( f, sector, buf[:], nil ); err != nil {
+      return err
+    }
+    switch id:= string(buf[1:6]); id {
+    case "NSR02", "NSR03":
+      return nil
+    case "BEA01", "TEA01", "CD001", "CDW02", "BOOT2":
+    default:
+      return errors.New ( "UDF volume recognition sequence not found" )
+    }
+  }
+
+  return errors.New ( "UDF volume recognition sequence not found" )
+
+} // end checkVolumeRecognitionSequence
+
+
+// Busca l'Anchor Volume Descriptor Pointer i llig la seqüència de
+// descriptors de volum (principal o reserva).
+func (self *UDF) readVolumeDescriptors(
+
+  f           TrackReader,
+  num_sectors int64,
+
+) error {
+
+  // Busca l'anchor
+  var buf [LOGICAL_SECTOR_SIZE]byte
+  locations:= []int64{256}
+  if num_sectors > 256 {
+    locations= append(locations,num_sectors-1,num_sectors-256)
+  }
+  locations= append(locations,512)
+  found:= false
+  for _,sector:= range locations {
+    if err:= self.readSector ( f, sector, buf[:], nil ); err != nil {
+      continue
+    }
+    if udf_check_tag ( buf[:], _UDF_TAG_AVDP ) == nil {
+      found= true
+      break
+    }
+  }
+  if !found {
+    return errors.New ( "UDF anchor volume descriptor pointer not found" )
+  }
+  main_len:= parse_int32_LSB ( buf[16:20] )
+  main_loc:= parse_int32_LSB ( buf[20:24] )
+  res_len:= parse_int32_LSB ( buf[24:28] )
+  res_loc:= parse_int32_LSB ( buf[28:32] )
+
+  // Llig seqüència
+  err:= self.readVolumeDescriptorSequence ( f, main_loc, main_len )
+  if err != nil {
+    log.Printf ( "failed to read main UDF volume descriptor sequence (%s),"+
+      " trying the reserve sequence", err )
+    err= self.readVolumeDescriptorSequence ( f, res_loc, res_len )
+  }
+
+  return err
+
+} // end readVolumeDescriptors
+
+
+func (self *UDF) readVolumeDescriptorSequence(
+
+  f      TrackReader,
+  loc    uint32,
+  length uint32,
+
+) error {
+
+  var buf [LOGICAL_SECTOR_SIZE]byte
+  var lvd []byte
+  pds:= make(map[uint16][]byte)
+  pds_vdsn:= make(map[uint16]uint32)
+  pvd_vdsn,lvd_vdsn,num_pvd:= uint32(0),uint32(0),0
+  sector,end:= int64(loc),int64(loc)+int64(length/LOGICAL_SECTOR_SIZE)
+  for n:= 0; sector < end && n < _UDF_MAX_CHAIN; n++ {
+
+    // Llig descriptor
+    if err:= self.readSector ( f, sector, buf[:], nil ); err != nil {
+      return err
+    }
+    sector++
+    tag,err:= udf_parse_tag ( buf[:] )
+    if err != nil {
+      if n == 0 { return err }
+      break // Final de seqüència no marcat
+    }
+
+    // Processa
+    stop:= false
+    switch tag {
+    case _UDF_TAG_PVD:
+      vdsn:= parse_int32_LSB ( buf[16:20] )
+      if num_pvd == 0 || vdsn >= pvd_vdsn {
+        num_pvd++
+        pvd_vdsn= vdsn
+        self.readPrimaryVolume ( buf[:] )
+      }
+    case _UDF_TAG_VDP:
+      length:= parse_int32_LSB ( buf[20:24] )
+      loc:= parse_int32_LSB ( buf[24:28] )
+      sector,end= int64(loc),int64(loc)+int64(length/LOGICAL_SECTOR_SIZE)
+    case _UDF_TAG_PD:
+      vdsn:= parse_int32_LSB ( buf[16:20] )
+      number:= parse_int16_LSB ( buf[22:24] )
+      if prev,ok:= pds_vdsn[number]; !ok || vdsn >= prev {
+        pds_vdsn[number]= vdsn
+        pds[number]= append([]byte{},buf[:]...)
+      }
+    case _UDF_TAG_LVD:
+      vdsn:= parse_int32_LSB ( buf[16:20] )
+      if lvd == nil || vdsn >= lvd_vdsn {
+        lvd_vdsn= vdsn
+        lvd= append([]byte{},buf[:]...)
+      }
+    case _UDF_TAG_TD:
+      stop= true
+    default: // Altres descriptors s'ignoren (IUVD, USD, ...)
+    }
+    if stop { break }
+
+  }
+  if num_pvd == 0 {
+    return errors.New ( "UDF primary volume descriptor not found" )
+  }
+  if lvd == nil {
+    return errors.New ( "UDF logical volume descriptor not found" )
+  }
+
+  return self.readLogicalVolume ( f, lvd, pds )
+
+} // end readVolumeDescriptorSequence
+
+
+func (self *UDF) readPrimaryVolume( data []byte ) {
+
+  pv:= &self.PrimaryVolume
+  pv.VolumeIdentifier= udf_decode_dstring ( data[24:56] )
+  pv.VolumeSequenceNumber= parse_int16_LSB ( data[56:58] )
+  pv.MaxVolumeSequenceNumber= parse_int16_LSB ( data[58:60] )
+  pv.VolumeSetIdentifier= udf_decode_dstring ( data[72:200] )
+  pv.ApplicationIdentifier= udf_decode_regid ( data[344:376] )
+  udf_parse_timestamp ( data[376:388], &pv.RecordingDateTime )
+  pv.ImplementationIdentifier= udf_decode_regid ( data[388:420] )
+
+} // end readPrimaryVolume
+
+
+func (self *UDF) readLogicalVolume(
+
+  f   TrackReader,
+  lvd []byte,
+  pds map[uint16][]byte,
+
+) error {
+
+  // Camps bàsics
+  lv:= &self.LogicalVolume
+  lv.LogicalVolumeIdentifier= udf_decode_dstring ( lvd[84:212] )
+  lv.LogicalBlockSize= parse_int32_LSB ( lvd[212:216] )
+  if lv.LogicalBlockSize != LOGICAL_SECTOR_SIZE {
+    return fmt.Errorf ( "unsupported UDF logical block size: %d",
+      lv.LogicalBlockSize )
+  }
+  lv.DomainIdentifier= udf_decode_regid ( lvd[216:248] )
+  lv.UDFRevision= parse_int16_LSB ( lvd[240:242] )
+  lv.ImplementationIdentifier= udf_decode_regid ( lvd[272:304] )
+  self.root_icb.read ( lvd[248:264] ) // De moment apunta al FSD
+
+  // Mapes de particions
+  map_len:= parse_int32_LSB ( lvd[264:268] )
+  num_maps:= parse_int32_LSB ( lvd[268:272] )
+  if 440+uint64(map_len) > LOGICAL_SECTOR_SIZE {
+    return fmt.Errorf ( "UDF partition map table too large: %d", map_len )
+  }
+  p:= lvd[440:440+map_len]
+  self.maps= make([]_UDF_PartitionMap,num_maps)
+  self.Partitions= make([]UDF_Partition,num_maps)
+  for i:= uint32(0); i < num_maps; i++ {
+
+    // Capçalera
+    if len(p) < 2 || int(p[1]) > len(p) || p[1] < 6 {
+      return errors.New ( "wrong UDF partition map table" )
+    }
+    m:= &self.maps[i]
+    mtype,mlen:= p[0],int(p[1])
+    switch mtype {
+    case 1:
+      m.ptype= UDF_PARTITION_TYPE_PHYSICAL
+      m.number= parse_int16_LSB ( p[4:6] )
+    case 2:
+      if mlen < 64 {
+        return fmt.Errorf ( "wrong UDF type 2 partition map length: %d", mlen )
+      }
+      m.number= parse_int16_LSB ( p[38:40] )
+      switch id:= udf_decode_regid ( p[4:36] ); id {
+      case "*UDF Sparable Partition":
+        m.ptype= UDF_PARTITION_TYPE_SPARABLE
+        m.packet_length= uint32(parse_int16_LSB ( p[40:42] ))
+        if err:= self.readSparingTables ( f, m, p ); err != nil {
+          return err
+        }
+      case "*UDF Metadata Partition":
+        m.ptype= UDF_PARTITION_TYPE_METADATA
+        m.meta_file= parse_int32_LSB ( p[40:44] )
+        m.meta_mirror= parse_int32_LSB ( p[44:48] )
+      case "*UDF Virtual Partition":
+        return errors.New ( "UDF virtual partitions (VAT) not supported" )
+      default:
+        return fmt.Errorf ( "unsupported UDF partition map: %s", id )
+      }
+    default:
+      return fmt.Errorf ( "unsupported UDF partition map type: %d", mtype )
+    }
+
+    // Descriptor de partició
+    pd,ok:= pds[m.number]
+    if !ok {
+      return fmt.Errorf ( "UDF partition descriptor %d not found", m.number )
+    }
+    m.start= parse_int32_LSB ( pd[188:192] )
+    m.length= parse_int32_LSB ( pd[192:196] )
+    self.Partitions[i]= UDF_Partition{
+      Type : m.ptype,
+      Number : m.number,
+      Contents : udf_decode_regid ( pd[24:56] ),
+      Start : m.start,
+      Length : m.length,
+    }
+
+    p= p[mlen:]
+
+  }
+
+  // Particions de metadades. Cal fer-ho després de tenir totes les
+  // particions físiques.
+  for i:= range self.maps {
+    m:= &self.maps[i]
+    if m.ptype != UDF_PARTITION_TYPE_METADATA { continue }
+    if err:= self.readMetadataFile ( f, uint16(i) ); err != nil {
+      return err
+    }
+  }
+
+  return nil
+
+} // end readLogicalVolume
+
+
+func (self *UDF) readSparingTables(
+
+  f    TrackReader,
+  m    *_UDF_PartitionMap,
+  pmap []byte,
+
+) error {
+
+  m.sparing= make(map[uint32]uint32)
+  num_tables:= int(pmap[42])
+  if num_tables > 4 { num_tables= 4 }
+  var buf [LOGICAL_SECTOR_SIZE]byte
+  for i:= 0; i < num_tables; i++ {
+
+    // Llig primer sector de la taula
+    loc:= parse_int32_LSB ( pmap[48+4*i:52+4*i] )
+    if err:= self.readSector ( f, int64(loc), buf[:], nil ); err != nil {
+      continue
+    }
+    if _,err:= udf_parse_tag ( buf[:] ); err != nil { continue }
+    if udf_decode_regid ( buf[16:48] ) != "*UDF Sparing Table" { continue }
+
+    // Llig la resta
+    num_entries:= int(parse_int16_LSB ( buf[48:50] ))
+    size:= 56+8*num_entries
+    data:= append([]byte{},buf[:]...)
+    for s:= int64(loc)+1; len(data) < size; s++ {
+      if err:= self.readSector ( f, s, buf[:], nil ); err != nil {
+        return err
+      }
+      data= append(data,buf[:]...)
+    }
+
+    // Entrades
+    for e:= 0; e < num_entries; e++ {
+      orig:= parse_int32_LSB ( data[56+8*e:60+8*e] )
+      mapped:= parse_int32_LSB ( data[60+8*e:64+8*e] )
+      if orig < 0xFFFFFFF0 {
+        m.sparing[orig]= mapped
+      }
+    }
+
+    return nil
+
+  }
+  log.Printf ( "UDF sparing table not found, sparable partition %d will"+
+    " be read as physical", m.number )
+
+  return nil
+
+} // end readSparingTables
+
+
+func (self *UDF) readMetadataFile( f TrackReader, ref uint16 ) error {
+
+  // Busca la partició física
+  m:= &self.maps[ref]
+  phys_ref:= -1
+  for i:= range self.maps {
+    if self.maps[i].number == m.number &&
+      self.maps[i].ptype != UDF_PARTITION_TYPE_METADATA {
+      phys_ref= i
+      break
+    }
+  }
+  if phys_ref == -1 {
+    return fmt.Errorf ( "physical partition of UDF metadata partition"+
+      " %d not found", m.number )
+  }
+  m.phys_ref= uint16(phys_ref)
+
+  // Llig el fitxer de metadades (o l'espill)
+  icb:= _UDF_LongAD{ lb : m.meta_file, part : m.phys_ref }
+  fe,err:= self.readFileEntry ( f, &icb )
+  if err != nil {
+    log.Printf ( "failed to read UDF metadata file (%s), trying the"+
+      " mirror file", err )
+    icb.lb= m.meta_mirror
+    if fe,err= self.readFileEntry ( f, &icb ); err != nil {
+      return err
+    }
+  }
+  if fe.embedded != nil {
+    return errors.New ( "UDF metadata file with embedded data" )
+  }
+  m.meta_extents= fe.extents
+
+  return nil
+
+} // end readMetadataFile
+
+
+func (self *UDF) readFileSetDescriptor( f TrackReader ) error {
+
+  // Llig
+  var buf [LOGICAL_SECTOR_SIZE]byte
+  fsd:= self.root_icb
+  if err:= self.readBlock ( f, fsd.part, fsd.lb, buf[:] ); err != nil {
+    return err
+  }
+  if err:= udf_check_tag ( buf[:], _UDF_TAG_FSD ); err != nil {
+    return err
+  }
+
+  // Processa
+  fs:= &self.FileSet
+  udf_parse_timestamp ( buf[16:28], &fs.RecordingDateTime )
+  fs.LogicalVolumeIdentifier= udf_decode_dstring ( buf[112:240] )
+  fs.FileSetIdentifier= udf_decode_dstring ( buf[304:336] )
+  fs.CopyrightFileIdentifier= udf_decode_dstring ( buf[336:368] )
+  fs.AbstractFileIdentifier= udf_decode_dstring ( buf[368:400] )
+  self.root_icb.read ( buf[400:416] )
+
+  return nil
+
+} // end readFileSetDescriptor
+
+
+// Llig una File Entry o Extended File Entry.
+func (self *UDF) readFileEntry(
+
+  f   TrackReader,
+  icb *_UDF_LongAD,
+
+) (*_UDF_FileEntry,error) {
+
+  // Llig (seguint les entrades indirectes)
+  var buf [LOGICAL_SECTOR_SIZE]byte
+  loc:= *icb
+  var tag uint16
+  for n:= 0; ; n++ {
+    if n == _UDF_MAX_CHAIN {
+      return nil,errors.New ( "too many UDF indirect entries" )
+    }
+    if err:= self.readBlock ( f, loc.part, loc.lb, buf[:] ); err != nil {
+      return nil,err
+    }
+    var err error
+    if tag,err= udf_parse_tag ( buf[:] ); err != nil {
+      return nil,err
+    }
+    if tag != _UDF_TAG_IE { break }
+    loc.read ( buf[36:52] )
+  }
+
+  // Camps
+  ret:= _UDF_FileEntry{}
+  ret.file_type= buf[27]
+  ad_type:= parse_int16_LSB ( buf[34:36] )&0x7
+  var base,l_ea,l_ad uint32
+  switch tag {
+  case _UDF_TAG_FE:
+    ret.size= parse_int64_LSB ( buf[56:64] )
+    udf_parse_timestamp ( buf[84:96], &ret.modification )
+    l_ea= parse_int32_LSB ( buf[168:172] )
+    l_ad= parse_int32_LSB ( buf[172:176] )
+    base= 176
+  case _UDF_TAG_EFE:
+    ret.size= parse_int64_LSB ( buf[56:64] )
+    udf_parse_timestamp ( buf[92:104], &ret.modification )
+    l_ea= parse_int32_LSB ( buf[208:212] )
+    l_ad= parse_int32_LSB ( buf[212:216] )
+    base= 216
+  default:
+    return nil,fmt.Errorf ( "unexpected UDF descriptor: expected file"+
+      " entry but found tag %d", tag )
+  }
+  if uint64(base)+uint64(l_ea)+uint64(l_ad) > LOGICAL_SECTOR_SIZE {
+    return nil,errors.New ( "wrong UDF file entry: allocation descriptors"+
+      " out of bounds" )
+  }
+  ads:= buf[base+l_ea:base+l_ea+l_ad]
+
+  // Descriptors d'assignació
+  if ad_type == _UDF_AD_EMBEDDED {
+    if uint64(len(ads)) < ret.size {
+      return nil,errors.New ( "wrong UDF file entry: embedded data"+
+        " shorter than file size" )
+    }
+    ret.embedded= append([]byte{},ads[:ret.size]...)
+  } else {
+    var err error
+    ret.extents,err= self.readAllocationDescriptors ( f, ads,
+      int(ad_type), loc.part )
+    if err != nil { return nil,err }
+  }
+
+  return &ret,nil
+
+} // end readFileEntry
+
+
+func (self *UDF) readAllocationDescriptors(
+
+  f       TrackReader,
+  ads     []byte,
+  ad_type int,
+  part    uint16, // Partició de l'ICB
+
+) ([]_UDF_Extent,error) {
+
+  var ad_size int
+  switch ad_type {
+  case _UDF_AD_SHORT:
+    ad_size= 8
+  case _UDF_AD_LONG:
+    ad_size= 16
+  case _UDF_AD_EXT:
+    ad_size= 20
+  default:
+    return nil,fmt.Errorf ( "unknown UDF allocation descriptor type: %d",
+      ad_type )
+  }
+  ret:= make([]_UDF_Extent,0,len(ads)/ad_size)
+  var buf [LOGICAL_SECTOR_SIZE]byte
+  for n:= 0; len(ads) >= ad_size; {
+
+    // Descodifica
+    var ext _UDF_Extent
+    raw:= parse_int32_LSB ( ads[0:4] )
+    ext.etype= uint8(raw>>30)
+    ext.length= raw&0x3FFFFFFF
+    switch ad_type {
+    case _UDF_AD_SHORT:
+      ext.lb= parse_int32_LSB ( ads[4:8] )
+      ext.part= part
+    case _UDF_AD_LONG:
+      ext.lb= parse_int32_LSB ( ads[4:8] )
+      ext.part= parse_int16_LSB ( ads[8:10] )
+    case _UDF_AD_EXT:
+      ext.lb= parse_int32_LSB ( ads[12:16] )
+      ext.part= parse_int16_LSB ( ads[16:18] )
+    }
+    ads= ads[ad_size:]
+    if ext.length == 0 { break }
+
+    // Continua en un Allocation Extent Descriptor
+    if ext.etype == _UDF_EXTENT_NEXT {
+      if n++; n == _UDF_MAX_CHAIN {
+        return nil,errors.New ( "too many UDF allocation extent descriptors" )
+      }
+      if err:= self.readBlock ( f, ext.part, ext.lb, buf[:] ); err != nil {
+        return nil,err
+      }
+      if err:= udf_check_tag ( buf[:], _UDF_TAG_AED ); err != nil {
+        return nil,err
+      }
+      l_ad:= parse_int32_LSB ( buf[20:24] )
+      if 24+uint64(l_ad) > LOGICAL_SECTOR_SIZE {
+        return nil,errors.New ( "wrong UDF allocation extent descriptor" )
+      }
+      ads= buf[24:24+l_ad]
+      continue
+    }
+    ret= append(ret,ext)
+
+  }
+
+  return ret,nil
+
+} // end readAllocationDescriptors
+
+
+func (self *UDF) getFileReader( fe *_UDF_FileEntry ) (*_UDF_FileReader,error) {
+
+  ret:= _UDF_FileReader{
+    udf : self,
+    extents : fe.extents,
+    remain : fe.size,
+    next_sec : -1,
+  }
+  if fe.embedded != nil {
+    ret.buf= fe.embedded
+    ret.extents= nil
+  }
+
+  var err error
+  ret.f,err= self.cd.TrackReader ( self.session, self.track, 0 )
+  if err != nil { return nil,err }
+
+  return &ret,nil
+
+} // end getFileReader
+
+
+func (self *UDF) readDirectory( fe *_UDF_FileEntry ) (*UDF_Directory,error) {
+
+  // Comprovacions
+  if fe.file_type != _UDF_FILE_TYPE_DIRECTORY {
+    return nil,errors.New ( "failed to load directory entry: it "+
+      "is marked as not directory" )
+  }
+  if fe.size > 0x10000000 {
+    return nil,fmt.Errorf ( "UDF directory too large: %d bytes", fe.size )
+  }
+
+  // Llig contingut
+  content:= make([]byte,fe.size)
+  fr,err:= self.getFileReader ( fe )
+  if err != nil { return nil,err }
+  defer fr.Close ()
+  if nb,err:= fr.Read ( content ); err != nil && err != io.EOF {
+    return nil,err
+  } else if uint64(nb) != fe.size {
+    return nil,fmt.Errorf ( "failed to load directory content: expected "+
+      "%d bytes but instead %d bytes were read", fe.size, nb )
+  }
+
+  // Processa File Identifier Descriptors
+  ret:= UDF_Directory{
+    udf : self,
+  }
+  for p:= content; len(p) >= 38; {
+    if err:= udf_check_tag ( p, _UDF_TAG_FID ); err != nil {
+      return nil,err
+    }
+    l_fi:= int(p[19])
+    l_iu:= int(parse_int16_LSB ( p[36:38] ))
+    size:= (38+l_iu+l_fi+3)&^3
+    if 38+l_iu+l_fi > len(p) {
+      return nil,errors.New ( "wrong UDF file identifier descriptor" )
+    }
+    fid:= _UDF_FileId{
+      characteristics : p[18],
+    }
+    fid.icb.read ( p[20:36] )
+    if (fid.characteristics&UDF_FILE_CHAR_PARENT) != 0 {
+      fid.name= ".."
+    } else {
+      fid.name= udf_decode_chars ( p[38+l_iu:38+l_iu+l_fi] )
+    }
+    if (fid.characteristics&UDF_FILE_CHAR_DELETED) == 0 {
+      ret.entries= append(ret.entries,fid)
+    }
+    if size > len(p) { break }
+    p= p[size:]
+  }
+
+  return &ret,nil
+
+} // end readDirectory
+
+
+// Torna el directori arrel.
+func (self *UDF) Root() (*UDF_Directory,error) {
+
+  f,err:= self.cd.TrackReader ( self.session, self.track, 0 )
+  if err != nil { return nil,err }
+  defer f.Close ()
+  fe,err:= self.readFileEntry ( f, &self.root_icb )
+  if err != nil { return nil,err }
+
+  return self.readDirectory ( fe )
+
+} // end Root
+
+
+// UDF DIRECTORY ///////////////////////////////////////////////////////////////
+
+type UDF_Directory struct {
+
+  udf     *UDF
+  entries []_UDF_FileId
+
+}
+
+
+func (self *UDF_Directory) Begin() (*UDF_DirectoryIter,error) {
+
+  ret:= UDF_DirectoryIter{
+    dir : self,
+    pos : 0,
+  }
+  if err:= ret.loadEntry (); err != nil {
+    return nil,err
+  }
+
+  return &ret,nil
+
+} // end Begin
+
+
+// UDF DIRECTORY ITER //////////////////////////////////////////////////////////
+
+type UDF_DirectoryIter struct {
+
+  // PRIVAT!!!
+  dir *UDF_Directory
+  pos int
+  fe  *_UDF_FileEntry
+
+}
+
+
+func (self *UDF_DirectoryIter) loadEntry() error {
+
+  if self.End () {
+    self.fe= nil
+    return nil
+  }
+  udf:= self.dir.udf
+  f,err:= udf.cd.TrackReader ( udf.session, udf.track, 0 )
+  if err != nil { return err }
+  defer f.Close ()
+  self.fe,err= udf.readFileEntry ( f, &self.dir.entries[self.pos].icb )
+
+  return err
+
+} // end loadEntry
+
+
+func (self *UDF_DirectoryIter) Characteristics() uint8 {
+  return self.dir.entries[self.pos].characteristics
+} // end Characteristics
+
+
+func (self *UDF_DirectoryIter) End() bool {
+  return self.pos >= len(self.dir.entries)
+} // end End
+
+
+func (self *UDF_DirectoryIter) GetDirectory() (*UDF_Directory,error) {
+
+  if !self.IsDir () {
+    return nil,fmt.Errorf ( "trying to access regular file '%s' as directory",
+      self.Name () )
+  }
+
+  return self.dir.udf.readDirectory ( self.fe )
+
+} // end GetDirectory
+
+
+func (self *UDF_DirectoryIter) GetFileReader() (*_UDF_FileReader,error) {
+  return self.dir.udf.getFileReader ( self.fe )
+} // end GetFileReader
+
+
+func (self *UDF_DirectoryIter) IsDir() bool {
+  return self.fe.file_type == _UDF_FILE_TYPE_DIRECTORY
+} // end IsDir
+
+
+func (self *UDF_DirectoryIter) ModificationTime() *UDF_Timestamp {
+  return &self.fe.modification
+} // end ModificationTime
+
+
+func (self *UDF_DirectoryIter) Name() string {
+  return self.dir.entries[self.pos].name
+} // end Name
+
+
+func (self *UDF_DirectoryIter) Next() error {
+
+  if self.End () {
+    return errors.New ( "reached end of directory entries" )
+  }
+  self.pos++
+
+  return self.loadEntry ()
+
+} // end Next
+
+
+func (self *UDF_DirectoryIter) Size() uint64 { return self.fe.size }
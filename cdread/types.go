@@ -46,21 +46,70 @@ const (
   TRACK_TYPE_UNK            = -1
 )
 
+// Flags del track. Els 4 primers bits coincideixen amb el camp de
+// control del subcanal Q.
+const (
+  TRACK_FLAGS_PRE  = 0x01 // Pre-èmfasi
+  TRACK_FLAGS_DCP  = 0x02 // Còpia digital permesa
+  TRACK_FLAGS_DATA = 0x04 // Track de dades
+  TRACK_FLAGS_4CH  = 0x08 // Àudio de 4 canals
+  TRACK_FLAGS_SCMS = 0x10 // Serial Copy Management System (no forma
+                          // part del camp de control)
+)
+
+// Informació CD-Text (sols el bloc en anglés/ISO 8859-1).
+type CDText struct {
+  Title      string
+  Performer  string
+  Songwriter string
+  Composer   string
+  Arranger   string
+  Message    string
+  DiscId     string // Sols disc
+  Genre      string // Sols disc
+  UPC_ISRC   string // UPC/EAN en el disc, ISRC en els tracks
+}
+
 type TrackInfo struct {
   Id            uint8    // Identificador en BCD 99 (01h..99h)
   Indexes       []IndexInfo
   PosLastSector Position // Posició absoluta de l'últim sector del
                          // track
   Type          int
+  Cooked        bool     // Cert si els sectors Mode 2 s'emmagatzemen
+                         // sense sincronització ni capçalera (2336
+                         // bytes, o 2048 en Mode 2 Form 1)
+  Flags         uint8    // TRACK_FLAGS_*
+  ISRC          string   // Buit si no se sap
+  CDText        CDText
 }
 
 type SessionInfo struct {
   Tracks []TrackInfo
 }
 
+// Physical Format Information d'un DVD (capa 0).
+type DVDInfo struct {
+  BookType    uint8  // 0 DVD-ROM, 1 DVD-RAM, 2 DVD-R, 3 DVD-RW,
+                     // 9 DVD+RW, 10 DVD+R
+  PartVersion uint8
+  DiscSize    uint8  // 0 120mm, 1 80mm
+  MaxRate     uint8  // 0 2.52, 1 5.04, 2 10.08 Mbps, 15 no especificat
+  NumLayers   int
+  TrackPath   bool   // Cert si és OTP (Opposite Track Path)
+  LayerType   uint8  // Bits: 1 gravable, 2 regravable
+  StartSector uint32 // Primer sector físic de l'àrea de dades
+  EndSector   uint32 // Últim sector físic de l'àrea de dades
+  EndSectorL0 uint32 // Últim sector físic de la capa 0
+}
+
 type Info struct {
   Sessions []SessionInfo
   Tracks   []TrackInfo
+  Catalog  string   // Media Catalog Number (UPC/EAN). Buit si no se sap
+  CDText   CDText
+  Comments []string // Comentaris (per exemple REM en CUE)
+  DVD      *DVDInfo // nil si no és un DVD o no se sap
 }
 
 // Açò sols afecta als CD-XA
@@ -70,6 +119,8 @@ const (
   MODE_CDXA            = 1 // Torna tots els sectors (cadascun en la
                            // grandària que toque).
   MODE_CDXA_MEDIA_ONLY = 2 // Ignora sectors Form1
+  MODE_RAW             = 3 // Torna els sectors RAW complets (2352
+                           // bytes) en tracks que no siguen ISO.
 )
 
 type CD interface {
@@ -85,6 +136,39 @@ type CD interface {
   
 }
 
+// Imatges que també emmagatzemen el subcanal.
+type SubchannelCD interface {
+  CD
+
+  // Torna un lector del subcanal d'un track. Cada sector ocupa 96
+  // bytes desentrellaçats (12 bytes per a cada canal P,Q,R..W). Seek
+  // funciona igual que en TrackReader.
+  SubchannelReader(session int,track int) (TrackReader,error)
+  
+}
+
+// Imatges que emmagatzemen el pregap (índex 00) dels tracks.
+type PregapCD interface {
+  CD
+
+  // Torna un lector dels sectors del pregap (de l'índex 00 fins a
+  // l'índex 01) d'un track. Els modes i Seek funcionen igual que en
+  // TrackReader, però el sector 0 és el primer del pregap. Torna nil
+  // si el track no té pregap o la imatge no el conté.
+  PregapReader(session int,track int,mode int) (TrackReader,error)
+  
+}
+
+// Imatges formades per diversos fitxers.
+type FileLister interface {
+  CD
+
+  // Torna els noms de tots els fitxers que formen la imatge, inclòs
+  // el descriptor (CUE, GDI, etc.), en l'ordre en què s'especifiquen.
+  Files() []string
+  
+}
+
 type TrackReader interface {
 
   // Tanca el lector. Deprés de tancat no es pot llegir.
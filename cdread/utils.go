@@ -104,3 +104,50 @@ func GetPosition( sec_ind int64 ) Position {
   return ret
   
 } // end GetPosition
+
+
+// Tradueix una estructura de tipus Position en un índex de sector.
+func GetSectorIndex( pos Position ) int64 {
+
+  // Passa de BCD
+  mm:= int64(pos.Minutes>>4)*10 + int64(pos.Minutes&0xf)
+  ss:= int64(pos.Seconds>>4)*10 + int64(pos.Seconds&0xf)
+  sec:= int64(pos.Sector>>4)*10 + int64(pos.Sector&0xf)
+
+  return (mm*60 + ss)*75 + sec
+  
+} // end GetSectorIndex
+
+
+// Comprova el CRC (CRC-16 CCITT invertit) dels 12 bytes del subcanal
+// Q.
+func CheckSubQCRC( q []byte ) bool {
+
+  var crc uint16= 0
+  for _,b:= range q[:10] {
+    crc^= uint16(b)<<8
+    for i:= 0; i < 8; i++ {
+      if (crc&0x8000) != 0 {
+        crc= (crc<<1)^0x1021
+      } else {
+        crc<<= 1
+      }
+    }
+  }
+  crc= ^crc
+  
+  return uint8(crc>>8) == q[10] && uint8(crc) == q[11]
+  
+} // end CheckSubQCRC
+
+
+// Afegeix un nom de fitxer a la llista si no està ja.
+func appendFileName( names []string, name string ) []string {
+
+  for _,n:= range names {
+    if n == name { return names }
+  }
+
+  return append(names,name)
+  
+} // end appendFileName
@@ -0,0 +1,1266 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  write_iso.go - Funcions per crear imatges ISO 9660 (amb les
+ *                 extensions Joliet, Rock Ridge i El Torito).
+ */
+
+package cdread
+
+import (
+  "errors"
+  "fmt"
+  "io"
+  "os"
+  "sort"
+  "strings"
+  "time"
+  "unicode/utf16"
+)
+
+
+
+
+/****************/
+/* PART PRIVADA */
+/****************/
+
+// Grandària màxima d'un extent. En el nivell 3 els fitxers més grans
+// es divideixen en diversos extents.
+const _ISO_WRITER_MAX_EXTENT = 0xFFFFF800
+
+const _ISO_WRITER_BUF_SIZE = 64*1024
+
+const _ISO_WRITER_MAX_RECORD = 255
+
+// Bytes del nom en una entrada NM (la grandària màxima d'una entrada
+// SUSP és 255 i la capçalera i els flags n'ocupen 5).
+const _ISO_WRITER_MAX_NM = 250
+
+const _ISO_WRITER_CE_SIZE = 28
+
+const _ISO_WRITER_MAX_DEPTH = 8
+
+// Identificadors Rock Ridge (RRIP 1.09).
+const _ISO_WRITER_RR_ID = "RRIP_1991A"
+const _ISO_WRITER_RR_DES = "THE ROCK RIDGE INTERCHANGE PROTOCOL PROVIDES "+
+  "SUPPORT FOR POSIX FILE SYSTEM SEMANTICS"
+const _ISO_WRITER_RR_SRC = "PLEASE CONTACT DISC PUBLISHER FOR "+
+  "SPECIFICATION SOURCE.  SEE PUBLISHER IDENTIFIER IN PRIMARY VOLUME "+
+  "DESCRIPTOR FOR CONTACT INFORMATION."
+
+
+// FUNCIONS ////////////////////////////////////////////////////////////////////
+
+func set_int16_LSB_MSB( data []byte, val uint16 ) {
+  data[0]= uint8(val)
+  data[1]= uint8(val>>8)
+  data[2]= uint8(val>>8)
+  data[3]= uint8(val)
+} // end set_int16_LSB_MSB
+
+
+func set_int32_LSB_MSB( data []byte, val uint32 ) {
+  set_int32_LSB ( data[0:4], val )
+  set_int32_MSB ( data[4:8], val )
+} // end set_int32_LSB_MSB
+
+
+func set_int32_LSB( data []byte, val uint32 ) {
+  data[0]= uint8(val)
+  data[1]= uint8(val>>8)
+  data[2]= uint8(val>>16)
+  data[3]= uint8(val>>24)
+} // end set_int32_LSB
+
+
+func set_int32_MSB( data []byte, val uint32 ) {
+  data[0]= uint8(val>>24)
+  data[1]= uint8(val>>16)
+  data[2]= uint8(val>>8)
+  data[3]= uint8(val)
+} // end set_int32_MSB
+
+
+// Desplaçament respecte GMT en intervals de 15 minuts.
+func get_gmt_offset( t time.Time ) uint8 {
+  _,off:= t.Zone ()
+  return uint8(int8(off/(15*60)))
+} // end get_gmt_offset
+
+
+func set_date_time( data []byte, t time.Time ) {
+  s:= fmt.Sprintf ( "%04d%02d%02d%02d%02d%02d%02d",
+    t.Year (), int(t.Month ()), t.Day (),
+    t.Hour (), t.Minute (), t.Second (), t.Nanosecond ()/10000000 )
+  copy(data[:16],s)
+  data[16]= get_gmt_offset ( t )
+} // end set_date_time
+
+
+func set_date_time_empty( data []byte ) {
+  for i:= 0; i < 16; i++ {
+    data[i]= '0'
+  }
+  data[16]= 0
+} // end set_date_time_empty
+
+
+func set_date_time_record( data []byte, t time.Time ) {
+  data[0]= uint8(t.Year ()-1900)
+  data[1]= uint8(t.Month ())
+  data[2]= uint8(t.Day ())
+  data[3]= uint8(t.Hour ())
+  data[4]= uint8(t.Minute ())
+  data[5]= uint8(t.Second ())
+  data[6]= get_gmt_offset ( t )
+} // end set_date_time_record
+
+
+// Escriu un string en a-characters omplint amb espais.
+func set_achars( data []byte, s string ) {
+  s= to_achars ( s )
+  for i:= range data {
+    if i < len(s) {
+      data[i]= s[i]
+    } else {
+      data[i]= ' '
+    }
+  }
+} // end set_achars
+
+
+// Escriu un string en UCS-2 (big endian) omplint amb espais.
+func set_ucs2_chars( data []byte, s string ) {
+  u:= utf16.Encode ( []rune(s) )
+  for i:= 0; i+1 < len(data); i+= 2 {
+    var c uint16= ' '
+    if i/2 < len(u) { c= u[i/2] }
+    data[i]= uint8(c>>8)
+    data[i+1]= uint8(c)
+  }
+} // end set_ucs2_chars
+
+
+// Converteix a d-characters (A-Z, 0-9 i _).
+func to_dchars( s string ) string {
+
+  var b strings.Builder
+  for _,c:= range strings.ToUpper ( s ) {
+    if (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' {
+      b.WriteRune ( c )
+    } else {
+      b.WriteByte ( '_' )
+    }
+  }
+
+  return b.String ()
+
+} // end to_dchars
+
+
+// Converteix a a-characters (A-Z, 0-9, espai i !"%&'()*+,-./:;<=>?_).
+func to_achars( s string ) string {
+
+  var b strings.Builder
+  for _,c:= range strings.ToUpper ( s ) {
+    if (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') ||
+      strings.ContainsRune ( " !\"%&'()*+,-./:;<=>?_", c ) {
+      b.WriteRune ( c )
+    } else {
+      b.WriteByte ( '_' )
+    }
+  }
+
+  return b.String ()
+
+} // end to_achars
+
+
+func to_joliet( s string ) []uint16 {
+
+  ret:= utf16.Encode ( []rune(s) )
+  for i,c:= range ret {
+    if c < 0x20 || c == '*' || c == '/' || c == ':' || c == ';' ||
+      c == '?' || c == '\\' {
+      ret[i]= '_'
+    }
+  }
+
+  return ret
+
+} // end to_joliet
+
+
+func ucs2_to_bytes( s []uint16 ) []byte {
+
+  ret:= make([]byte,len(s)*2)
+  for i,c:= range s {
+    ret[2*i]= uint8(c>>8)
+    ret[2*i+1]= uint8(c)
+  }
+
+  return ret
+
+} // end ucs2_to_bytes
+
+
+// Construeix una entrada SUSP.
+func new_susp_entry( sig string, data []byte ) []byte {
+
+  ret:= make([]byte,4+len(data))
+  ret[0]= sig[0]
+  ret[1]= sig[1]
+  ret[2]= uint8(len(ret))
+  ret[3]= 1
+  copy(ret[4:],data)
+
+  return ret
+
+} // end new_susp_entry
+
+
+// REGISTRES ///////////////////////////////////////////////////////////////////
+
+// Un registre de directori pendent d'escriure.
+type _ISO_WriterRecord struct {
+
+  dir   *ISO_WriterDir  // Si apunta a un directori
+  file  *ISO_WriterFile // Si apunta a un fitxer
+  part  int             // Tros en fitxers multiextent
+  id    []byte
+  flags uint8
+  su    []byte          // Entrades SUSP dins del registre
+  ce    []byte          // Entrades SUSP en l'àrea de continuació
+
+  // Posició de l'àrea de continuació
+  ce_block  uint32
+  ce_offset uint32
+
+}
+
+
+func (self *_ISO_WriterRecord) length() int {
+
+  ret:= 33 + len(self.id)
+  if len(self.id)%2 == 0 { ret++ }
+  ret+= len(self.su)
+  if self.ce != nil { ret+= _ISO_WRITER_CE_SIZE }
+  if ret%2 == 1 { ret++ }
+
+  return ret
+
+} // end length
+
+
+// Mou entrades SUSP a l'àrea de continuació si el registre no cap.
+func (self *_ISO_WriterRecord) setSU( entries [][]byte ) {
+
+  // Intenta ficar-ho tot
+  for _,e:= range entries {
+    self.su= append(self.su,e...)
+  }
+  if self.length () <= _ISO_WRITER_MAX_RECORD { return }
+
+  // Reparteix reservant espai per a l'entrada CE
+  self.su= nil
+  self.ce= []byte{}
+  i:= 0
+  for ; i < len(entries); i++ {
+    self.su= append(self.su,entries[i]...)
+    if self.length () > _ISO_WRITER_MAX_RECORD {
+      self.su= self.su[:len(self.su)-len(entries[i])]
+      break
+    }
+  }
+  for ; i < len(entries); i++ {
+    self.ce= append(self.ce,entries[i]...)
+  }
+
+} // end setSU
+
+
+// Serialitza el registre.
+func (self *_ISO_WriterRecord) serialize(
+
+  w      *ISO_Writer,
+  joliet bool,
+
+) []byte {
+
+  ret:= make([]byte,self.length ())
+
+  // Extent i grandària
+  var extent,size uint32
+  if self.dir != nil {
+    if joliet {
+      extent,size= self.dir.jextent,self.dir.jsize
+    } else {
+      extent,size= self.dir.extent,self.dir.size
+    }
+  } else {
+    extent,size= self.file.getExtent ( self.part )
+  }
+
+  ret[0]= uint8(len(ret))
+  ret[1]= 0
+  set_int32_LSB_MSB ( ret[2:10], extent )
+  set_int32_LSB_MSB ( ret[10:18], size )
+  if self.dir != nil {
+    set_date_time_record ( ret[18:25], self.dir.date )
+  } else {
+    set_date_time_record ( ret[18:25], self.file.date )
+  }
+  ret[25]= self.flags
+  ret[26]= 0
+  ret[27]= 0
+  set_int16_LSB_MSB ( ret[28:32], 1 )
+  ret[32]= uint8(len(self.id))
+  copy(ret[33:],self.id)
+  pos:= 33 + len(self.id)
+  if len(self.id)%2 == 0 { pos++ }
+  copy(ret[pos:],self.su)
+  pos+= len(self.su)
+  if self.ce != nil {
+    var data [24]byte
+    set_int32_LSB_MSB ( data[0:8], self.ce_block )
+    set_int32_LSB_MSB ( data[8:16], self.ce_offset )
+    set_int32_LSB_MSB ( data[16:24], uint32(len(self.ce)) )
+    copy(ret[pos:],new_susp_entry ( "CE", data[:] ))
+  }
+
+  return ret
+
+} // end serialize
+
+
+
+
+/****************/
+/* PART PÚBLICA */
+/****************/
+
+// Opcions per a crear una imatge ISO 9660.
+type ISO_WriterOptions struct {
+
+  Level                  int  // Nivell d'intercanvi (1, 2 o 3)
+  Joliet                 bool
+  RockRidge              bool
+  SystemIdentifier       string
+  VolumeIdentifier       string
+  VolumeSetIdentifier    string
+  PublisherIdentifier    string
+  DataPreparerIdentifier string
+  ApplicationIdentifier  string
+  Date                   time.Time // Data de creació del volum i
+                                   // data per defecte dels
+                                   // fitxers. Si és zero
+                                   // s'utilitza l'actual.
+
+  // El Torito
+  Boot            bool   // Si cert cal cridar a SetBootImage
+  BootNoEmulation bool   // Si fals s'emula un disquet
+  BootLoadSize    uint16 // Sectors virtuals de 512 bytes a
+                         // carregar. Sols sense emulació. 0 ->
+                         // valor per defecte.
+
+}
+
+
+type ISO_WriterFile struct {
+
+  name   string
+  extent uint32
+  size   int64
+  date   time.Time // Data de modificació
+
+  // Noms calculats
+  iso_id    string
+  joliet_id []uint16
+
+}
+
+
+// Torna la posició i grandària del tros indicat.
+func (self *ISO_WriterFile) getExtent( part int ) (uint32,uint32) {
+
+  extent:= self.extent + uint32(part)*
+    (_ISO_WRITER_MAX_EXTENT/LOGICAL_SECTOR_SIZE)
+  remain:= self.size - int64(part)*_ISO_WRITER_MAX_EXTENT
+  if remain > _ISO_WRITER_MAX_EXTENT {
+    return extent,_ISO_WRITER_MAX_EXTENT
+  } else {
+    return extent,uint32(remain)
+  }
+
+} // end getExtent
+
+
+// Canvia la data de modificació del fitxer. Per defecte és la data de
+// les opcions.
+func (self *ISO_WriterFile) SetDate( date time.Time ) { self.date= date }
+
+
+// Número de trossos en què es divideix el fitxer.
+func (self *ISO_WriterFile) numParts() int {
+  if self.size <= _ISO_WRITER_MAX_EXTENT { return 1 }
+  return int((self.size+_ISO_WRITER_MAX_EXTENT-1)/_ISO_WRITER_MAX_EXTENT)
+} // end numParts
+
+
+type ISO_WriterDir struct {
+
+  w      *ISO_Writer
+  name   string
+  parent *ISO_WriterDir
+  dirs   []*ISO_WriterDir
+  files  []*ISO_WriterFile
+  depth  int
+  date   time.Time // Data de modificació
+
+  // Noms calculats
+  iso_id    string
+  joliet_id []uint16
+
+  // Posició
+  extent  uint32
+  size    uint32
+  jextent uint32
+  jsize   uint32
+  num     uint16 // Número en la taula de camins
+  jnum    uint16
+
+}
+
+
+// Afegeix un subdirectori.
+func (self *ISO_WriterDir) AddDir( name string ) *ISO_WriterDir {
+
+  ret:= ISO_WriterDir{
+    w : self.w,
+    name : name,
+    parent : self,
+    depth : self.depth+1,
+    date : self.w.opts.Date,
+  }
+  self.dirs= append(self.dirs,&ret)
+
+  return &ret
+
+} // end AddDir
+
+
+// Canvia la data de modificació del directori. Per defecte és la data
+// de les opcions.
+func (self *ISO_WriterDir) SetDate( date time.Time ) { self.date= date }
+
+
+// Afegeix un fitxer copiant immediatament el contingut del lector
+// proporcionat en la imatge.
+func (self *ISO_WriterDir) AddFile(
+
+  name string,
+  r    io.Reader,
+
+) (*ISO_WriterFile,error) {
+
+  w:= self.w
+  if w.closed {
+    return nil,errors.New ( "ISO image already closed" )
+  }
+  ret:= ISO_WriterFile{
+    name : name,
+    extent : w.next_sec,
+    size : 0,
+    date : w.opts.Date,
+  }
+
+  // En els nivells 1 i 2 el fitxer ha de cabre en un extent. Si el
+  // lector permet consultar la grandària es comprova abans de copiar.
+  var max_size int64= -1
+  if w.opts.Level < 3 {
+    max_size= _ISO_WRITER_MAX_EXTENT
+    if st,ok:= r.(interface{ Stat() (os.FileInfo,error) }); ok {
+      if info,err:= st.Stat (); err == nil && info.Size () > max_size {
+        return nil,fmt.Errorf ( "file '%s' is too big for ISO 9660 level %d",
+          name, w.opts.Level )
+      }
+    }
+  }
+
+  // Copia
+  offset:= int64(w.next_sec)*LOGICAL_SECTOR_SIZE
+  buf:= make([]byte,_ISO_WRITER_BUF_SIZE)
+  for {
+    n,err:= r.Read ( buf )
+    if n > 0 {
+      if max_size != -1 && ret.size+int64(n) > max_size {
+        return nil,fmt.Errorf ( "file '%s' is too big for ISO 9660 level %d",
+          name, w.opts.Level )
+      }
+      if _,err:= w.f.WriteAt ( buf[:n], offset+ret.size ); err != nil {
+        return nil,err
+      }
+      ret.size+= int64(n)
+    }
+    if err == io.EOF {
+      break
+    } else if err != nil {
+      return nil,err
+    }
+  }
+
+  // Omple fins al final del sector
+  if pad:= ret.size%LOGICAL_SECTOR_SIZE; pad != 0 {
+    zeros:= make([]byte,LOGICAL_SECTOR_SIZE-pad)
+    if _,err:= w.f.WriteAt ( zeros, offset+ret.size ); err != nil {
+      return nil,err
+    }
+  }
+  w.next_sec+= uint32((ret.size+LOGICAL_SECTOR_SIZE-1)/LOGICAL_SECTOR_SIZE)
+  self.files= append(self.files,&ret)
+
+  return &ret,nil
+
+} // end AddFile
+
+
+// Calcula els noms ISO 9660 i Joliet dels fills.
+func (self *ISO_WriterDir) assignNames() {
+
+  level:= self.w.opts.Level
+  used:= make(map[string]bool)
+  jused:= make(map[string]bool)
+
+  // Funció per fer únics els noms
+  uniq:= func(base,ext string,max_base int,sep bool) string {
+    if len(base) > max_base { base= base[:max_base] }
+    mk:= func(b string) string {
+      if sep { return b+"."+ext }
+      return b
+    }
+    ret:= mk(base)
+    for n:= 1; used[ret]; n++ {
+      suf:= fmt.Sprintf ( "_%d", n )
+      b:= base
+      if len(b)+len(suf) > max_base { b= b[:max_base-len(suf)] }
+      ret= mk(b+suf)
+    }
+    used[ret]= true
+    return ret
+  }
+  juniq:= func(name string) []uint16 {
+    u:= to_joliet ( name )
+    if len(u) > 64 { u= u[:64] }
+    ret:= u
+    for n:= 1; jused[string(utf16.Decode ( ret ))]; n++ {
+      suf:= utf16.Encode ( []rune(fmt.Sprintf ( "_%d", n )) )
+      b:= u
+      if len(b)+len(suf) > 64 { b= b[:64-len(suf)] }
+      ret= append(append([]uint16{},b...),suf...)
+    }
+    jused[string(utf16.Decode ( ret ))]= true
+    return ret
+  }
+
+  // Directoris
+  for _,d:= range self.dirs {
+    max:= 31
+    if level == 1 { max= 8 }
+    base:= to_dchars ( d.name )
+    if base == "" { base= "_" }
+    d.iso_id= uniq ( base, "", max, false )
+    d.joliet_id= juniq ( d.name )
+    d.assignNames ()
+  }
+
+  // Fitxers
+  for _,f:= range self.files {
+    base,ext:= f.name,""
+    if ind:= strings.LastIndex ( f.name, "." ); ind > 0 {
+      base,ext= f.name[:ind],f.name[ind+1:]
+    }
+    base,ext= to_dchars ( base ),to_dchars ( ext )
+    if base == "" { base= "_" }
+    var max_base int
+    if level == 1 {
+      if len(ext) > 3 { ext= ext[:3] }
+      max_base= 8
+    } else {
+      if len(ext) > 10 { ext= ext[:10] }
+      max_base= 30-1-len(ext)
+    }
+    f.iso_id= uniq ( base, ext, max_base, true ) + ";1"
+    f.joliet_id= juniq ( f.name )
+  }
+
+} // end assignNames
+
+
+// Entrades Rock Ridge per a un fill.
+func (self *ISO_WriterDir) rrEntries(
+
+  dir  *ISO_WriterDir,
+  file *ISO_WriterFile,
+  name string, // Buit per a '.' i '..'
+
+) [][]byte {
+
+  var ret [][]byte
+
+  // PX
+  var px [32]byte
+  if dir != nil {
+    set_int32_LSB_MSB ( px[0:8], 0040555 )
+    set_int32_LSB_MSB ( px[8:16], uint32(2+len(dir.dirs)) )
+  } else {
+    set_int32_LSB_MSB ( px[0:8], 0100444 )
+    set_int32_LSB_MSB ( px[8:16], 1 )
+  }
+  set_int32_LSB_MSB ( px[16:24], 0 )
+  set_int32_LSB_MSB ( px[24:32], 0 )
+  ret= append(ret,new_susp_entry ( "PX", px[:] ))
+
+  // TF (modificació, accés i atributs)
+  var date time.Time
+  if dir != nil {
+    date= dir.date
+  } else {
+    date= file.date
+  }
+  var tf [1+3*7]byte
+  tf[0]= 0x0E
+  for i:= 0; i < 3; i++ {
+    set_date_time_record ( tf[1+i*7:], date )
+  }
+  ret= append(ret,new_susp_entry ( "TF", tf[:] ))
+
+  // NM. La grandària d'una entrada SUSP és d'un byte, els noms
+  // llargs es reparteixen en diverses entrades amb el flag CONTINUE.
+  for rest:= []byte(name); len(rest) > 0; {
+    n,flags:= len(rest),byte(0)
+    if n > _ISO_WRITER_MAX_NM {
+      n,flags= _ISO_WRITER_MAX_NM,0x01
+    }
+    data:= append([]byte{flags},rest[:n]...)
+    ret= append(ret,new_susp_entry ( "NM", data ))
+    rest= rest[n:]
+  }
+
+  return ret
+
+} // end rrEntries
+
+
+// Crea els registres del directori.
+func (self *ISO_WriterDir) makeRecords( joliet bool ) []*_ISO_WriterRecord {
+
+  rr:= self.w.opts.RockRidge && !joliet
+  parent:= self.parent
+  if parent == nil { parent= self }
+
+  // '.'
+  dot:= _ISO_WriterRecord{
+    dir : self,
+    id : []byte{0},
+    flags : FILE_FLAGS_DIRECTORY,
+  }
+  if rr {
+    entries:= self.rrEntries ( self, nil, "" )
+    if self.parent == nil {
+      sp:= new_susp_entry ( "SP", []byte{0xBE,0xEF,0} )
+      entries= append([][]byte{sp},entries...)
+      data:= []byte{
+        uint8(len(_ISO_WRITER_RR_ID)),
+        uint8(len(_ISO_WRITER_RR_DES)),
+        uint8(len(_ISO_WRITER_RR_SRC)),
+        1,
+      }
+      data= append(data,_ISO_WRITER_RR_ID...)
+      data= append(data,_ISO_WRITER_RR_DES...)
+      data= append(data,_ISO_WRITER_RR_SRC...)
+      entries= append(entries,new_susp_entry ( "ER", data ))
+    }
+    dot.setSU ( entries )
+  }
+
+  // '..'
+  dotdot:= _ISO_WriterRecord{
+    dir : parent,
+    id : []byte{1},
+    flags : FILE_FLAGS_DIRECTORY,
+  }
+  if rr {
+    dotdot.setSU ( self.rrEntries ( parent, nil, "" ) )
+  }
+
+  // Fills
+  var children []*_ISO_WriterRecord
+  for _,d:= range self.dirs {
+    rec:= _ISO_WriterRecord{
+      dir : d,
+      flags : FILE_FLAGS_DIRECTORY,
+    }
+    if joliet {
+      rec.id= ucs2_to_bytes ( d.joliet_id )
+    } else {
+      rec.id= []byte(d.iso_id)
+    }
+    if rr {
+      rec.setSU ( self.rrEntries ( d, nil, d.name ) )
+    }
+    children= append(children,&rec)
+  }
+  for _,f:= range self.files {
+    var id []byte
+    if joliet {
+      id= ucs2_to_bytes ( f.joliet_id )
+    } else {
+      id= []byte(f.iso_id)
+    }
+    nparts:= f.numParts ()
+    for p:= 0; p < nparts; p++ {
+      rec:= _ISO_WriterRecord{
+        file : f,
+        part : p,
+        id : id,
+      }
+      if p < nparts-1 {
+        rec.flags= FILE_FLAGS_MULTIEXTENT
+      }
+      if rr {
+        rec.setSU ( self.rrEntries ( nil, f, f.name ) )
+      }
+      children= append(children,&rec)
+    }
+  }
+  sort.SliceStable ( children, func(i,j int) bool {
+    return string(children[i].id) < string(children[j].id)
+  })
+
+  return append([]*_ISO_WriterRecord{&dot,&dotdot},children...)
+
+} // end makeRecords
+
+
+// Grandària en bytes (múltiple del sector) que ocupen els
+// registres. Els registres no poden creuar la frontera d'un sector.
+func iso_records_size( recs []*_ISO_WriterRecord ) uint32 {
+
+  var pos uint32= 0
+  for _,r:= range recs {
+    l:= uint32(r.length ())
+    if pos%LOGICAL_SECTOR_SIZE + l > LOGICAL_SECTOR_SIZE {
+      pos= (pos/LOGICAL_SECTOR_SIZE+1)*LOGICAL_SECTOR_SIZE
+    }
+    pos+= l
+  }
+
+  return ((pos+LOGICAL_SECTOR_SIZE-1)/LOGICAL_SECTOR_SIZE)*LOGICAL_SECTOR_SIZE
+
+} // end iso_records_size
+
+
+// Escriptor d'imatges ISO 9660. Primer es copien tots els fitxers
+// (amb AddFile) i quan es tanca s'escriuen els directoris, les taules
+// de camins i els descriptors de volum.
+type ISO_Writer struct {
+
+  opts      ISO_WriterOptions
+  f         *os.File
+  root      *ISO_WriterDir
+  next_sec  uint32 // Següent sector lliure
+  boot_file *ISO_WriterFile
+  closed    bool
+
+  // Sectors reservats
+  sec_boot_vd   uint32
+  sec_joliet_vd uint32
+  sec_term_vd   uint32
+  sec_catalog   uint32
+
+}
+
+
+// Crea una nova imatge ISO 9660.
+func NewISOWriter(
+
+  file_name string,
+  opts      ISO_WriterOptions,
+
+) (*ISO_Writer,error) {
+
+  // Comprova opcions
+  if opts.Level < 1 || opts.Level > 3 {
+    return nil,fmt.Errorf ( "invalid ISO 9660 level: %d", opts.Level )
+  }
+  if opts.Date.IsZero () {
+    opts.Date= time.Now ()
+  }
+
+  // Crea
+  ret:= ISO_Writer{
+    opts : opts,
+  }
+  ret.root= &ISO_WriterDir{
+    w : &ret,
+    depth : 1,
+    date : opts.Date,
+  }
+
+  // Reserva descriptors de volum
+  sec:= uint32(16+1) // Primary volume
+  if opts.Boot {
+    ret.sec_boot_vd= sec
+    sec++
+  }
+  if opts.Joliet {
+    ret.sec_joliet_vd= sec
+    sec++
+  }
+  ret.sec_term_vd= sec
+  sec++
+  if opts.Boot {
+    ret.sec_catalog= sec
+    sec++
+  }
+  ret.next_sec= sec
+
+  // Crea fitxer
+  var err error
+  ret.f,err= os.Create ( file_name )
+  if err != nil { return nil,err }
+
+  return &ret,nil
+
+} // end NewISOWriter
+
+
+// Torna el directori arrel.
+func (self *ISO_Writer) Root() *ISO_WriterDir { return self.root }
+
+
+// Indica quin fitxer s'utilitza com a imatge d'arrencada.
+func (self *ISO_Writer) SetBootImage( file *ISO_WriterFile ) error {
+
+  if !self.opts.Boot {
+    return errors.New ( "ISO image was not created as bootable" )
+  }
+  if !self.opts.BootNoEmulation {
+    switch file.size {
+    case 1200*1024, 1440*1024, 2880*1024:
+    default:
+      return fmt.Errorf ( "boot image '%s' size (%d) does not match"+
+        " any floppy size (1.2M, 1.44M or 2.88M)", file.name, file.size )
+    }
+  }
+  self.boot_file= file
+
+  return nil
+
+} // end SetBootImage
+
+
+// Escriu les estructures del sistema de fitxers i tanca la imatge.
+func (self *ISO_Writer) Close() error {
+
+  if self.closed {
+    return errors.New ( "ISO image already closed" )
+  }
+  self.closed= true
+  defer self.f.Close ()
+  if self.opts.Boot && self.boot_file == nil {
+    return errors.New ( "boot image not found" )
+  }
+
+  // Noms
+  self.root.assignNames ()
+
+  // Directoris en ordre de la taula de camins
+  dirs:= self.sortDirs ( false )
+  for i,d:= range dirs {
+    d.num= uint16(i+1)
+    if d.depth > _ISO_WRITER_MAX_DEPTH {
+      return fmt.Errorf ( "directory '%s' exceeds ISO 9660 maximum depth (%d)",
+        d.name, _ISO_WRITER_MAX_DEPTH )
+    }
+  }
+  var jdirs []*ISO_WriterDir
+  if self.opts.Joliet {
+    jdirs= self.sortDirs ( true )
+    for i,d:= range jdirs {
+      d.jnum= uint16(i+1)
+    }
+  }
+  if len(dirs) > 0xFFFF {
+    return errors.New ( "too many directories" )
+  }
+
+  // Ubica directoris
+  recs:= make([][]*_ISO_WriterRecord,len(dirs))
+  for i,d:= range dirs {
+    recs[i]= d.makeRecords ( false )
+    d.size= iso_records_size ( recs[i] )
+    d.extent= self.next_sec
+    self.next_sec+= d.size/LOGICAL_SECTOR_SIZE
+  }
+
+  // Àrea de continuació Rock Ridge
+  ce_begin:= self.next_sec
+  var ce_pos uint32= 0
+  for _,rs:= range recs {
+    for _,r:= range rs {
+      if r.ce == nil { continue }
+      l:= uint32(len(r.ce))
+      if ce_pos%LOGICAL_SECTOR_SIZE + l > LOGICAL_SECTOR_SIZE {
+        ce_pos= (ce_pos/LOGICAL_SECTOR_SIZE+1)*LOGICAL_SECTOR_SIZE
+      }
+      r.ce_block= ce_begin + ce_pos/LOGICAL_SECTOR_SIZE
+      r.ce_offset= ce_pos%LOGICAL_SECTOR_SIZE
+      ce_pos+= l
+    }
+  }
+  ce_area:= make([]byte,
+    ((ce_pos+LOGICAL_SECTOR_SIZE-1)/LOGICAL_SECTOR_SIZE)*LOGICAL_SECTOR_SIZE)
+  for _,rs:= range recs {
+    for _,r:= range rs {
+      if r.ce != nil {
+        copy(ce_area[(r.ce_block-ce_begin)*LOGICAL_SECTOR_SIZE+r.ce_offset:],
+          r.ce)
+      }
+    }
+  }
+  self.next_sec+= uint32(len(ce_area))/LOGICAL_SECTOR_SIZE
+
+  // Directoris Joliet
+  jrecs:= make([][]*_ISO_WriterRecord,len(jdirs))
+  for i,d:= range jdirs {
+    jrecs[i]= d.makeRecords ( true )
+    d.jsize= iso_records_size ( jrecs[i] )
+    d.jextent= self.next_sec
+    self.next_sec+= d.jsize/LOGICAL_SECTOR_SIZE
+  }
+
+  // Taules de camins
+  pt_l,pt_m:= self.makePathTables ( dirs, false )
+  pt_size:= uint32(len(pt_l))
+  pt_l_sec:= self.allocBytes ( pt_size )
+  pt_m_sec:= self.allocBytes ( pt_size )
+  var jpt_l,jpt_m []byte
+  var jpt_size,jpt_l_sec,jpt_m_sec uint32
+  if self.opts.Joliet {
+    jpt_l,jpt_m= self.makePathTables ( jdirs, true )
+    jpt_size= uint32(len(jpt_l))
+    jpt_l_sec= self.allocBytes ( jpt_size )
+    jpt_m_sec= self.allocBytes ( jpt_size )
+  }
+
+  // Escriu directoris
+  for i,d:= range dirs {
+    if err:= self.writeDir ( recs[i], d.extent, d.size, false ); err != nil {
+      return err
+    }
+  }
+  if err:= self.writeAt ( ce_area, ce_begin ); err != nil { return err }
+  for i,d:= range jdirs {
+    if err:= self.writeDir ( jrecs[i], d.jextent, d.jsize, true );
+    err != nil {
+      return err
+    }
+  }
+
+  // Escriu taules de camins
+  if err:= self.writeAt ( pt_l, pt_l_sec ); err != nil { return err }
+  if err:= self.writeAt ( pt_m, pt_m_sec ); err != nil { return err }
+  if self.opts.Joliet {
+    if err:= self.writeAt ( jpt_l, jpt_l_sec ); err != nil { return err }
+    if err:= self.writeAt ( jpt_m, jpt_m_sec ); err != nil { return err }
+  }
+
+  // Àrea del sistema
+  if err:= self.writeAt ( make([]byte,16*LOGICAL_SECTOR_SIZE), 0 );
+  err != nil {
+    return err
+  }
+
+  // Descriptors de volum
+  vd:= self.makeVolumeDescriptor ( false, pt_size, pt_l_sec, pt_m_sec )
+  if err:= self.writeAt ( vd, 16 ); err != nil { return err }
+  if self.opts.Boot {
+    if err:= self.writeAt ( self.makeBootRecord (), self.sec_boot_vd );
+    err != nil {
+      return err
+    }
+    if err:= self.writeAt ( self.makeBootCatalog (), self.sec_catalog );
+    err != nil {
+      return err
+    }
+  }
+  if self.opts.Joliet {
+    vd= self.makeVolumeDescriptor ( true, jpt_size, jpt_l_sec, jpt_m_sec )
+    if err:= self.writeAt ( vd, self.sec_joliet_vd ); err != nil {
+      return err
+    }
+  }
+  term:= make([]byte,LOGICAL_SECTOR_SIZE)
+  term[0]= 255
+  copy(term[1:6],"CD001")
+  term[6]= 1
+  if err:= self.writeAt ( term, self.sec_term_vd ); err != nil {
+    return err
+  }
+
+  return nil
+
+} // end Close
+
+
+// Reserva sectors per a nbytes i torna el primer sector.
+func (self *ISO_Writer) allocBytes( nbytes uint32 ) uint32 {
+
+  ret:= self.next_sec
+  self.next_sec+= (nbytes+LOGICAL_SECTOR_SIZE-1)/LOGICAL_SECTOR_SIZE
+
+  return ret
+
+} // end allocBytes
+
+
+// Escriu les dades en el sector indicat omplint amb zeros fins al
+// final de sector.
+func (self *ISO_Writer) writeAt( data []byte, sector uint32 ) error {
+
+  if pad:= len(data)%LOGICAL_SECTOR_SIZE; pad != 0 {
+    data= append(data,make([]byte,LOGICAL_SECTOR_SIZE-pad)...)
+  }
+  _,err:= self.f.WriteAt ( data, int64(sector)*LOGICAL_SECTOR_SIZE )
+
+  return err
+
+} // end writeAt
+
+
+func (self *ISO_Writer) writeDir(
+
+  recs   []*_ISO_WriterRecord,
+  extent uint32,
+  size   uint32,
+  joliet bool,
+
+) error {
+
+  data:= make([]byte,size)
+  var pos uint32= 0
+  for _,r:= range recs {
+    buf:= r.serialize ( self, joliet )
+    l:= uint32(len(buf))
+    if pos%LOGICAL_SECTOR_SIZE + l > LOGICAL_SECTOR_SIZE {
+      pos= (pos/LOGICAL_SECTOR_SIZE+1)*LOGICAL_SECTOR_SIZE
+    }
+    copy(data[pos:],buf)
+    pos+= l
+  }
+
+  return self.writeAt ( data, extent )
+
+} // end writeDir
+
+
+// Ordena els directoris per nivell, pare i nom.
+func (self *ISO_Writer) sortDirs( joliet bool ) []*ISO_WriterDir {
+
+  key:= func(d *ISO_WriterDir) string {
+    if joliet { return string(ucs2_to_bytes ( d.joliet_id )) }
+    return d.iso_id
+  }
+  ret:= []*ISO_WriterDir{self.root}
+  for i:= 0; i < len(ret); i++ {
+    children:= append([]*ISO_WriterDir{},ret[i].dirs...)
+    sort.SliceStable ( children, func(a,b int) bool {
+      return key ( children[a] ) < key ( children[b] )
+    })
+    ret= append(ret,children...)
+  }
+
+  return ret
+
+} // end sortDirs
+
+
+// Torna les taules de camins de tipus L i M.
+func (self *ISO_Writer) makePathTables(
+
+  dirs   []*ISO_WriterDir,
+  joliet bool,
+
+) ([]byte,[]byte) {
+
+  var l,m []byte
+  for _,d:= range dirs {
+    var id []byte
+    var extent uint32
+    var parent uint16
+    if joliet {
+      id,extent= ucs2_to_bytes ( d.joliet_id ),d.jextent
+    } else {
+      id,extent= []byte(d.iso_id),d.extent
+    }
+    if d.parent == nil {
+      id,parent= []byte{0},1
+    } else if joliet {
+      parent= d.parent.jnum
+    } else {
+      parent= d.parent.num
+    }
+    rec:= make([]byte,8+len(id)+len(id)%2)
+    rec[0]= uint8(len(id))
+    copy(rec[8:],id)
+    set_int32_LSB ( rec[2:6], extent )
+    rec[6],rec[7]= uint8(parent),uint8(parent>>8)
+    l= append(l,rec...)
+    set_int32_MSB ( rec[2:6], extent )
+    rec[6],rec[7]= uint8(parent>>8),uint8(parent)
+    m= append(m,rec...)
+  }
+
+  return l,m
+
+} // end makePathTables
+
+
+func (self *ISO_Writer) makeVolumeDescriptor(
+
+  joliet     bool,
+  pt_size    uint32,
+  pt_l_sec   uint32,
+  pt_m_sec   uint32,
+
+) []byte {
+
+  ret:= make([]byte,LOGICAL_SECTOR_SIZE)
+  set:= set_achars
+  if joliet {
+    ret[0]= 2
+    set= set_ucs2_chars
+  } else {
+    ret[0]= 1
+  }
+  copy(ret[1:6],"CD001")
+  ret[6]= 1
+  set ( ret[8:40], self.opts.SystemIdentifier )
+  if joliet {
+    set ( ret[40:72], self.opts.VolumeIdentifier )
+  } else {
+    set ( ret[40:72], to_dchars ( self.opts.VolumeIdentifier ) )
+  }
+  set_int32_LSB_MSB ( ret[80:88], self.next_sec )
+  if joliet {
+    copy(ret[88:91],"%/E") // UCS-2 nivell 3
+  }
+  set_int16_LSB_MSB ( ret[120:124], 1 )
+  set_int16_LSB_MSB ( ret[124:128], 1 )
+  set_int16_LSB_MSB ( ret[128:132], LOGICAL_SECTOR_SIZE )
+  set_int32_LSB_MSB ( ret[132:140], pt_size )
+  set_int32_LSB ( ret[140:144], pt_l_sec )
+  set_int32_MSB ( ret[148:152], pt_m_sec )
+
+  // Directori arrel
+  root:= _ISO_WriterRecord{
+    dir : self.root,
+    id : []byte{0},
+    flags : FILE_FLAGS_DIRECTORY,
+  }
+  copy(ret[156:190],root.serialize ( self, joliet ))
+
+  // Identificadors
+  set ( ret[190:318], self.opts.VolumeSetIdentifier )
+  set ( ret[318:446], self.opts.PublisherIdentifier )
+  set ( ret[446:574], self.opts.DataPreparerIdentifier )
+  set ( ret[574:702], self.opts.ApplicationIdentifier )
+  set ( ret[702:739], "" )
+  set ( ret[739:776], "" )
+  set ( ret[776:813], "" )
+
+  // Dates
+  set_date_time ( ret[813:830], self.opts.Date )
+  set_date_time ( ret[830:847], self.opts.Date )
+  set_date_time_empty ( ret[847:864] )
+  set_date_time_empty ( ret[864:881] )
+  ret[881]= 1
+
+  return ret
+
+} // end makeVolumeDescriptor
+
+
+func (self *ISO_Writer) makeBootRecord() []byte {
+
+  ret:= make([]byte,LOGICAL_SECTOR_SIZE)
+  ret[0]= 0
+  copy(ret[1:6],"CD001")
+  ret[6]= 1
+  copy(ret[7:39],"EL TORITO SPECIFICATION")
+  set_int32_LSB ( ret[71:75], self.sec_catalog )
+
+  return ret
+
+} // end makeBootRecord
+
+
+func (self *ISO_Writer) makeBootCatalog() []byte {
+
+  ret:= make([]byte,LOGICAL_SECTOR_SIZE)
+
+  // Validation entry
+  ret[0]= 1 // Header ID
+  ret[1]= 0 // 80x86
+  ret[30]= 0x55
+  ret[31]= 0xAA
+  var sum uint16= 0
+  for i:= 0; i < 32; i+= 2 {
+    sum+= uint16(ret[i]) | (uint16(ret[i+1])<<8)
+  }
+  sum= -sum
+  ret[28],ret[29]= uint8(sum),uint8(sum>>8)
+
+  // Initial/Default entry
+  e:= ret[32:64]
+  e[0]= 0x88 // Bootable
+  var count uint16= 1
+  if self.opts.BootNoEmulation {
+    e[1]= 0
+    count= self.opts.BootLoadSize
+    if count == 0 { count= 4 }
+  } else {
+    switch self.boot_file.size {
+    case 1200*1024:
+      e[1]= 1
+    case 1440*1024:
+      e[1]= 2
+    default:
+      e[1]= 3
+    }
+  }
+  e[6],e[7]= uint8(count),uint8(count>>8)
+  set_int32_LSB ( e[8:12], self.boot_file.extent )
+
+  return ret
+
+} // end makeBootCatalog
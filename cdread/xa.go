@@ -0,0 +1,349 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  xa.go - Descodificador d'àudio CD-XA ADPCM (PlayStation, CD-i). Els
+ *          sectors Form 2 d'àudio es separen per fitxer i canal a
+ *          partir del subheader.
+ */
+
+package cdread
+
+import (
+  "fmt"
+  "io"
+)
+
+
+
+
+/****************/
+/* PART PRIVADA */
+/****************/
+
+// Submode del subheader
+const (
+  _XA_SUBMODE_AUDIO = 0x04
+  _XA_SUBMODE_FORM2 = 0x20
+)
+
+// Grups de so per sector
+const _XA_NUM_GROUPS = 18
+
+// Coeficients dels filtres
+var _XA_K0= [4]int32{0,60,115,98}
+var _XA_K1= [4]int32{0,0,-52,-55}
+
+
+// Torna cert si és un sector Form 2 d'àudio.
+func isXAAudioSector( sector []byte ) bool {
+  submode:= sector[0x12]
+  return submode&_XA_SUBMODE_AUDIO != 0 && submode&_XA_SUBMODE_FORM2 != 0
+} // end isXAAudioSector
+
+
+// Llig la informació de codificació del subheader.
+func decodeXACodingInfo( file,channel,coding uint8 ) XA_Channel {
+
+  ret:= XA_Channel{
+    File          : file,
+    Channel       : channel,
+    Stereo        : coding&0x3 == 1,
+    SampleRate    : 37800,
+    BitsPerSample : 4,
+  }
+  if (coding>>2)&0x3 == 1 {
+    ret.SampleRate= 18900
+  }
+  if (coding>>4)&0x3 == 1 {
+    ret.BitsPerSample= 8
+  }
+
+  return ret
+
+} // end decodeXACodingInfo
+
+
+// Descodifica una unitat de 28 mostres.
+func decodeXAUnit(
+
+  group  []byte,
+  unit   int,
+  bits   int,
+  hist   *[2]int32,
+  out    []int16,
+  stride int,
+
+) {
+
+  param:= group[4+unit]
+  shift:= uint(param&0xf)
+  filter:= (param>>4)&0x3
+  if bits == 4 && shift > 12 {
+    shift= 9
+  } else if bits == 8 && shift > 8 {
+    shift= 8
+  }
+  for s:= 0; s < 28; s++ {
+    var t int32
+    if bits == 4 {
+      b:= group[16+s*4+unit/2]
+      if unit&1 != 0 {
+        b>>= 4
+      }
+      t= int32(int16(uint16(b&0xf)<<12))>>shift
+    } else {
+      t= int32(int16(uint16(group[16+s*4+unit])<<8))>>shift
+    }
+    t+= (hist[0]*_XA_K0[filter] + hist[1]*_XA_K1[filter] + 32)>>6
+    if t > 32767 {
+      t= 32767
+    } else if t < -32768 {
+      t= -32768
+    }
+    hist[1]= hist[0]
+    hist[0]= t
+    out[s*stride]= int16(t)
+  }
+
+} // end decodeXAUnit
+
+
+// Descodifica un sector RAW. Torna el nombre de mostres (comptant
+// els dos canals en estèreo).
+func decodeXASector(
+
+  sector []byte,
+  info   *XA_Channel,
+  hist   *[2][2]int32,
+  out    []int16,
+
+) int {
+
+  nunits:= 8
+  if info.BitsPerSample == 8 {
+    nunits= 4
+  }
+  n:= 0
+  for g:= 0; g < _XA_NUM_GROUPS; g++ {
+    group:= sector[0x18+g*128:0x18+(g+1)*128]
+    if info.Stereo {
+      for u:= 0; u < nunits; u+= 2 {
+        decodeXAUnit ( group, u, info.BitsPerSample, &hist[0], out[n:], 2 )
+        decodeXAUnit ( group, u+1, info.BitsPerSample, &hist[1],
+          out[n+1:], 2 )
+        n+= 28*2
+      }
+    } else {
+      for u:= 0; u < nunits; u++ {
+        decodeXAUnit ( group, u, info.BitsPerSample, &hist[0], out[n:], 1 )
+        n+= 28
+      }
+    }
+  }
+
+  return n
+
+} // end decodeXASector
+
+
+// Llig el següent sector RAW. Torna false si no queden sectors.
+func readXASector(
+
+  f      TrackReader,
+  remain *int64,
+  sector []byte,
+
+) (bool,error) {
+
+  if *remain == 0 { return false,nil }
+  if _,err:= io.ReadFull ( f, sector ); err == io.EOF {
+    return false,nil
+  } else if err != nil {
+    return false,err
+  }
+  *remain--
+
+  return true,nil
+
+} // end readXASector
+
+
+// Recorre els sectors i torna els canals d'àudio trobats.
+func scanXAChannels( f TrackReader, nsectors int64 ) ([]XA_Channel,error) {
+
+  var ret []XA_Channel
+  var sector [SECTOR_SIZE]byte
+  remain:= nsectors
+  for {
+    ok,err:= readXASector ( f, &remain, sector[:] )
+    if err != nil { return nil,err }
+    if !ok { break }
+    if !isXAAudioSector ( sector[:] ) { continue }
+    file,channel:= sector[0x10],sector[0x11]&0x1f
+    i:= 0
+    for ; i < len(ret) && (ret[i].File != file || ret[i].Channel != channel);
+    i++ {
+    }
+    if i == len(ret) {
+      ret= append(ret,decodeXACodingInfo ( file, channel, sector[0x13] ))
+    }
+    ret[i].NumSectors++
+  }
+
+  return ret,nil
+
+} // end scanXAChannels
+
+
+
+
+/****************/
+/* PART PÚBLICA */
+/****************/
+
+// XA CHANNEL //////////////////////////////////////////////////////////////////
+
+type XA_Channel struct {
+  File          uint8
+  Channel       uint8
+  Stereo        bool
+  SampleRate    int // 37800 o 18900
+  BitsPerSample int // 4 o 8
+  NumSectors    int64
+}
+
+
+func (self *XA_Channel) NumChannels() int {
+  if self.Stereo {
+    return 2
+  } else {
+    return 1
+  }
+} // end NumChannels
+
+
+// Torna la grandària en bytes de l'àudio descodificat (PCM 16 bits).
+func (self *XA_Channel) PCMSize() int64 {
+  if self.BitsPerSample == 4 {
+    return self.NumSectors*_XA_NUM_GROUPS*8*28*2
+  } else {
+    return self.NumSectors*_XA_NUM_GROUPS*4*28*2
+  }
+} // end PCMSize
+
+
+// XA READER ///////////////////////////////////////////////////////////////////
+
+// Lector de l'àudio d'un canal en PCM 16 bits little-endian.
+type XA_Reader struct {
+
+  f       TrackReader
+  remain  int64 // Sectors que queden
+  info    XA_Channel
+  hist    [2][2]int32
+  sector  [SECTOR_SIZE]byte
+  samples [_XA_NUM_GROUPS*8*28]int16
+  buf     []byte
+  mem     [_XA_NUM_GROUPS*8*28*2]byte
+
+}
+
+
+func newXAReader(
+
+  f        TrackReader,
+  nsectors int64,
+  info     *XA_Channel,
+
+) *XA_Reader {
+
+  ret:= XA_Reader{
+    f      : f,
+    remain : nsectors,
+    info   : *info,
+  }
+  ret.buf= ret.mem[:0]
+
+  return &ret
+
+} // end newXAReader
+
+
+func (self *XA_Reader) loadNextSector() (bool,error) {
+
+  for {
+    ok,err:= self.readSector ()
+    if err != nil || !ok { return ok,err }
+    s:= self.sector[:]
+    if isXAAudioSector ( s ) && s[0x10] == self.info.File &&
+      s[0x11]&0x1f == self.info.Channel {
+      break
+    }
+  }
+  n:= decodeXASector ( self.sector[:], &self.info, &self.hist,
+    self.samples[:] )
+  for i:= 0; i < n; i++ {
+    self.mem[2*i]= uint8(uint16(self.samples[i]))
+    self.mem[2*i+1]= uint8(uint16(self.samples[i])>>8)
+  }
+  self.buf= self.mem[:2*n]
+
+  return true,nil
+
+} // end loadNextSector
+
+
+func (self *XA_Reader) readSector() (bool,error) {
+  ok,err:= readXASector ( self.f, &self.remain, self.sector[:] )
+  if err != nil {
+    return false,fmt.Errorf ( "failed to read XA sector: %s", err )
+  }
+  return ok,nil
+} // end readSector
+
+
+func (self *XA_Reader) Close() error {
+  return self.f.Close ()
+} // end Close
+
+
+func (self *XA_Reader) Info() *XA_Channel { return &self.info }
+
+
+func (self *XA_Reader) Read( b []byte ) (int,error) {
+
+  n:= 0
+  for n < len(b) {
+    if len(self.buf) == 0 {
+      ok,err:= self.loadNextSector ()
+      if err != nil { return n,err }
+      if !ok {
+        if n == 0 { return 0,io.EOF }
+        break
+      }
+    }
+    nc:= copy ( b[n:], self.buf )
+    self.buf= self.buf[nc:]
+    n+= nc
+  }
+
+  return n,nil
+
+} // end Read
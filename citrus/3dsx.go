@@ -0,0 +1,207 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  3dsx.go - Format 3DSX dels executables homebrew.
+ */
+
+package citrus
+
+import (
+  "errors"
+  "fmt"
+  "os"
+
+  "github.com/adriagipas/imgcp/utils"
+)
+
+
+/*********/
+/* TIPUS */
+/*********/
+
+const _3DSX_HEADER_SIZE     = 0x20
+const _3DSX_EXT_HEADER_SIZE = 0x2c
+
+type ThreeDSX_Segment struct {
+
+  Size       uint32
+  NAbsRelocs uint32 // Relocacions absolutes
+  NRelRelocs uint32 // Relocacions relatives
+
+}
+
+type ThreeDSX struct {
+
+  HeaderSize      uint16
+  RelocHeaderSize uint16
+  FormatVersion   uint32
+  Flags           uint32
+  Code            ThreeDSX_Segment
+  ReadOnly        ThreeDSX_Segment
+  Data            ThreeDSX_Segment // Size inclou el BSS
+  BSSSize         uint32
+
+  // Capçalera estesa (0 si no en té)
+  SMDHOffset  int64
+  SMDHSize    int64
+  RomFSOffset int64
+
+  file_name string
+  file_size int64
+
+}
+
+
+/************/
+/* FUNCIONS */
+/************/
+
+func NewThreeDSX( file_name string ) (*ThreeDSX,error) {
+
+  // Obri
+  fd,err:= os.Open ( file_name )
+  if err != nil { return nil,err }
+  defer fd.Close ()
+  info,err:= fd.Stat ()
+  if err != nil { return nil,err }
+  ret:= ThreeDSX{
+    file_name : file_name,
+    file_size : info.Size (),
+  }
+
+  // Capçalera
+  var buf [_3DSX_EXT_HEADER_SIZE]byte
+  n,err:= fd.ReadAt ( buf[:_3DSX_HEADER_SIZE], 0 )
+  if n != _3DSX_HEADER_SIZE {
+    return nil,errors.New ( "Error while reading 3DSX header: not enough bytes" )
+  } else if err != nil {
+    return nil,fmt.Errorf ( "Error while reading 3DSX header: %s", err )
+  }
+  if buf[0]!='3' || buf[1]!='D' || buf[2]!='S' || buf[3]!='X' {
+    return nil,fmt.Errorf ( "Not a 3DSX file: wrong magic number (%c%c%c%c)",
+      buf[0], buf[1], buf[2], buf[3] )
+  }
+  ret.HeaderSize= readLE16 ( buf[0x4:] )
+  ret.RelocHeaderSize= readLE16 ( buf[0x6:] )
+  ret.FormatVersion= readLE32 ( buf[0x8:] )
+  ret.Flags= readLE32 ( buf[0xc:] )
+  ret.Code.Size= readLE32 ( buf[0x10:] )
+  ret.ReadOnly.Size= readLE32 ( buf[0x14:] )
+  ret.Data.Size= readLE32 ( buf[0x18:] )
+  ret.BSSSize= readLE32 ( buf[0x1c:] )
+  if ret.HeaderSize < _3DSX_HEADER_SIZE || ret.RelocHeaderSize < 8 ||
+    ret.BSSSize > ret.Data.Size {
+    return nil,errors.New ( "Error while reading 3DSX header: wrong sizes" )
+  }
+
+  // Capçalera estesa
+  if ret.HeaderSize >= _3DSX_EXT_HEADER_SIZE {
+    n,err:= fd.ReadAt ( buf[_3DSX_HEADER_SIZE:], _3DSX_HEADER_SIZE )
+    if n != _3DSX_EXT_HEADER_SIZE-_3DSX_HEADER_SIZE {
+      return nil,errors.New ( "Error while reading 3DSX extended header:"+
+        " not enough bytes" )
+    } else if err != nil {
+      return nil,fmt.Errorf ( "Error while reading 3DSX extended header: %s",
+        err )
+    }
+    ret.SMDHOffset= int64(readLE32 ( buf[0x20:] ))
+    ret.SMDHSize= int64(readLE32 ( buf[0x24:] ))
+    ret.RomFSOffset= int64(readLE32 ( buf[0x28:] ))
+    if ret.SMDHOffset+ret.SMDHSize > ret.file_size ||
+      ret.RomFSOffset > ret.file_size {
+      return nil,fmt.Errorf ( "Mismatch between file size (%d) and the"+
+        " offsets specified in the extended header", ret.file_size )
+    }
+  }
+
+  // Capçaleres de relocació
+  reloc:= make([]byte,3*int(ret.RelocHeaderSize))
+  if _,err:= fd.ReadAt ( reloc, int64(ret.HeaderSize) ); err != nil {
+    return nil,fmt.Errorf ( "Error while reading 3DSX relocation headers: %s",
+      err )
+  }
+  for i,seg:= range []*ThreeDSX_Segment{&ret.Code,&ret.ReadOnly,&ret.Data} {
+    mem:= reloc[i*int(ret.RelocHeaderSize):]
+    seg.NAbsRelocs= readLE32 ( mem )
+    seg.NRelRelocs= readLE32 ( mem[4:] )
+  }
+
+  // Comprova que els segments i les relocacions caben en el fitxer.
+  size:= int64(ret.HeaderSize) + int64(len(reloc)) +
+    int64(ret.Code.Size) + int64(ret.ReadOnly.Size) +
+    int64(ret.Data.Size-ret.BSSSize)
+  for _,seg:= range []*ThreeDSX_Segment{&ret.Code,&ret.ReadOnly,&ret.Data} {
+    size+= 4*(int64(seg.NAbsRelocs)+int64(seg.NRelRelocs))
+  }
+  if size > ret.file_size {
+    return nil,fmt.Errorf ( "Mismatch between file size (%d) and the size "+
+      "specified in the header (%d)", ret.file_size, size )
+  }
+
+  return &ret,nil
+
+} // end NewThreeDSX
+
+
+// Si no en té torna nil sense error.
+func (self *ThreeDSX) OpenSMDH() (*utils.SubfileReader,error) {
+  if self.SMDHSize == 0 {
+    return nil,nil
+  }
+  return utils.NewSubfileReader ( self.file_name, self.SMDHOffset,
+    self.SMDHSize )
+} // end OpenSMDH
+
+
+// Si no en té torna nil sense error.
+func (self *ThreeDSX) GetSMDH() (*SMDH,error) {
+
+  fd,err:= self.OpenSMDH ()
+  if err != nil || fd == nil { return nil,err }
+  defer fd.Close ()
+
+  return ReadSMDH ( fd )
+
+} // end GetSMDH
+
+
+// La RomFS dels 3DSX sols conté el nivell 3 i arriba fins al final
+// del fitxer. Si no en té torna nil sense error.
+func (self *ThreeDSX) GetRomFS() (*RomFS_Directory,error) {
+  if self.RomFSOffset == 0 {
+    return nil,nil
+  }
+  return openRomFS (
+    self.file_name,
+    self.RomFSOffset,
+    self.file_size - self.RomFSOffset,
+    nil,
+    0,
+  )
+} // end GetRomFS
+
+
+// Grandària de la RomFS (0 si no en té).
+func (self *ThreeDSX) RomFSSize() int64 {
+  if self.RomFSOffset == 0 {
+    return 0
+  }
+  return self.file_size - self.RomFSOffset
+} // end RomFSSize
@@ -26,6 +26,7 @@ package citrus
 import (
   "errors"
   "fmt"
+  "io"
   "os"
 )
 
@@ -34,6 +35,8 @@ import (
 /* TIPUS */
 /*********/
 
+const _CCI_BUF_SIZE = 1024*1024
+
 type CCIHeader struct {
 
   NCSDHeader
@@ -154,3 +157,85 @@ func (self *CCI) GetNCCHPartition( ind int ) (*NCCH,error) {
   )
   
 } // CCI.GetNCCHPartition
+
+
+// Elimina el farciment (0xFF) que hi ha després de l'última
+// partició. Torna el nombre de bytes eliminats.
+func (self *CCI) Trim() (int64,error) {
+
+  // Obri
+  fd,err:= os.OpenFile ( self.file_name, os.O_RDWR, 0 )
+  if err != nil { return 0,err }
+  defer fd.Close ()
+  info,err:= fd.Stat ()
+  if err != nil { return 0,err }
+  size:= info.Size ()
+  end:= self.Header.DataEnd ()
+  if size <= end {
+    return 0,nil
+  }
+
+  // Comprova que sols és farciment
+  buf:= make([]byte,_CCI_BUF_SIZE)
+  for off:= end; off < size; {
+    chunk:= buf
+    if remain:= size-off; remain < int64(len(chunk)) {
+      chunk= chunk[:remain]
+    }
+    n,err:= fd.ReadAt ( chunk, off )
+    if err == io.EOF && n < len(chunk) {
+      err= io.ErrUnexpectedEOF
+    }
+    if err != nil && err != io.EOF {
+      return 0,fmt.Errorf ( "Error while reading CCI padding: %s", err )
+    }
+    for i:= 0; i < n; i++ {
+      if buf[i] != 0xff {
+        return 0,fmt.Errorf ( "Unable to trim CCI: non-padding data found"+
+          " at offset %X", off+int64(i) )
+      }
+    }
+    off+= int64(n)
+  }
+
+  // Trunca
+  if err:= fd.Truncate ( end ); err != nil { return 0,err }
+  self.Header.Trimmed= end < self.Header.Size
+
+  return size-end,nil
+
+} // end Trim
+
+
+// Restaura la grandària original (la grandària de la imatge indicada
+// en la capçalera) afegint farciment (0xFF). Torna el nombre de bytes
+// afegits.
+func (self *CCI) Untrim() (int64,error) {
+
+  // Obri
+  fd,err:= os.OpenFile ( self.file_name, os.O_RDWR, 0 )
+  if err != nil { return 0,err }
+  defer fd.Close ()
+  info,err:= fd.Stat ()
+  if err != nil { return 0,err }
+  size:= info.Size ()
+  if size >= self.Header.Size {
+    return 0,nil
+  }
+
+  // Afegeix farciment
+  buf:= make([]byte,_CCI_BUF_SIZE)
+  for i:= range buf {
+    buf[i]= 0xff
+  }
+  for off:= size; off < self.Header.Size; {
+    n:= self.Header.Size-off
+    if n > int64(len(buf)) { n= int64(len(buf)) }
+    if _,err:= fd.WriteAt ( buf[:n], off ); err != nil { return 0,err }
+    off+= n
+  }
+  self.Header.Trimmed= false
+
+  return self.Header.Size-size,nil
+
+} // end Untrim
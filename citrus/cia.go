@@ -0,0 +1,351 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  cia.go - CTR Importable Archive format.
+ */
+
+package citrus
+
+import (
+  "errors"
+  "fmt"
+  "os"
+
+  "github.com/adriagipas/imgcp/utils"
+)
+
+
+/*********/
+/* TIPUS */
+/*********/
+
+const (
+  CIA_CONTENT_TYPE_ENCRYPTED = 0x0001
+  CIA_CONTENT_TYPE_DISC      = 0x0002
+  CIA_CONTENT_TYPE_CFM       = 0x0004
+  CIA_CONTENT_TYPE_OPTIONAL  = 0x4000
+  CIA_CONTENT_TYPE_SHARED    = 0x8000
+)
+
+type CIAHeader struct {
+
+  HeaderSize  int64
+  Type        uint16
+  Version     uint16
+  CertSize    int64
+  TicketSize  int64
+  TMDSize     int64
+  MetaSize    int64
+  ContentSize int64
+
+  // Offsets de les seccions (alineades a 64 bytes)
+  CertOffset    int64
+  TicketOffset  int64
+  TMDOffset     int64
+  ContentOffset int64
+  MetaOffset    int64
+
+  content_index [0x2000]byte
+
+}
+
+type CIA_Content struct {
+
+  Id     uint32
+  Index  uint16
+  Type   uint16
+  Size   int64
+  Hash   [0x20]byte // SHA-256
+  Offset int64      // Offset en el fitxer CIA
+
+}
+
+type CIA_TMD struct {
+
+  Issuer       string
+  TitleId      uint64
+  TitleType    uint32
+  TitleVersion uint16
+  SaveDataSize uint32
+  Contents     []CIA_Content // Sols els que estan en el CIA
+
+}
+
+type CIA struct {
+
+  Header    CIAHeader
+  TMD       CIA_TMD
+  file_name string
+
+}
+
+
+/************/
+/* FUNCIONS */
+/************/
+
+func readBE16( mem []byte ) uint16 {
+  return (uint16(mem[0])<<8) | uint16(mem[1])
+} // end readBE16
+
+
+func readBE32( mem []byte ) uint32 {
+  return (uint32(mem[0])<<24) |
+    (uint32(mem[1])<<16) |
+    (uint32(mem[2])<<8) |
+    uint32(mem[3])
+} // end readBE32
+
+
+func readBE64( mem []byte ) uint64 {
+  return (uint64(readBE32 ( mem ))<<32) | uint64(readBE32 ( mem[4:] ))
+} // end readBE64
+
+
+func alignCIA( offset int64 ) int64 {
+  return (offset+63)&^63
+} // end alignCIA
+
+
+// Grandària de la signatura (incloent el padding) a partir del seu
+// tipus. Torna -1 si el tipus és desconegut.
+func getSignatureSize( sig_type uint32 ) int64 {
+
+  switch sig_type {
+  case 0x010000, 0x010003: // RSA-4096
+    return 0x200+0x3c
+  case 0x010001, 0x010004: // RSA-2048
+    return 0x100+0x3c
+  case 0x010002, 0x010005: // ECDSA
+    return 0x3c+0x40
+  default:
+    return -1
+  }
+
+} // end getSignatureSize
+
+
+func (self *CIAHeader) Read( fd *os.File ) error {
+
+  // Grandària fitxer
+  info,err:= fd.Stat ()
+  if err != nil {
+    return err
+  }
+  file_size:= info.Size ()
+
+  // Llig capçalera
+  var buf [0x2020]byte
+  n,err:= fd.ReadAt ( buf[:], 0 )
+  if err != nil {
+    return fmt.Errorf ( "Error while reading CIA header: %s", err )
+  }
+  if n != len(buf) {
+    return errors.New ( "Error while reading CIA header: not enough bytes" )
+  }
+  self.HeaderSize= int64(uint32(buf[0]) |
+    (uint32(buf[1])<<8) |
+    (uint32(buf[2])<<16) |
+    (uint32(buf[3])<<24))
+  if self.HeaderSize != 0x2020 {
+    return fmt.Errorf ( "Not a CIA file: wrong header size (%d)",
+      self.HeaderSize )
+  }
+  self.Type= uint16(buf[4]) | (uint16(buf[5])<<8)
+  self.Version= uint16(buf[6]) | (uint16(buf[7])<<8)
+  size:= func(mem []byte) int64 {
+    return int64(uint32(mem[0]) |
+      (uint32(mem[1])<<8) |
+      (uint32(mem[2])<<16) |
+      (uint32(mem[3])<<24))
+  }
+  self.CertSize= size ( buf[0x8:] )
+  self.TicketSize= size ( buf[0xc:] )
+  self.TMDSize= size ( buf[0x10:] )
+  self.MetaSize= size ( buf[0x14:] )
+  self.ContentSize= int64(uint64(size ( buf[0x18:] )) |
+    (uint64(size ( buf[0x1c:] ))<<32))
+  copy ( self.content_index[:], buf[0x20:] )
+
+  // Offsets
+  self.CertOffset= alignCIA ( self.HeaderSize )
+  self.TicketOffset= alignCIA ( self.CertOffset+self.CertSize )
+  self.TMDOffset= alignCIA ( self.TicketOffset+self.TicketSize )
+  self.ContentOffset= alignCIA ( self.TMDOffset+self.TMDSize )
+  self.MetaOffset= alignCIA ( self.ContentOffset+self.ContentSize )
+  if self.ContentSize < 0 || self.MetaOffset+self.MetaSize > file_size {
+    return fmt.Errorf ( "Mismatch between file size (%d) and the size "+
+      "specified in the header (%d)", file_size,
+      self.MetaOffset+self.MetaSize )
+  }
+
+  return nil
+
+} // end CIAHeader.Read
+
+
+// Indica si el contingut amb l'índex indicat està en el CIA.
+func (self *CIAHeader) HasContent( index uint16 ) bool {
+  return self.content_index[index/8]&(0x80>>(index%8)) != 0
+} // end CIAHeader.HasContent
+
+
+func (self *CIA_TMD) read( fd *os.File, header *CIAHeader ) error {
+
+  // Llig TMD
+  if header.TMDSize < 4 {
+    return errors.New ( "Error while reading TMD: not enough bytes" )
+  }
+  buf:= make([]byte,header.TMDSize)
+  n,err:= fd.ReadAt ( buf, header.TMDOffset )
+  if err != nil {
+    return fmt.Errorf ( "Error while reading TMD: %s", err )
+  }
+  if n != len(buf) {
+    return errors.New ( "Error while reading TMD: not enough bytes" )
+  }
+
+  // Capçalera
+  sig_type:= readBE32 ( buf )
+  sig_size:= getSignatureSize ( sig_type )
+  if sig_size == -1 {
+    return fmt.Errorf ( "Error while reading TMD: unknown signature"+
+      " type (%08X)", sig_type )
+  }
+  off:= 4+sig_size
+  if off+0xc4 > int64(len(buf)) {
+    return errors.New ( "Error while reading TMD: not enough bytes" )
+  }
+  h:= buf[off:off+0xc4]
+  self.Issuer= string(h[:0x40])
+  for i,c:= range h[:0x40] {
+    if c == 0 {
+      self.Issuer= string(h[:i])
+      break
+    }
+  }
+  self.TitleId= readBE64 ( h[0x4c:] )
+  self.TitleType= readBE32 ( h[0x54:] )
+  self.SaveDataSize= uint32(h[0x5a]) |
+    (uint32(h[0x5b])<<8) |
+    (uint32(h[0x5c])<<16) |
+    (uint32(h[0x5d])<<24)
+  self.TitleVersion= readBE16 ( h[0x9c:] )
+  ncontents:= int64(readBE16 ( h[0x9e:] ))
+
+  // Contingut (després de la taula de 64 Content Info Records)
+  off+= 0xc4 + 64*0x24
+  if off+ncontents*0x30 > int64(len(buf)) {
+    return errors.New ( "Error while reading TMD: not enough bytes" )
+  }
+  self.Contents= make([]CIA_Content,0,ncontents)
+  offset:= header.ContentOffset
+  for i:= int64(0); i < ncontents; i++ {
+    rec:= buf[off+i*0x30:off+(i+1)*0x30]
+    content:= CIA_Content{
+      Id    : readBE32 ( rec ),
+      Index : readBE16 ( rec[0x4:] ),
+      Type  : readBE16 ( rec[0x6:] ),
+      Size  : int64(readBE64 ( rec[0x8:] )),
+    }
+    copy ( content.Hash[:], rec[0x10:0x30] )
+    if !header.HasContent ( content.Index ) { continue }
+    content.Offset= offset
+    offset+= content.Size
+    if content.Size < 0 ||
+      offset > header.ContentOffset+header.ContentSize {
+      return fmt.Errorf ( "Error while reading TMD: content %d is out of"+
+        " the content section", content.Index )
+    }
+    self.Contents= append(self.Contents,content)
+  }
+
+  return nil
+
+} // end CIA_TMD.read
+
+
+func NewCIA( file_name string ) (*CIA,error) {
+
+  // Inicialitza.
+  ret:= CIA{
+    file_name: file_name,
+  }
+
+  // Llig capçalera i TMD.
+  fd,err:= os.Open ( file_name )
+  if err != nil {
+    return nil,err
+  }
+  defer fd.Close ()
+  if err:= ret.Header.Read ( fd ); err != nil {
+    return nil,err
+  }
+  if err:= ret.TMD.read ( fd, &ret.Header ); err != nil {
+    return nil,err
+  }
+
+  return &ret,nil
+
+} // end NewCIA
+
+
+// ind és la posició en TMD.Contents.
+func (self *CIA) GetNCCHContent( ind int ) (*NCCH,error) {
+
+  content:= &self.TMD.Contents[ind]
+  if content.Type&CIA_CONTENT_TYPE_ENCRYPTED != 0 {
+    return nil,fmt.Errorf ( "Content %d is encrypted", content.Index )
+  }
+  return newNCCHSubfile (
+    self.file_name,
+    content.Offset,
+    content.Size,
+  )
+
+} // end CIA.GetNCCHContent
+
+
+// Comprova el SHA-256 del contingut (tal com està en el CIA) amb el
+// del TMD. El hash del TMD és de les dades desxifrades, per tant no
+// té sentit en continguts xifrats (CIA_CONTENT_TYPE_ENCRYPTED).
+func (self *CIA) VerifyContentHash( ind int ) (bool,error) {
+
+  content:= &self.TMD.Contents[ind]
+  fd,err:= utils.NewSubfileReader ( self.file_name, content.Offset,
+    content.Size )
+  if err != nil { return false,err }
+  defer fd.Close ()
+  sum,err:= hashSection ( fd, content.Size )
+  if err != nil {
+    return false,fmt.Errorf ( "Error while reading content %d: %s",
+      content.Index, err )
+  }
+
+  return sum == content.Hash,nil
+
+} // end CIA.VerifyContentHash
+
+
+// Torna la versió en format major.minor.micro.
+func CIA_version2str( version uint16 ) string {
+  return fmt.Sprintf ( "%d.%d.%d",
+    (version>>10)&0x3f, (version>>4)&0x3f, version&0xf )
+} // end CIA_version2str
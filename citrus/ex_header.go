@@ -0,0 +1,256 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  ex_header.go - NCCH Extended Header.
+ */
+
+package citrus
+
+import (
+  "bytes"
+  "errors"
+  "fmt"
+  "io"
+)
+
+
+/*********/
+/* TIPUS */
+/*********/
+
+// Grandària de l'ExHeader incloent l'Access Descriptor.
+const NCCH_EXHEADER_SIZE = 0x800
+
+const (
+  EXHEADER_FLAGS_COMPRESSED_CODE = 0x01
+  EXHEADER_FLAGS_SD_APPLICATION  = 0x02
+)
+
+// Permisos ARM9
+const (
+  EXHEADER_ARM9_MOUNT_NAND       = 0x001
+  EXHEADER_ARM9_MOUNT_NAND_RO    = 0x002
+  EXHEADER_ARM9_MOUNT_TWLN       = 0x004
+  EXHEADER_ARM9_MOUNT_WNAND      = 0x008
+  EXHEADER_ARM9_MOUNT_CARD_SPI   = 0x010
+  EXHEADER_ARM9_USE_SDIF3        = 0x020
+  EXHEADER_ARM9_CREATE_SEED      = 0x040
+  EXHEADER_ARM9_USE_CARD_SPI     = 0x080
+  EXHEADER_ARM9_SD_APPLICATION   = 0x100
+  EXHEADER_ARM9_MOUNT_SDMC_WRITE = 0x200
+)
+
+type ExHeader_CodeSet struct {
+
+  Address uint32
+  Pages   uint32 // Grandària de la regió física en pàgines
+  Size    uint32
+
+}
+
+type ExHeader_ARM11 struct {
+
+  ProgramId             uint64
+  CoreVersion           uint32
+  EnableL2Cache         bool
+  CPUSpeed804MHz        bool
+  New3DSSystemMode      uint8
+  IdealProcessor        uint8
+  AffinityMask          uint8
+  SystemMode            uint8
+  Priority              uint8
+  ResourceLimitCategory uint8
+
+  // Emmagatzematge
+  ExtDataId           uint64
+  SystemSaveDataIds   [2]uint32
+  AccessibleUniqueIds uint64
+  FSAccess            uint64 // 56 bits
+  NoRomFS             bool
+  ExtSaveDataAccess   bool
+
+  Services []string
+
+}
+
+type ExHeader struct {
+
+  Title           string
+  Flags           uint8
+  RemasterVersion uint16
+  Text            ExHeader_CodeSet
+  ReadOnly        ExHeader_CodeSet
+  Data            ExHeader_CodeSet
+  StackSize       uint32
+  BSSSize         uint32
+  Dependencies    []uint64 // Title Ids
+  SaveDataSize    uint64
+  JumpId          uint64
+  ARM11           ExHeader_ARM11
+  ARM11Kernel     []uint32 // Descriptors
+  ARM9Access      uint32   // EXHEADER_ARM9_*
+  ARM9Version     uint8
+
+}
+
+
+/************/
+/* FUNCIONS */
+/************/
+
+func readLE16( mem []byte ) uint16 {
+  return uint16(mem[0]) | (uint16(mem[1])<<8)
+} // end readLE16
+
+
+func readLE32( mem []byte ) uint32 {
+  return uint32(mem[0]) |
+    (uint32(mem[1])<<8) |
+    (uint32(mem[2])<<16) |
+    (uint32(mem[3])<<24)
+} // end readLE32
+
+
+func readLE64( mem []byte ) uint64 {
+  return uint64(readLE32 ( mem )) | (uint64(readLE32 ( mem[4:] ))<<32)
+} // end readLE64
+
+
+func newExHeader_CodeSet( mem []byte ) ExHeader_CodeSet {
+  return ExHeader_CodeSet{
+    Address : readLE32 ( mem ),
+    Pages   : readLE32 ( mem[4:] ),
+    Size    : readLE32 ( mem[8:] ),
+  }
+} // end newExHeader_CodeSet
+
+
+// mem són els 0x170 bytes de les capacitats locals de l'ARM11.
+func (self *ExHeader_ARM11) read( mem []byte ) {
+
+  self.ProgramId= readLE64 ( mem )
+  self.CoreVersion= readLE32 ( mem[0x8:] )
+  self.EnableL2Cache= (mem[0xc]&0x01) != 0
+  self.CPUSpeed804MHz= (mem[0xc]&0x02) != 0
+  self.New3DSSystemMode= mem[0xd]&0x0f
+  self.IdealProcessor= mem[0xe]&0x03
+  self.AffinityMask= (mem[0xe]>>2)&0x03
+  self.SystemMode= mem[0xe]>>4
+  self.Priority= mem[0xf]
+  self.ResourceLimitCategory= mem[0x16f]
+
+  // Emmagatzematge
+  self.ExtDataId= readLE64 ( mem[0x30:] )
+  self.SystemSaveDataIds[0]= readLE32 ( mem[0x38:] )
+  self.SystemSaveDataIds[1]= readLE32 ( mem[0x3c:] )
+  self.AccessibleUniqueIds= readLE64 ( mem[0x40:] )
+  self.FSAccess= readLE64 ( mem[0x48:] )&0x00ffffffffffffff
+  self.NoRomFS= (mem[0x4f]&0x01) != 0
+  self.ExtSaveDataAccess= (mem[0x4f]&0x02) != 0
+
+  // Serveis (32 normals + 2 estesos)
+  self.Services= make([]string,0,34)
+  for off:= 0x50; off < 0x160; off+= 8 {
+    name:= bytes.TrimRight ( mem[off:off+8], "\000" )
+    if len(name) > 0 {
+      self.Services= append(self.Services,string(name))
+    }
+  }
+
+} // end ExHeader_ARM11.read
+
+
+// mem són els primers 0x400 bytes de l'ExHeader.
+func (self *ExHeader) read( mem []byte ) {
+
+  // System Control Info
+  self.Title= string(bytes.TrimRight ( mem[:8], "\000" ))
+  self.Flags= mem[0xd]
+  self.RemasterVersion= readLE16 ( mem[0xe:] )
+  self.Text= newExHeader_CodeSet ( mem[0x10:] )
+  self.StackSize= readLE32 ( mem[0x1c:] )
+  self.ReadOnly= newExHeader_CodeSet ( mem[0x20:] )
+  self.Data= newExHeader_CodeSet ( mem[0x30:] )
+  self.BSSSize= readLE32 ( mem[0x3c:] )
+  self.Dependencies= nil
+  for off:= 0x40; off < 0x1c0; off+= 8 {
+    if id:= readLE64 ( mem[off:] ); id != 0 {
+      self.Dependencies= append(self.Dependencies,id)
+    }
+  }
+  self.SaveDataSize= readLE64 ( mem[0x1c0:] )
+  self.JumpId= readLE64 ( mem[0x1c8:] )
+
+  // Access Control Info
+  self.ARM11.read ( mem[0x200:0x370] )
+  self.ARM11Kernel= nil
+  for off:= 0x370; off < 0x3e0; off+= 4 {
+    if desc:= readLE32 ( mem[off:] ); desc != 0xffffffff {
+      self.ARM11Kernel= append(self.ARM11Kernel,desc)
+    }
+  }
+  self.ARM9Access= readLE32 ( mem[0x3f0:] )
+  self.ARM9Version= mem[0x3ff]
+
+} // end ExHeader.read
+
+
+// Si no en té torna nil sense error. Si està xifrat es desxifra.
+func (self *NCCH) OpenExHeader() (SectionReader,error) {
+
+  if self.Header.ExHeaderSize == 0 {
+    return nil,nil
+  }
+  primary,_,err:= self.getSectionKeys ( _NCCH_SECTION_EXHEADER, 0x200 )
+  if err != nil { return nil,err }
+
+  return openSection (
+    self.file_name,
+    self.offset + 0x200,
+    NCCH_EXHEADER_SIZE,
+    primary,
+    0,
+  )
+
+} // end OpenExHeader
+
+
+// Si no en té torna nil sense error.
+func (self *NCCH) GetExHeader() (*ExHeader,error) {
+
+  // Llig
+  fd,err:= self.OpenExHeader ()
+  if err != nil || fd == nil { return nil,err }
+  defer fd.Close ()
+  var buf [0x400]byte
+  if _,err:= io.ReadFull ( fd, buf[:] ); err == io.ErrUnexpectedEOF ||
+    err == io.EOF {
+    return nil,errors.New ( "Error while reading ExHeader: not enough bytes" )
+  } else if err != nil {
+    return nil,fmt.Errorf ( "Error while reading ExHeader: %s", err )
+  }
+
+  // Processa
+  ret:= ExHeader{}
+  ret.read ( buf[:] )
+
+  return &ret,nil
+
+} // end GetExHeader
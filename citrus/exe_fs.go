@@ -27,8 +27,6 @@ import (
   "bytes"
   "errors"
   "fmt"
-  
-  "github.com/adriagipas/imgcp/utils"
 )
 
 
@@ -41,6 +39,7 @@ type ExeFS_File struct {
   Name   string
   Offset uint32
   Size   uint32
+  Hash   [32]byte // SHA-256
   
 }
 
@@ -50,6 +49,8 @@ type ExeFS struct {
   
   file_name  string
   offset     int64
+  primary    *_Section_Key // Capçalera, icon i banner. nil si no xifrat
+  secondary  *_Section_Key // Resta de fitxers
   
 }
 
@@ -58,8 +59,8 @@ type ExeFS struct {
 /* FUNCIONS */
 /************/
 
-// mem ha de ser un slice de 16 bytes
-func (self *ExeFS) addFile( mem []byte ) {
+// mem ha de ser un slice de 16 bytes i hash de 32 bytes.
+func (self *ExeFS) addFile( mem []byte, hash []byte ) {
   
   file_name:= bytes.TrimRight ( mem[:8], "\000" )
   if len(file_name)>0 {
@@ -71,14 +72,13 @@ func (self *ExeFS) addFile( mem []byte ) {
       (uint32(mem[13])<<8) |
       (uint32(mem[14])<<16) |
       (uint32(mem[15])<<24)
-    self.Files= append(
-      self.Files,
-      ExeFS_File{
-        Name: string(file_name),
-        Offset: offset,
-        Size: size,
-      },
-    )
+    file:= ExeFS_File{
+      Name: string(file_name),
+      Offset: offset,
+      Size: size,
+    }
+    copy ( file.Hash[:], hash )
+    self.Files= append(self.Files,file)
   }
   
 } // end ExeFS.addFile
@@ -88,10 +88,12 @@ func newExeFS(
   file_name string,
   offset    int64,
   length    int64,
+  primary   *_Section_Key,
+  secondary *_Section_Key,
 ) (*ExeFS,error) {
 
   // Obri subfitxer.
-  fd,err:= utils.NewSubfileReader ( file_name, offset, length )
+  fd,err:= openSection ( file_name, offset, length, primary, 0 )
   if err != nil { return nil,err }
   defer fd.Close ()
   
@@ -111,12 +113,15 @@ func newExeFS(
     Files: nil,
     file_name: file_name,
     offset: offset,
+    primary: primary,
+    secondary: secondary,
   }
   ret.Files= make([]ExeFS_File,0,10)
 
-  // Afegeix fitxers
+  // Afegeix fitxers. Els hashos estan al final de la capçalera en
+  // ordre invers.
   for i:= 0; i < 10; i++ {
-    ret.addFile ( buf[i*16:(i+1)*16])
+    ret.addFile ( buf[i*16:(i+1)*16], buf[0x200-(i+1)*0x20:0x200-i*0x20] )
   }
   
   return &ret,nil
@@ -124,15 +129,22 @@ func newExeFS(
 } // end newExeFS
 
 
-func (self *ExeFS) Open( file *ExeFS_File ) (*utils.SubfileReader,error) {
-  return utils.NewSubfileReader (
+// Els fitxers icon i banner es xifren amb la clau primària.
+func (self *ExeFS) Open( file *ExeFS_File ) (SectionReader,error) {
+  key:= self.secondary
+  if file.Name == "icon" || file.Name == "banner" {
+    key= self.primary
+  }
+  return openSection (
     self.file_name,
     0x200 + self.offset + int64(uint64(file.Offset)),
     int64(uint64(file.Size)),
+    key,
+    0x200 + int64(uint64(file.Offset)),
   )
 } // end ExeFS.Open
 
 
-func (self *ExeFS) OpenIndex( index int ) (*utils.SubfileReader,error) {
+func (self *ExeFS) OpenIndex( index int ) (SectionReader,error) {
   return self.Open ( &self.Files[index] )
 } // end ExeFS.OpenIndex
@@ -24,6 +24,7 @@
 package citrus
 
 import (
+  "crypto/cipher"
   "errors"
   "fmt"
   "io"
@@ -67,19 +68,29 @@ type NCCH_FileOffset struct {
 
 type NCCH_Header struct {
 
-  Size        int64
-  Id          uint64
-  MakerCode   string
-  Version     uint16
-  ProgramId   uint64
-  ProductCode string
-  Platform    int
-  Flags       uint8
-  Type        int
-  Plain       NCCH_FileOffset
-  Logo        NCCH_FileOffset
-  ExeFS       NCCH_FileOffset
-  RomFS       NCCH_FileOffset
+  Size         int64
+  Id           uint64
+  MakerCode    string
+  Version      uint16
+  ProgramId    uint64
+  ProductCode  string
+  ExHeaderSize int64 // 0 si no en té
+  Platform     int
+  Flags        uint8
+  Type         int
+  Plain        NCCH_FileOffset
+  Logo         NCCH_FileOffset
+  ExeFS        NCCH_FileOffset
+  RomFS        NCCH_FileOffset
+  LogoHash     [32]byte // SHA-256
+  ExHeaderHash [32]byte // SHA-256 dels primers ExHeaderSize bytes
+  ExeFSHash    [32]byte // SHA-256 de la regió de hash de l'ExeFS
+  RomFSHash    [32]byte // SHA-256 de la regió de hash de la RomFS
+  Crypto       int      // NCCH_CRYPTO_*
+  Seed         bool     // La clau secundària empra una llavor
+  KeyY         [16]byte
+
+  seed_check [4]byte
   
 }
 
@@ -89,6 +100,12 @@ type NCCH struct {
 
   file_name string
   offset    int64
+
+  // Claus (nil si no està xifrat)
+  keys_ready bool
+  keys_err   error
+  primary    cipher.Block
+  secondary  cipher.Block
   
 }
 
@@ -173,6 +190,10 @@ func (self *NCCH_Header) Read( fd io.Reader, file_size int64 ) error {
     (uint64(buf[0x11e])<<48) |
     (uint64(buf[0x11f])<<56)
   self.ProductCode= string(buf[0x150:0x160])
+  self.ExHeaderSize= int64(uint32(buf[0x180]) |
+    (uint32(buf[0x181])<<8) |
+    (uint32(buf[0x182])<<16) |
+    (uint32(buf[0x183])<<24))
   switch buf[0x188+4] {
   case 0x01:
     self.Platform= NCCH_PLATFORM_3DS
@@ -192,11 +213,41 @@ func (self *NCCH_Header) Read( fd io.Reader, file_size int64 ) error {
     self.Type= NCCH_TYPE_UNK
   }
 
+  // Xifrat
+  copy ( self.KeyY[:], buf[:16] )
+  copy ( self.seed_check[:], buf[0x114:0x118] )
+  cflags:= buf[0x188+7]
+  if (cflags&_NCCH_CFLAG_NO_CRYPTO) != 0 {
+    self.Crypto= NCCH_CRYPTO_NONE
+  } else if (cflags&_NCCH_CFLAG_FIXED_KEY) != 0 {
+    self.Crypto= NCCH_CRYPTO_FIXED
+  } else {
+    switch buf[0x188+3] {
+    case 0x00:
+      self.Crypto= NCCH_CRYPTO_ORIGINAL
+    case 0x01:
+      self.Crypto= NCCH_CRYPTO_7X
+    case 0x0a:
+      self.Crypto= NCCH_CRYPTO_93
+    case 0x0b:
+      self.Crypto= NCCH_CRYPTO_96
+    default:
+      self.Crypto= NCCH_CRYPTO_UNK
+    }
+    self.Seed= (cflags&_NCCH_CFLAG_SEED) != 0
+  }
+  
   // Llig els offsets dels fitxers.
   self.Plain= newNCCH_FileOffset ( buf[0x190:0x198] )
   self.Logo= newNCCH_FileOffset ( buf[0x198:0x1a0] )
   self.ExeFS= newNCCH_FileOffset ( buf[0x1a0:0x1ac] )
   self.RomFS= newNCCH_FileOffset ( buf[0x1b0:0x1bc] )
+
+  // Hashos
+  copy ( self.LogoHash[:], buf[0x130:0x150] )
+  copy ( self.ExHeaderHash[:], buf[0x160:0x180] )
+  copy ( self.ExeFSHash[:], buf[0x1c0:0x1e0] )
+  copy ( self.RomFSHash[:], buf[0x1e0:0x200] )
   
   return nil
   
@@ -243,29 +294,39 @@ func NewNCCH( file_name string ) (*NCCH,error) {
 } // end NewNCCH
 
 
-// Si no en té torna nil sense error.
+// Si no en té torna nil sense error. Si està xifrat es desxifra.
 func (self *NCCH) GetExeFS() (*ExeFS,error) {
   if self.Header.ExeFS.Size == 0 {
     return nil,nil
   } else {
+    primary,secondary,err:= self.getSectionKeys ( _NCCH_SECTION_EXEFS,
+      self.Header.ExeFS.Offset )
+    if err != nil { return nil,err }
     return newExeFS (
       self.file_name,
       self.offset + self.Header.ExeFS.Offset,
       self.Header.ExeFS.Size,
+      primary,
+      secondary,
     )
   }
 } // end GetExeFS
 
 
-// Si no en té torna nil sense error.
+// Si no en té torna nil sense error. Si està xifrat es desxifra.
 func (self *NCCH) GetRomFS() (*RomFS_Directory,error) {
   if self.Header.RomFS.Size == 0 {
     return nil,nil
   } else {
+    _,secondary,err:= self.getSectionKeys ( _NCCH_SECTION_ROMFS,
+      self.Header.RomFS.Offset )
+    if err != nil { return nil,err }
     return openRomFS (
       self.file_name,
       self.offset + self.Header.RomFS.Offset,
       self.Header.RomFS.Size,
+      secondary,
+      _BASE_OFFSET,
     )
   }
 } // end GetRomFS
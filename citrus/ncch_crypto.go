@@ -0,0 +1,566 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  ncch_crypto.go - Xifrat AES-CTR de les seccions NCCH. Les claus
+ *                   (KeyX) es lligen del fitxer aes_keys.txt de
+ *                   l'usuari i les llavors de seeddb.bin.
+ */
+
+package citrus
+
+import (
+  "bufio"
+  "bytes"
+  "crypto/aes"
+  "crypto/cipher"
+  "crypto/sha256"
+  "encoding/hex"
+  "errors"
+  "fmt"
+  "io"
+  "os"
+  "path"
+  "strconv"
+  "strings"
+
+  "github.com/adriagipas/imgcp/utils"
+)
+
+
+/*********/
+/* TIPUS */
+/*********/
+
+// Mètodes de xifrat
+const (
+  NCCH_CRYPTO_NONE     = 0
+  NCCH_CRYPTO_FIXED    = 1 // Clau fixa
+  NCCH_CRYPTO_ORIGINAL = 2 // Keyslot 0x2C
+  NCCH_CRYPTO_7X       = 3 // Keyslot 0x25
+  NCCH_CRYPTO_93       = 4 // Keyslot 0x18 (New 3DS)
+  NCCH_CRYPTO_96       = 5 // Keyslot 0x1B (New 3DS)
+  NCCH_CRYPTO_UNK      = -1
+)
+
+// Tipus de secció (part del comptador)
+const (
+  _NCCH_SECTION_EXHEADER = 1
+  _NCCH_SECTION_EXEFS    = 2
+  _NCCH_SECTION_ROMFS    = 3
+)
+
+// Flags de xifrat (byte 7 dels flags)
+const (
+  _NCCH_CFLAG_FIXED_KEY = 0x01
+  _NCCH_CFLAG_NO_CRYPTO = 0x04
+  _NCCH_CFLAG_SEED      = 0x20
+)
+
+// Lector d'un tros d'una secció, possiblement xifrada.
+type SectionReader interface {
+  Read(buf []byte) (int,error)
+  Seek(offset int64,whence int) (int64,error)
+  Close() error
+}
+
+// Clau i comptador inicial d'una secció.
+type _Section_Key struct {
+  block cipher.Block
+  ctr   [16]byte
+}
+
+type _CTR_Reader struct {
+  f       *utils.SubfileReader
+  key     *_Section_Key
+  sec_off int64 // Posició del tros dins de la secció
+  pos     int64 // Posició actual dins del tros
+}
+
+type _AES_Keys struct {
+  generator [16]byte
+  key_x     map[uint8][16]byte
+}
+
+
+/***********/
+/* LECTORS */
+/***********/
+
+// Obri un tros d'una secció. offset i length són relatius al
+// fitxer. sec_off és la posició del tros dins de la secció. Si key
+// és nil no es desxifra.
+func openSection(
+
+  file_name string,
+  offset    int64,
+  length    int64,
+  key       *_Section_Key,
+  sec_off   int64,
+
+) (SectionReader,error) {
+
+  f,err:= utils.NewSubfileReader ( file_name, offset, length )
+  if err != nil { return nil,err }
+  if key == nil { return f,nil }
+  ret:= _CTR_Reader{
+    f       : f,
+    key     : key,
+    sec_off : sec_off,
+    pos     : 0,
+  }
+
+  return &ret,nil
+
+} // end openSection
+
+
+func (self *_CTR_Reader) Close() error {
+  return self.f.Close ()
+} // end Close
+
+
+func (self *_CTR_Reader) Read( buf []byte ) (int,error) {
+
+  n,err:= self.f.Read ( buf )
+  if err != nil || n <= 0 { return n,err }
+  self.key.xorKeyStream ( buf[:n], self.sec_off+self.pos )
+  self.pos+= int64(n)
+
+  return n,nil
+
+} // end Read
+
+
+func (self *_CTR_Reader) Seek( offset int64, whence int ) (int64,error) {
+
+  ret,err:= self.f.Seek ( offset, whence )
+  if err != nil { return ret,err }
+  self.pos= ret
+
+  return ret,nil
+
+} // end Seek
+
+
+// Desxifra buf, que està en la posició pos de la secció.
+func (self *_Section_Key) xorKeyStream( buf []byte, pos int64 ) {
+
+  // Comptador
+  var ctr [16]byte
+  copy ( ctr[:], self.ctr[:] )
+  carry:= uint64(pos/16)
+  for i:= 15; i >= 0 && carry != 0; i-- {
+    carry+= uint64(ctr[i])
+    ctr[i]= uint8(carry)
+    carry>>= 8
+  }
+
+  // Desxifra
+  stream:= cipher.NewCTR ( self.block, ctr[:] )
+  if skip:= pos%16; skip != 0 {
+    var tmp [16]byte
+    stream.XORKeyStream ( tmp[:skip], tmp[:skip] )
+  }
+  stream.XORKeyStream ( buf, buf )
+
+} // end xorKeyStream
+
+
+/*********/
+/* CLAUS */
+/*********/
+
+// Constant del generador de claus.
+var _KEY_GENERATOR= [16]byte{
+  0x1f,0xf9,0xe9,0xaa,0xc5,0xfe,0x04,0x08,
+  0x02,0x45,0x91,0xdc,0x5d,0x52,0x76,0x8a,
+}
+
+var _aes_keys *_AES_Keys= nil
+var _aes_keys_err error= nil
+
+
+// Busca un fitxer de claus. Primer la variable d'entorn, després el
+// directori actual i per últim ~/.config/imgcp.
+func findKeysFile( name string, env string ) string {
+
+  if file_name:= os.Getenv ( env ); file_name != "" {
+    return file_name
+  }
+  if _,err:= os.Stat ( name ); err == nil {
+    return name
+  }
+  if dir,err:= os.UserConfigDir (); err == nil {
+    file_name:= path.Join ( dir, "imgcp", name )
+    if _,err:= os.Stat ( file_name ); err == nil {
+      return file_name
+    }
+  }
+
+  return ""
+
+} // end findKeysFile
+
+
+// Llig aes_keys.txt (format de Citra: slot0xNNKeyX=HEX).
+func loadAESKeys() (*_AES_Keys,error) {
+
+  // Sols es llig una vegada
+  if _aes_keys != nil || _aes_keys_err != nil {
+    return _aes_keys,_aes_keys_err
+  }
+
+  // Obri
+  file_name:= findKeysFile ( "aes_keys.txt", "IMGCP_AES_KEYS" )
+  if file_name == "" {
+    _aes_keys_err= errors.New ( "aes_keys.txt not found (set IMGCP_AES_KEYS"+
+      " or copy it to the current folder or ~/.config/imgcp)" )
+    return nil,_aes_keys_err
+  }
+  f,err:= os.Open ( file_name )
+  if err != nil {
+    _aes_keys_err= err
+    return nil,err
+  }
+  defer f.Close ()
+
+  // Llig
+  ret:= _AES_Keys{
+    generator : _KEY_GENERATOR,
+    key_x     : make(map[uint8][16]byte),
+  }
+  scanner:= bufio.NewScanner ( f )
+  for nline:= 1; scanner.Scan (); nline++ {
+    line:= strings.TrimSpace ( scanner.Text () )
+    if line == "" || line[0] == '#' { continue }
+    name,value,ok:= strings.Cut ( line, "=" )
+    var key []byte
+    if ok {
+      key,err= hex.DecodeString ( strings.TrimSpace ( value ) )
+    }
+    if !ok || err != nil || len(key) != 16 {
+      _aes_keys_err= fmt.Errorf ( "%s:%d: wrong key format", file_name, nline )
+      return nil,_aes_keys_err
+    }
+    name= strings.ToLower ( strings.TrimSpace ( name ) )
+    if name == "generator" {
+      copy ( ret.generator[:], key )
+    } else if strings.HasPrefix ( name, "slot0x" ) &&
+      strings.HasSuffix ( name, "keyx" ) {
+      // Les altres claus (KeyY, KeyN) s'ignoren
+      slot,err:= strconv.ParseUint ( name[6:len(name)-4], 16, 8 )
+      if err != nil {
+        _aes_keys_err= fmt.Errorf ( "%s:%d: wrong key name", file_name, nline )
+        return nil,_aes_keys_err
+      }
+      var tmp [16]byte
+      copy ( tmp[:], key )
+      ret.key_x[uint8(slot)]= tmp
+    }
+  }
+  if err:= scanner.Err (); err != nil {
+    _aes_keys_err= err
+    return nil,err
+  }
+  _aes_keys= &ret
+
+  return _aes_keys,nil
+
+} // end loadAESKeys
+
+
+// Rotació a l'esquerra de n bits d'un valor de 128 bits (big-endian).
+func rol128( v [16]byte, n uint ) [16]byte {
+
+  var ret [16]byte
+  nbytes,nbits:= int(n/8),n%8
+  for i:= 0; i < 16; i++ {
+    a:= v[(i+nbytes)%16]
+    b:= v[(i+nbytes+1)%16]
+    ret[i]= (a<<nbits) | uint8(uint16(b)>>(8-nbits))
+  }
+
+  return ret
+
+} // end rol128
+
+
+// NormalKey = ROL((ROL(KeyX,2) XOR KeyY) + C, 87)
+func scrambleKey( key_x,key_y,generator [16]byte ) [16]byte {
+
+  tmp:= rol128 ( key_x, 2 )
+  for i:= range tmp {
+    tmp[i]^= key_y[i]
+  }
+  carry:= uint16(0)
+  for i:= 15; i >= 0; i-- {
+    carry+= uint16(tmp[i]) + uint16(generator[i])
+    tmp[i]= uint8(carry)
+    carry>>= 8
+  }
+
+  return rol128 ( tmp, 87 )
+
+} // end scrambleKey
+
+
+// Busca la llavor d'un títol en seeddb.bin.
+func loadSeed( program_id uint64 ) ([16]byte,error) {
+
+  var ret [16]byte
+
+  // Obri
+  file_name:= findKeysFile ( "seeddb.bin", "IMGCP_SEEDDB" )
+  if file_name == "" {
+    return ret,errors.New ( "seeddb.bin not found (set IMGCP_SEEDDB"+
+      " or copy it to the current folder or ~/.config/imgcp)" )
+  }
+  f,err:= os.Open ( file_name )
+  if err != nil { return ret,err }
+  defer f.Close ()
+  r:= bufio.NewReader ( f )
+
+  // Busca
+  var header [0x10]byte
+  if _,err:= io.ReadFull ( r, header[:] ); err != nil {
+    return ret,fmt.Errorf ( "failed to read '%s': %s", file_name, err )
+  }
+  n:= uint32(header[0]) |
+    (uint32(header[1])<<8) |
+    (uint32(header[2])<<16) |
+    (uint32(header[3])<<24)
+  var entry [0x20]byte
+  for i:= uint32(0); i < n; i++ {
+    if _,err:= io.ReadFull ( r, entry[:] ); err != nil {
+      return ret,fmt.Errorf ( "failed to read '%s': %s", file_name, err )
+    }
+    id:= uint64(entry[0]) |
+      (uint64(entry[1])<<8) |
+      (uint64(entry[2])<<16) |
+      (uint64(entry[3])<<24) |
+      (uint64(entry[4])<<32) |
+      (uint64(entry[5])<<40) |
+      (uint64(entry[6])<<48) |
+      (uint64(entry[7])<<56)
+    if id == program_id {
+      copy ( ret[:], entry[8:0x18] )
+      return ret,nil
+    }
+  }
+
+  return ret,fmt.Errorf ( "seed for title %016x not found in '%s'",
+    program_id, file_name )
+
+} // end loadSeed
+
+
+/********/
+/* NCCH */
+/********/
+
+func getCryptoKeySlot( crypto int ) uint8 {
+
+  switch crypto {
+  case NCCH_CRYPTO_7X:
+    return 0x25
+  case NCCH_CRYPTO_93:
+    return 0x18
+  case NCCH_CRYPTO_96:
+    return 0x1b
+  default:
+    return 0x2c
+  }
+
+} // end getCryptoKeySlot
+
+
+func newSectionBlock( key [16]byte ) cipher.Block {
+  block,_:= aes.NewCipher ( key[:] ) // Sols falla si la clau és incorrecta
+  return block
+} // end newSectionBlock
+
+
+// Calcula les claus primària (ExHeader, capçalera ExeFS, icon i
+// banner) i secundària (resta de l'ExeFS i RomFS).
+func (self *NCCH) initKeys() error {
+
+  if self.keys_ready { return self.keys_err }
+  self.keys_ready= true
+  h:= &self.Header
+
+  switch h.Crypto {
+  case NCCH_CRYPTO_NONE:
+    return nil
+
+  case NCCH_CRYPTO_FIXED:
+    // Les aplicacions de sistema empren una clau fixa no pública.
+    if (h.ProgramId>>32)&0x10 != 0 {
+      self.keys_err= errors.New ( "NCCH is encrypted with the fixed"+
+        " system key, which is not supported" )
+      return self.keys_err
+    }
+    var zero [16]byte
+    self.primary= newSectionBlock ( zero )
+    self.secondary= self.primary
+    return nil
+
+  case NCCH_CRYPTO_UNK:
+    self.keys_err= errors.New ( "NCCH is encrypted with an unknown method" )
+    return self.keys_err
+  }
+
+  // Claus
+  keys,err:= loadAESKeys ()
+  if err != nil {
+    self.keys_err= fmt.Errorf ( "NCCH is encrypted: %s", err )
+    return self.keys_err
+  }
+  key_x,ok:= keys.key_x[0x2c]
+  if !ok {
+    self.keys_err= errors.New ( "NCCH is encrypted: slot0x2CKeyX not"+
+      " found in aes_keys.txt" )
+    return self.keys_err
+  }
+  self.primary= newSectionBlock ( scrambleKey ( key_x, h.KeyY,
+    keys.generator ) )
+
+  // Clau secundària
+  slot:= getCryptoKeySlot ( h.Crypto )
+  if key_x,ok= keys.key_x[slot]; !ok {
+    self.keys_err= fmt.Errorf ( "NCCH is encrypted: slot0x%02XKeyX not"+
+      " found in aes_keys.txt", slot )
+    return self.keys_err
+  }
+  key_y:= h.KeyY
+  if h.Seed {
+    seed,err:= loadSeed ( h.ProgramId )
+    if err != nil {
+      self.keys_err= fmt.Errorf ( "NCCH is encrypted with a seed: %s", err )
+      return self.keys_err
+    }
+    if err:= h.checkSeed ( seed ); err != nil {
+      self.keys_err= err
+      return err
+    }
+    var tmp [32]byte
+    copy ( tmp[:16], h.KeyY[:] )
+    copy ( tmp[16:], seed[:] )
+    hash:= sha256.Sum256 ( tmp[:] )
+    copy ( key_y[:], hash[:16] )
+  }
+  self.secondary= newSectionBlock ( scrambleKey ( key_x, key_y,
+    keys.generator ) )
+
+  return nil
+
+} // end initKeys
+
+
+// Comprova que la llavor correspon amb el títol.
+func (self *NCCH_Header) checkSeed( seed [16]byte ) error {
+
+  var tmp [24]byte
+  copy ( tmp[:16], seed[:] )
+  for i:= 0; i < 8; i++ {
+    tmp[16+i]= uint8(self.ProgramId>>(8*i))
+  }
+  hash:= sha256.Sum256 ( tmp[:] )
+  if !bytes.Equal ( hash[:4], self.seed_check[:] ) {
+    return fmt.Errorf ( "wrong seed for title %016x", self.ProgramId )
+  }
+
+  return nil
+
+} // end checkSeed
+
+
+// Comptador inicial d'una secció. offset és la posició de la secció
+// dins de l'NCCH.
+func (self *NCCH_Header) sectionCTR( section uint8, offset int64 ) [16]byte {
+
+  var ret [16]byte
+  if self.Version == 1 {
+    for i:= 0; i < 8; i++ {
+      ret[i]= uint8(self.Id>>(8*i))
+    }
+    ret[12]= uint8(offset>>24)
+    ret[13]= uint8(offset>>16)
+    ret[14]= uint8(offset>>8)
+    ret[15]= uint8(offset)
+  } else {
+    for i:= 0; i < 8; i++ {
+      ret[i]= uint8(self.Id>>(8*(7-i)))
+    }
+    ret[8]= section
+  }
+
+  return ret
+
+} // end sectionCTR
+
+
+// Torna les claus (primària i secundària) d'una secció. Si l'NCCH no
+// està xifrat torna nil.
+func (self *NCCH) getSectionKeys(
+
+  section uint8,
+  offset  int64,
+
+) (*_Section_Key,*_Section_Key,error) {
+
+  if err:= self.initKeys (); err != nil {
+    return nil,nil,err
+  }
+  if self.primary == nil {
+    return nil,nil,nil
+  }
+  ctr:= self.Header.sectionCTR ( section, offset )
+  primary:= _Section_Key{ block : self.primary, ctr : ctr }
+  secondary:= _Section_Key{ block : self.secondary, ctr : ctr }
+
+  return &primary,&secondary,nil
+
+} // end getSectionKeys
+
+
+/**********************/
+/* FUNCIONS PÚBLIQUES */
+/**********************/
+
+func NCCH_crypto2str( crypto int ) string {
+
+  switch crypto {
+  case NCCH_CRYPTO_NONE:
+    return "None"
+  case NCCH_CRYPTO_FIXED:
+    return "Fixed key"
+  case NCCH_CRYPTO_ORIGINAL:
+    return "Original (slot 0x2C)"
+  case NCCH_CRYPTO_7X:
+    return "7.x (slot 0x25)"
+  case NCCH_CRYPTO_93:
+    return "9.3 (slot 0x18)"
+  case NCCH_CRYPTO_96:
+    return "9.6 (slot 0x1B)"
+  default:
+    return "Unknown"
+  }
+
+} // end NCCH_crypto2str
@@ -0,0 +1,324 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  ncch_verify.go - Comprovació dels hashos SHA-256 d'un NCCH
+ *                   (ExHeader, ExeFS i arbre IVFC de la RomFS).
+ */
+
+package citrus
+
+import (
+  "bytes"
+  "crypto/sha256"
+  "errors"
+  "fmt"
+  "io"
+)
+
+
+/*********/
+/* TIPUS */
+/*********/
+
+// Resultat de comprovar una regió. Les regions que no es divideixen
+// en blocs tenen un únic bloc.
+type HashCheck struct {
+
+  Region    string
+  NBlocks   int64
+  BadBlocks int64
+
+}
+
+type _IVFC_Level struct {
+  offset     int64 // Posició dins de la RomFS
+  size       int64
+  block_size int64
+}
+
+
+/************/
+/* FUNCIONS */
+/************/
+
+func (self *HashCheck) Ok() bool {
+  return self.BadBlocks == 0
+} // end Ok
+
+
+func newHashCheck( region string, ok bool ) HashCheck {
+  ret:= HashCheck{
+    Region    : region,
+    NBlocks   : 1,
+    BadBlocks : 0,
+  }
+  if !ok {
+    ret.BadBlocks= 1
+  }
+  return ret
+} // end newHashCheck
+
+
+// Calcula el SHA-256 de size bytes des de la posició actual.
+func hashSection( fd SectionReader, size int64 ) ([32]byte,error) {
+
+  var ret [32]byte
+  h:= sha256.New ()
+  if n,err:= io.CopyN ( h, fd, size ); err == io.EOF || n != size {
+    return ret,errors.New ( "not enough bytes" )
+  } else if err != nil {
+    return ret,err
+  }
+  copy ( ret[:], h.Sum ( nil ) )
+
+  return ret,nil
+
+} // end hashSection
+
+
+func alignIVFC( offset int64, block_size int64 ) int64 {
+  return (offset+block_size-1)/block_size*block_size
+} // end alignIVFC
+
+
+// Comprova els blocs d'un nivell amb els hashos que comencen en la
+// posició hashes (dins de la RomFS). Torna el nombre de blocs i el
+// nombre de blocs erronis.
+func checkIVFCLevel(
+
+  fd     SectionReader,
+  hashes int64,
+  level  *_IVFC_Level,
+
+) (int64,int64,error) {
+
+  nblocks:= (level.size+level.block_size-1)/level.block_size
+  block:= make([]byte,level.block_size)
+  var hash [32]byte
+  var nbad int64= 0
+  for i:= int64(0); i < nblocks; i++ {
+
+    // Hash esperat
+    if _,err:= fd.Seek ( hashes+i*32, 0 ); err != nil {
+      return 0,0,err
+    }
+    if _,err:= io.ReadFull ( fd, hash[:] ); err != nil {
+      return 0,0,err
+    }
+
+    // Bloc (l'últim es completa amb zeros)
+    size:= level.size-i*level.block_size
+    if size > level.block_size {
+      size= level.block_size
+    }
+    if _,err:= fd.Seek ( level.offset+i*level.block_size, 0 ); err != nil {
+      return 0,0,err
+    }
+    if _,err:= io.ReadFull ( fd, block[:size] ); err != nil {
+      return 0,0,err
+    }
+    for j:= size; j < level.block_size; j++ {
+      block[j]= 0
+    }
+    if sum:= sha256.Sum256 ( block ); !bytes.Equal ( sum[:], hash[:] ) {
+      nbad++
+    }
+
+  }
+
+  return nblocks,nbad,nil
+
+} // end checkIVFCLevel
+
+
+// Comprova l'arbre IVFC de la RomFS. fd ha d'apuntar al principi de
+// la RomFS.
+func verifyIVFC( fd SectionReader ) ([]HashCheck,error) {
+
+  // Capçalera
+  var buf [0x5c]byte
+  if _,err:= io.ReadFull ( fd, buf[:] ); err != nil {
+    return nil,fmt.Errorf ( "Error while reading RomFS header: %s", err )
+  }
+  if buf[0]!='I' || buf[1]!='V' || buf[2]!='F' || buf[3]!='C' {
+    return nil,errors.New ( "Not a RomFS file: wrong magic number" )
+  }
+  master_size:= int64(readLE32 ( buf[0x8:] ))
+  var levels [3]_IVFC_Level
+  for i:= 0; i < 3; i++ {
+    mem:= buf[0xc+i*0x18:]
+    levels[i].size= int64(readLE64 ( mem[0x8:] ))
+    log2:= readLE32 ( mem[0x10:] )
+    if log2 > 30 || levels[i].size < 0 {
+      return nil,fmt.Errorf ( "Error while reading RomFS header: wrong"+
+        " IVFC level %d", i+1 )
+    }
+    levels[i].block_size= int64(1)<<log2
+  }
+  if master_size < ((levels[0].size+levels[0].block_size-1)/
+    levels[0].block_size)*32 {
+    return nil,errors.New ( "Error while reading RomFS header: master hash"+
+      " too small" )
+  }
+
+  // Posicions. El nivell 3 va primer, després el 1 i el 2.
+  levels[2].offset= alignIVFC ( 0x60+master_size, levels[2].block_size )
+  levels[0].offset= alignIVFC ( levels[2].offset+levels[2].size,
+    levels[0].block_size )
+  levels[1].offset= alignIVFC ( levels[0].offset+levels[0].size,
+    levels[1].block_size )
+
+  // Comprova
+  ret:= make([]HashCheck,0,3)
+  hashes:= int64(0x60)
+  for i:= 0; i < 3; i++ {
+    nblocks,nbad,err:= checkIVFCLevel ( fd, hashes, &levels[i] )
+    if err != nil {
+      return nil,fmt.Errorf ( "Error while reading RomFS level %d: %s",
+        i+1, err )
+    }
+    ret= append(ret,HashCheck{
+      Region    : fmt.Sprintf ( "RomFS level %d", i+1 ),
+      NBlocks   : nblocks,
+      BadBlocks : nbad,
+    })
+    hashes= levels[i].offset
+  }
+
+  return ret,nil
+
+} // end verifyIVFC
+
+
+// Comprova tots els hashos de l'NCCH. Si està xifrat es desxifra.
+func (self *NCCH) VerifyHashes() ([]HashCheck,error) {
+
+  var ret []HashCheck
+  h:= &self.Header
+
+  // Logo
+  if h.Logo.Size != 0 {
+    fd,err:= openSection ( self.file_name, self.offset+h.Logo.Offset,
+      h.Logo.Size, nil, 0 )
+    if err != nil { return nil,err }
+    sum,err:= hashSection ( fd, h.Logo.Size )
+    fd.Close ()
+    if err != nil {
+      return nil,fmt.Errorf ( "Error while reading logo: %s", err )
+    }
+    ret= append(ret,newHashCheck ( "Logo", sum == h.LogoHash ))
+  }
+
+  // ExHeader
+  if h.ExHeaderSize != 0 {
+    fd,err:= self.OpenExHeader ()
+    if err != nil { return nil,err }
+    sum,err:= hashSection ( fd, h.ExHeaderSize )
+    fd.Close ()
+    if err != nil {
+      return nil,fmt.Errorf ( "Error while reading ExHeader: %s", err )
+    }
+    ret= append(ret,newHashCheck ( "ExHeader", sum == h.ExHeaderHash ))
+  }
+
+  // ExeFS
+  if h.ExeFS.Size != 0 {
+    checks,err:= self.verifyExeFS ()
+    if err != nil { return nil,err }
+    ret= append(ret,checks...)
+  }
+
+  // RomFS
+  if h.RomFS.Size != 0 {
+    checks,err:= self.verifyRomFS ()
+    if err != nil { return nil,err }
+    ret= append(ret,checks...)
+  }
+
+  return ret,nil
+
+} // end VerifyHashes
+
+
+func (self *NCCH) verifyExeFS() ([]HashCheck,error) {
+
+  h:= &self.Header
+
+  // Superbloc
+  primary,_,err:= self.getSectionKeys ( _NCCH_SECTION_EXEFS, h.ExeFS.Offset )
+  if err != nil { return nil,err }
+  fd,err:= openSection ( self.file_name, self.offset+h.ExeFS.Offset,
+    h.ExeFS.Size, primary, 0 )
+  if err != nil { return nil,err }
+  sum,err:= hashSection ( fd, h.ExeFS.HeaderSize )
+  fd.Close ()
+  if err != nil {
+    return nil,fmt.Errorf ( "Error while reading ExeFS: %s", err )
+  }
+  ret:= []HashCheck{newHashCheck ( "ExeFS superblock", sum == h.ExeFSHash )}
+
+  // Fitxers
+  exefs,err:= self.GetExeFS ()
+  if err != nil { return nil,err }
+  for i:= range exefs.Files {
+    file:= &exefs.Files[i]
+    fd,err:= exefs.Open ( file )
+    if err != nil { return nil,err }
+    sum,err:= hashSection ( fd, int64(uint64(file.Size)) )
+    fd.Close ()
+    if err != nil {
+      return nil,fmt.Errorf ( "Error while reading ExeFS file '%s': %s",
+        file.Name, err )
+    }
+    ret= append(ret,newHashCheck ( "ExeFS file "+file.Name,
+      sum == file.Hash ))
+  }
+
+  return ret,nil
+
+} // end verifyExeFS
+
+
+func (self *NCCH) verifyRomFS() ([]HashCheck,error) {
+
+  h:= &self.Header
+
+  // Superbloc
+  _,secondary,err:= self.getSectionKeys ( _NCCH_SECTION_ROMFS,
+    h.RomFS.Offset )
+  if err != nil { return nil,err }
+  fd,err:= openSection ( self.file_name, self.offset+h.RomFS.Offset,
+    h.RomFS.Size, secondary, 0 )
+  if err != nil { return nil,err }
+  defer fd.Close ()
+  sum,err:= hashSection ( fd, h.RomFS.HeaderSize )
+  if err != nil {
+    return nil,fmt.Errorf ( "Error while reading RomFS: %s", err )
+  }
+  ret:= []HashCheck{newHashCheck ( "RomFS superblock", sum == h.RomFSHash )}
+
+  // Arbre IVFC
+  if _,err:= fd.Seek ( 0, 0 ); err != nil { return nil,err }
+  checks,err:= verifyIVFC ( fd )
+  if err != nil { return nil,err }
+
+  return append(ret,checks...),nil
+
+} // end verifyRomFS
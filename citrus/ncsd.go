@@ -55,6 +55,7 @@ type NCSDHeader struct {
   Size       int64
   Partitions [8]NCSD_Partition
   MediaID    uint64
+  Trimmed    bool // El fitxer és menor que Size (sense el farciment)
   
 }
 
@@ -95,10 +96,11 @@ func (self *NCSDHeader) Read( fd *os.File ) error {
     (uint32(buf[0x106])<<16) |
     (uint32(buf[0x107])<<24)
   self.Size= int64(header_size)*0x200
-  if self.Size != file_size {
+  if file_size > self.Size {
     return fmt.Errorf ( "Mismatch between image size (%d) and the size "+
       "specified in the header (%d)", file_size, self.Size )
   }
+  self.Trimmed= file_size < self.Size
   
   // Llig informació particions
   for i:= 0; i < 8; i++ {
@@ -137,7 +139,7 @@ func (self *NCSDHeader) Read( fd *os.File ) error {
     // Comprovacions bàsiques.
     if self.Partitions[i].Offset+self.Partitions[i].Size > file_size {
       return fmt.Errorf ( "Error while reading NCSD header: partition %d"+
-        " ([%d,%d+d[) is out of image boundaries ([%d,%d[) than file image",
+        " ([%d,%d[) is out of image boundaries ([%d,%d[)", i,
         self.Partitions[i].Offset,
         self.Partitions[i].Offset+self.Partitions[i].Size,
         0, file_size )
@@ -160,6 +162,22 @@ func (self *NCSDHeader) Read( fd *os.File ) error {
 } // end Read
 
 
+// Posició on acaben les dades de l'última partició.
+func (self *NCSDHeader) DataEnd() int64 {
+
+  var ret int64= 0x200 // Capçalera
+  for i:= 0; i < 8; i++ {
+    p:= &self.Partitions[i]
+    if p.Type != NCSD_PARTITION_TYPE_UNUSED && p.Offset+p.Size > ret {
+      ret= p.Offset+p.Size
+    }
+  }
+
+  return ret
+  
+} // end DataEnd
+
+
 func NCSD_ptype2str( ptype int ) string {
   
   switch ptype {
@@ -27,7 +27,6 @@ import (
   "errors"
   "fmt"
   
-  "github.com/adriagipas/imgcp/utils"
   "golang.org/x/text/encoding/unicode"
 )
 
@@ -51,6 +50,8 @@ type RomFS_Directory struct {
   directory_table uint32
   file_table      uint32
   file_data       uint32
+  key             *_Section_Key // nil si no està xifrat
+  level3          int64         // Posició del nivell 3
   
   // Referències
   self    uint32
@@ -74,6 +75,8 @@ type RomFS_File struct {
   directory_table uint32
   file_table      uint32
   file_data       uint32
+  key             *_Section_Key // nil si no està xifrat
+  level3          int64         // Posició del nivell 3
   
   // Referències
   parent_dir uint32
@@ -90,17 +93,19 @@ type RomFS_File struct {
 /* FUNCIONS */
 /************/
 
-func (self *RomFS_File) Open() (*utils.SubfileReader,error) {
+func (self *RomFS_File) Open() (SectionReader,error) {
 
-  offset:= _BASE_OFFSET + int64(uint64(self.file_data) + self.offset)
+  offset:= self.level3 + int64(uint64(self.file_data) + self.offset)
   size:= int64(self.Size)
-  if offset < 0 || offset+size >= self.file_size {
+  if offset < 0 || offset+size > self.file_size {
     return nil,fmt.Errorf ( "File '%s' out of range (parent file)", self.Name )
   }
-  return utils.NewSubfileReader (
+  return openSection (
     self.file_name,
     self.file_offset + offset,
     size,
+    self.key,
+    offset,
   )
   
 } // end RomFS_File.Open
@@ -112,8 +117,8 @@ func (self *RomFS_File) Sibling() (*RomFS_File,error) {
   if self.sibling == 0xFFFFFFFF { return nil,nil }
   return newRomFS_File (
     self.file_name, self.file_offset, self.file_size,
-    self.directory_table, self.file_table, self.file_data,
-    self.sibling )
+    self.directory_table, self.file_table, self.file_data, self.key,
+    self.level3, self.sibling )
   
 } // end RomFS_File.Sibling
 
@@ -126,15 +131,17 @@ func newRomFS_File(
   directory_table uint32,
   file_table      uint32,
   file_data       uint32,
+  key             *_Section_Key,
+  level3          int64,
   entry_offset    uint32,
   
 ) (*RomFS_File,error) {
 
   // Llig entry
-  fd,err:= utils.NewSubfileReader ( file_name, file_offset, file_length )
+  fd,err:= openSection ( file_name, file_offset, file_length, key, 0 )
   if err != nil { return nil,err }
   defer fd.Close ()
-  real_file_offset:= _BASE_OFFSET + int64(uint64(file_table + entry_offset))
+  real_file_offset:= level3 + int64(uint64(file_table + entry_offset))
   if _,err:= fd.Seek ( real_file_offset, 0 ); err != nil {
     return nil,err
   }
@@ -222,6 +229,8 @@ func newRomFS_File(
     directory_table: directory_table,
     file_table: file_table,
     file_data: file_data,
+    key: key,
+    level3: level3,
     parent_dir: parent_dir,
     sibling: sibling,
     offset: offset,
@@ -240,8 +249,8 @@ func (self *RomFS_Directory) Parent() (*RomFS_Directory,error) {
   if self.parent == 0xFFFFFFFF { return nil,nil }
   return newRomFS_Directory (
     self.file_name, self.file_offset, self.file_size,
-    self.directory_table, self.file_table, self.file_data,
-    self.parent )
+    self.directory_table, self.file_table, self.file_data, self.key,
+    self.level3, self.parent )
   
 } // end RomFS_Directory.Parent
 
@@ -253,8 +262,8 @@ func (self *RomFS_Directory) Sibling() (*RomFS_Directory,error) {
   if self.sibling == 0xFFFFFFFF { return nil,nil }
   return newRomFS_Directory (
     self.file_name, self.file_offset, self.file_size,
-    self.directory_table, self.file_table, self.file_data,
-    self.sibling )
+    self.directory_table, self.file_table, self.file_data, self.key,
+    self.level3, self.sibling )
   
 } // end RomFS_Directory.Sibling
 
@@ -266,8 +275,8 @@ func (self *RomFS_Directory) Child() (*RomFS_Directory,error) {
   if self.child == 0xFFFFFFFF { return nil,nil }
   return newRomFS_Directory (
     self.file_name, self.file_offset, self.file_size,
-    self.directory_table, self.file_table, self.file_data,
-    self.child )
+    self.directory_table, self.file_table, self.file_data, self.key,
+    self.level3, self.child )
   
 } // end RomFS_Directory.Child
 
@@ -278,8 +287,8 @@ func (self *RomFS_Directory) File() (*RomFS_File,error) {
   if self.file == 0xFFFFFFFF { return nil,nil }
   return newRomFS_File (
     self.file_name, self.file_offset, self.file_size,
-    self.directory_table, self.file_table, self.file_data,
-    self.file )
+    self.directory_table, self.file_table, self.file_data, self.key,
+    self.level3, self.file )
   
 } // end RomFS_Directory.File
 
@@ -292,15 +301,17 @@ func newRomFS_Directory(
   directory_table uint32,
   file_table      uint32,
   file_data       uint32,
+  key             *_Section_Key,
+  level3          int64,
   entry_offset    uint32,
   
 ) (*RomFS_Directory,error) {
 
   // Llig entry
-  fd,err:= utils.NewSubfileReader ( file_name, offset, length )
+  fd,err:= openSection ( file_name, offset, length, key, 0 )
   if err != nil { return nil,err }
   defer fd.Close ()
-  dir_offset:= _BASE_OFFSET + int64(uint64(directory_table + entry_offset))
+  dir_offset:= level3 + int64(uint64(directory_table + entry_offset))
   if _,err:= fd.Seek ( dir_offset, 0 ); err != nil {
     return nil,err
   }
@@ -373,6 +384,8 @@ func newRomFS_Directory(
     directory_table: directory_table,
     file_table: file_table,
     file_data: file_data,
+    key: key,
+    level3: level3,
     self: entry_offset,
     parent: parent,
     sibling: sibling,
@@ -385,41 +398,48 @@ func newRomFS_Directory(
 } // end newRomFS_Directory
 
 
+// level3 és la posició del nivell 3 dins de la RomFS. Si és 0 la
+// RomFS no té capçalera IVFC i comença directament pel nivell 3
+// (3DSX).
 func openRomFS(
   file_name string,
   offset    int64,
   length    int64,
+  key       *_Section_Key,
+  level3    int64,
 ) (*RomFS_Directory,error) {
 
   // Obri subfitxer.
-  fd,err:= utils.NewSubfileReader ( file_name, offset, length )
+  fd,err:= openSection ( file_name, offset, length, key, 0 )
   if err != nil { return nil,err }
   defer fd.Close ()
 
-  // Llig capçalera.
-  var buf [0x5c]byte
-  n,err:= fd.Read ( buf[:] )
-  if err != nil {
-    return nil,fmt.Errorf ( "Error while reading RomFS header: %s", err )
-  }
-  if n != len(buf) {
-    return nil,
-      errors.New ( "Error while reading RomFS header: not enough bytes" )
-  }
-  if buf[0]!='I' || buf[1]!='V' || buf[2]!='F' || buf[3]!='C' ||
-    buf[4]!=0x00 || buf[5]!=0x00 || buf[6]!=0x01 || buf[7]!=0x00 {
-    return nil,fmt.Errorf (
-      "Not a RomFs file: wrong magic number (%c%c%c%c%d%d%d%d)",
-    buf[0], buf[1], buf[2], buf[3], buf[4], buf[5], buf[6], buf[7] )
+  // Llig capçalera IVFC (si en té).
+  if level3 != 0 {
+    var buf [0x5c]byte
+    n,err:= fd.Read ( buf[:] )
+    if err != nil {
+      return nil,fmt.Errorf ( "Error while reading RomFS header: %s", err )
+    }
+    if n != len(buf) {
+      return nil,
+        errors.New ( "Error while reading RomFS header: not enough bytes" )
+    }
+    if buf[0]!='I' || buf[1]!='V' || buf[2]!='F' || buf[3]!='C' ||
+      buf[4]!=0x00 || buf[5]!=0x00 || buf[6]!=0x01 || buf[7]!=0x00 {
+      return nil,fmt.Errorf (
+        "Not a RomFs file: wrong magic number (%c%c%c%c%d%d%d%d)",
+      buf[0], buf[1], buf[2], buf[3], buf[4], buf[5], buf[6], buf[7] )
+    }
   }
   
   // Obté offset taula directories
-  if _,err:= fd.Seek ( _BASE_OFFSET+0xc, 0 ); err != nil {
+  if _,err:= fd.Seek ( level3+0xc, 0 ); err != nil {
     return nil,fmt.Errorf (
       "Error while trying to locate the directory table: %s", err )
   }
   var tmp [4]byte
-  n,err= fd.Read ( tmp[:] )
+  n,err:= fd.Read ( tmp[:] )
   if err != nil {
     return nil,fmt.Errorf (
       "Error while trying to locate the directory table: %s", err )
@@ -434,7 +454,7 @@ func openRomFS(
     (uint32(tmp[3])<<24)
 
   // Obté offset taula fitxers
-  if _,err:= fd.Seek ( _BASE_OFFSET+0x1c, 0 ); err != nil {
+  if _,err:= fd.Seek ( level3+0x1c, 0 ); err != nil {
     return nil,fmt.Errorf (
       "Error while trying to locate the file table: %s", err )
   }
@@ -453,7 +473,7 @@ func openRomFS(
     (uint32(tmp[3])<<24)
 
   // Obté offset dades fitxers
-  if _,err:= fd.Seek ( _BASE_OFFSET+0x24, 0 ); err != nil {
+  if _,err:= fd.Seek ( level3+0x24, 0 ); err != nil {
     return nil,fmt.Errorf (
       "Error while trying to locate the file data offset: %s", err )
   }
@@ -473,6 +493,7 @@ func openRomFS(
   
   // Crea directory
   return newRomFS_Directory (
-    file_name, offset, length, directory_table, file_table, file_data, 0 )
+    file_name, offset, length, directory_table, file_table, file_data,
+    key, level3, 0 )
   
 } // end openRomFS
@@ -0,0 +1,308 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  smdh.go - Icona i títols de les aplicacions (fitxer icon de
+ *            l'ExeFS).
+ */
+
+package citrus
+
+import (
+  "errors"
+  "fmt"
+  "image"
+  "image/color"
+  "io"
+  "unicode/utf16"
+)
+
+
+/*********/
+/* TIPUS */
+/*********/
+
+const SMDH_SIZE = 0x36c0
+
+const SMDH_NUM_LANGUAGES = 16
+
+// Idiomes (índex en SMDH.Titles)
+const (
+  SMDH_LANG_JAPANESE            = 0
+  SMDH_LANG_ENGLISH             = 1
+  SMDH_LANG_FRENCH              = 2
+  SMDH_LANG_GERMAN              = 3
+  SMDH_LANG_ITALIAN             = 4
+  SMDH_LANG_SPANISH             = 5
+  SMDH_LANG_SIMPLIFIED_CHINESE  = 6
+  SMDH_LANG_KOREAN              = 7
+  SMDH_LANG_DUTCH               = 8
+  SMDH_LANG_PORTUGUESE          = 9
+  SMDH_LANG_RUSSIAN             = 10
+  SMDH_LANG_TRADITIONAL_CHINESE = 11
+)
+
+// Regions
+const (
+  SMDH_REGION_JAPAN         = 0x01
+  SMDH_REGION_NORTH_AMERICA = 0x02
+  SMDH_REGION_EUROPE        = 0x04
+  SMDH_REGION_AUSTRALIA     = 0x08
+  SMDH_REGION_CHINA         = 0x10
+  SMDH_REGION_KOREA         = 0x20
+  SMDH_REGION_TAIWAN        = 0x40
+  SMDH_REGION_FREE          = 0x7fffffff
+)
+
+// Organismes de classificació (índex en SMDH.Ratings)
+const (
+  SMDH_RATING_CERO      = 0
+  SMDH_RATING_ESRB      = 1
+  SMDH_RATING_USK       = 3
+  SMDH_RATING_PEGI_GEN  = 4
+  SMDH_RATING_PEGI_PRT  = 6
+  SMDH_RATING_PEGI_BBFC = 7
+  SMDH_RATING_COB       = 8
+  SMDH_RATING_GRB       = 9
+  SMDH_RATING_CGSRR     = 10
+)
+
+// Bits de cada classificació
+const (
+  SMDH_RATING_AGE_MASK       = 0x1f
+  SMDH_RATING_NO_RESTRICTION = 0x20
+  SMDH_RATING_PENDING        = 0x40
+  SMDH_RATING_ACTIVE         = 0x80
+)
+
+const (
+  SMDH_FLAGS_VISIBLE        = 0x0001
+  SMDH_FLAGS_AUTO_BOOT      = 0x0002
+  SMDH_FLAGS_ALLOW_3D       = 0x0004
+  SMDH_FLAGS_REQUIRE_EULA   = 0x0008
+  SMDH_FLAGS_AUTO_SAVE      = 0x0010
+  SMDH_FLAGS_EXT_BANNER     = 0x0020
+  SMDH_FLAGS_RATING_REQ     = 0x0040
+  SMDH_FLAGS_SAVE_DATA      = 0x0080
+  SMDH_FLAGS_RECORD_USAGE   = 0x0100
+  SMDH_FLAGS_NO_SAVE_BACKUP = 0x0400
+  SMDH_FLAGS_NEW3DS         = 0x1000
+)
+
+type SMDH_Title struct {
+
+  Short     string
+  Long      string
+  Publisher string
+
+}
+
+type SMDH struct {
+
+  Version       uint16
+  Titles        [SMDH_NUM_LANGUAGES]SMDH_Title
+  Ratings       [16]uint8
+  RegionLockout uint32
+  Flags         uint32
+  EULAVersion   uint16
+
+  small_icon [0x480]byte  // 24x24 RGB565
+  large_icon [0x1200]byte // 48x48 RGB565
+
+}
+
+
+/************/
+/* FUNCIONS */
+/************/
+
+// Decodifica una cadena UTF-16LE acabada en 0.
+func decodeUTF16LE( mem []byte ) string {
+
+  tmp:= make([]uint16,0,len(mem)/2)
+  for i:= 0; i+1 < len(mem); i+= 2 {
+    c:= readLE16 ( mem[i:] )
+    if c == 0 { break }
+    tmp= append(tmp,c)
+  }
+
+  return string(utf16.Decode ( tmp ))
+
+} // end decodeUTF16LE
+
+
+// Les icones es guarden en tiles de 8x8 i dins de cada tile els
+// píxels segueixen l'ordre Z (Morton).
+func decodeSMDHIcon( mem []byte, size int ) *image.RGBA {
+
+  ret:= image.NewRGBA ( image.Rect ( 0, 0, size, size ) )
+  i:= 0
+  for ty:= 0; ty < size; ty+= 8 {
+    for tx:= 0; tx < size; tx+= 8 {
+      for k:= 0; k < 64; k++ {
+        x:= (k&1) | ((k>>1)&2) | ((k>>2)&4)
+        y:= ((k>>1)&1) | ((k>>2)&2) | ((k>>3)&4)
+        pixel:= readLE16 ( mem[2*i:] )
+        r:= uint8(pixel>>11)&0x1f
+        g:= uint8(pixel>>5)&0x3f
+        b:= uint8(pixel)&0x1f
+        ret.SetRGBA ( tx+x, ty+y, color.RGBA{
+          R : (r<<3) | (r>>2),
+          G : (g<<2) | (g>>4),
+          B : (b<<3) | (b>>2),
+          A : 0xff,
+        })
+        i++
+      }
+    }
+  }
+
+  return ret
+
+} // end decodeSMDHIcon
+
+
+func ReadSMDH( fd io.Reader ) (*SMDH,error) {
+
+  // Llig
+  var buf [SMDH_SIZE]byte
+  if _,err:= io.ReadFull ( fd, buf[:] ); err == io.ErrUnexpectedEOF ||
+    err == io.EOF {
+    return nil,errors.New ( "Error while reading SMDH: not enough bytes" )
+  } else if err != nil {
+    return nil,fmt.Errorf ( "Error while reading SMDH: %s", err )
+  }
+  if buf[0]!='S' || buf[1]!='M' || buf[2]!='D' || buf[3]!='H' {
+    return nil,fmt.Errorf ( "Not a SMDH file: wrong magic number (%c%c%c%c)",
+      buf[0], buf[1], buf[2], buf[3] )
+  }
+
+  // Processa
+  ret:= SMDH{
+    Version : readLE16 ( buf[4:] ),
+  }
+  for i:= 0; i < SMDH_NUM_LANGUAGES; i++ {
+    mem:= buf[0x8+i*0x200:0x8+(i+1)*0x200]
+    ret.Titles[i]= SMDH_Title{
+      Short     : decodeUTF16LE ( mem[:0x80] ),
+      Long      : decodeUTF16LE ( mem[0x80:0x180] ),
+      Publisher : decodeUTF16LE ( mem[0x180:] ),
+    }
+  }
+  copy ( ret.Ratings[:], buf[0x2008:0x2018] )
+  ret.RegionLockout= readLE32 ( buf[0x2018:] )
+  ret.Flags= readLE32 ( buf[0x2028:] )
+  ret.EULAVersion= readLE16 ( buf[0x202c:] )
+  copy ( ret.small_icon[:], buf[0x2040:0x24c0] )
+  copy ( ret.large_icon[:], buf[0x24c0:0x36c0] )
+
+  return &ret,nil
+
+} // end ReadSMDH
+
+
+// Icona de 24x24.
+func (self *SMDH) SmallIcon() image.Image {
+  return decodeSMDHIcon ( self.small_icon[:], 24 )
+} // end SmallIcon
+
+
+// Icona de 48x48.
+func (self *SMDH) LargeIcon() image.Image {
+  return decodeSMDHIcon ( self.large_icon[:], 48 )
+} // end LargeIcon
+
+
+// Si no té fitxer icon torna nil sense error.
+func (self *ExeFS) GetSMDH() (*SMDH,error) {
+
+  for i:= range self.Files {
+    if self.Files[i].Name == "icon" {
+      fd,err:= self.Open ( &self.Files[i] )
+      if err != nil { return nil,err }
+      defer fd.Close ()
+      return ReadSMDH ( fd )
+    }
+  }
+
+  return nil,nil
+
+} // end GetSMDH
+
+
+func SMDH_language2str( lang int ) string {
+
+  switch lang {
+  case SMDH_LANG_JAPANESE:
+    return "Japanese"
+  case SMDH_LANG_ENGLISH:
+    return "English"
+  case SMDH_LANG_FRENCH:
+    return "French"
+  case SMDH_LANG_GERMAN:
+    return "German"
+  case SMDH_LANG_ITALIAN:
+    return "Italian"
+  case SMDH_LANG_SPANISH:
+    return "Spanish"
+  case SMDH_LANG_SIMPLIFIED_CHINESE:
+    return "Simplified Chinese"
+  case SMDH_LANG_KOREAN:
+    return "Korean"
+  case SMDH_LANG_DUTCH:
+    return "Dutch"
+  case SMDH_LANG_PORTUGUESE:
+    return "Portuguese"
+  case SMDH_LANG_RUSSIAN:
+    return "Russian"
+  case SMDH_LANG_TRADITIONAL_CHINESE:
+    return "Traditional Chinese"
+  default:
+    return "Unknown"
+  }
+
+} // end SMDH_language2str
+
+
+func SMDH_rating2str( rating int ) string {
+
+  switch rating {
+  case SMDH_RATING_CERO:
+    return "CERO"
+  case SMDH_RATING_ESRB:
+    return "ESRB"
+  case SMDH_RATING_USK:
+    return "USK"
+  case SMDH_RATING_PEGI_GEN:
+    return "PEGI GEN"
+  case SMDH_RATING_PEGI_PRT:
+    return "PEGI PRT"
+  case SMDH_RATING_PEGI_BBFC:
+    return "PEGI BBFC"
+  case SMDH_RATING_COB:
+    return "COB"
+  case SMDH_RATING_GRB:
+    return "GRB"
+  case SMDH_RATING_CGSRR:
+    return "CGSRR"
+  default:
+    return "Unknown"
+  }
+
+} // end SMDH_rating2str
@@ -0,0 +1,235 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  write_ncch.go - Reconstrueix un NCCH sense xifrar amb una RomFS
+ *                  nova.
+ */
+
+package citrus
+
+import (
+  "bufio"
+  "crypto/sha256"
+  "errors"
+  "fmt"
+  "io"
+  "os"
+
+  "github.com/adriagipas/imgcp/utils"
+)
+
+
+/****************/
+/* PART PRIVADA */
+/****************/
+
+const _NCCH_MEDIA_UNIT = 0x200
+
+
+// Llig la capçalera IVFC d'una imatge RomFS i torna el hash i la
+// grandària de la regió de hash (capçalera més hash mestre).
+func readRomFSHashRegion(
+
+  fd   io.Reader,
+  size int64,
+
+) ([32]byte,int64,error) {
+
+  var ret [32]byte
+  var buf [0x60]byte
+  if _,err:= io.ReadFull ( fd, buf[:] ); err != nil {
+    return ret,0,fmt.Errorf ( "Error while reading RomFS header: %s", err )
+  }
+  if buf[0]!='I' || buf[1]!='V' || buf[2]!='F' || buf[3]!='C' {
+    return ret,0,errors.New ( "Not a RomFS file: wrong magic number" )
+  }
+  hash_size:= alignRomFS ( 0x60+int64(readLE32 ( buf[0x8:] )),
+    _NCCH_MEDIA_UNIT )
+  if hash_size > size {
+    return ret,0,errors.New ( "Error while reading RomFS header: master hash"+
+      " too big" )
+  }
+  h:= sha256.New ()
+  h.Write ( buf[:] )
+  if _,err:= io.CopyN ( h, fd, hash_size-int64(len(buf)) ); err != nil {
+    return ret,0,fmt.Errorf ( "Error while reading RomFS header: %s", err )
+  }
+  copy ( ret[:], h.Sum ( nil ) )
+
+  return ret,hash_size,nil
+
+} // end readRomFSHashRegion
+
+
+// Llig (desxifrant si cal) len(mem) bytes de fd en mem.
+func readSection( fd SectionReader, mem []byte, err error ) error {
+
+  if err != nil { return err }
+  if fd == nil { return nil }
+  defer fd.Close ()
+  _,err= io.ReadFull ( fd, mem )
+
+  return err
+
+} // end readSection
+
+
+// Torna totes les regions anteriors a la RomFS (capçalera inclosa)
+// desxifrades.
+func (self *NCCH) readPlainSections( end int64 ) ([]byte,error) {
+
+  h:= &self.Header
+
+  // Còpia directa
+  ret:= make([]byte,end)
+  fd,err:= utils.NewSubfileReader ( self.file_name, self.offset, end )
+  if err != nil { return nil,err }
+  defer fd.Close ()
+  if _,err:= io.ReadFull ( fd, ret ); err != nil {
+    return nil,fmt.Errorf ( "Error while reading NCCH: %s", err )
+  }
+  if h.Crypto == NCCH_CRYPTO_NONE {
+    return ret,nil
+  }
+
+  // ExHeader
+  if h.ExHeaderSize != 0 {
+    fd,err:= self.OpenExHeader ()
+    err= readSection ( fd, ret[0x200:0x200+NCCH_EXHEADER_SIZE], err )
+    if err != nil {
+      return nil,fmt.Errorf ( "Error while reading ExHeader: %s", err )
+    }
+  }
+
+  // ExeFS. L'espai entre fitxers es deixa a zero.
+  if h.ExeFS.Size != 0 {
+    mem:= ret[h.ExeFS.Offset:h.ExeFS.Offset+h.ExeFS.Size]
+    for i:= range mem {
+      mem[i]= 0
+    }
+    primary,_,err:= self.getSectionKeys ( _NCCH_SECTION_EXEFS,
+      h.ExeFS.Offset )
+    if err != nil { return nil,err }
+    fd,err:= openSection ( self.file_name, self.offset+h.ExeFS.Offset,
+      0x200, primary, 0 )
+    if err:= readSection ( fd, mem[:0x200], err ); err != nil {
+      return nil,fmt.Errorf ( "Error while reading ExeFS: %s", err )
+    }
+    exefs,err:= self.GetExeFS ()
+    if err != nil { return nil,err }
+    for i:= range exefs.Files {
+      file:= &exefs.Files[i]
+      off:= 0x200+int64(uint64(file.Offset))
+      fd,err:= exefs.Open ( file )
+      err= readSection ( fd, mem[off:off+int64(uint64(file.Size))], err )
+      if err != nil {
+        return nil,fmt.Errorf ( "Error while reading ExeFS file '%s': %s",
+          file.Name, err )
+      }
+    }
+  }
+
+  return ret,nil
+
+} // end readPlainSections
+
+
+/****************/
+/* PART PÚBLICA */
+/****************/
+
+// Escriu en file_name una còpia sense xifrar de l'NCCH on la RomFS
+// se substitueix per la imatge romfs_name (veure RomFS_Writer). Es
+// recalculen les grandàries i el hash de la RomFS de la capçalera,
+// però no la signatura.
+func (self *NCCH) Rebuild( file_name string, romfs_name string ) error {
+
+  h:= &self.Header
+
+  // Comprovacions
+  if info,err:= os.Stat ( file_name ); err == nil {
+    if orig,err:= os.Stat ( self.file_name ); err == nil &&
+      os.SameFile ( info, orig ) {
+      return errors.New ( "Output file and NCCH file are the same file" )
+    }
+  }
+
+  // RomFS
+  romfs,err:= os.Open ( romfs_name )
+  if err != nil { return err }
+  defer romfs.Close ()
+  info,err:= romfs.Stat ()
+  if err != nil { return err }
+  romfs_hash,hash_size,err:= readRomFSHashRegion ( romfs, info.Size () )
+  if err != nil { return err }
+  if _,err:= romfs.Seek ( 0, 0 ); err != nil { return err }
+  romfs_size:= alignRomFS ( info.Size (), _NCCH_MEDIA_UNIT )
+
+  // Posició de la RomFS. Es conserva l'original si no se solapa.
+  end:= int64(0x200)
+  if h.ExHeaderSize != 0 {
+    end= 0x200 + NCCH_EXHEADER_SIZE
+  }
+  for _,sec:= range []*NCCH_FileOffset{&h.Plain,&h.Logo,&h.ExeFS} {
+    if sec.Size != 0 && sec.Offset+sec.Size > end {
+      end= sec.Offset+sec.Size
+    }
+  }
+  romfs_off:= h.RomFS.Offset
+  if h.RomFS.Size == 0 || romfs_off < end {
+    romfs_off= alignRomFS ( end, 0x1000 )
+  }
+
+  // Seccions anteriors
+  data,err:= self.readPlainSections ( end )
+  if err != nil { return err }
+
+  // Actualitza capçalera
+  setLE32 ( data[0x104:],
+    uint32((romfs_off+romfs_size)/_NCCH_MEDIA_UNIT) )
+  setLE32 ( data[0x1b0:], uint32(romfs_off/_NCCH_MEDIA_UNIT) )
+  setLE32 ( data[0x1b4:], uint32(romfs_size/_NCCH_MEDIA_UNIT) )
+  setLE32 ( data[0x1b8:], uint32(hash_size/_NCCH_MEDIA_UNIT) )
+  setLE32 ( data[0x1bc:], 0 )
+  copy ( data[0x1e0:0x200], romfs_hash[:] )
+  data[0x188+3]= 0
+  data[0x188+7]&^= _NCCH_CFLAG_FIXED_KEY|_NCCH_CFLAG_SEED
+  data[0x188+7]|= _NCCH_CFLAG_NO_CRYPTO
+
+  // Escriu
+  f,err:= os.Create ( file_name )
+  if err != nil { return err }
+  defer f.Close ()
+  w:= bufio.NewWriter ( f )
+  if _,err:= w.Write ( data ); err != nil { return err }
+  zeros:= make([]byte,_NCCH_MEDIA_UNIT)
+  for pad:= romfs_off-end; pad > 0; pad-= int64(len(zeros)) {
+    n:= pad
+    if n > int64(len(zeros)) { n= int64(len(zeros)) }
+    if _,err:= w.Write ( zeros[:n] ); err != nil { return err }
+  }
+  if _,err:= io.Copy ( w, romfs ); err != nil { return err }
+  if _,err:= w.Write ( zeros[:romfs_size-info.Size ()] ); err != nil {
+    return err
+  }
+
+  return w.Flush ()
+
+} // end Rebuild
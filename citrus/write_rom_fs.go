@@ -0,0 +1,545 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  write_rom_fs.go - Funcions per crear imatges RomFS (nivell 3 amb
+ *                    les taules de hash i els nivells IVFC).
+ */
+
+package citrus
+
+import (
+  "bufio"
+  "crypto/sha256"
+  "errors"
+  "io"
+  "os"
+  "unicode/utf16"
+)
+
+
+/****************/
+/* PART PRIVADA */
+/****************/
+
+// Grandària de bloc dels tres nivells IVFC.
+const _ROMFS_WRITER_BLOCK_LOG2 = 12
+const _ROMFS_WRITER_BLOCK_SIZE = 1<<_ROMFS_WRITER_BLOCK_LOG2
+
+const _ROMFS_WRITER_BUF_SIZE = 64*1024
+
+const _ROMFS_WRITER_UNUSED = 0xffffffff
+
+// Capçalera del nivell 3
+const _ROMFS_WRITER_L3_HEADER_SIZE = 0x28
+
+// Capçalera IVFC
+const _ROMFS_WRITER_IVFC_HEADER_SIZE = 0x5c
+
+
+// FUNCIONS ////////////////////////////////////////////////////////////////////
+
+func setLE32( data []byte, val uint32 ) {
+  data[0]= uint8(val)
+  data[1]= uint8(val>>8)
+  data[2]= uint8(val>>16)
+  data[3]= uint8(val>>24)
+} // end setLE32
+
+
+func setLE64( data []byte, val uint64 ) {
+  setLE32 ( data[0:4], uint32(val) )
+  setLE32 ( data[4:8], uint32(val>>32) )
+} // end setLE64
+
+
+func alignRomFS( offset int64, align int64 ) int64 {
+  return (offset+align-1)/align*align
+} // end alignRomFS
+
+
+// Nombre d'entrades de les taules de hash.
+func romfsHashTableSize( nentries int ) uint32 {
+
+  count:= uint32(nentries)
+  if count < 3 {
+    return 3
+  } else if count < 19 {
+    return count|1
+  }
+  for count%2 == 0 || count%3 == 0 || count%5 == 0 || count%7 == 0 ||
+    count%11 == 0 || count%13 == 0 || count%17 == 0 {
+    count++
+  }
+
+  return count
+
+} // end romfsHashTableSize
+
+
+func romfsHashName( parent uint32, name []uint16 ) uint32 {
+
+  ret:= parent^123456789
+  for _,c:= range name {
+    ret= (ret>>5) | (ret<<27)
+    ret^= uint32(c)
+  }
+
+  return ret
+
+} // end romfsHashName
+
+
+// Calcula el SHA-256 de cada bloc (l'últim es completa amb zeros).
+func romfsHashBlocks( data []byte ) []byte {
+
+  var block [_ROMFS_WRITER_BLOCK_SIZE]byte
+  ret:= make([]byte,0,
+    (len(data)+_ROMFS_WRITER_BLOCK_SIZE-1)/_ROMFS_WRITER_BLOCK_SIZE*32)
+  for off:= 0; off < len(data); off+= _ROMFS_WRITER_BLOCK_SIZE {
+    n:= copy ( block[:], data[off:] )
+    for i:= n; i < len(block); i++ {
+      block[i]= 0
+    }
+    sum:= sha256.Sum256 ( block[:] )
+    ret= append(ret,sum[:]...)
+  }
+
+  return ret
+
+} // end romfsHashBlocks
+
+
+// Escriptor que calcula el hash de cada bloc del que s'escriu.
+type _RomFS_BlockHasher struct {
+
+  w      io.Writer
+  block  [_ROMFS_WRITER_BLOCK_SIZE]byte
+  pos    int
+  hashes []byte
+
+}
+
+
+func (self *_RomFS_BlockHasher) Write( data []byte ) (int,error) {
+
+  n,err:= self.w.Write ( data )
+  for remain:= data[:n]; len(remain) > 0; {
+    nc:= copy ( self.block[self.pos:], remain )
+    remain= remain[nc:]
+    self.pos+= nc
+    if self.pos == len(self.block) {
+      sum:= sha256.Sum256 ( self.block[:] )
+      self.hashes= append(self.hashes,sum[:]...)
+      self.pos= 0
+    }
+  }
+
+  return n,err
+
+} // end Write
+
+
+// Completa l'últim bloc amb zeros i torna els hashos.
+func (self *_RomFS_BlockHasher) finish() []byte {
+
+  if self.pos > 0 {
+    for i:= self.pos; i < len(self.block); i++ {
+      self.block[i]= 0
+    }
+    sum:= sha256.Sum256 ( self.block[:] )
+    self.hashes= append(self.hashes,sum[:]...)
+    self.pos= 0
+  }
+
+  return self.hashes
+
+} // end finish
+
+
+/****************/
+/* PART PÚBLICA */
+/****************/
+
+// ENTRADES ////////////////////////////////////////////////////////////////////
+
+type RomFS_WriterFile struct {
+
+  name   []uint16
+  parent *RomFS_WriterDir
+  offset int64  // Posició dins de les dades
+  size   int64
+  meta   uint32 // Posició en la taula de metadades
+
+}
+
+
+type RomFS_WriterDir struct {
+
+  w      *RomFS_Writer
+  name   []uint16
+  parent *RomFS_WriterDir
+  dirs   []*RomFS_WriterDir
+  files  []*RomFS_WriterFile
+  meta   uint32 // Posició en la taula de metadades
+
+}
+
+
+func romfsEntrySize( name []uint16 ) uint32 {
+  return uint32(alignRomFS ( int64(len(name)*2), 4 ))
+} // end romfsEntrySize
+
+
+// Afegeix un subdirectori.
+func (self *RomFS_WriterDir) AddDir( name string ) *RomFS_WriterDir {
+
+  ret:= RomFS_WriterDir{
+    w : self.w,
+    name : utf16.Encode ( []rune(name) ),
+    parent : self,
+  }
+  self.dirs= append(self.dirs,&ret)
+
+  return &ret
+
+} // end AddDir
+
+
+// Afegeix un fitxer copiant immediatament el contingut del lector
+// proporcionat.
+func (self *RomFS_WriterDir) AddFile(
+
+  name string,
+  r    io.Reader,
+
+) (*RomFS_WriterFile,error) {
+
+  w:= self.w
+  if w.closed {
+    return nil,errors.New ( "RomFS image already closed" )
+  }
+  ret:= RomFS_WriterFile{
+    name : utf16.Encode ( []rune(name) ),
+    parent : self,
+    offset : alignRomFS ( w.data_size, 0x10 ),
+    size : 0,
+  }
+
+  // Copia
+  buf:= make([]byte,_ROMFS_WRITER_BUF_SIZE)
+  for {
+    n,err:= r.Read ( buf )
+    if n > 0 {
+      if _,err:= w.tmp.WriteAt ( buf[:n], ret.offset+ret.size ); err != nil {
+        return nil,err
+      }
+      ret.size+= int64(n)
+    }
+    if err == io.EOF {
+      break
+    } else if err != nil {
+      return nil,err
+    }
+  }
+  if ret.size > 0 {
+    w.data_size= ret.offset+ret.size
+  }
+  self.files= append(self.files,&ret)
+
+  return &ret,nil
+
+} // end AddFile
+
+
+// Assigna les posicions en la taula de metadades. Els subdirectoris
+// d'un directori són consecutius.
+func (self *RomFS_WriterDir) assignDirs(
+
+  next *uint32,
+  dirs *[]*RomFS_WriterDir,
+
+) {
+
+  for _,d:= range self.dirs {
+    d.meta= *next
+    *next+= 0x18 + romfsEntrySize ( d.name )
+    *dirs= append(*dirs,d)
+  }
+  for _,d:= range self.dirs {
+    d.assignDirs ( next, dirs )
+  }
+
+} // end assignDirs
+
+
+func (self *RomFS_WriterDir) parentMeta() uint32 {
+  if self.parent == nil { return 0 }
+  return self.parent.meta
+} // end parentMeta
+
+
+// ESCRIPTOR ///////////////////////////////////////////////////////////////////
+
+// Escriptor d'imatges RomFS. Les dades dels fitxers es copien (amb
+// AddFile) en un fitxer temporal i quan es tanca s'escriu la imatge
+// amb les metadades, les dades i l'arbre de hash IVFC.
+type RomFS_Writer struct {
+
+  file_name string
+  tmp       *os.File
+  data_size int64
+  root      *RomFS_WriterDir
+  closed    bool
+
+}
+
+
+// Crea una nova imatge RomFS.
+func NewRomFSWriter( file_name string ) (*RomFS_Writer,error) {
+
+  ret:= RomFS_Writer{
+    file_name : file_name,
+  }
+  ret.root= &RomFS_WriterDir{
+    w : &ret,
+  }
+  var err error
+  if ret.tmp,err= os.CreateTemp ( "", "imgcp-romfs-*" ); err != nil {
+    return nil,err
+  }
+
+  return &ret,nil
+
+} // end NewRomFSWriter
+
+
+// Torna el directori arrel.
+func (self *RomFS_Writer) Root() *RomFS_WriterDir { return self.root }
+
+
+// Escriu la imatge i tanca.
+func (self *RomFS_Writer) Close() error {
+
+  if self.closed {
+    return errors.New ( "RomFS image already closed" )
+  }
+  self.closed= true
+  defer os.Remove ( self.tmp.Name () )
+  defer self.tmp.Close ()
+
+  // Metadades
+  l3,err:= self.makeLevel3Header ()
+  if err != nil { return err }
+
+  // Nivell 3
+  f,err:= os.Create ( self.file_name )
+  if err != nil { return err }
+  defer f.Close ()
+  if _,err:= f.Seek ( _ROMFS_WRITER_BLOCK_SIZE, 0 ); err != nil {
+    return err
+  }
+  bw:= bufio.NewWriter ( f )
+  hasher:= _RomFS_BlockHasher{ w : bw }
+  if _,err:= hasher.Write ( l3 ); err != nil { return err }
+  if _,err:= self.tmp.Seek ( 0, 0 ); err != nil { return err }
+  if _,err:= io.CopyN ( &hasher, self.tmp, self.data_size ); err != nil {
+    return err
+  }
+  l3_size:= int64(len(l3))+self.data_size
+
+  // Nivells 2 i 1
+  level2:= hasher.finish ()
+  level1:= romfsHashBlocks ( level2 )
+  master:= romfsHashBlocks ( level1 )
+  if 0x60+len(master) > _ROMFS_WRITER_BLOCK_SIZE {
+    return errors.New ( "RomFS image is too big" )
+  }
+  l1_off:= alignRomFS ( _ROMFS_WRITER_BLOCK_SIZE+l3_size,
+    _ROMFS_WRITER_BLOCK_SIZE )
+  l2_off:= alignRomFS ( l1_off+int64(len(level1)), _ROMFS_WRITER_BLOCK_SIZE )
+  end:= alignRomFS ( l2_off+int64(len(level2)), 0x200 )
+  zeros:= make([]byte,_ROMFS_WRITER_BLOCK_SIZE)
+  for _,level:= range []struct{
+    off  int64
+    data []byte
+  }{
+    {l1_off,level1},
+    {l2_off,level2},
+    {end,nil},
+  } {
+    pad:= level.off-(_ROMFS_WRITER_BLOCK_SIZE+l3_size)
+    for ; pad > 0; pad-= int64(len(zeros)) {
+      n:= pad
+      if n > int64(len(zeros)) { n= int64(len(zeros)) }
+      if _,err:= bw.Write ( zeros[:n] ); err != nil { return err }
+    }
+    if _,err:= bw.Write ( level.data ); err != nil { return err }
+    l3_size= level.off+int64(len(level.data))-_ROMFS_WRITER_BLOCK_SIZE
+  }
+  if err:= bw.Flush (); err != nil { return err }
+
+  // Capçalera IVFC
+  header:= make([]byte,_ROMFS_WRITER_BLOCK_SIZE)
+  copy ( header, "IVFC" )
+  setLE32 ( header[0x4:], 0x10000 )
+  setLE32 ( header[0x8:], uint32(len(master)) )
+  var logical int64= 0
+  for i,size:= range []int64{
+    int64(len(level1)),
+    int64(len(level2)),
+    int64(len(l3)) + self.data_size,
+  } {
+    mem:= header[0xc+i*0x18:]
+    setLE64 ( mem[0x0:], uint64(logical) )
+    setLE64 ( mem[0x8:], uint64(size) )
+    setLE32 ( mem[0x10:], _ROMFS_WRITER_BLOCK_LOG2 )
+    logical= alignRomFS ( logical+size, _ROMFS_WRITER_BLOCK_SIZE )
+  }
+  setLE32 ( header[0x58:], _ROMFS_WRITER_IVFC_HEADER_SIZE )
+  copy ( header[0x60:], master )
+  if _,err:= f.WriteAt ( header, 0 ); err != nil { return err }
+
+  return nil
+
+} // end Close
+
+
+// Construeix la capçalera, les taules de hash i les metadades del
+// nivell 3 (completat fins a l'inici de les dades).
+func (self *RomFS_Writer) makeLevel3Header() ([]byte,error) {
+
+  // Posicions dels directoris i fitxers
+  self.root.meta= 0
+  dirs:= []*RomFS_WriterDir{self.root}
+  dir_meta_size:= uint32(0x18)
+  self.root.assignDirs ( &dir_meta_size, &dirs )
+  var files []*RomFS_WriterFile
+  file_meta_size:= uint32(0)
+  for _,d:= range dirs {
+    for _,f:= range d.files {
+      f.meta= file_meta_size
+      file_meta_size+= 0x20 + romfsEntrySize ( f.name )
+      files= append(files,f)
+    }
+  }
+
+  // Taules de hash
+  dir_hash:= make([]uint32,romfsHashTableSize ( len(dirs) ))
+  dir_next:= make([]uint32,len(dirs))
+  for i:= range dir_hash {
+    dir_hash[i]= _ROMFS_WRITER_UNUSED
+  }
+  for i,d:= range dirs {
+    ind:= romfsHashName ( d.parentMeta (), d.name )%uint32(len(dir_hash))
+    dir_next[i]= dir_hash[ind]
+    dir_hash[ind]= d.meta
+  }
+  file_hash:= make([]uint32,romfsHashTableSize ( len(files) ))
+  file_next:= make([]uint32,len(files))
+  for i:= range file_hash {
+    file_hash[i]= _ROMFS_WRITER_UNUSED
+  }
+  for i,f:= range files {
+    ind:= romfsHashName ( f.parent.meta, f.name )%uint32(len(file_hash))
+    file_next[i]= file_hash[ind]
+    file_hash[ind]= f.meta
+  }
+
+  // Posicions de les taules
+  dir_hash_off:= uint32(_ROMFS_WRITER_L3_HEADER_SIZE)
+  dir_meta_off:= dir_hash_off + uint32(len(dir_hash))*4
+  file_hash_off:= dir_meta_off + dir_meta_size
+  file_meta_off:= file_hash_off + uint32(len(file_hash))*4
+  data_off:= uint32(alignRomFS ( int64(file_meta_off+file_meta_size), 0x10 ))
+  ret:= make([]byte,data_off)
+
+  // Capçalera
+  setLE32 ( ret[0x00:], _ROMFS_WRITER_L3_HEADER_SIZE )
+  setLE32 ( ret[0x04:], dir_hash_off )
+  setLE32 ( ret[0x08:], uint32(len(dir_hash))*4 )
+  setLE32 ( ret[0x0c:], dir_meta_off )
+  setLE32 ( ret[0x10:], dir_meta_size )
+  setLE32 ( ret[0x14:], file_hash_off )
+  setLE32 ( ret[0x18:], uint32(len(file_hash))*4 )
+  setLE32 ( ret[0x1c:], file_meta_off )
+  setLE32 ( ret[0x20:], file_meta_size )
+  setLE32 ( ret[0x24:], data_off )
+
+  // Taules de hash
+  for i,val:= range dir_hash {
+    setLE32 ( ret[dir_hash_off+uint32(i)*4:], val )
+  }
+  for i,val:= range file_hash {
+    setLE32 ( ret[file_hash_off+uint32(i)*4:], val )
+  }
+
+  // Metadades dels directoris
+  for i,d:= range dirs {
+    mem:= ret[dir_meta_off+d.meta:]
+    setLE32 ( mem[0x00:], d.parentMeta () )
+    setLE32 ( mem[0x04:], _ROMFS_WRITER_UNUSED )
+    if d.parent != nil {
+      siblings:= d.parent.dirs
+      for j,s:= range siblings {
+        if s == d && j+1 < len(siblings) {
+          setLE32 ( mem[0x04:], siblings[j+1].meta )
+        }
+      }
+    }
+    setLE32 ( mem[0x08:], _ROMFS_WRITER_UNUSED )
+    if len(d.dirs) > 0 {
+      setLE32 ( mem[0x08:], d.dirs[0].meta )
+    }
+    setLE32 ( mem[0x0c:], _ROMFS_WRITER_UNUSED )
+    if len(d.files) > 0 {
+      setLE32 ( mem[0x0c:], d.files[0].meta )
+    }
+    setLE32 ( mem[0x10:], dir_next[i] )
+    setLE32 ( mem[0x14:], uint32(len(d.name)*2) )
+    for j,c:= range d.name {
+      mem[0x18+2*j]= uint8(c)
+      mem[0x18+2*j+1]= uint8(c>>8)
+    }
+  }
+
+  // Metadades dels fitxers
+  for i,f:= range files {
+    mem:= ret[file_meta_off+f.meta:]
+    setLE32 ( mem[0x00:], f.parent.meta )
+    setLE32 ( mem[0x04:], _ROMFS_WRITER_UNUSED )
+    siblings:= f.parent.files
+    for j,s:= range siblings {
+      if s == f && j+1 < len(siblings) {
+        setLE32 ( mem[0x04:], siblings[j+1].meta )
+      }
+    }
+    setLE64 ( mem[0x08:], uint64(f.offset) )
+    setLE64 ( mem[0x10:], uint64(f.size) )
+    setLE32 ( mem[0x18:], file_next[i] )
+    setLE32 ( mem[0x1c:], uint32(len(f.name)*2) )
+    for j,c:= range f.name {
+      mem[0x20+2*j]= uint8(c)
+      mem[0x20+2*j+1]= uint8(c>>8)
+    }
+  }
+
+  return ret,nil
+
+} // end makeLevel3Header
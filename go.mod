@@ -2,4 +2,4 @@ module github.com/adriagipas/imgcp
 
 go 1.19
 
-require golang.org/x/text v0.17.0 // indirect
+require golang.org/x/text v0.17.0
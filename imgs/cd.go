@@ -24,6 +24,7 @@
 package imgs
 
 import (
+  "bytes"
   "errors"
   "fmt"
   "io"
@@ -77,6 +78,63 @@ func (self *_CD) PrintInfo( file io.Writer, prefix string ) error {
   // Imprimeix
   F(file,"%sCD-Rom Image (%s)\n", prefix, self.cd.Format () )
   P(file,prefix,"")
+  if len(info.Catalog) > 0 {
+    F(file,"%sCatalog:   %s\n",prefix,info.Catalog)
+  }
+  if len(info.CDText.Title) > 0 {
+    F(file,"%sTitle:     %s\n",prefix,info.CDText.Title)
+  }
+  if len(info.CDText.Performer) > 0 {
+    F(file,"%sPerformer: %s\n",prefix,info.CDText.Performer)
+  }
+  for _,comment:= range info.Comments {
+    F(file,"%sComment:   %s\n",prefix,comment)
+  }
+  if len(info.Catalog) > 0 || len(info.CDText.Title) > 0 ||
+    len(info.CDText.Performer) > 0 || len(info.Comments) > 0 {
+    P(file,prefix,"")
+  }
+  if info.DVD != nil {
+    dvd:= info.DVD
+    var book string
+    switch dvd.BookType {
+    case 0:
+      book= "DVD-ROM"
+    case 1:
+      book= "DVD-RAM"
+    case 2:
+      book= "DVD-R"
+    case 3:
+      book= "DVD-RW"
+    case 9:
+      book= "DVD+RW"
+    case 10:
+      book= "DVD+R"
+    default:
+      book= fmt.Sprintf ( "Unknown (%d)", dvd.BookType )
+    }
+    var size string
+    if dvd.DiscSize == 0 {
+      size= "120mm"
+    } else {
+      size= "80mm"
+    }
+    var track_path string
+    if dvd.TrackPath {
+      track_path= "Opposite (OTP)"
+    } else {
+      track_path= "Parallel (PTP)"
+    }
+    F(file,"%sBook Type:   %s (version %d)\n",prefix,book,dvd.PartVersion)
+    F(file,"%sDisc Size:   %s\n",prefix,size)
+    F(file,"%sLayers:      %d\n",prefix,dvd.NumLayers)
+    if dvd.NumLayers > 1 {
+      F(file,"%sTrack Path:  %s\n",prefix,track_path)
+    }
+    F(file,"%sData Area:   %06X - %06X\n",
+      prefix,dvd.StartSector,dvd.EndSector)
+    P(file,prefix,"")
+  }
   P(file,prefix, "Sessions:")
   
   var sess *cdread.SessionInfo
@@ -126,10 +184,49 @@ func (self *_CD) PrintInfo( file io.Writer, prefix string ) error {
       // Salt de línia
       P(file,"")
 
-      // Si és ISO imprimeix la info
+      // Metadades
+      if track.Flags&(^uint8(cdread.TRACK_FLAGS_DATA)) != 0 {
+        F(file,"%s        Flags:",prefix)
+        if (track.Flags&cdread.TRACK_FLAGS_DCP) != 0 { F(file," DCP") }
+        if (track.Flags&cdread.TRACK_FLAGS_4CH) != 0 { F(file," 4CH") }
+        if (track.Flags&cdread.TRACK_FLAGS_PRE) != 0 { F(file," PRE") }
+        if (track.Flags&cdread.TRACK_FLAGS_SCMS) != 0 { F(file," SCMS") }
+        P(file,"")
+      }
+      if len(track.ISRC) > 0 {
+        F(file,"%s        ISRC: %s\n",prefix,track.ISRC)
+      }
+      if len(track.CDText.Title) > 0 {
+        F(file,"%s        Title: %s\n",prefix,track.CDText.Title)
+      }
+      if len(track.CDText.Performer) > 0 {
+        F(file,"%s        Performer: %s\n",prefix,track.CDText.Performer)
+      }
+
+      // Si és UDF o ISO imprimeix la info (UDF té preferència)
       if track.Type != cdread.TRACK_TYPE_AUDIO &&
         track.Type != cdread.TRACK_TYPE_UNK {
-        if iso,err:= newISO_9660 ( self.cd, s, t ); err == nil {
+        if ipbin,err:= cdread.ReadIPBin ( self.cd, s, t ); err == nil {
+          pre:= prefix+"        "
+          P(file,"")
+          P(file,pre+"Dreamcast IP.BIN")
+          P(file,pre)
+          F(file,"%sHardware Id:      %s\n",pre,ipbin.HardwareId)
+          F(file,"%sMaker Id:         %s\n",pre,ipbin.MakerId)
+          F(file,"%sDevice Info:      %s\n",pre,ipbin.DeviceInfo)
+          F(file,"%sArea Symbols:     %s\n",pre,ipbin.AreaSymbols)
+          F(file,"%sPeripherals:      %s\n",pre,ipbin.Peripherals)
+          F(file,"%sProduct Number:   %s\n",pre,ipbin.ProductNumber)
+          F(file,"%sProduct Version:  %s\n",pre,ipbin.ProductVersion)
+          F(file,"%sRelease Date:     %s\n",pre,ipbin.ReleaseDate)
+          F(file,"%sBoot File Name:   %s\n",pre,ipbin.BootFileName)
+          F(file,"%sSoftware Maker:   %s\n",pre,ipbin.SoftwareMaker)
+          F(file,"%sTitle:            %s\n",pre,ipbin.Title)
+        }
+        if udf,err:= newUDF ( self.cd, s, t ); err == nil {
+          P(file,"")
+          udf.PrintInfo ( file, prefix+"        " )
+        } else if iso,err:= newISO_9660 ( self.cd, s, t ); err == nil {
           P(file,"")
           iso.PrintInfo ( file, prefix+"        " )
         }
@@ -308,16 +405,30 @@ type _CD_TracksDirIter struct {
   dir          *_CD_TracksDir
   current_track int
   is_iso        bool
+  is_udf        bool
+  sub_cd        cdread.SubchannelCD // nil si no hi ha subcanal
+  current_entry int // 0 - track, 1 - N.sub, 2 - N.subq.txt
   
 }
+// NOTA!!! Després de l'últim track hi ha una entrada més, el
+// directori 'raw' amb la vista RAW dels tracks.
+
+
+// Entrades per track.
+const (
+  _CD_TRACK_ENTRY_MAIN = 0
+  _CD_TRACK_ENTRY_SUB  = 1
+  _CD_TRACK_ENTRY_SUBQ = 2
+)
 
 
 func (self *_CD_TracksDirIter) checkIsIso() error {
 
   self.is_iso= false
+  self.is_udf= false
   
   // Si estem al final no faces res.
-  if self.End() { return nil}
+  if self.End() || self.isRawEntry () { return nil}
   
   ttype:= self.getTrackType ()
   if ttype == cdread.TRACK_TYPE_AUDIO || ttype == cdread.TRACK_TYPE_UNK {
@@ -329,13 +440,25 @@ func (self *_CD_TracksDirIter) checkIsIso() error {
   if err != nil { return err }
   defer tr.Close ()
   
-  // Comprova signatura ISO
-  var data [6]byte
+  // Comprova signatura ISO (o High Sierra)
+  var data [14]byte
   if err:= tr.Seek ( 0x10 ); err == nil { // Podria ser que fora més menut
     if _,err:= tr.Read ( data[:] ); err != nil { return err }
     if data[1]=='C' && data[2]=='D' && data[3]=='0' &&
       data[4]=='0' && data[5]=='1' {
       self.is_iso= true
+    } else if data[9]=='C' && data[10]=='D' && data[11]=='R' &&
+      data[12]=='O' && data[13]=='M' {
+      self.is_iso= true
+    }
+  }
+
+  // Comprova UDF (pot conviure amb ISO)
+  if data[1]=='C' || data[1]=='B' || data[1]=='N' {
+    if _,err:= cdread.ReadUDF ( self.dir.cd, self.dir.sess,
+      self.current_track ); err == nil {
+      self.is_iso= true
+      self.is_udf= true
     }
   }
 
@@ -344,6 +467,13 @@ func (self *_CD_TracksDirIter) checkIsIso() error {
 } // end checkIsIso
 
 
+// Indica si l'entrada actual és el directori 'raw'.
+func (self *_CD_TracksDirIter) isRawEntry() bool {
+  return self.current_track ==
+    len(self.dir.cd_info.Sessions[self.dir.sess].Tracks)
+} // end isRawEntry
+
+
 func (self *_CD_TracksDirIter) getTrackType() int {
     return self.dir.cd_info.Sessions[self.dir.sess].
       Tracks[self.current_track].Type
@@ -357,6 +487,15 @@ func newCDTracksDirIter( dir *_CD_TracksDir ) (*_CD_TracksDirIter,error) {
     dir : dir,
     current_track : 0,
     is_iso : false,
+    current_entry : _CD_TRACK_ENTRY_MAIN,
+  }
+
+  // Comprova si té subcanal
+  if sub_cd,ok:= dir.cd.(cdread.SubchannelCD); ok {
+    if tr,err:= sub_cd.SubchannelReader ( dir.sess, 0 ); err == nil {
+      tr.Close ()
+      ret.sub_cd= sub_cd
+    }
   }
 
   // Comprova si és ISO
@@ -378,13 +517,26 @@ func (self *_CD_TracksDirIter) End() bool {
 
   ntracks:= len(self.dir.cd_info.Sessions[self.dir.sess].Tracks)
   
-  return self.current_track >= ntracks
+  return self.current_track > ntracks
   
 } // end End
 
 
 func (self *_CD_TracksDirIter) GetDirectory() (Directory,error) {
 
+  if self.isRawEntry () {
+    ret:= _CD_RawDir{
+      cd : self.dir.cd,
+      cd_info : self.dir.cd_info,
+      sess : self.dir.sess,
+    }
+    return &ret,nil
+  }
+  if self.is_udf {
+    udf,err:= newUDF ( self.dir.cd, self.dir.sess, self.current_track )
+    if err != nil { return nil,err }
+    return udf.GetRootDirectory ( )
+  }
   iso,err:= newISO_9660 ( self.dir.cd, self.dir.sess, self.current_track )
   if err != nil { return nil,err }
 
@@ -394,6 +546,18 @@ func (self *_CD_TracksDirIter) GetDirectory() (Directory,error) {
 
 
 func (self *_CD_TracksDirIter) GetFileReader() (utils.FileReader,error) {
+
+  if self.isRawEntry () {
+    return nil,errors.New ( "'raw' is a directory" )
+  }
+  switch self.current_entry {
+  case _CD_TRACK_ENTRY_SUB:
+    return self.sub_cd.SubchannelReader ( self.dir.sess, self.current_track )
+  case _CD_TRACK_ENTRY_SUBQ:
+    f,err:= self.sub_cd.SubchannelReader ( self.dir.sess, self.current_track )
+    if err != nil { return nil,err }
+    return newCDSubQReader ( f ),nil
+  }
   
   ttype:= self.getTrackType ()
   if ttype == cdread.TRACK_TYPE_AUDIO {
@@ -409,9 +573,14 @@ func (self *_CD_TracksDirIter) GetFileReader() (utils.FileReader,error) {
 
 func (self *_CD_TracksDirIter) GetName() string {
 
+  if self.isRawEntry () { return "raw" }
   ttype:= self.getTrackType ()
   var ret string
-  if ttype == cdread.TRACK_TYPE_AUDIO {
+  if self.current_entry == _CD_TRACK_ENTRY_SUB {
+    ret= fmt.Sprintf ( "%d.sub", self.current_track )
+  } else if self.current_entry == _CD_TRACK_ENTRY_SUBQ {
+    ret= fmt.Sprintf ( "%d.subq.txt", self.current_track )
+  } else if ttype == cdread.TRACK_TYPE_AUDIO {
     ret= fmt.Sprintf ( "%d.wav", self.current_track )
   } else if self.is_iso {
     ret= strconv.FormatInt ( int64(self.current_track), 10 )
@@ -433,6 +602,12 @@ func (self *_CD_TracksDirIter) List( file io.Writer ) error {
     fmt.Fprintf ( file, format, args... )
   }
 
+  // Directori RAW
+  if self.isRawEntry () {
+    P("d  --:--:--  [RAW  ]  ",self.GetName (),"\n")
+    return nil
+  }
+  
   // És o no directori
   it_type:= self.Type ()
   if it_type==DIRECTORY_ITER_TYPE_DIR { P("d") } else { P("-") }
@@ -457,10 +632,16 @@ func (self *_CD_TracksDirIter) List( file io.Writer ) error {
 
   // Tipus
   ttype:= self.getTrackType ()
-  if ttype == cdread.TRACK_TYPE_AUDIO {
+  if self.current_entry == _CD_TRACK_ENTRY_SUB {
+    P("[SUB  ]")
+  } else if self.current_entry == _CD_TRACK_ENTRY_SUBQ {
+    P("[SUBQ ]")
+  } else if ttype == cdread.TRACK_TYPE_AUDIO {
     P("[AUDIO]")
   } else if ttype == cdread.TRACK_TYPE_UNK {
     P("[?????]")
+  } else if self.is_udf {
+    P("[UDF  ]")
   } else if self.is_iso {
     P("[ISO  ]")
   } else {
@@ -480,7 +661,15 @@ func (self *_CD_TracksDirIter) List( file io.Writer ) error {
 
 
 func (self *_CD_TracksDirIter) Next() error {
+
+  // Entrades del subcanal
+  if self.sub_cd != nil && !self.isRawEntry () &&
+    self.current_entry < _CD_TRACK_ENTRY_SUBQ {
+    self.current_entry++
+    return nil
+  }
   
+  self.current_entry= _CD_TRACK_ENTRY_MAIN
   self.current_track++
   if err:= self.checkIsIso (); err != nil {
     return err
@@ -498,7 +687,9 @@ func (self *_CD_TracksDirIter) Remove() error {
 
 func (self *_CD_TracksDirIter) Type() int {
   
-  if self.is_iso {
+  if self.isRawEntry () {
+    return DIRECTORY_ITER_TYPE_DIR
+  } else if self.is_iso && self.current_entry == _CD_TRACK_ENTRY_MAIN {
     return DIRECTORY_ITER_TYPE_DIR
   } else {
     return DIRECTORY_ITER_TYPE_FILE
@@ -509,13 +700,544 @@ func (self *_CD_TracksDirIter) Type() int {
 
 
 
+/***********/
+/* RAW DIR */
+/***********/
+
+// Vista RAW dels tracks d'una sessió. Per cada track hi ha N.bin
+// amb els sectors complets de 2352 bytes i N.csv amb un mapa dels
+// sectors (mode, form, subheader i MSF).
+type _CD_RawDir struct {
+  
+  cd      cdread.CD
+  cd_info *cdread.Info
+  sess    int
+  
+}
+
+
+func (self *_CD_RawDir) Begin() (DirectoryIter,error) {
+
+  ret:= _CD_RawDirIter{
+    dir : self,
+    current_track : 0,
+    current_entry : 0,
+  }
+  ret.skipUnknownTracks ()
+  
+  return &ret,nil
+  
+} // end Begin
+
+
+func (self *_CD_RawDir) MakeDir( name string ) (Directory,error) {
+  return nil,errors.New ( "Make directory not implemented for CD images" )
+} // end MakeDir
+
+
+func (self *_CD_RawDir) GetFileWriter(name string) (utils.FileWriter,error) {
+  return nil,errors.New ( "Make directory not implemented for CD images" )
+} // end GetFileWriter
+
+
+type _CD_RawDirIter struct {
+
+  dir           *_CD_RawDir
+  current_track int
+  current_entry int // 0 - N.bin, 1 - N.csv
+  
+}
+
+
+// Entrades per track.
+const (
+  _CD_RAW_ENTRY_BIN = 0
+  _CD_RAW_ENTRY_MAP = 1
+)
+
+
+func (self *_CD_RawDirIter) getTrack() *cdread.TrackInfo {
+  return &self.dir.cd_info.Sessions[self.dir.sess].Tracks[self.current_track]
+} // end getTrack
+
+
+// Bota els tracks de tipus desconegut.
+func (self *_CD_RawDirIter) skipUnknownTracks() {
+  for !self.End () && self.getTrack ().Type == cdread.TRACK_TYPE_UNK {
+    self.current_track++
+  }
+} // end skipUnknownTracks
+
+
+func (self *_CD_RawDirIter) CompareToName(name string) bool {
+  return strings.ToLower ( name ) == self.GetName ()
+} // end CompareToName
+
+
+func (self *_CD_RawDirIter) End() bool {
+  return self.current_track >=
+    len(self.dir.cd_info.Sessions[self.dir.sess].Tracks)
+} // end End
+
+
+func (self *_CD_RawDirIter) GetDirectory() (Directory,error) {
+  return nil,errors.New ( "_CD_RawDirIter.GetDirectory: WTF!!" )
+} // end GetDirectory
+
+
+func (self *_CD_RawDirIter) GetFileReader() (utils.FileReader,error) {
+
+  // Obté primer sector
+  track:= self.getTrack ()
+  first,err:= getCDTrackFirstSector ( track )
+  if err != nil { return nil,err }
+
+  // Crea lector
+  f,err:= self.dir.cd.TrackReader ( self.dir.sess, self.current_track,
+    cdread.MODE_RAW )
+  if err != nil { return nil,err }
+  if self.current_entry == _CD_RAW_ENTRY_MAP {
+    return newCDSectorMapReader ( f, track.Type, track.Cooked, first ),nil
+  } else {
+    return newCDRawReader ( f, track.Type, track.Cooked, first ),nil
+  }
+  
+} // end GetFileReader
+
+
+func (self *_CD_RawDirIter) GetName() string {
+  if self.current_entry == _CD_RAW_ENTRY_MAP {
+    return fmt.Sprintf ( "%d.csv", self.current_track )
+  } else {
+    return fmt.Sprintf ( "%d.bin", self.current_track )
+  }
+} // end GetName
+
+
+func (self *_CD_RawDirIter) List( file io.Writer ) error {
+
+  P:= func(args... any) {
+    fmt.Fprint ( file, args... )
+  }
+  F:= func(format string,args... any) {
+    fmt.Fprintf ( file, format, args... )
+  }
+  
+  P("-  ")
+
+  // Posició inicial
+  var i int
+  track:= self.getTrack ()
+  for i= 0; i < len(track.Indexes) && track.Indexes[i].Id != 1; i++ {
+  }
+  if i==len(track.Indexes) {
+    P("??:??:??")
+  } else {
+    F("%02x:%02x:%02x",
+      track.Indexes[i].Pos.Minutes,
+      track.Indexes[i].Pos.Seconds,
+      track.Indexes[i].Pos.Sector )
+  }
+
+  P("  ")
+
+  // Tipus
+  if self.current_entry == _CD_RAW_ENTRY_MAP {
+    P("[MAP  ]")
+  } else {
+    P("[RAW  ]")
+  }
+
+  P("  ")
+
+  // Nom
+  P(self.GetName ())
+
+  P("\n")
+
+  return nil
+  
+} // end List
+
+
+func (self *_CD_RawDirIter) Next() error {
+
+  if self.current_entry == _CD_RAW_ENTRY_BIN {
+    self.current_entry= _CD_RAW_ENTRY_MAP
+  } else {
+    self.current_entry= _CD_RAW_ENTRY_BIN
+    self.current_track++
+    self.skipUnknownTracks ()
+  }
+
+  return nil
+  
+} // end Next
+
+
+func (self *_CD_RawDirIter) Remove() error {
+  return errors.New ( "Remove file not implemented for CD images" )
+} // end Remove
+
+
+func (self *_CD_RawDirIter) Type() int {
+  return DIRECTORY_ITER_TYPE_FILE
+} // end Type
+
+
+// Torna l'índex absolut del primer sector del track (índex 01).
+func getCDTrackFirstSector( track *cdread.TrackInfo ) (int64,error) {
+
+  for _,ind:= range track.Indexes {
+    if ind.Id == 0x01 {
+      return cdread.GetSectorIndex ( ind.Pos ),nil
+    }
+  }
+
+  return -1,fmt.Errorf ( "track %02x without index 01", track.Id )
+  
+} // end getCDTrackFirstSector
+
+
+// Llig el següent sector RAW de 2352 bytes. Els tracks ISO (sols
+// 2048 bytes per sector) es tornen com sectors Mode 1 reconstruint
+// la capçalera, l'EDC i l'ECC. En els tracks cuinats (2336 bytes
+// per sector, o 2048 en Mode 2 Form 1) el lector ja fica la
+// capçalera i es reconstrueixen l'EDC i l'ECC. Torna false si no
+// queden sectors.
+func readCDRawSector(
+
+  f          cdread.TrackReader,
+  track_type int,
+  cooked     bool,
+  sec_ind    int64,
+  sector     []byte,
+
+) (bool,error) {
+
+  var err error
+  if track_type == cdread.TRACK_TYPE_ISO {
+    for i:= range sector {
+      sector[i]= 0
+    }
+    _,err= io.ReadFull ( f, sector[0x10:0x810] )
+  } else {
+    _,err= io.ReadFull ( f, sector )
+  }
+  if err == io.EOF {
+    return false,nil
+  } else if err != nil {
+    return false,err
+  }
+  if track_type == cdread.TRACK_TYPE_ISO {
+    cdread.FillRawSector ( sector, sec_ind, 1, cdread.TRACK_TYPE_MODE1_RAW )
+  } else if cooked {
+    cdread.RegenerateRawSector ( sector, track_type )
+  }
+
+  return true,nil
+  
+} // end readCDRawSector
+
+
+
+
+/**************/
+/* RAW READER */
+/**************/
+
+// Torna els sectors complets de 2352 bytes d'un track.
+type _CD_RawReader struct {
+
+  f          cdread.TrackReader
+  track_type int
+  cooked     bool
+  sec_ind    int64
+  sector     [cdread.SECTOR_SIZE]byte
+  buf        []byte
+  
+}
+
+
+func newCDRawReader(
+
+  f          cdread.TrackReader,
+  track_type int,
+  cooked     bool,
+  first      int64,
+  
+) *_CD_RawReader {
+  return &_CD_RawReader{
+    f : f,
+    track_type : track_type,
+    cooked : cooked,
+    sec_ind : first,
+  }
+} // end newCDRawReader
+
+
+func (self *_CD_RawReader) Read( buf []byte ) (int,error) {
+
+  ret:= 0
+  for ret < len(buf) {
+
+    // Carrega sector
+    if len(self.buf) == 0 {
+      ok,err:= readCDRawSector ( self.f, self.track_type, self.cooked,
+        self.sec_ind, self.sector[:] )
+      if err != nil { return ret,err }
+      if !ok {
+        if ret == 0 { return 0,io.EOF }
+        break
+      }
+      self.buf= self.sector[:]
+      self.sec_ind++
+    }
+
+    // Copia
+    n:= copy ( buf[ret:], self.buf )
+    self.buf= self.buf[n:]
+    ret+= n
+    
+  }
+  
+  return ret,nil
+  
+} // end Read
+
+
+func (self *_CD_RawReader) Close() error {
+  return self.f.Close ()
+} // end Close
+
+
+
+
+/*********************/
+/* SECTOR MAP READER */
+/*********************/
+
+// Mapa dels sectors d'un track en format CSV. Els camps del
+// subheader sols s'ompli en tracks Mode 2 CD-XA.
+type _CD_SectorMapReader struct {
+
+  f          cdread.TrackReader
+  track_type int
+  cooked     bool
+  sec_ind    int64
+  sector     [cdread.SECTOR_SIZE]byte
+  line       []byte
+  pos        int
+  
+}
+
+
+func newCDSectorMapReader(
+
+  f          cdread.TrackReader,
+  track_type int,
+  cooked     bool,
+  first      int64,
+  
+) *_CD_SectorMapReader {
+  return &_CD_SectorMapReader{
+    f : f,
+    track_type : track_type,
+    cooked : cooked,
+    sec_ind : first,
+    line : []byte("lba,msf,header_msf,sync,mode,form,file,channel,"+
+      "submode,coding\n"),
+    pos : 0,
+  }
+} // end newCDSectorMapReader
+
+
+func (self *_CD_SectorMapReader) decodeLine() {
+
+  var b strings.Builder
+  s:= self.sector[:]
+  pos:= cdread.GetPosition ( self.sec_ind )
+  fmt.Fprintf ( &b, "%d,%02x:%02x:%02x,", self.sec_ind-2*75,
+    pos.Minutes, pos.Seconds, pos.Sector )
+  if self.track_type == cdread.TRACK_TYPE_AUDIO {
+    b.WriteString ( ",,audio,,,,,\n" )
+  } else {
+    fmt.Fprintf ( &b, "%02x:%02x:%02x,", s[0xc], s[0xd], s[0xe] )
+    if bytes.Equal ( s[:12], cdread.SECTOR_SYNC ) {
+      b.WriteString ( "ok," )
+    } else {
+      b.WriteString ( "bad," )
+    }
+    mode:= s[0xf]
+    fmt.Fprintf ( &b, "%d,", mode )
+    if mode == 2 && self.track_type == cdread.TRACK_TYPE_MODE2_CDXA_RAW {
+      form:= 1
+      if s[0x12]&0x20 != 0 {
+        form= 2
+      }
+      fmt.Fprintf ( &b, "%d,%d,%d,%02x,%02x\n",
+        form, s[0x10], s[0x11], s[0x12], s[0x13] )
+    } else {
+      b.WriteString ( ",,,,\n" )
+    }
+  }
+  self.line= []byte(b.String ())
+  self.pos= 0
+  self.sec_ind++
+  
+} // end decodeLine
+
+
+func (self *_CD_SectorMapReader) Read( buf []byte ) (int,error) {
+
+  ret:= 0
+  for ret < len(buf) {
+
+    // Carrega línia
+    if self.pos >= len(self.line) {
+      ok,err:= readCDRawSector ( self.f, self.track_type, self.cooked,
+        self.sec_ind, self.sector[:] )
+      if err != nil { return ret,err }
+      if !ok {
+        if ret == 0 { return 0,io.EOF }
+        break
+      }
+      self.decodeLine ()
+    }
+
+    // Copia
+    n:= copy ( buf[ret:], self.line[self.pos:] )
+    self.pos+= n
+    ret+= n
+    
+  }
+  
+  return ret,nil
+  
+} // end Read
+
+
+func (self *_CD_SectorMapReader) Close() error {
+  return self.f.Close ()
+} // end Close
+
+
+
+
+/***************/
+/* SUBQ READER */
+/***************/
+
+// Descodifica el subcanal Q de cada sector com a text.
+type _CD_SubQReader struct {
+
+  f      cdread.TrackReader
+  sector int64
+  line   []byte
+  pos    int
+  
+}
+
+
+func newCDSubQReader( f cdread.TrackReader ) *_CD_SubQReader {
+  return &_CD_SubQReader{
+    f : f,
+    sector : 0,
+  }
+} // end newCDSubQReader
+
+
+func (self *_CD_SubQReader) decodeLine( q []byte ) {
+
+  var b strings.Builder
+  fmt.Fprintf ( &b, "%06d  CTL=%X ADR=%d  ", self.sector, q[0]>>4, q[0]&0xf )
+  switch q[0]&0xf {
+  case 1:
+    fmt.Fprintf ( &b, "TNO=%02x IDX=%02x REL=%02x:%02x:%02x"+
+      " ABS=%02x:%02x:%02x",
+      q[1], q[2], q[3], q[4], q[5], q[7], q[8], q[9] )
+  case 2:
+    b.WriteString ( "MCN=" )
+    for i:= 0; i < 13; i++ {
+      c:= q[1+i/2]
+      if i%2 == 0 { c>>= 4 }
+      b.WriteByte ( '0'+(c&0xf) )
+    }
+    fmt.Fprintf ( &b, " AFRAME=%02x", q[9] )
+  case 3:
+    // 5 caràcters de 6 bits i 7 dígits BCD.
+    b.WriteString ( "ISRC=" )
+    bits:= uint32(q[1])<<24 | uint32(q[2])<<16 | uint32(q[3])<<8 |
+      uint32(q[4])
+    for i:= 0; i < 5; i++ {
+      c:= uint8((bits>>(26-6*i))&0x3f)
+      b.WriteByte ( '0'+c )
+    }
+    for i:= 0; i < 7; i++ {
+      c:= q[5+i/2]
+      if i%2 == 0 { c>>= 4 }
+      b.WriteByte ( '0'+(c&0xf) )
+    }
+    fmt.Fprintf ( &b, " AFRAME=%02x", q[9] )
+  default:
+    fmt.Fprintf ( &b, "DATA=% X", q[1:10] )
+  }
+  if cdread.CheckSubQCRC ( q ) {
+    b.WriteString ( "  CRC OK\n" )
+  } else {
+    b.WriteString ( "  CRC BAD\n" )
+  }
+  self.line= []byte(b.String ())
+  self.pos= 0
+  self.sector++
+  
+} // end decodeLine
+
+
+func (self *_CD_SubQReader) Read( buf []byte ) (int,error) {
+
+  var sec [96]byte
+  ret:= 0
+  for ret < len(buf) {
+
+    // Carrega línia
+    if self.pos >= len(self.line) {
+      if _,err:= io.ReadFull ( self.f, sec[:] ); err != nil {
+        if err == io.EOF || err == io.ErrUnexpectedEOF {
+          if ret == 0 { return 0,io.EOF }
+          break
+        }
+        return ret,err
+      }
+      self.decodeLine ( sec[12:24] )
+    }
+
+    // Copia
+    n:= copy ( buf[ret:], self.line[self.pos:] )
+    self.pos+= n
+    ret+= n
+    
+  }
+  
+  return ret,nil
+  
+} // end Read
+
+
+func (self *_CD_SubQReader) Close() error {
+  return self.f.Close ()
+} // end Close
+
+
+
+
 /**************/
 /* WAV READER */
 /**************/
 
 type _CD_WavReader struct {
 
-  f          cdread.TrackReader
+  f          io.ReadCloser
   header     [44]byte
   header_pos int
   
@@ -524,12 +1246,6 @@ type _CD_WavReader struct {
 
 func newCDWavReader( f cdread.TrackReader ) (*_CD_WavReader,error) {
 
-  // Inicialitza
-  ret:= _CD_WavReader{
-    f : f,
-    header_pos : 0,
-  }
-
   // Calcula grandària i rebobina.
   var size int64= 0
   var nread int
@@ -543,6 +1259,28 @@ func newCDWavReader( f cdread.TrackReader ) (*_CD_WavReader,error) {
   if err != io.EOF { return nil,err }
   if err= f.Seek ( 0 ); err != nil { return nil,err }
 
+  return newWavReader ( f, size, 2, 44100 ),nil
+  
+} // end newCDWavReader
+
+
+// Crea un lector que afegeix la capçalera RIFF/WAVE a unes mostres
+// PCM de 16 bits.
+func newWavReader(
+
+  f        io.ReadCloser,
+  size     int64,
+  channels int,
+  srate    int,
+
+) *_CD_WavReader {
+
+  // Inicialitza
+  ret:= _CD_WavReader{
+    f : f,
+    header_pos : 0,
+  }
+
   // Inicialitza capçalera
   h:= ret.header[:]
   // --> RIFF
@@ -562,21 +1300,20 @@ func newCDWavReader( f cdread.TrackReader ) (*_CD_WavReader,error) {
   // --> Type of format (1 is PCM) - 2 byte integer
   h[20]= 1; h[21]= 0
   // --> Number of Channels - 2 byte integer
-  h[22]= 2; h[23]= 0
+  h[22]= byte(uint8(channels)); h[23]= 0
   // --> Sample Rate
-  srate:= 44100
   h[24]= byte(uint8(srate&0xff))
   h[25]= byte(uint8((srate>>8)&0xff))
   h[26]= byte(uint8((srate>>16)&0xff))
   h[27]= byte(uint8((srate>>24)&0xff))
   // --> (Sample Rate * BitsPerSample * Channels) / 8
-  tmp1:= 176400
+  tmp1:= srate*16*channels/8
   h[28]= byte(uint8(tmp1&0xff))
   h[29]= byte(uint8((tmp1>>8)&0xff))
   h[30]= byte(uint8((tmp1>>16)&0xff))
   h[31]= byte(uint8((tmp1>>24)&0xff))
   // --> (BitsPerSample * Channels)
-  h[32]= 4; h[33]= 0
+  h[32]= byte(uint8(2*channels)); h[33]= 0
   // --> Bits per sample
   h[34]= 16; h[35]= 0
   // --> data
@@ -587,9 +1324,9 @@ func newCDWavReader( f cdread.TrackReader ) (*_CD_WavReader,error) {
   h[42]= byte(uint8((size>>16)&0xff))
   h[43]= byte(uint8((size>>24)&0xff))
 
-  return &ret,nil
+  return &ret
   
-} // end _CD_WavReader
+} // end newWavReader
 
 
 func (self *_CD_WavReader) Read( buf []byte ) (int,error) {
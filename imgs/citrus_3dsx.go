@@ -0,0 +1,268 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  citrus_3dsx.go - Format 3DSX (executables homebrew de 3DS).
+ *
+ */
+
+package imgs
+
+import (
+  "errors"
+  "fmt"
+  "io"
+  "strings"
+
+  "github.com/adriagipas/imgcp/citrus"
+  "github.com/adriagipas/imgcp/utils"
+)
+
+
+/********/
+/* 3DSX */
+/********/
+
+const (
+  _3DSX_SMDH  = 0
+  _3DSX_ROMFS = 1
+)
+
+// Com que és sols lectura llisc al principi el contingut.
+type _3DSX struct {
+  state *citrus.ThreeDSX
+}
+
+
+func new3DSX( file_name string ) (*_3DSX,error) {
+
+  state,err:= citrus.NewThreeDSX ( file_name )
+  if err != nil { return nil,err }
+  ret:= _3DSX{
+    state: state,
+  }
+
+  return &ret,nil
+
+} // end new3DSX
+
+
+func (self *_3DSX) PrintInfo( file io.Writer, prefix string ) error {
+
+  // Preparació
+  P := func(args... any) {
+    fmt.Fprint ( file, prefix )
+    fmt.Fprintln ( file, args... )
+  }
+  F := func(format string, args... any) {
+    fmt.Fprint ( file, prefix )
+    fmt.Fprintf ( file, format, args... )
+    fmt.Fprint ( file, "\n" )
+  }
+  segment:= func(name string,seg *citrus.ThreeDSX_Segment) {
+    F("  %-8s %10s  (%d absolute, %d relative relocations)",name,
+      utils.NumBytesToStr ( uint64(seg.Size) ),seg.NAbsRelocs,seg.NRelRelocs)
+  }
+
+  // Imprimeix
+  state:= self.state
+  P("3DS Homebrew Executable format (3DSX)")
+  P("")
+  F("Format Version: %d",state.FormatVersion)
+  P("Segments:")
+  segment ( ".text", &state.Code )
+  segment ( ".rodata", &state.ReadOnly )
+  segment ( ".data", &state.Data )
+  F("BSS Size:       %s",utils.NumBytesToStr ( uint64(state.BSSSize) ))
+  if state.SMDHSize != 0 {
+    F("SMDH:           %s",utils.NumBytesToStr ( uint64(state.SMDHSize) ))
+  }
+  if state.RomFSOffset != 0 {
+    F("RomFS:          %s",utils.NumBytesToStr ( uint64(state.RomFSSize ()) ))
+  }
+  P("")
+
+  // SMDH
+  if state.SMDHSize != 0 {
+    if smdh,err:= state.GetSMDH (); err != nil {
+      F("SMDH:           %s",err)
+      P("")
+    } else {
+      fPrintInfoSMDH ( file, prefix, smdh )
+    }
+  }
+
+  return nil
+
+} // end _3DSX.PrintInfo
+
+
+// Com que no té subdirectoris i ja està carregat torna el propi
+// objecte.
+func (self *_3DSX) GetRootDirectory() (Directory,error) {
+  return self,nil
+} // end _3DSX.GetRootDirectory
+
+
+func (self *_3DSX) MakeDir(name string) (Directory,error) {
+  return nil,errors.New ( "Make directory not implemented for 3DSX files" )
+} // end Mkdir
+
+
+func (self *_3DSX) GetFileWriter(name string) (utils.FileWriter,error) {
+  return nil,errors.New ( "Writing a file not implemented for 3DSX files" )
+} // end GetFileWriter
+
+
+func (self *_3DSX) Begin() (DirectoryIter,error) {
+
+  ret:= _3DSX_DirIter{
+    state: self.state,
+    pos: _3DSX_SMDH,
+  }
+  if self.state.SMDHSize == 0 {
+    ret.Next ()
+  }
+
+  return &ret,nil
+
+} // end Begin
+
+
+/**********************/
+/* 3DSX DIRECTORY ITER */
+/**********************/
+
+type _3DSX_DirIter struct {
+
+  state *citrus.ThreeDSX
+  pos   int
+
+}
+
+
+func (self *_3DSX_DirIter) CompareToName(name string) bool {
+  return strings.ToLower ( name ) == strings.ToLower ( self.GetName () )
+} // end CompareToName
+
+
+func (self *_3DSX_DirIter) End() bool {
+  return self.pos>=2
+} // end End
+
+
+func (self *_3DSX_DirIter) GetDirectory() (Directory,error) {
+
+  if self.pos != _3DSX_ROMFS {
+    return nil,errors.New ( "_3DSX_DirIter.GetDirectory: WTF!!!" )
+  }
+  state,err:= self.state.GetRomFS ()
+  if err != nil { return nil,err }
+
+  return &_RomFS{
+    state: state, // No deuria ser nil
+  },nil
+
+} // end GetDirectory
+
+
+func (self *_3DSX_DirIter) GetFileReader() (utils.FileReader,error) {
+  if self.pos != _3DSX_SMDH {
+    return nil,errors.New ( "_3DSX_DirIter.GetFileReader: WTF!!!" )
+  }
+  return self.state.OpenSMDH ()
+} // end GetFileReader
+
+
+func (self *_3DSX_DirIter) GetName() string {
+  switch self.pos {
+  case _3DSX_SMDH:
+    return "icon.smdh"
+  case _3DSX_ROMFS:
+    return "romFS"
+  default:
+    return "???"
+  }
+} // end GetName
+
+
+func (self *_3DSX_DirIter) List( file io.Writer ) error {
+
+  P:= func(args... any) {
+    fmt.Fprint ( file, args... )
+  }
+  F:= func(format string,args... any) {
+    fmt.Fprintf ( file, format, args... )
+  }
+
+  // És o no directori
+  if self.Type ()==DIRECTORY_ITER_TYPE_DIR { P("d") } else { P("-") }
+
+  P("  ")
+
+  // Grandària
+  var nbytes int64
+  switch self.pos {
+  case _3DSX_SMDH:
+    nbytes= self.state.SMDHSize
+  case _3DSX_ROMFS:
+    nbytes= self.state.RomFSSize ()
+  }
+  size := utils.NumBytesToStr ( uint64(nbytes) )
+  for i := 0; i < 10-len(size); i++ {
+    P(" ")
+  }
+  P(size,"  ")
+
+  // Nom
+  F("%s",self.GetName ())
+
+  P("\n")
+
+  return nil
+
+} // end List
+
+
+func (self *_3DSX_DirIter) Next() error {
+
+  if !self.End () {
+    for self.pos++; self.pos < 2; self.pos++ {
+      if self.pos == _3DSX_ROMFS && self.state.RomFSOffset != 0 {
+        return nil
+      }
+    }
+  }
+
+  return nil
+
+} // end Next
+
+
+func (self *_3DSX_DirIter) Remove() error {
+  return errors.New ( "Remove file not implemented for 3DSX files" )
+} // end Remove
+
+
+func (self *_3DSX_DirIter) Type() int {
+  if self.pos == _3DSX_ROMFS {
+    return DIRECTORY_ITER_TYPE_DIR
+  } else {
+    return DIRECTORY_ITER_TYPE_FILE
+  }
+} // end Type
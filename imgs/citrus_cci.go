@@ -71,6 +71,13 @@ func (self *_CCI) PrintInfo( file io.Writer, prefix string ) error {
   F(file,"%s Card Revision: %04x\n",prefix,self.state.Header.CardRevision)
   F(file,"%s Title Id.:     %016x\n",prefix,self.state.Header.TitleID)
   F(file,"%s Version CVer:  %04x\n",prefix,self.state.Header.VersionCVer)
+  F(file,"%s Media Size:    %s\n",prefix,
+    utils.NumBytesToStr ( uint64(self.state.Header.Size) ))
+  if self.state.Header.Trimmed {
+    P(file,prefix, "Trimmed:       yes")
+  } else {
+    P(file,prefix, "Trimmed:       no")
+  }
   P(file,prefix, "Partitions:")
   for i:= 0; i < 8; i++ {
     p:= &self.state.Header.Partitions[i]
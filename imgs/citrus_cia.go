@@ -0,0 +1,276 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  citrus_cia.go - Format citrus CIA.
+ *
+ */
+
+package imgs
+
+import (
+  "errors"
+  "fmt"
+  "io"
+  "strconv"
+  "strings"
+
+  "github.com/adriagipas/imgcp/citrus"
+  "github.com/adriagipas/imgcp/utils"
+)
+
+
+/*******/
+/* CIA */
+/*******/
+
+// Com que és sols lectura llisc al principi el contingut.
+type _CIA struct {
+  state *citrus.CIA
+}
+
+
+func newCIA( file_name string ) (*_CIA,error) {
+
+  ret:= _CIA{}
+  var err error
+  if ret.state,err= citrus.NewCIA ( file_name ); err != nil {
+    return nil,err
+  }
+
+  return &ret,nil
+
+} // end newCIA
+
+
+func ciaContentType2str( ctype uint16 ) string {
+
+  var ret []string
+  if ctype&citrus.CIA_CONTENT_TYPE_ENCRYPTED != 0 {
+    ret= append(ret,"Encrypted")
+  }
+  if ctype&citrus.CIA_CONTENT_TYPE_DISC != 0 {
+    ret= append(ret,"Disc")
+  }
+  if ctype&citrus.CIA_CONTENT_TYPE_CFM != 0 {
+    ret= append(ret,"CFM")
+  }
+  if ctype&citrus.CIA_CONTENT_TYPE_OPTIONAL != 0 {
+    ret= append(ret,"Optional")
+  }
+  if ctype&citrus.CIA_CONTENT_TYPE_SHARED != 0 {
+    ret= append(ret,"Shared")
+  }
+  if len(ret) == 0 {
+    return "-"
+  }
+
+  return strings.Join ( ret, ", " )
+
+} // end ciaContentType2str
+
+
+func (self *_CIA) PrintInfo( file io.Writer, prefix string ) error {
+
+  // Preparació impressió
+  P := fmt.Fprintln
+  F := fmt.Fprintf
+
+  // Imprimeix
+  tmd:= &self.state.TMD
+  P(file,prefix, "CTR Importable Archive (CIA)")
+  P(file,"")
+  F(file,"%s Title Id.:       %016x\n",prefix,tmd.TitleId)
+  F(file,"%s Title Version:   %04x (%s)\n",prefix,tmd.TitleVersion,
+    citrus.CIA_version2str ( tmd.TitleVersion ))
+  F(file,"%s Title Type:      %08x\n",prefix,tmd.TitleType)
+  F(file,"%s Save Data Size:  %s\n",prefix,
+    utils.NumBytesToStr ( uint64(tmd.SaveDataSize) ))
+  F(file,"%s TMD Issuer:      %s\n",prefix,tmd.Issuer)
+  P(file,prefix, "Contents:")
+  for i:= range tmd.Contents {
+    c:= &tmd.Contents[i]
+    P(file,"")
+    F(file,"%s  %d)\n",prefix,c.Index)
+    P(file,"")
+    F(file,"%s    ID:         %08x\n", prefix, c.Id )
+    F(file,"%s    TYPE:       %s\n", prefix, ciaContentType2str ( c.Type ) )
+    F(file,"%s    OFFSET:     %016x\n", prefix, c.Offset )
+    F(file,"%s    SIZE:       %s\n", prefix,
+      utils.NumBytesToStr ( uint64(c.Size) ) )
+    F(file,"%s    SHA-256:    %x\n", prefix, c.Hash )
+    P(file,"")
+    if err:= self.fPrintInfoNCCHContent ( i, file, prefix+"    " );
+    err != nil {
+      return err
+    }
+  }
+
+  return nil
+
+} // end _CIA.PrintInfo
+
+
+func (self *_CIA) fPrintInfoNCCHContent(
+
+  ind    int,
+  file   io.Writer,
+  prefix string,
+
+) error {
+
+  // Obté estat
+  state,err:= self.state.GetNCCHContent ( ind )
+  if err != nil {
+    fmt.Fprintf ( file, "%s%s\n", prefix, err )
+    return nil
+  }
+
+  // NCCH
+  ncch,err:= newNCCH ( state )
+  if err != nil { return err }
+
+  // Imprimeix
+  return ncch.PrintInfo ( file, prefix )
+
+} // end fPrintInfoNCCHContent
+
+
+// Com que no té subdirectoris i ja està carregat torna el propi
+// objecte.
+func (self *_CIA) GetRootDirectory() (Directory,error) {
+  return self,nil
+} // end _CIA.GetRootDirectory
+
+
+func (self *_CIA) MakeDir(name string) (Directory,error) {
+  return nil,errors.New ( "Creation of contents is not supported" )
+} // end Mkdir
+
+
+func (self *_CIA) GetFileWriter(name string) (utils.FileWriter,error) {
+  return nil,errors.New ( "Writing a file not implemented for CIA files" )
+}
+
+
+func (self *_CIA) Begin() (DirectoryIter,error) {
+
+  ret:= _CIA_DirIter{
+    state: self.state,
+    current: 0,
+  }
+
+  return &ret,nil
+
+} // end Begin
+
+
+/**********************/
+/* CIA DIRECTORY ITER */
+/**********************/
+
+type _CIA_DirIter struct {
+
+  state   *citrus.CIA
+  current int // Posició en TMD.Contents
+
+}
+
+
+func (self *_CIA_DirIter) CompareToName(name string) bool {
+  num,err:= strconv.Atoi ( name )
+  return err == nil &&
+    num == int(self.state.TMD.Contents[self.current].Index)
+} // end CompareToName
+
+
+func (self *_CIA_DirIter) End() bool {
+  return self.current>=len(self.state.TMD.Contents)
+} // end End
+
+
+func (self *_CIA_DirIter) GetDirectory() (Directory,error) {
+
+  // Obté estat del NCCH
+  state,err:= self.state.GetNCCHContent ( self.current )
+  if err != nil { return nil,err }
+
+  // NCCH
+  ncch,err:= newNCCH ( state )
+  if err != nil { return nil,err }
+
+  // Crea
+  return ncch.GetRootDirectory ()
+
+} // end GetDirectory
+
+
+func (self *_CIA_DirIter) GetFileReader() (utils.FileReader,error) {
+  return nil,errors.New ( "_CIA_DirIter.GetFileReader: WTF!!" )
+} // end GetFileReader
+
+
+func (self *_CIA_DirIter) GetName() string {
+  return strconv.FormatInt (
+    int64(self.state.TMD.Contents[self.current].Index), 10 )
+} // end GetName
+
+
+func (self *_CIA_DirIter) List( file io.Writer ) error {
+
+  content:= &self.state.TMD.Contents[self.current]
+
+  fmt.Fprintf ( file, "content  " )
+
+  // Identificador
+  fmt.Fprintf ( file, "%08x  ", content.Id )
+
+  // Grandària
+  size:= utils.NumBytesToStr ( uint64(content.Size) )
+  for i := 0; i < 10-len(size); i++ {
+    fmt.Fprintf ( file, " " )
+  }
+  fmt.Fprintf ( file, "%s  ", size )
+
+  // Nom
+  fmt.Fprintf ( file, "%d\n", content.Index )
+
+  return nil
+
+} // end List
+
+
+func (self *_CIA_DirIter) Next() error {
+
+  if !self.End () {
+    self.current++
+  }
+
+  return nil
+
+} // end Next
+
+
+func (self *_CIA_DirIter) Remove() error {
+  return errors.New ( "Remove file not implemented for CIA images" )
+} // end Remove
+
+
+func (self *_CIA_DirIter) Type() int {
+  return DIRECTORY_ITER_TYPE_DIR
+} // end Type
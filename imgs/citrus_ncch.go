@@ -24,8 +24,11 @@
 package imgs
 
 import (
+  "bytes"
   "errors"
   "fmt"
+  "image"
+  "image/png"
   "io"
   "strings"
   
@@ -39,10 +42,11 @@ import (
 /********/
 
 const (
-  _NCCH_PLAIN = 0
-  _NCCH_LOGO  = 1
-  _NCCH_EXEFS = 2
-  _NCCH_ROMFS = 3
+  _NCCH_EXHEADER = 0
+  _NCCH_PLAIN    = 1
+  _NCCH_LOGO     = 2
+  _NCCH_EXEFS    = 3
+  _NCCH_ROMFS    = 4
 )
 
 // Com que és sols lectura llisc al principi el contingut.
@@ -111,6 +115,11 @@ func (self *_NCCH) PrintInfo( file io.Writer, prefix string ) error {
     ftype= "Unknown"
   }
   F("Type:         %s",ftype)
+  crypto:= citrus.NCCH_crypto2str ( self.state.Header.Crypto )
+  if self.state.Header.Seed {
+    crypto+= " + seed"
+  }
+  F("Crypto:       %s",crypto)
   P("Flags:\n")
   if (self.state.Header.Flags&citrus.NCCH_FLAGS_EXECUTABLE)!=0 {
     P("  - Executable")
@@ -128,12 +137,267 @@ func (self *_NCCH) PrintInfo( file io.Writer, prefix string ) error {
     P("  - Trial")
   }
   P("")
+
+  // ExHeader
+  if self.state.Header.ExHeaderSize != 0 {
+    exheader,err:= self.state.GetExHeader ()
+    if err != nil {
+      F("ExHeader:     %s",err)
+      P("")
+    } else {
+      fPrintInfoExHeader ( file, prefix, exheader )
+    }
+  }
+
+  // SMDH
+  if self.state.Header.ExeFS.Size != 0 {
+    var smdh *citrus.SMDH
+    exefs,err:= self.state.GetExeFS ()
+    if err == nil {
+      smdh,err= exefs.GetSMDH ()
+    }
+    if err != nil {
+      F("SMDH:         %s",err)
+      P("")
+    } else if smdh != nil {
+      fPrintInfoSMDH ( file, prefix, smdh )
+    }
+  }
   
   return nil
   
 } // end _NCCH.PrintInfo
 
 
+func fPrintInfoExHeader(
+
+  file     io.Writer,
+  prefix   string,
+  exheader *citrus.ExHeader,
+
+) {
+
+  // Preparació
+  P := func(args... any) {
+    fmt.Fprint ( file, prefix )
+    fmt.Fprintln ( file, args... )
+  }
+  F := func(format string, args... any) {
+    fmt.Fprint ( file, prefix )
+    fmt.Fprintf ( file, format, args... )
+    fmt.Fprint ( file, "\n" )
+  }
+  code_set:= func(name string,cs *citrus.ExHeader_CodeSet) {
+    F("    %-8s %08x  %s (%d pages)",name,cs.Address,
+      utils.NumBytesToStr ( uint64(cs.Size) ),cs.Pages)
+  }
+
+  // System Control Info
+  arm11:= &exheader.ARM11
+  P("Extended Header:")
+  P("")
+  F("  Title:            %s",exheader.Title)
+  F("  Remaster Version: %04x",exheader.RemasterVersion)
+  if (exheader.Flags&citrus.EXHEADER_FLAGS_COMPRESSED_CODE)!=0 {
+    P("  Compressed code:  yes")
+  } else {
+    P("  Compressed code:  no")
+  }
+  if (exheader.Flags&citrus.EXHEADER_FLAGS_SD_APPLICATION)!=0 {
+    P("  SD application:   yes")
+  } else {
+    P("  SD application:   no")
+  }
+  P("  Code sets:")
+  code_set ( ".text", &exheader.Text )
+  code_set ( ".rodata", &exheader.ReadOnly )
+  code_set ( ".data", &exheader.Data )
+  F("  Stack Size:       %s",utils.NumBytesToStr ( uint64(exheader.StackSize) ))
+  F("  BSS Size:         %s",utils.NumBytesToStr ( uint64(exheader.BSSSize) ))
+  F("  Save Data Size:   %s",utils.NumBytesToStr ( exheader.SaveDataSize ))
+  F("  Jump Id.:         %016x",exheader.JumpId)
+  if len(exheader.Dependencies) > 0 {
+    P("  Dependencies:")
+    for _,id:= range exheader.Dependencies {
+      F("    - %016x",id)
+    }
+  }
+  P("")
+
+  // ARM11
+  P("  ARM11 Access Control:")
+  P("")
+  F("    Program Id.:      %016x",arm11.ProgramId)
+  F("    Core Version:     %d",arm11.CoreVersion)
+  F("    Priority:         %d",arm11.Priority)
+  F("    Ideal Processor:  %d",arm11.IdealProcessor)
+  F("    Affinity Mask:    %d",arm11.AffinityMask)
+  F("    System Mode:      %d",arm11.SystemMode)
+  F("    New3DS Mode:      %d",arm11.New3DSSystemMode)
+  F("    Resource Limit:   %d",arm11.ResourceLimitCategory)
+  if arm11.EnableL2Cache { P("    L2 cache:         enabled") }
+  if arm11.CPUSpeed804MHz { P("    CPU speed:        804MHz") }
+  F("    Ext. Data Id.:    %016x",arm11.ExtDataId)
+  F("    System Saves:     %08x %08x",arm11.SystemSaveDataIds[0],
+    arm11.SystemSaveDataIds[1])
+  F("    Unique Ids:       %016x",arm11.AccessibleUniqueIds)
+  F("    FS Access:        %014x",arm11.FSAccess)
+  if arm11.NoRomFS { P("    Does not use RomFS") }
+  if arm11.ExtSaveDataAccess { P("    Extended savedata access") }
+  if len(arm11.Services) > 0 {
+    P("    Services:")
+    for _,name:= range arm11.Services {
+      F("      - %s",name)
+    }
+  }
+  if len(exheader.ARM11Kernel) > 0 {
+    P("    Kernel Capabilities:")
+    for i:= 0; i < len(exheader.ARM11Kernel); i+= 4 {
+      line:= "     "
+      for j:= i; j < i+4 && j < len(exheader.ARM11Kernel); j++ {
+        line+= fmt.Sprintf ( " %08x", exheader.ARM11Kernel[j] )
+      }
+      P(line)
+    }
+  }
+  P("")
+
+  // ARM9
+  var arm9 []string
+  for _,perm:= range []struct{
+    mask uint32
+    name string
+  }{
+    {citrus.EXHEADER_ARM9_MOUNT_NAND,"Mount NAND"},
+    {citrus.EXHEADER_ARM9_MOUNT_NAND_RO,"Mount NAND RO write"},
+    {citrus.EXHEADER_ARM9_MOUNT_TWLN,"Mount TWLN"},
+    {citrus.EXHEADER_ARM9_MOUNT_WNAND,"Mount WNAND"},
+    {citrus.EXHEADER_ARM9_MOUNT_CARD_SPI,"Mount card SPI"},
+    {citrus.EXHEADER_ARM9_USE_SDIF3,"Use SDIF3"},
+    {citrus.EXHEADER_ARM9_CREATE_SEED,"Create seed"},
+    {citrus.EXHEADER_ARM9_USE_CARD_SPI,"Use card SPI"},
+    {citrus.EXHEADER_ARM9_SD_APPLICATION,"SD application"},
+    {citrus.EXHEADER_ARM9_MOUNT_SDMC_WRITE,"Mount SDMC write"},
+  } {
+    if (exheader.ARM9Access&perm.mask)!=0 {
+      arm9= append(arm9,perm.name)
+    }
+  }
+  if len(arm9) == 0 {
+    arm9= append(arm9,"-")
+  }
+  P("  ARM9 Access Control:")
+  P("")
+  F("    Version:          %d",exheader.ARM9Version)
+  F("    Permissions:      %s",strings.Join ( arm9, ", " ))
+  P("")
+
+} // end fPrintInfoExHeader
+
+
+func fPrintInfoSMDH( file io.Writer, prefix string, smdh *citrus.SMDH ) {
+
+  // Preparació
+  P := func(args... any) {
+    fmt.Fprint ( file, prefix )
+    fmt.Fprintln ( file, args... )
+  }
+  F := func(format string, args... any) {
+    fmt.Fprint ( file, prefix )
+    fmt.Fprintf ( file, format, args... )
+    fmt.Fprint ( file, "\n" )
+  }
+
+  // Títols
+  P("Application Titles (SMDH):")
+  for i:= range smdh.Titles {
+    title:= &smdh.Titles[i]
+    if title.Short == "" && title.Long == "" && title.Publisher == "" {
+      continue
+    }
+    P("")
+    F("  %s:",citrus.SMDH_language2str ( i ))
+    F("    Short:      %s",title.Short)
+    F("    Long:       %s",strings.ReplaceAll ( title.Long, "\n", " " ))
+    F("    Publisher:  %s",title.Publisher)
+  }
+  P("")
+
+  // Regions
+  var regions []string
+  if smdh.RegionLockout == citrus.SMDH_REGION_FREE {
+    regions= append(regions,"Region free")
+  } else {
+    for _,reg:= range []struct{
+      mask uint32
+      name string
+    }{
+      {citrus.SMDH_REGION_JAPAN,"Japan"},
+      {citrus.SMDH_REGION_NORTH_AMERICA,"North America"},
+      {citrus.SMDH_REGION_EUROPE,"Europe"},
+      {citrus.SMDH_REGION_AUSTRALIA,"Australia"},
+      {citrus.SMDH_REGION_CHINA,"China"},
+      {citrus.SMDH_REGION_KOREA,"Korea"},
+      {citrus.SMDH_REGION_TAIWAN,"Taiwan"},
+    } {
+      if (smdh.RegionLockout&reg.mask)!=0 {
+        regions= append(regions,reg.name)
+      }
+    }
+  }
+  if len(regions) == 0 {
+    regions= append(regions,"-")
+  }
+  F("  Regions:      %s",strings.Join ( regions, ", " ))
+
+  // Classificacions
+  var ratings []string
+  for i,rating:= range smdh.Ratings {
+    if (rating&citrus.SMDH_RATING_ACTIVE)==0 { continue }
+    var age string
+    if (rating&citrus.SMDH_RATING_PENDING)!=0 {
+      age= "pending"
+    } else if (rating&citrus.SMDH_RATING_NO_RESTRICTION)!=0 {
+      age= "all ages"
+    } else {
+      age= fmt.Sprintf ( "%d+", rating&citrus.SMDH_RATING_AGE_MASK )
+    }
+    ratings= append(ratings,
+      fmt.Sprintf ( "%s %s", citrus.SMDH_rating2str ( i ), age ))
+  }
+  if len(ratings) == 0 {
+    ratings= append(ratings,"-")
+  }
+  F("  Age Ratings:  %s",strings.Join ( ratings, ", " ))
+  F("  EULA Version: %d.%d",smdh.EULAVersion>>8,smdh.EULAVersion&0xff)
+
+  // Flags
+  P("  Flags:")
+  for _,flag:= range []struct{
+    mask uint32
+    name string
+  }{
+    {citrus.SMDH_FLAGS_VISIBLE,"Visible"},
+    {citrus.SMDH_FLAGS_AUTO_BOOT,"Auto-boot"},
+    {citrus.SMDH_FLAGS_ALLOW_3D,"Allow 3D"},
+    {citrus.SMDH_FLAGS_REQUIRE_EULA,"Require EULA"},
+    {citrus.SMDH_FLAGS_AUTO_SAVE,"Autosave on exit"},
+    {citrus.SMDH_FLAGS_EXT_BANNER,"Extended banner"},
+    {citrus.SMDH_FLAGS_RATING_REQ,"Rating required"},
+    {citrus.SMDH_FLAGS_SAVE_DATA,"Uses save data"},
+    {citrus.SMDH_FLAGS_RECORD_USAGE,"Record usage"},
+    {citrus.SMDH_FLAGS_NO_SAVE_BACKUP,"Disable save backups"},
+    {citrus.SMDH_FLAGS_NEW3DS,"New 3DS exclusive"},
+  } {
+    if (smdh.Flags&flag.mask)!=0 {
+      F("    - %s",flag.name)
+    }
+  }
+  P("")
+
+} // end fPrintInfoSMDH
+
+
 // Com que no té subdirectoris i ja està carregat torna el propi
 // objecte.
 func (self *_NCCH) GetRootDirectory() (Directory,error) {
@@ -154,7 +418,9 @@ func (self *_NCCH) GetFileWriter(name string) (utils.FileWriter,error) {
 func (self *_NCCH) Begin() (DirectoryIter,error) {
 
   var pos int
-  if self.state.Header.Plain.Size != 0 {
+  if self.state.Header.ExHeaderSize != 0 {
+    pos= _NCCH_EXHEADER
+  } else if self.state.Header.Plain.Size != 0 {
     pos= _NCCH_PLAIN
   } else if self.state.Header.Logo.Size != 0 {
     pos= _NCCH_LOGO
@@ -191,6 +457,8 @@ func (self *_NCCH_DirIter) CompareToName(name string) bool {
   
   tmp:= strings.ToLower ( name )
   switch self.pos {
+  case _NCCH_EXHEADER:
+    return tmp == "exheader.bin"
   case _NCCH_PLAIN:
     return tmp == "plain"
   case _NCCH_LOGO:
@@ -207,7 +475,7 @@ func (self *_NCCH_DirIter) CompareToName(name string) bool {
 
 
 func (self *_NCCH_DirIter) End() bool {
-  return self.pos>=4
+  return self.pos>=5
 } // end End
 
 
@@ -240,6 +508,9 @@ func (self *_NCCH_DirIter) GetDirectory() (Directory,error) {
 func (self *_NCCH_DirIter) GetFileReader() (utils.FileReader,error) {
 
   switch self.pos {
+
+  case _NCCH_EXHEADER:
+    return self.state.OpenExHeader ()
     
   case _NCCH_PLAIN:
     return self.state.GetPlain ()
@@ -257,6 +528,8 @@ func (self *_NCCH_DirIter) GetFileReader() (utils.FileReader,error) {
 
 func (self *_NCCH_DirIter) GetName() string {
   switch self.pos {
+  case _NCCH_EXHEADER:
+    return "exheader.bin"
   case _NCCH_PLAIN:
     return "plain"
   case _NCCH_LOGO:
@@ -289,6 +562,8 @@ func (self *_NCCH_DirIter) List( file io.Writer ) error {
   // Grandària
   var nbytes int64
   switch self.pos {
+  case _NCCH_EXHEADER:
+    nbytes= citrus.NCCH_EXHEADER_SIZE
   case _NCCH_PLAIN:
     nbytes= self.state.Header.Plain.Size
   case _NCCH_LOGO:
@@ -317,7 +592,7 @@ func (self *_NCCH_DirIter) List( file io.Writer ) error {
 func (self *_NCCH_DirIter) Next() error {
 
   if !self.End () {
-    for self.pos++; self.pos < 4; self.pos++ {
+    for self.pos++; self.pos < 5; self.pos++ {
       switch self.pos {
       case _NCCH_PLAIN: // <-- Innecessari
         if self.state.Header.Plain.Size != 0 { return nil }
@@ -343,7 +618,7 @@ func (self *_NCCH_DirIter) Remove() error {
 
 func (self *_NCCH_DirIter) Type() int {
   switch self.pos {
-  case _NCCH_PLAIN, _NCCH_LOGO:
+  case _NCCH_EXHEADER, _NCCH_PLAIN, _NCCH_LOGO:
     return DIRECTORY_ITER_TYPE_FILE
   default:
     return DIRECTORY_ITER_TYPE_DIR
@@ -374,8 +649,14 @@ func (self *_ExeFS) GetFileWriter(name string) (utils.FileWriter,error) {
 
 func (self *_ExeFS) Begin() (DirectoryIter,error) {
 
+  // Si el fitxer icon no és un SMDH vàlid no es mostren les icones.
+  smdh,err:= self.state.GetSMDH ()
+  if err != nil {
+    smdh= nil
+  }
   ret:= _ExeFS_DirIter{
     state: self.state,
+    smdh: smdh,
     pos: 0,
   }
 
@@ -388,36 +669,77 @@ func (self *_ExeFS) Begin() (DirectoryIter,error) {
 /* EXEFS DIRECTORY ITER */
 /************************/
 
+// Després dels fitxers, si hi ha SMDH, es mostren les dues icones
+// com a PNG.
 type _ExeFS_DirIter struct {
   
   state *citrus.ExeFS
+  smdh  *citrus.SMDH
   pos   int
   
 }
 
 
 func (self *_ExeFS_DirIter) CompareToName(name string) bool {
-  return name == self.state.Files[self.pos].Name
+  return name == self.GetName ()
 } // end CompareToName
 
 
 func (self *_ExeFS_DirIter) End() bool {
-  return self.pos>=len(self.state.Files)
+  nentries:= len(self.state.Files)
+  if self.smdh != nil {
+    nentries+= 2
+  }
+  return self.pos>=nentries
 } // end End
 
 
+// Torna la icona si l'entrada actual és virtual, nil en cas contrari.
+func (self *_ExeFS_DirIter) getIcon() image.Image {
+  switch self.pos-len(self.state.Files) {
+  case 0:
+    return self.smdh.SmallIcon ()
+  case 1:
+    return self.smdh.LargeIcon ()
+  default:
+    return nil
+  }
+} // end getIcon
+
+
+func (self *_ExeFS_DirIter) encodeIcon() ([]byte,error) {
+  var buf bytes.Buffer
+  if err:= png.Encode ( &buf, self.getIcon () ); err != nil {
+    return nil,err
+  }
+  return buf.Bytes (),nil
+} // end encodeIcon
+
+
 func (self *_ExeFS_DirIter) GetDirectory() (Directory,error) {
   return nil,errors.New ( "_ExeFS_DirIter.GetDirectory: WTF!!!" )
 } // end GetDirectory
 
 
 func (self *_ExeFS_DirIter) GetFileReader() (utils.FileReader,error) {
-  return self.state.OpenIndex ( self.pos )
+  if self.pos < len(self.state.Files) {
+    return self.state.OpenIndex ( self.pos )
+  }
+  data,err:= self.encodeIcon ()
+  if err != nil { return nil,err }
+  return io.NopCloser ( bytes.NewReader ( data ) ),nil
 } // end GetFileReader
 
 
 func (self *_ExeFS_DirIter) GetName() string {
-  return self.state.Files[self.pos].Name
+  switch self.pos-len(self.state.Files) {
+  case 0:
+    return "icon_24x24.png"
+  case 1:
+    return "icon_48x48.png"
+  default:
+    return self.state.Files[self.pos].Name
+  }
 } // end GetName
 
 
@@ -436,7 +758,15 @@ func (self *_ExeFS_DirIter) List( file io.Writer ) error {
   P("  ")
 
   // Grandària
-  size := utils.NumBytesToStr ( uint64(self.state.Files[self.pos].Size) )
+  var nbytes uint64
+  if self.pos < len(self.state.Files) {
+    nbytes= uint64(self.state.Files[self.pos].Size)
+  } else {
+    data,err:= self.encodeIcon ()
+    if err != nil { return err }
+    nbytes= uint64(len(data))
+  }
+  size := utils.NumBytesToStr ( nbytes )
   for i := 0; i < 10-len(size); i++ {
     P(" ")
   }
@@ -28,6 +28,7 @@ import (
   "os"
 
   "github.com/adriagipas/imgcp/cdread"
+  "github.com/adriagipas/imgcp/nitro"
 )
 
 /*********/
@@ -45,6 +46,10 @@ const TYPE_ISO9660      = 7
 const TYPE_CCI          = 8
 const TYPE_NCCH         = 9
 const TYPE_STFS         = 10
+const TYPE_UDF          = 11
+const TYPE_CIA          = 12
+const TYPE_3DSX         = 13
+const TYPE_NDS          = 14
 
 
 /************/
@@ -83,9 +88,13 @@ func detect_cd( file_name string ) (int,error) {
   cd,err:= cdread.Open ( file_name )
   if err != nil { return TYPE_UNK,nil }
 
-  // Intenta ISO
+  // Intenta UDF i ISO. Si estan els dos (imatges bridge) es
+  // prefereix UDF.
   info:= cd.Info ()
   if len(info.Sessions)==1 && len(info.Tracks)==1 {
+    if _,err:= cdread.ReadUDF ( cd, 0, 0 ); err == nil {
+      return TYPE_UDF,nil
+    }
     _,err:= cdread.ReadISO ( cd, 0, 0 )
     if err != nil {
       return TYPE_CD,nil
@@ -147,6 +156,8 @@ func detect_h4(file_name string) (int,error) {
     return TYPE_IFF,nil
   } else if head[0]=='L' && head[1]=='I' && head[2]=='S' && head[3]=='T' {
     return TYPE_IFF,nil
+  } else if head[0]=='3' && head[1]=='D' && head[2]=='S' && head[3]=='X' {
+    return TYPE_3DSX,nil
   } else {
     return TYPE_UNK,nil
   }
@@ -208,10 +219,20 @@ func detect_h512(file_name string) (int,error) {
     ret,points= TYPE_NCCH,tmp
   }
 
+  // --> Citrus CIA
+  if tmp := detect_CIA ( header, nbytes ); tmp > points {
+    ret,points= TYPE_CIA,tmp
+  }
+
   // --> Citrus STFS
   if tmp := detect_STFS ( header, nbytes ); tmp > points {
     ret,points= TYPE_STFS,tmp
   }
+
+  // --> Nitro NDS
+  if tmp := detect_NDS ( header, nbytes ); tmp > points {
+    ret,points= TYPE_NDS,tmp
+  }
   
   return ret,nil
   
@@ -373,6 +394,32 @@ func detect_NCCH(header []byte, nbytes int64) int {
 } // detect_NCCH
 
 
+func detect_CIA(header []byte, nbytes int64) int {
+
+  // No té número màgic, es comprova la grandària de la capçalera i
+  // que les seccions caben en el fitxer.
+  get32 := func(off int) int64 {
+    return int64(uint32(header[off]) | (uint32(header[off+1])<<8) |
+      (uint32(header[off+2])<<16) | (uint32(header[off+3])<<24))
+  }
+  if get32 ( 0 ) != 0x2020 || header[4]!=0 || header[5]!=0 {
+    return -1
+  }
+  align := func(n int64) int64 { return (n+63)&^63 }
+  size := align ( 0x2020 )
+  for _,off := range []int{0x8,0xc,0x10} {
+    size= align ( size+get32 ( off ) )
+  }
+  size+= get32 ( 0x18 ) | (get32 ( 0x1c )<<32)
+  if size > nbytes || get32 ( 0x10 ) == 0 {
+    return -1
+  }
+
+  return 100 // Molt probable
+  
+} // detect_CIA
+
+
 func detect_STFS(header []byte, nbytes int64) int {
 
   // Magic number
@@ -389,3 +436,23 @@ func detect_STFS(header []byte, nbytes int64) int {
   }
   
 } // detect_STFS
+
+
+func detect_NDS(header []byte, nbytes int64) int {
+
+  // CRC del logo
+  logo_crc := uint16(header[0x15c]) | (uint16(header[0x15d])<<8)
+  if logo_crc != nitro.NDS_LOGO_CRC {
+    return -1
+  }
+  ret := 50
+
+  // CRC de la capçalera
+  header_crc := uint16(header[0x15e]) | (uint16(header[0x15f])<<8)
+  if nitro.CRC16 ( header[:0x15e] ) == header_crc {
+    ret+= 50
+  }
+
+  return ret
+
+} // end detect_NDS
@@ -82,11 +82,23 @@ func NewImage(file_name string) (Image,error) {
   case TYPE_CCI:
     return newCCI ( file_name )
     
+  case TYPE_CIA:
+    return newCIA ( file_name )
+    
   case TYPE_NCCH:
     return newNCCH_from_filename ( file_name )
 
+  case TYPE_3DSX:
+    return new3DSX ( file_name )
+
+  case TYPE_NDS:
+    return newNDS ( file_name )
+
   case TYPE_STFS:
     return newSTFS ( file_name )
+
+  case TYPE_UDF:
+    return newUDF_from_filename ( file_name )
     
   default:
     return nil,fmt.Errorf ( "Unable to detect the image type for file '%s'",
@@ -95,7 +95,11 @@ func (self *_ISO_9660) PrintInfo( file io.Writer, prefix string ) error {
   }
   
   // Imprimeix
-  P("ISO 9660")
+  if self.iso.HighSierra {
+    P("High Sierra")
+  } else {
+    P("ISO 9660")
+  }
   P("")
   F("Version:                       %d\n",self.iso.PrimaryVolume.Version)
   if len(self.iso.PrimaryVolume.SystemIdentifier)>0 {
@@ -152,6 +156,12 @@ func (self *_ISO_9660) PrintInfo( file io.Writer, prefix string ) error {
     &self.iso.PrimaryVolume.VolumeEffective)
   F("File Structure Version:        %d\n",
     self.iso.PrimaryVolume.FileStructureVersion)
+  if br:= self.iso.BootRecord; br != nil {
+    F("Boot System Identifier:        %s\n",br.BootSystemIdentifier)
+    if br.ElTorito {
+      F("Boot Catalog Sector:           %d\n",br.CatalogSector)
+    }
+  }
   
   P("")
   
@@ -209,8 +219,11 @@ func (self *_ISO_9660_Directory) GetFileWriter(
 } // end GetFileWriter
 
 
+// Després de cada fitxer amb àudio CD-XA s'afegeix una entrada
+// virtual (directori NOM.xa) amb un fitxer WAV per cada canal.
 type _ISO_9660_DirIter struct {
   cdread.ISO_DirectoryIter
+  xa_dir bool // L'entrada actual és el directori virtual CD-XA
 }
 
 
@@ -221,6 +234,9 @@ func (self *_ISO_9660_DirIter) CompareToName(name string) bool {
 
 func (self *_ISO_9660_DirIter) GetDirectory() (Directory,error) {
 
+  if self.xa_dir {
+    return newISO9660XADir ( &self.ISO_DirectoryIter )
+  }
   ret:= _ISO_9660_Directory{}
   var err error
   ret.dir,err= self.ISO_DirectoryIter.GetDirectory ()
@@ -232,11 +248,17 @@ func (self *_ISO_9660_DirIter) GetDirectory() (Directory,error) {
 
 
 func (self *_ISO_9660_DirIter) GetFileReader() (utils.FileReader,error) {
+  if self.xa_dir {
+    return nil,fmt.Errorf ( "'%s' is a directory", self.GetName () )
+  }
   return self.ISO_DirectoryIter.GetFileReader ()
 } // end GetFileReader
 
 
 func (self *_ISO_9660_DirIter) GetName() string {
+  if self.xa_dir {
+    return self.Id ()+".xa"
+  }
   return self.Id ()
 } // end GetName
 
@@ -252,6 +274,9 @@ func (self *_ISO_9660_DirIter) List( file io.Writer ) error {
   
   // Flags.
   flags:= self.Flags ()
+  if self.xa_dir {
+    flags|= cdread.FILE_FLAGS_DIRECTORY
+  }
   if (flags&cdread.FILE_FLAGS_DIRECTORY) != 0 { P("d") } else { P("-") }
   if (flags&cdread.FILE_FLAGS_EXISTENCE) != 0 { P("h") } else { P("-") }
   if (flags&cdread.FILE_FLAGS_ASSOCIATED_FILE) != 0 { P("s") } else { P("-") }
@@ -289,6 +314,19 @@ func (self *_ISO_9660_DirIter) List( file io.Writer ) error {
 } // end List
 
 
+func (self *_ISO_9660_DirIter) Next() error {
+
+  if !self.xa_dir && self.IsXAAudio () {
+    self.xa_dir= true
+    return nil
+  }
+  self.xa_dir= false
+  
+  return self.ISO_DirectoryIter.Next ()
+  
+} // end Next
+
+
 func (self *_ISO_9660_DirIter) Remove() error {
   return errors.New ( "Remove file not implemented for ISO 9660 images" )
 } // end Remove
@@ -298,7 +336,9 @@ func (self *_ISO_9660_DirIter) Type() int {
 
   var ret int
   flags:= self.Flags ()
-  if (flags&cdread.FILE_FLAGS_DIRECTORY) != 0 {
+  if self.xa_dir {
+    ret= DIRECTORY_ITER_TYPE_DIR
+  } else if (flags&cdread.FILE_FLAGS_DIRECTORY) != 0 {
     name:= self.GetName ()
     if name == "." || name == ".." {
       ret= DIRECTORY_ITER_TYPE_DIR_SPECIAL
@@ -312,3 +352,129 @@ func (self *_ISO_9660_DirIter) Type() int {
   return ret
   
 } // end Type
+
+
+
+
+/****************/
+/* DIRECTORY XA */
+/****************/
+
+type _ISO_9660_XADir struct {
+  it       cdread.ISO_DirectoryIter
+  channels []cdread.XA_Channel
+}
+
+
+func newISO9660XADir(
+  it *cdread.ISO_DirectoryIter,
+) (*_ISO_9660_XADir,error) {
+
+  ret:= _ISO_9660_XADir{
+    it : *it,
+  }
+  var err error
+  if ret.channels,err= it.GetXAChannels (); err != nil {
+    return nil,err
+  }
+
+  return &ret,nil
+  
+} // end newISO9660XADir
+
+
+func (self *_ISO_9660_XADir) Begin() (DirectoryIter,error) {
+  ret:= _ISO_9660_XADirIter{
+    dir : self,
+    pos : 0,
+  }
+  return &ret,nil
+} // end Begin
+
+
+func (self *_ISO_9660_XADir) MakeDir(name string) (Directory,error) {
+  return nil,errors.New ( "Make directory not implemented for ISO 9660"+
+    " image files")
+} // end MakeDir
+
+
+func (self *_ISO_9660_XADir) GetFileWriter(
+  name string,
+) (utils.FileWriter,error) {
+  return nil,errors.New ( "Writing a file not implemented for ISO 9660"+
+    " image files")
+} // end GetFileWriter
+
+
+// Cada canal es mostra com F_C.wav (fitxer i canal del subheader).
+type _ISO_9660_XADirIter struct {
+  dir *_ISO_9660_XADir
+  pos int
+}
+
+
+func (self *_ISO_9660_XADirIter) CompareToName(name string) bool {
+  return name == self.GetName ()
+} // end CompareToName
+
+
+func (self *_ISO_9660_XADirIter) End() bool {
+  return self.pos >= len(self.dir.channels)
+} // end End
+
+
+func (self *_ISO_9660_XADirIter) GetDirectory() (Directory,error) {
+  return nil,fmt.Errorf ( "'%s' is not a directory", self.GetName () )
+} // end GetDirectory
+
+
+func (self *_ISO_9660_XADirIter) GetFileReader() (utils.FileReader,error) {
+
+  ch:= &self.dir.channels[self.pos]
+  f,err:= self.dir.it.GetXAReader ( ch )
+  if err != nil { return nil,err }
+  
+  return newWavReader ( f, ch.PCMSize (), ch.NumChannels (),
+    ch.SampleRate ),nil
+  
+} // end GetFileReader
+
+
+func (self *_ISO_9660_XADirIter) GetName() string {
+  ch:= &self.dir.channels[self.pos]
+  return fmt.Sprintf ( "%d_%d.wav", ch.File, ch.Channel )
+} // end GetName
+
+
+func (self *_ISO_9660_XADirIter) List( file io.Writer ) error {
+
+  ch:= &self.dir.channels[self.pos]
+  var mode string
+  if ch.Stereo {
+    mode= "stereo"
+  } else {
+    mode= "mono"
+  }
+  size:= utils.NumBytesToStr ( uint64(ch.PCMSize ()+44) )
+  fmt.Fprintf ( file, "-  %10s  %5d Hz  %-6s  %d bits  %s\n",
+    size, ch.SampleRate, mode, ch.BitsPerSample, self.GetName () )
+  
+  return nil
+  
+} // end List
+
+
+func (self *_ISO_9660_XADirIter) Next() error {
+  self.pos++
+  return nil
+} // end Next
+
+
+func (self *_ISO_9660_XADirIter) Remove() error {
+  return errors.New ( "Remove file not implemented for ISO 9660 images" )
+} // end Remove
+
+
+func (self *_ISO_9660_XADirIter) Type() int {
+  return DIRECTORY_ITER_TYPE_FILE
+} // end Type
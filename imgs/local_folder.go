@@ -30,6 +30,7 @@ import (
   "io"
   "os"
   "path"
+  "time"
 
   "github.com/adriagipas/imgcp/utils"
 )
@@ -193,6 +194,17 @@ func (self *_LocalFolder_DirectoryIter) GetFileReader(
 } // end GetFileReader
 
 
+// Torna la data de modificació de l'entrada actual.
+func (self *_LocalFolder_DirectoryIter) GetModTime() (time.Time,error) {
+
+  info,err := self.entries[self.pos].Info ()
+  if err != nil { return time.Time{},err }
+
+  return info.ModTime (),nil
+  
+} // end GetModTime
+
+
 func (self *_LocalFolder_DirectoryIter) GetName() string {
   return self.entries[self.pos].Name ()
 } // end GetName
@@ -0,0 +1,675 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  nitro_nds.go - Format ROM de Nintendo DS (NitroFS).
+ *
+ */
+
+package imgs
+
+import (
+  "bytes"
+  "errors"
+  "fmt"
+  "image/png"
+  "io"
+  "strings"
+
+  "github.com/adriagipas/imgcp/nitro"
+  "github.com/adriagipas/imgcp/utils"
+)
+
+
+/*******/
+/* NDS */
+/*******/
+
+const (
+  _NDS_ARM9     = 0
+  _NDS_ARM7     = 1
+  _NDS_OVERLAY  = 2
+  _NDS_BANNER   = 3
+  _NDS_ICON     = 4
+  _NDS_NITROFS  = 5
+  _NDS_NENTRIES = 6
+)
+
+// Com que és sols lectura llisc al principi el contingut.
+type _NDS struct {
+
+  state  *nitro.NDS
+  banner *nitro.NDS_Banner         // nil si no en té
+  root   *nitro.NitroFS_Directory  // nil si no en té
+
+}
+
+
+func newNDS( file_name string ) (*_NDS,error) {
+
+  state,err:= nitro.NewNDS ( file_name )
+  if err != nil { return nil,err }
+  banner,err:= state.GetBanner ()
+  if err != nil { return nil,err }
+  root,err:= state.GetNitroFS ()
+  if err != nil { return nil,err }
+  ret:= _NDS{
+    state: state,
+    banner: banner,
+    root: root,
+  }
+
+  return &ret,nil
+
+} // end newNDS
+
+
+func (self *_NDS) PrintInfo( file io.Writer, prefix string ) error {
+
+  // Preparació
+  P := func(args... any) {
+    fmt.Fprint ( file, prefix )
+    fmt.Fprintln ( file, args... )
+  }
+  F := func(format string, args... any) {
+    fmt.Fprint ( file, prefix )
+    fmt.Fprintf ( file, format, args... )
+    fmt.Fprint ( file, "\n" )
+  }
+  ok:= func(val bool) string {
+    if val { return "OK" } else { return "WRONG" }
+  }
+  binary:= func(name string,bin *nitro.NDS_Binary) {
+    size:= utils.NumBytesToStr ( uint64(bin.Size) )
+    if bin.EntryAddress != 0 {
+      F("  %-6s %10s  (offset: %08X, RAM: %08X, entry: %08X)",name,size,
+        bin.Offset,bin.RAMAddress,bin.EntryAddress)
+    } else {
+      F("  %-6s %10s  (offset: %08X, RAM: %08X)",name,size,
+        bin.Offset,bin.RAMAddress)
+    }
+  }
+
+  // Imprimeix
+  header:= &self.state.Header
+  P("Nintendo DS ROM format (NitroFS)")
+  P("")
+  F("Title:        %s",header.Title)
+  F("Game Code:    %s",header.GameCode)
+  F("Maker Code:   %s",header.MakerCode)
+  F("Unit:         %s",nitro.NDS_unitcode2str ( header.UnitCode ))
+  F("Capacity:     %s",utils.NumBytesToStr ( uint64(header.Capacity) ))
+  F("Version:      %d",header.Version)
+  F("ROM Size:     %s",utils.NumBytesToStr ( uint64(header.ROMSize) ))
+  F("Logo CRC:     %04X (%s)",header.LogoCRC,ok ( header.LogoOk ))
+  F("Header CRC:   %04X (%s)",header.HeaderCRC,ok ( header.HeaderOk ))
+  P("Binaries:")
+  binary ( "ARM9", &header.ARM9 )
+  binary ( "ARM7", &header.ARM7 )
+  F("FNT:          %s",utils.NumBytesToStr ( uint64(header.FNT.Size) ))
+  F("FAT:          %d files",header.FAT.Size/8)
+  F("Overlays:     %d (ARM9), %d (ARM7)",
+    len(self.state.ARM9Overlays),len(self.state.ARM7Overlays))
+  P("")
+
+  // DSi
+  if dsi:= header.DSi; dsi != nil {
+    P("DSi Extended Header:")
+    F("  Title Id.:    %016x",dsi.TitleId)
+    var regions []string
+    if dsi.RegionFlags == nitro.NDS_DSI_REGION_FREE {
+      regions= append(regions,"Region free")
+    } else {
+      for _,reg:= range []struct{
+        mask uint32
+        name string
+      }{
+        {nitro.NDS_DSI_REGION_JAPAN,"Japan"},
+        {nitro.NDS_DSI_REGION_USA,"USA"},
+        {nitro.NDS_DSI_REGION_EUROPE,"Europe"},
+        {nitro.NDS_DSI_REGION_AUSTRALIA,"Australia"},
+        {nitro.NDS_DSI_REGION_CHINA,"China"},
+        {nitro.NDS_DSI_REGION_KOREA,"Korea"},
+      } {
+        if (dsi.RegionFlags&reg.mask)!=0 {
+          regions= append(regions,reg.name)
+        }
+      }
+    }
+    if len(regions) == 0 {
+      regions= append(regions,"-")
+    }
+    F("  Regions:      %s",strings.Join ( regions, ", " ))
+    binary ( "ARM9i", &dsi.ARM9i )
+    binary ( "ARM7i", &dsi.ARM7i )
+    for i,area:= range dsi.Modcrypt {
+      if area.Size == 0 { continue }
+      F("  Modcrypt %d:   %s (offset: %08X)",i+1,
+        utils.NumBytesToStr ( uint64(area.Size) ),area.Offset)
+    }
+    F("  ROM Size:     %s",utils.NumBytesToStr ( uint64(dsi.ROMSize) ))
+    F("  Public Save:  %s",
+      utils.NumBytesToStr ( uint64(dsi.PublicSaveSize) ))
+    F("  Private Save: %s",
+      utils.NumBytesToStr ( uint64(dsi.PrivateSaveSize) ))
+    P("")
+  }
+
+  // Banner
+  if self.banner != nil {
+    F("Banner Titles (version %04X, CRC %s):",self.banner.Version,
+      ok ( self.banner.CRCOk ))
+    for i,title:= range self.banner.Titles {
+      if title == "" { continue }
+      F("  %-9s %s",nitro.NDS_language2str ( i )+":",
+        strings.ReplaceAll ( title, "\n", " / " ))
+    }
+    P("")
+  }
+
+  return nil
+
+} // end _NDS.PrintInfo
+
+
+// Com que el directori arrel és virtual torna el propi objecte.
+func (self *_NDS) GetRootDirectory() (Directory,error) {
+  return self,nil
+} // end _NDS.GetRootDirectory
+
+
+func (self *_NDS) MakeDir(name string) (Directory,error) {
+  return nil,errors.New ( "Make directory not implemented for NDS files" )
+} // end Mkdir
+
+
+func (self *_NDS) GetFileWriter(name string) (utils.FileWriter,error) {
+  return nil,errors.New ( "Writing a file not implemented for NDS files" )
+} // end GetFileWriter
+
+
+func (self *_NDS) Begin() (DirectoryIter,error) {
+
+  ret:= _NDS_DirIter{
+    nds: self,
+    pos: _NDS_ARM9,
+  }
+
+  return &ret,nil
+
+} // end Begin
+
+
+/**********************/
+/* NDS DIRECTORY ITER */
+/**********************/
+
+type _NDS_DirIter struct {
+
+  nds *_NDS
+  pos int
+
+}
+
+
+func (self *_NDS_DirIter) CompareToName(name string) bool {
+  return strings.ToLower ( name ) == strings.ToLower ( self.GetName () )
+} // end CompareToName
+
+
+func (self *_NDS_DirIter) End() bool {
+  return self.pos>=_NDS_NENTRIES
+} // end End
+
+
+func (self *_NDS_DirIter) encodeIcon() ([]byte,error) {
+  var buf bytes.Buffer
+  if err:= png.Encode ( &buf, self.nds.banner.Icon () ); err != nil {
+    return nil,err
+  }
+  return buf.Bytes (),nil
+} // end encodeIcon
+
+
+func (self *_NDS_DirIter) GetDirectory() (Directory,error) {
+  switch self.pos {
+  case _NDS_OVERLAY:
+    return &_NDS_Overlays{state: self.nds.state},nil
+  case _NDS_NITROFS:
+    return &_NitroFS{
+      state: self.nds.state,
+      dir: self.nds.root,
+    },nil
+  default:
+    return nil,errors.New ( "_NDS_DirIter.GetDirectory: WTF!!!" )
+  }
+} // end GetDirectory
+
+
+func (self *_NDS_DirIter) GetFileReader() (utils.FileReader,error) {
+  switch self.pos {
+  case _NDS_ARM9:
+    return self.nds.state.OpenARM9 ()
+  case _NDS_ARM7:
+    return self.nds.state.OpenARM7 ()
+  case _NDS_BANNER:
+    return self.nds.state.OpenBanner ( self.nds.banner )
+  case _NDS_ICON:
+    data,err:= self.encodeIcon ()
+    if err != nil { return nil,err }
+    return io.NopCloser ( bytes.NewReader ( data ) ),nil
+  default:
+    return nil,errors.New ( "_NDS_DirIter.GetFileReader: WTF!!!" )
+  }
+} // end GetFileReader
+
+
+func (self *_NDS_DirIter) GetName() string {
+  switch self.pos {
+  case _NDS_ARM9:
+    return "arm9.bin"
+  case _NDS_ARM7:
+    return "arm7.bin"
+  case _NDS_OVERLAY:
+    return "overlay"
+  case _NDS_BANNER:
+    return "banner.bin"
+  case _NDS_ICON:
+    return "icon.png"
+  case _NDS_NITROFS:
+    return "nitroFS"
+  default:
+    return "???"
+  }
+} // end GetName
+
+
+func (self *_NDS_DirIter) List( file io.Writer ) error {
+
+  P:= func(args... any) {
+    fmt.Fprint ( file, args... )
+  }
+  F:= func(format string,args... any) {
+    fmt.Fprintf ( file, format, args... )
+  }
+
+  // És o no directori
+  if self.Type ()==DIRECTORY_ITER_TYPE_DIR { P("d") } else { P("-") }
+
+  P("  ")
+
+  // Grandària
+  var nbytes uint64
+  header:= &self.nds.state.Header
+  switch self.pos {
+  case _NDS_ARM9:
+    nbytes= uint64(header.ARM9.Size)
+  case _NDS_ARM7:
+    nbytes= uint64(header.ARM7.Size)
+  case _NDS_BANNER:
+    nbytes= uint64(self.nds.banner.Size)
+  case _NDS_ICON:
+    data,err:= self.encodeIcon ()
+    if err != nil { return err }
+    nbytes= uint64(len(data))
+  }
+  if self.Type () == DIRECTORY_ITER_TYPE_DIR {
+    P("            ")
+  } else {
+    size := utils.NumBytesToStr ( nbytes )
+    for i := 0; i < 10-len(size); i++ {
+      P(" ")
+    }
+    P(size,"  ")
+  }
+
+  // Nom
+  F("%s",self.GetName ())
+
+  P("\n")
+
+  return nil
+
+} // end List
+
+
+func (self *_NDS_DirIter) Next() error {
+
+  if !self.End () {
+    for self.pos++; self.pos < _NDS_NENTRIES; self.pos++ {
+      switch self.pos {
+      case _NDS_OVERLAY:
+        if len(self.nds.state.ARM9Overlays) > 0 ||
+          len(self.nds.state.ARM7Overlays) > 0 {
+          return nil
+        }
+      case _NDS_BANNER, _NDS_ICON:
+        if self.nds.banner != nil { return nil }
+      case _NDS_NITROFS:
+        if self.nds.root != nil { return nil }
+      default:
+        return nil
+      }
+    }
+  }
+
+  return nil
+
+} // end Next
+
+
+func (self *_NDS_DirIter) Remove() error {
+  return errors.New ( "Remove file not implemented for NDS files" )
+} // end Remove
+
+
+func (self *_NDS_DirIter) Type() int {
+  if self.pos == _NDS_OVERLAY || self.pos == _NDS_NITROFS {
+    return DIRECTORY_ITER_TYPE_DIR
+  } else {
+    return DIRECTORY_ITER_TYPE_FILE
+  }
+} // end Type
+
+
+/****************/
+/* NDS OVERLAYS */
+/****************/
+
+// Primer els overlays de l'ARM9 i després els de l'ARM7.
+type _NDS_Overlays struct {
+  state *nitro.NDS
+}
+
+
+func (self *_NDS_Overlays) MakeDir(name string) (Directory,error) {
+  return nil,errors.New (
+    "Make directory not implemented for NDS overlays" )
+} // end Mkdir
+
+
+func (self *_NDS_Overlays) GetFileWriter(name string) (utils.FileWriter,error) {
+  return nil,errors.New (
+    "Writing a file not implemented for NDS overlays" )
+} // end GetFileWriter
+
+
+func (self *_NDS_Overlays) Begin() (DirectoryIter,error) {
+
+  ret:= _NDS_Overlays_DirIter{
+    state: self.state,
+    pos: 0,
+  }
+
+  return &ret,nil
+
+} // end Begin
+
+
+/*******************************/
+/* NDS OVERLAYS DIRECTORY ITER */
+/*******************************/
+
+type _NDS_Overlays_DirIter struct {
+
+  state *nitro.NDS
+  pos   int
+
+}
+
+
+// Torna l'overlay actual i la CPU (9 o 7).
+func (self *_NDS_Overlays_DirIter) get() (*nitro.NDS_Overlay,int) {
+  n9:= len(self.state.ARM9Overlays)
+  if self.pos < n9 {
+    return &self.state.ARM9Overlays[self.pos],9
+  } else {
+    return &self.state.ARM7Overlays[self.pos-n9],7
+  }
+} // end get
+
+
+func (self *_NDS_Overlays_DirIter) CompareToName(name string) bool {
+  return name == self.GetName ()
+} // end CompareToName
+
+
+func (self *_NDS_Overlays_DirIter) End() bool {
+  return self.pos >=
+    len(self.state.ARM9Overlays)+len(self.state.ARM7Overlays)
+} // end End
+
+
+func (self *_NDS_Overlays_DirIter) GetDirectory() (Directory,error) {
+  return nil,errors.New ( "_NDS_Overlays_DirIter.GetDirectory: WTF!!!" )
+} // end GetDirectory
+
+
+func (self *_NDS_Overlays_DirIter) GetFileReader() (utils.FileReader,error) {
+  ov,_:= self.get ()
+  return self.state.OpenFile ( ov.FileId )
+} // end GetFileReader
+
+
+func (self *_NDS_Overlays_DirIter) GetName() string {
+  ov,cpu:= self.get ()
+  return fmt.Sprintf ( "overlay%d_%04d.bin", cpu, ov.Id )
+} // end GetName
+
+
+func (self *_NDS_Overlays_DirIter) List( file io.Writer ) error {
+
+  P:= func(args... any) {
+    fmt.Fprint ( file, args... )
+  }
+  F:= func(format string,args... any) {
+    fmt.Fprintf ( file, format, args... )
+  }
+
+  // És o no directori
+  P("-")
+
+  P("  ")
+
+  // Grandària
+  ov,_:= self.get ()
+  area,err:= self.state.GetFile ( ov.FileId )
+  if err != nil { return err }
+  size := utils.NumBytesToStr ( uint64(area.Size) )
+  for i := 0; i < 10-len(size); i++ {
+    P(" ")
+  }
+  P(size,"  ")
+
+  // Nom
+  F("%s",self.GetName ())
+
+  P("\n")
+
+  return nil
+
+} // end List
+
+
+func (self *_NDS_Overlays_DirIter) Next() error {
+
+  if !self.End () {
+    self.pos++
+  }
+
+  return nil
+
+} // end Next
+
+
+func (self *_NDS_Overlays_DirIter) Remove() error {
+  return errors.New ( "Remove file not implemented for NDS overlays" )
+} // end Remove
+
+
+func (self *_NDS_Overlays_DirIter) Type() int {
+  return DIRECTORY_ITER_TYPE_FILE
+} // end Type
+
+
+/***********/
+/* NITROFS */
+/***********/
+
+type _NitroFS struct {
+
+  state *nitro.NDS
+  dir   *nitro.NitroFS_Directory
+
+}
+
+
+func (self *_NitroFS) MakeDir(name string) (Directory,error) {
+  return nil,errors.New (
+    "Make directory not implemented for NDS.NitroFS files" )
+} // end Mkdir
+
+
+func (self *_NitroFS) GetFileWriter(name string) (utils.FileWriter,error) {
+  return nil,errors.New (
+    "Writing a file not implemented for NDS.NitroFS files" )
+} // end GetFileWriter
+
+
+func (self *_NitroFS) Begin() (DirectoryIter,error) {
+
+  ret:= _NitroFS_DirIter{
+    state: self.state,
+    dir: self.dir,
+    pos: 0,
+  }
+
+  return &ret,nil
+
+} // end Begin
+
+
+/**************************/
+/* NITROFS DIRECTORY ITER */
+/**************************/
+
+// Primer els directoris i després els fitxers.
+type _NitroFS_DirIter struct {
+
+  state *nitro.NDS
+  dir   *nitro.NitroFS_Directory
+  pos   int
+
+}
+
+
+func (self *_NitroFS_DirIter) CompareToName(name string) bool {
+  return name == self.GetName ()
+} // end CompareToName
+
+
+func (self *_NitroFS_DirIter) End() bool {
+  return self.pos >= len(self.dir.Dirs)+len(self.dir.Files)
+} // end End
+
+
+func (self *_NitroFS_DirIter) GetDirectory() (Directory,error) {
+  if self.pos >= len(self.dir.Dirs) {
+    return nil,errors.New ( "_NitroFS_DirIter.GetDirectory: WTF!!!" )
+  }
+  return &_NitroFS{
+    state: self.state,
+    dir: self.dir.Dirs[self.pos],
+  },nil
+} // end GetDirectory
+
+
+func (self *_NitroFS_DirIter) GetFileReader() (utils.FileReader,error) {
+  if self.pos < len(self.dir.Dirs) {
+    return nil,errors.New ( "_NitroFS_DirIter.GetFileReader: WTF!!!" )
+  }
+  return self.state.OpenNitroFSFile (
+    &self.dir.Files[self.pos-len(self.dir.Dirs)] )
+} // end GetFileReader
+
+
+func (self *_NitroFS_DirIter) GetName() string {
+  if self.pos < len(self.dir.Dirs) {
+    return self.dir.Dirs[self.pos].Name
+  } else {
+    return self.dir.Files[self.pos-len(self.dir.Dirs)].Name
+  }
+} // end GetName
+
+
+func (self *_NitroFS_DirIter) List( file io.Writer ) error {
+
+  P:= func(args... any) {
+    fmt.Fprint ( file, args... )
+  }
+  F:= func(format string,args... any) {
+    fmt.Fprintf ( file, format, args... )
+  }
+
+  // És o no directori i grandària
+  if self.pos < len(self.dir.Dirs) {
+    P("d  ")
+    P("            ")
+  } else {
+    P("-  ")
+    nbytes:= self.dir.Files[self.pos-len(self.dir.Dirs)].Size
+    size := utils.NumBytesToStr ( uint64(nbytes) )
+    for i := 0; i < 10-len(size); i++ {
+      P(" ")
+    }
+    P(size,"  ")
+  }
+
+  // Nom
+  F("%s",self.GetName ())
+
+  P("\n")
+
+  return nil
+
+} // end List
+
+
+func (self *_NitroFS_DirIter) Next() error {
+
+  if !self.End () {
+    self.pos++
+  }
+
+  return nil
+
+} // end Next
+
+
+func (self *_NitroFS_DirIter) Remove() error {
+  return errors.New ( "Remove file not implemented for NDS.NitroFS files" )
+} // end Remove
+
+
+func (self *_NitroFS_DirIter) Type() int {
+  if self.pos < len(self.dir.Dirs) {
+    return DIRECTORY_ITER_TYPE_DIR
+  } else {
+    return DIRECTORY_ITER_TYPE_FILE
+  }
+} // end Type
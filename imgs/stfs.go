@@ -148,6 +148,10 @@ func (self *_STFS) PrintInfo( file io.Writer, prefix string ) error {
   }
   F("Volume Descriptor Type:                %s\n",
     self.state.DescriptorType())
+  if self.state.Metadata.DescriptorType == 1 {
+    F("SVOD Layout:                           %s\n",
+      self.state.SvodLayout())
+  }
   PrintBytes("Device ID:                            ",
     self.state.Metadata.DeviceID[:])
   F("Transfer Flags:                        %02x\n",
@@ -0,0 +1,291 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  udf.go - Implementa el sistema de fitxers UDF.
+ *
+ */
+
+package imgs
+
+import (
+  "errors"
+  "fmt"
+  "io"
+
+  "github.com/adriagipas/imgcp/cdread"
+  "github.com/adriagipas/imgcp/utils"
+)
+
+
+
+
+/*******/
+/* UDF */
+/*******/
+
+
+type _UDF struct {
+
+  udf *cdread.UDF
+
+}
+
+
+func newUDF( cd cdread.CD, session int, track int ) (*_UDF,error) {
+
+  ret:= _UDF{}
+  var err error
+  ret.udf,err= cdread.ReadUDF ( cd, session, track )
+  if err != nil { return nil,err }
+
+  return &ret,nil
+
+} // end newUDF
+
+
+func newUDF_from_filename( file_name string ) (*_UDF,error) {
+
+  cd,err:= cdread.Open ( file_name )
+  if err != nil { return nil,err }
+  info:= cd.Info ()
+  if len(info.Sessions)>1 || len(info.Tracks)>1 {
+    return nil,fmt.Errorf ( "'%s' is not a UDF image file", file_name )
+  }
+
+  return newUDF ( cd, 0, 0 )
+
+} // end newUDF_from_filename
+
+
+func (self *_UDF) PrintInfo( file io.Writer, prefix string ) error {
+
+  // Preparació
+  P:= func(args... any) {
+    fmt.Fprint ( file, prefix )
+    fmt.Fprintln ( file, args... )
+  }
+  F:= func(format string,args... any) {
+    fmt.Fprint ( file, prefix )
+    fmt.Fprintf ( file, format, args... )
+  }
+  PrintTimestamp:= func(field_name string,ts *cdread.UDF_Timestamp) {
+    if !ts.Empty {
+      F("%s%02d/%02d/%04d (%02d:%02d:%02d.%02d",
+        field_name,
+        ts.Day, ts.Month, ts.Year,
+        ts.Hour, ts.Minute, ts.Second, ts.Centiseconds)
+      if ts.TimeZoneOk {
+        fmt.Fprintf ( file, " UTC %+d min", ts.TimeZone )
+      }
+      fmt.Fprintln ( file, ")" )
+    }
+  }
+  S:= func(field_name string,val string) {
+    if len(val)>0 {
+      F("%s%s\n",field_name,val)
+    }
+  }
+
+  // Imprimeix
+  pv:= &self.udf.PrimaryVolume
+  lv:= &self.udf.LogicalVolume
+  fs:= &self.udf.FileSet
+  P("UDF")
+  P("")
+  F("UDF Revision:                  %x.%02x\n",
+    lv.UDFRevision>>8, lv.UDFRevision&0xff)
+  S("Volume Identifier:             ",pv.VolumeIdentifier)
+  S("Volume Set Identifier:         ",pv.VolumeSetIdentifier)
+  F("Volume Sequence Number:        %d/%d\n",
+    pv.VolumeSequenceNumber, pv.MaxVolumeSequenceNumber)
+  S("Application Identifier:        ",pv.ApplicationIdentifier)
+  S("Implementation Identifier:     ",pv.ImplementationIdentifier)
+  PrintTimestamp("Recording Date:                ",&pv.RecordingDateTime)
+  S("Logical Volume Identifier:     ",lv.LogicalVolumeIdentifier)
+  F("Logical Block Size:            %d\n",lv.LogicalBlockSize)
+  S("Domain Identifier:             ",lv.DomainIdentifier)
+  S("File Set Identifier:           ",fs.FileSetIdentifier)
+  S("Copyright File Identifier:     ",fs.CopyrightFileIdentifier)
+  S("Abstract File Identifier:      ",fs.AbstractFileIdentifier)
+  P("Partitions:")
+  for i,p:= range self.udf.Partitions {
+    var ptype string
+    switch p.Type {
+    case cdread.UDF_PARTITION_TYPE_PHYSICAL:
+      ptype= "Physical"
+    case cdread.UDF_PARTITION_TYPE_SPARABLE:
+      ptype= "Sparable"
+    case cdread.UDF_PARTITION_TYPE_METADATA:
+      ptype= "Metadata"
+    default:
+      ptype= "Unknown"
+    }
+    F("  %d) Number: %d  Type: %s  Contents: %s  Start: %d  Length: %d\n",
+      i, p.Number, ptype, p.Contents, p.Start, p.Length)
+  }
+
+  P("")
+
+  return nil
+
+} // end PrintInfo
+
+
+func (self *_UDF) GetRootDirectory() (Directory,error) {
+
+  ret:= _UDF_Directory{}
+  var err error
+  ret.dir,err= self.udf.Root ()
+  if err != nil { return nil,err }
+
+  return &ret,nil
+
+} // end GetRootDirectory
+
+
+/*************/
+/* DIRECTORY */
+/*************/
+
+type _UDF_Directory struct {
+  dir *cdread.UDF_Directory
+}
+
+
+func (self *_UDF_Directory) Begin() (DirectoryIter,error) {
+
+  tmp,err:= self.dir.Begin ()
+  if err != nil { return nil,err }
+  ret:= _UDF_DirIter{
+    UDF_DirectoryIter : *tmp,
+  }
+
+  return &ret,nil
+
+} // end Begin
+
+
+func (self *_UDF_Directory) MakeDir(name string) (Directory,error) {
+  return nil,errors.New ( "Make directory not implemented for UDF"+
+    " image files")
+} // end MakeDir
+
+
+func (self *_UDF_Directory) GetFileWriter(
+  name string,
+) (utils.FileWriter,error) {
+  return nil,errors.New ( "Writing a file not implemented for UDF"+
+    " image files")
+} // end GetFileWriter
+
+
+type _UDF_DirIter struct {
+  cdread.UDF_DirectoryIter
+}
+
+
+func (self *_UDF_DirIter) CompareToName(name string) bool {
+  return name == self.GetName ()
+} // end CompareToName
+
+
+func (self *_UDF_DirIter) GetDirectory() (Directory,error) {
+
+  ret:= _UDF_Directory{}
+  var err error
+  ret.dir,err= self.UDF_DirectoryIter.GetDirectory ()
+  if err != nil { return nil,err }
+
+  return &ret,nil
+
+} // end GetDirectory
+
+
+func (self *_UDF_DirIter) GetFileReader() (utils.FileReader,error) {
+  return self.UDF_DirectoryIter.GetFileReader ()
+} // end GetFileReader
+
+
+func (self *_UDF_DirIter) GetName() string {
+  return self.Name ()
+} // end GetName
+
+
+func (self *_UDF_DirIter) List( file io.Writer ) error {
+
+  P:= func(args... any) {
+    fmt.Fprint ( file, args... )
+  }
+  F:= func(format string,args... any) {
+    fmt.Fprintf ( file, format, args... )
+  }
+
+  // Flags.
+  chars:= self.Characteristics ()
+  if self.IsDir () { P("d") } else { P("-") }
+  if (chars&cdread.UDF_FILE_CHAR_HIDDEN) != 0 { P("h") } else { P("-") }
+  P("  ")
+
+  // Grandària
+  size := utils.NumBytesToStr ( self.Size () )
+  for i := 0; i < 10-len(size); i++ {
+    P(" ")
+  }
+  P(size,"  ")
+
+  // Data i hora
+  ts:= self.ModificationTime ()
+  if !ts.Empty {
+    F("%02d/%02d/%04d  %02d:%02d:%02d  ",
+      ts.Day,ts.Month,ts.Year,ts.Hour,ts.Minute,ts.Second)
+  } else {
+    P("??/??/????  ??:??:??  ")
+  }
+
+  // Nom
+  P(self.GetName ())
+
+  P("\n")
+
+  return nil
+
+} // end List
+
+
+func (self *_UDF_DirIter) Remove() error {
+  return errors.New ( "Remove file not implemented for UDF images" )
+} // end Remove
+
+
+func (self *_UDF_DirIter) Type() int {
+
+  var ret int
+  if self.IsDir () {
+    if (self.Characteristics ()&cdread.UDF_FILE_CHAR_PARENT) != 0 {
+      ret= DIRECTORY_ITER_TYPE_DIR_SPECIAL
+    } else {
+      ret= DIRECTORY_ITER_TYPE_DIR
+    }
+  } else {
+    ret= DIRECTORY_ITER_TYPE_FILE
+  }
+
+  return ret
+
+} // end Type
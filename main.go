@@ -52,6 +52,20 @@ func main() {
         err= ops.Copy ( args )
       case utils.OP_REMOVE:
         err= ops.Remove ( args )
+      case utils.OP_MKISO:
+        err= ops.MkIso ( args )
+      case utils.OP_MKROMFS:
+        err= ops.MkRomFS ( args )
+      case utils.OP_TRIM:
+        err= ops.Trim ( args )
+      case utils.OP_UNTRIM:
+        err= ops.Untrim ( args )
+      case utils.OP_DATCHECK:
+        err= ops.DatCheck ( args )
+      case utils.OP_CONVERT:
+        err= ops.Convert ( args )
+      case utils.OP_VERIFY:
+        err= ops.Verify ( args )
       default:
         err= ops.Show ( args )
       }
@@ -0,0 +1,234 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  banner.go - Icona i títols de les ROMs de NDS.
+ */
+
+package nitro
+
+import (
+  "errors"
+  "fmt"
+  "image"
+  "image/color"
+  "os"
+  "unicode/utf16"
+
+  "github.com/adriagipas/imgcp/utils"
+)
+
+
+/*********/
+/* TIPUS */
+/*********/
+
+const NDS_BANNER_NUM_LANGUAGES = 8
+
+// Idiomes (índex en NDS_Banner.Titles)
+const (
+  NDS_BANNER_LANG_JAPANESE = 0
+  NDS_BANNER_LANG_ENGLISH  = 1
+  NDS_BANNER_LANG_FRENCH   = 2
+  NDS_BANNER_LANG_GERMAN   = 3
+  NDS_BANNER_LANG_ITALIAN  = 4
+  NDS_BANNER_LANG_SPANISH  = 5
+  NDS_BANNER_LANG_CHINESE  = 6 // A partir de la versió 2
+  NDS_BANNER_LANG_KOREAN   = 7 // A partir de la versió 3
+)
+
+type NDS_Banner struct {
+
+  Version uint16
+  Size    uint32 // Grandària segons la versió
+  CRCOk   bool   // CRC de l'àrea comuna a totes les versions
+  Titles  [NDS_BANNER_NUM_LANGUAGES]string
+
+  icon    [0x200]byte // 32x32 4bpp
+  palette [16]uint16  // BGR555
+
+}
+
+
+/************/
+/* FUNCIONS */
+/************/
+
+func bannerSize( version uint16 ) uint32 {
+
+  switch version {
+  case 0x0001:
+    return 0x840
+  case 0x0002:
+    return 0x940
+  case 0x0003:
+    return 0xa40
+  case 0x0103:
+    return 0x23c0
+  default:
+    return 0
+  }
+
+} // end bannerSize
+
+
+// Decodifica una cadena UTF-16LE acabada en 0.
+func decodeUTF16LE( mem []byte ) string {
+
+  tmp:= make([]uint16,0,len(mem)/2)
+  for i:= 0; i+1 < len(mem); i+= 2 {
+    c:= readLE16 ( mem[i:] )
+    if c == 0 { break }
+    tmp= append(tmp,c)
+  }
+
+  return string(utf16.Decode ( tmp ))
+
+} // end decodeUTF16LE
+
+
+// Si no té banner torna nil sense error.
+func (self *NDS) GetBanner() (*NDS_Banner,error) {
+
+  offset:= int64(self.Header.BannerOffset)
+  if offset == 0 { return nil,nil }
+
+  // Llig la part comuna a totes les versions
+  fd,err:= os.Open ( self.file_name )
+  if err != nil { return nil,err }
+  defer fd.Close ()
+  var buf [0xa40]byte
+  n,err:= fd.ReadAt ( buf[:], offset )
+  if n < 0x840 {
+    if err == nil {
+      err= errors.New ( "not enough bytes" )
+    }
+    return nil,fmt.Errorf ( "Error while reading NDS banner: %s", err )
+  }
+
+  // Versió
+  ret:= NDS_Banner{
+    Version : readLE16 ( buf[0:] ),
+  }
+  ret.Size= bannerSize ( ret.Version )
+  if ret.Size == 0 {
+    return nil,fmt.Errorf ( "Error while reading NDS banner: unknown"+
+      " version (%04X)", ret.Version )
+  }
+  if offset+int64(ret.Size) > self.file_size {
+    return nil,errors.New ( "Error while reading NDS banner: banner is"+
+      " out of file boundaries" )
+  }
+  ret.CRCOk= CRC16 ( buf[0x20:0x840] ) == readLE16 ( buf[2:] )
+
+  // Icona
+  copy ( ret.icon[:], buf[0x20:0x220] )
+  for i:= range ret.palette {
+    ret.palette[i]= readLE16 ( buf[0x220+i*2:] )
+  }
+
+  // Títols
+  nlangs:= NDS_BANNER_LANG_CHINESE
+  if ret.Version >= 0x0003 {
+    nlangs= NDS_BANNER_LANG_KOREAN+1
+  } else if ret.Version >= 0x0002 {
+    nlangs= NDS_BANNER_LANG_CHINESE+1
+  }
+  for i:= 0; i < nlangs; i++ {
+    ret.Titles[i]= decodeUTF16LE ( buf[0x240+i*0x100:0x240+(i+1)*0x100] )
+  }
+
+  return &ret,nil
+
+} // end GetBanner
+
+
+func (self *NDS) OpenBanner(
+
+  banner *NDS_Banner,
+
+) (*utils.SubfileReader,error) {
+  return self.open ( self.Header.BannerOffset, banner.Size )
+} // end OpenBanner
+
+
+// Icona de 32x32. Es guarda en tiles de 8x8 amb 4 bits per píxel (el
+// nibble baix és el píxel de l'esquerra). El color 0 és transparent.
+func (self *NDS_Banner) Icon() image.Image {
+
+  // Paleta
+  var pal [16]color.RGBA
+  for i,c:= range self.palette {
+    r:= uint8(c)&0x1f
+    g:= uint8(c>>5)&0x1f
+    b:= uint8(c>>10)&0x1f
+    pal[i]= color.RGBA{
+      R : (r<<3) | (r>>2),
+      G : (g<<3) | (g>>2),
+      B : (b<<3) | (b>>2),
+      A : 0xff,
+    }
+  }
+  pal[0]= color.RGBA{}
+
+  // Píxels
+  ret:= image.NewRGBA ( image.Rect ( 0, 0, 32, 32 ) )
+  i:= 0
+  for ty:= 0; ty < 32; ty+= 8 {
+    for tx:= 0; tx < 32; tx+= 8 {
+      for y:= 0; y < 8; y++ {
+        for x:= 0; x < 8; x+= 2 {
+          b:= self.icon[i]
+          ret.SetRGBA ( tx+x, ty+y, pal[b&0xf] )
+          ret.SetRGBA ( tx+x+1, ty+y, pal[b>>4] )
+          i++
+        }
+      }
+    }
+  }
+
+  return ret
+
+} // end Icon
+
+
+func NDS_language2str( lang int ) string {
+
+  switch lang {
+  case NDS_BANNER_LANG_JAPANESE:
+    return "Japanese"
+  case NDS_BANNER_LANG_ENGLISH:
+    return "English"
+  case NDS_BANNER_LANG_FRENCH:
+    return "French"
+  case NDS_BANNER_LANG_GERMAN:
+    return "German"
+  case NDS_BANNER_LANG_ITALIAN:
+    return "Italian"
+  case NDS_BANNER_LANG_SPANISH:
+    return "Spanish"
+  case NDS_BANNER_LANG_CHINESE:
+    return "Chinese"
+  case NDS_BANNER_LANG_KOREAN:
+    return "Korean"
+  default:
+    return "Unknown"
+  }
+
+} // end NDS_language2str
@@ -0,0 +1,432 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  nds.go - Imatges de cartutxos de Nintendo DS (i DSi).
+ */
+
+package nitro
+
+import (
+  "bytes"
+  "errors"
+  "fmt"
+  "os"
+
+  "github.com/adriagipas/imgcp/utils"
+)
+
+
+/*********/
+/* TIPUS */
+/*********/
+
+const NDS_HEADER_SIZE     = 0x200
+const NDS_DSI_HEADER_SIZE = 0x1000
+
+// Valor del CRC del logo de Nintendo.
+const NDS_LOGO_CRC = 0xcf56
+
+const (
+  NDS_UNIT_NDS     = 0x00
+  NDS_UNIT_NDS_DSI = 0x02 // Compatible amb DSi
+  NDS_UNIT_DSI     = 0x03 // Sols DSi
+)
+
+// Regions DSi
+const (
+  NDS_DSI_REGION_JAPAN     = 0x01
+  NDS_DSI_REGION_USA       = 0x02
+  NDS_DSI_REGION_EUROPE    = 0x04
+  NDS_DSI_REGION_AUSTRALIA = 0x08
+  NDS_DSI_REGION_CHINA     = 0x10
+  NDS_DSI_REGION_KOREA     = 0x20
+  NDS_DSI_REGION_FREE      = 0xffffffff
+)
+
+type NDS_Binary struct {
+
+  Offset       uint32 // Posició en la ROM
+  EntryAddress uint32
+  RAMAddress   uint32
+  Size         uint32
+
+}
+
+type NDS_Area struct {
+
+  Offset uint32
+  Size   uint32
+
+}
+
+type NDS_Overlay struct {
+
+  Id              uint32
+  RAMAddress      uint32
+  RAMSize         uint32
+  BSSSize         uint32
+  StaticInitStart uint32
+  StaticInitEnd   uint32
+  FileId          uint32
+
+}
+
+type NDS_DSiHeader struct {
+
+  RegionFlags     uint32
+  ARM9i           NDS_Binary // EntryAddress no s'empra
+  ARM7i           NDS_Binary // EntryAddress no s'empra
+  BannerSize      uint32
+  ROMSize         uint32     // Grandària total emprada incloent l'àrea DSi
+  Modcrypt        [2]NDS_Area
+  TitleId         uint64
+  PublicSaveSize  uint32
+  PrivateSaveSize uint32
+  AgeRatings      [16]uint8
+
+}
+
+type NDS_Header struct {
+
+  Title        string
+  GameCode     string
+  MakerCode    string
+  UnitCode     uint8
+  Capacity     int64 // Grandària del xip
+  Version      uint8
+  ARM9         NDS_Binary
+  ARM7         NDS_Binary
+  FNT          NDS_Area
+  FAT          NDS_Area
+  ARM9Overlays NDS_Area
+  ARM7Overlays NDS_Area
+  BannerOffset uint32 // 0 si no en té
+  ROMSize      uint32 // Grandària total emprada
+  HeaderSize   uint32
+  LogoCRC      uint16
+  HeaderCRC    uint16
+  LogoOk       bool
+  HeaderOk     bool
+  DSi          *NDS_DSiHeader // nil si no en té
+
+}
+
+type NDS struct {
+
+  Header       NDS_Header
+  ARM9Overlays []NDS_Overlay
+  ARM7Overlays []NDS_Overlay
+
+  file_name string
+  file_size int64
+  fat       []NDS_Area // Start i End es guarden com a Offset i Size
+
+}
+
+
+/************/
+/* FUNCIONS */
+/************/
+
+func readLE16( mem []byte ) uint16 {
+  return uint16(mem[0]) | (uint16(mem[1])<<8)
+} // end readLE16
+
+
+func readLE32( mem []byte ) uint32 {
+  return uint32(mem[0]) |
+    (uint32(mem[1])<<8) |
+    (uint32(mem[2])<<16) |
+    (uint32(mem[3])<<24)
+} // end readLE32
+
+
+func readLE64( mem []byte ) uint64 {
+  return uint64(readLE32 ( mem )) | (uint64(readLE32 ( mem[4:] ))<<32)
+} // end readLE64
+
+
+// CRC-16 (polinomi 0xA001, valor inicial 0xFFFF) que s'empra en la
+// capçalera i el banner.
+func CRC16( data []byte ) uint16 {
+
+  crc:= uint16(0xffff)
+  for _,b:= range data {
+    crc^= uint16(b)
+    for i:= 0; i < 8; i++ {
+      if (crc&1) != 0 {
+        crc= (crc>>1)^0xa001
+      } else {
+        crc>>= 1
+      }
+    }
+  }
+
+  return crc
+
+} // end CRC16
+
+
+func newNDS_Binary( mem []byte ) NDS_Binary {
+  return NDS_Binary{
+    Offset       : readLE32 ( mem ),
+    EntryAddress : readLE32 ( mem[4:] ),
+    RAMAddress   : readLE32 ( mem[8:] ),
+    Size         : readLE32 ( mem[12:] ),
+  }
+} // end newNDS_Binary
+
+
+func newNDS_Area( mem []byte ) NDS_Area {
+  return NDS_Area{
+    Offset : readLE32 ( mem ),
+    Size   : readLE32 ( mem[4:] ),
+  }
+} // end newNDS_Area
+
+
+func trimString( mem []byte ) string {
+  return string(bytes.TrimRight ( mem, "\000 " ))
+} // end trimString
+
+
+// mem són els 0x1000 bytes de la capçalera.
+func (self *NDS_DSiHeader) read( mem []byte ) {
+
+  self.RegionFlags= readLE32 ( mem[0x1b0:] )
+  self.ARM9i= newNDS_Binary ( mem[0x1c0:] )
+  self.ARM9i.EntryAddress= 0
+  self.ARM7i= newNDS_Binary ( mem[0x1d0:] )
+  self.ARM7i.EntryAddress= 0
+  self.BannerSize= readLE32 ( mem[0x208:] )
+  self.ROMSize= readLE32 ( mem[0x210:] )
+  self.Modcrypt[0]= newNDS_Area ( mem[0x220:] )
+  self.Modcrypt[1]= newNDS_Area ( mem[0x228:] )
+  self.TitleId= readLE64 ( mem[0x230:] )
+  self.PublicSaveSize= readLE32 ( mem[0x238:] )
+  self.PrivateSaveSize= readLE32 ( mem[0x23c:] )
+  copy ( self.AgeRatings[:], mem[0x2f0:0x300] )
+
+} // end NDS_DSiHeader.read
+
+
+func (self *NDS_Header) Read( fd *os.File, file_size int64 ) error {
+
+  // Llig capçalera
+  var buf [NDS_DSI_HEADER_SIZE]byte
+  n,err:= fd.ReadAt ( buf[:], 0 )
+  if n < NDS_HEADER_SIZE {
+    if err == nil {
+      err= errors.New ( "not enough bytes" )
+    }
+    return fmt.Errorf ( "Error while reading NDS header: %s", err )
+  }
+
+  // Comprovacions
+  self.LogoCRC= readLE16 ( buf[0x15c:] )
+  self.HeaderCRC= readLE16 ( buf[0x15e:] )
+  self.LogoOk= self.LogoCRC == NDS_LOGO_CRC &&
+    CRC16 ( buf[0xc0:0x15c] ) == NDS_LOGO_CRC
+  self.HeaderOk= CRC16 ( buf[:0x15e] ) == self.HeaderCRC
+  if self.LogoCRC != NDS_LOGO_CRC {
+    return fmt.Errorf ( "Not a NDS file: wrong logo CRC (%04X)",
+      self.LogoCRC )
+  }
+
+  // Llig valors
+  self.Title= trimString ( buf[0x00:0x0c] )
+  self.GameCode= trimString ( buf[0x0c:0x10] )
+  self.MakerCode= trimString ( buf[0x10:0x12] )
+  self.UnitCode= buf[0x12]
+  self.Capacity= int64(128*1024)<<(buf[0x14]&0x0f)
+  self.Version= buf[0x1e]
+  self.ARM9= newNDS_Binary ( buf[0x20:] )
+  self.ARM7= newNDS_Binary ( buf[0x30:] )
+  self.FNT= newNDS_Area ( buf[0x40:] )
+  self.FAT= newNDS_Area ( buf[0x48:] )
+  self.ARM9Overlays= newNDS_Area ( buf[0x50:] )
+  self.ARM7Overlays= newNDS_Area ( buf[0x58:] )
+  self.BannerOffset= readLE32 ( buf[0x68:] )
+  self.ROMSize= readLE32 ( buf[0x80:] )
+  self.HeaderSize= readLE32 ( buf[0x84:] )
+
+  // Capçalera estesa DSi
+  self.DSi= nil
+  if (self.UnitCode&NDS_UNIT_NDS_DSI) != 0 {
+    if n != len(buf) {
+      return errors.New ( "Error while reading DSi extended header:"+
+        " not enough bytes" )
+    }
+    self.DSi= &NDS_DSiHeader{}
+    self.DSi.read ( buf[:] )
+  }
+
+  // Comprova que les àrees caben en el fitxer
+  for _,area:= range []struct{
+    name   string
+    offset uint32
+    size   uint32
+  }{
+    {"ARM9 binary",self.ARM9.Offset,self.ARM9.Size},
+    {"ARM7 binary",self.ARM7.Offset,self.ARM7.Size},
+    {"FNT",self.FNT.Offset,self.FNT.Size},
+    {"FAT",self.FAT.Offset,self.FAT.Size},
+    {"ARM9 overlay table",self.ARM9Overlays.Offset,self.ARM9Overlays.Size},
+    {"ARM7 overlay table",self.ARM7Overlays.Offset,self.ARM7Overlays.Size},
+  } {
+    if int64(area.offset)+int64(area.size) > file_size {
+      return fmt.Errorf ( "Error while reading NDS header: %s ([%d,%d[) is"+
+        " out of file boundaries ([0,%d[)", area.name, area.offset,
+        int64(area.offset)+int64(area.size), file_size )
+    }
+  }
+
+  return nil
+
+} // end NDS_Header.Read
+
+
+func readOverlays( fd *os.File, area NDS_Area ) ([]NDS_Overlay,error) {
+
+  buf:= make([]byte,area.Size/0x20*0x20)
+  if _,err:= fd.ReadAt ( buf, int64(area.Offset) ); err != nil {
+    return nil,fmt.Errorf ( "Error while reading overlay table: %s", err )
+  }
+  ret:= make([]NDS_Overlay,0,len(buf)/0x20)
+  for off:= 0; off < len(buf); off+= 0x20 {
+    mem:= buf[off:]
+    ret= append(ret,NDS_Overlay{
+      Id              : readLE32 ( mem[0x00:] ),
+      RAMAddress      : readLE32 ( mem[0x04:] ),
+      RAMSize         : readLE32 ( mem[0x08:] ),
+      BSSSize         : readLE32 ( mem[0x0c:] ),
+      StaticInitStart : readLE32 ( mem[0x10:] ),
+      StaticInitEnd   : readLE32 ( mem[0x14:] ),
+      FileId          : readLE32 ( mem[0x18:] ),
+    })
+  }
+
+  return ret,nil
+
+} // end readOverlays
+
+
+func NewNDS( file_name string ) (*NDS,error) {
+
+  // Obri
+  fd,err:= os.Open ( file_name )
+  if err != nil { return nil,err }
+  defer fd.Close ()
+  info,err:= fd.Stat ()
+  if err != nil { return nil,err }
+  ret:= NDS{
+    file_name : file_name,
+    file_size : info.Size (),
+  }
+
+  // Capçalera
+  if err:= ret.Header.Read ( fd, ret.file_size ); err != nil {
+    return nil,err
+  }
+
+  // FAT
+  buf:= make([]byte,ret.Header.FAT.Size/8*8)
+  if _,err:= fd.ReadAt ( buf, int64(ret.Header.FAT.Offset) ); err != nil {
+    return nil,fmt.Errorf ( "Error while reading FAT: %s", err )
+  }
+  ret.fat= make([]NDS_Area,len(buf)/8)
+  for i:= range ret.fat {
+    start:= readLE32 ( buf[i*8:] )
+    end:= readLE32 ( buf[i*8+4:] )
+    if end < start || int64(end) > ret.file_size {
+      return nil,fmt.Errorf ( "Error while reading FAT: file %d ([%d,%d[)"+
+        " is out of file boundaries ([0,%d[)", i, start, end, ret.file_size )
+    }
+    ret.fat[i]= NDS_Area{
+      Offset : start,
+      Size   : end-start,
+    }
+  }
+
+  // Overlays
+  if ret.ARM9Overlays,err= readOverlays ( fd,
+    ret.Header.ARM9Overlays ); err != nil {
+    return nil,err
+  }
+  if ret.ARM7Overlays,err= readOverlays ( fd,
+    ret.Header.ARM7Overlays ); err != nil {
+    return nil,err
+  }
+
+  return &ret,nil
+
+} // end NewNDS
+
+
+func (self *NDS) open(
+
+  offset uint32,
+  size   uint32,
+
+) (*utils.SubfileReader,error) {
+  return utils.NewSubfileReader ( self.file_name, int64(offset), int64(size) )
+} // end open
+
+
+func (self *NDS) OpenARM9() (*utils.SubfileReader,error) {
+  return self.open ( self.Header.ARM9.Offset, self.Header.ARM9.Size )
+} // end OpenARM9
+
+
+func (self *NDS) OpenARM7() (*utils.SubfileReader,error) {
+  return self.open ( self.Header.ARM7.Offset, self.Header.ARM7.Size )
+} // end OpenARM7
+
+
+// Torna la posició i la grandària d'un fitxer de la FAT.
+func (self *NDS) GetFile( id uint32 ) (NDS_Area,error) {
+  if id >= uint32(len(self.fat)) {
+    return NDS_Area{},fmt.Errorf ( "File %d not found in FAT", id )
+  }
+  return self.fat[id],nil
+} // end GetFile
+
+
+func (self *NDS) OpenFile( id uint32 ) (*utils.SubfileReader,error) {
+  area,err:= self.GetFile ( id )
+  if err != nil { return nil,err }
+  return self.open ( area.Offset, area.Size )
+} // end OpenFile
+
+
+func NDS_unitcode2str( code uint8 ) string {
+
+  switch code {
+  case NDS_UNIT_NDS:
+    return "NDS"
+  case NDS_UNIT_NDS_DSI:
+    return "NDS + DSi"
+  case NDS_UNIT_DSI:
+    return "DSi"
+  default:
+    return "Unknown"
+  }
+
+} // end NDS_unitcode2str
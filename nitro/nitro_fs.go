@@ -0,0 +1,178 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  nitro_fs.go - Sistema de fitxers de les ROMs de NDS (FNT i FAT).
+ */
+
+package nitro
+
+import (
+  "errors"
+  "fmt"
+  "os"
+
+  "github.com/adriagipas/imgcp/utils"
+)
+
+
+/*********/
+/* TIPUS */
+/*********/
+
+// Els identificadors dels directoris comencen en 0xF000 (arrel).
+const _NITROFS_ROOT_ID  = 0xf000
+const _NITROFS_MAX_DIRS = 0x1000
+
+type NitroFS_File struct {
+
+  Name   string
+  Id     uint32 // Índex en la FAT
+  Offset uint32
+  Size   uint32
+
+}
+
+type NitroFS_Directory struct {
+
+  Name  string // La cadena buida representa el root
+  Dirs  []*NitroFS_Directory
+  Files []NitroFS_File
+
+}
+
+
+/************/
+/* FUNCIONS */
+/************/
+
+// Llig la subtaula del directori id de la FNT.
+func (self *NDS) readNitroFSDir(
+
+  fnt     []byte,
+  id      int,
+  name    string,
+  visited []bool,
+
+) (*NitroFS_Directory,error) {
+
+  // Comprovacions
+  ndirs:= len(visited)
+  if id < 0 || id >= ndirs {
+    return nil,fmt.Errorf ( "Error while reading FNT: wrong directory"+
+      " id (%04X)", id+_NITROFS_ROOT_ID )
+  }
+  if visited[id] {
+    return nil,fmt.Errorf ( "Error while reading FNT: directory %04X"+
+      " visited twice", id+_NITROFS_ROOT_ID )
+  }
+  visited[id]= true
+
+  // Entrada de la taula principal
+  ret:= NitroFS_Directory{
+    Name : name,
+  }
+  off:= int(readLE32 ( fnt[id*8:] ))
+  file_id:= uint32(readLE16 ( fnt[id*8+4:] ))
+
+  // Subtaula
+  for {
+    if off >= len(fnt) {
+      return nil,errors.New ( "Error while reading FNT: unexpected end" )
+    }
+    tlen:= fnt[off]
+    off++
+    if tlen == 0 { break }
+    nlen:= int(tlen&0x7f)
+    if off+nlen > len(fnt) {
+      return nil,errors.New ( "Error while reading FNT: unexpected end" )
+    }
+    entry_name:= string(fnt[off:off+nlen])
+    off+= nlen
+
+    // Subdirectori
+    if (tlen&0x80) != 0 {
+      if off+2 > len(fnt) {
+        return nil,errors.New ( "Error while reading FNT: unexpected end" )
+      }
+      sub_id:= int(readLE16 ( fnt[off:] ))-_NITROFS_ROOT_ID
+      off+= 2
+      dir,err:= self.readNitroFSDir ( fnt, sub_id, entry_name, visited )
+      if err != nil { return nil,err }
+      ret.Dirs= append(ret.Dirs,dir)
+
+      // Fitxer
+    } else {
+      area,err:= self.GetFile ( file_id )
+      if err != nil {
+        return nil,fmt.Errorf ( "Error while reading FNT: %s", err )
+      }
+      ret.Files= append(ret.Files,NitroFS_File{
+        Name   : entry_name,
+        Id     : file_id,
+        Offset : area.Offset,
+        Size   : area.Size,
+      })
+      file_id++
+    }
+  }
+
+  return &ret,nil
+
+} // end readNitroFSDir
+
+
+// Llig l'arbre de directoris. Si no en té torna nil sense error.
+func (self *NDS) GetNitroFS() (*NitroFS_Directory,error) {
+
+  if self.Header.FNT.Size == 0 {
+    return nil,nil
+  }
+
+  // Llig la FNT
+  fd,err:= os.Open ( self.file_name )
+  if err != nil { return nil,err }
+  defer fd.Close ()
+  fnt:= make([]byte,self.Header.FNT.Size)
+  if _,err:= fd.ReadAt ( fnt, int64(self.Header.FNT.Offset) ); err != nil {
+    return nil,fmt.Errorf ( "Error while reading FNT: %s", err )
+  }
+
+  // El camp pare de l'arrel conté el nombre de directoris.
+  if len(fnt) < 8 {
+    return nil,errors.New ( "Error while reading FNT: not enough bytes" )
+  }
+  ndirs:= int(readLE16 ( fnt[6:] ))
+  if ndirs == 0 || ndirs > _NITROFS_MAX_DIRS || ndirs*8 > len(fnt) {
+    return nil,fmt.Errorf ( "Error while reading FNT: wrong number of"+
+      " directories (%d)", ndirs )
+  }
+
+  return self.readNitroFSDir ( fnt, 0, "", make([]bool,ndirs) )
+
+} // end GetNitroFS
+
+
+func (self *NDS) OpenNitroFSFile(
+
+  file *NitroFS_File,
+
+) (*utils.SubfileReader,error) {
+  return self.open ( file.Offset, file.Size )
+} // end OpenNitroFSFile
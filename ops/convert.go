@@ -0,0 +1,408 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ * convert.go - Implementa l'operació CONVERT. Escriu qualsevol imatge
+ *              de CD com un CUE/BIN (un únic BIN o un BIN per track)
+ *              amb sectors RAW de 2352 bytes.
+ */
+
+package ops
+
+import (
+  "bufio"
+  "errors"
+  "fmt"
+  "io"
+  "os"
+  "path"
+  "strings"
+
+  "github.com/adriagipas/imgcp/cdread"
+  "github.com/adriagipas/imgcp/utils"
+)
+
+
+/************/
+/* OPERACIÓ */
+/************/
+
+type _ConvertTrack struct {
+  info    *cdread.TrackInfo
+  sess    int
+  track   int
+  index01 int64 // Índex absolut del sector de l'índex 01
+  pregap  int64 // Sectors de l'índex 00
+  skip    int64 // Sectors inicials del pregap que no formen part del BIN
+}
+
+
+func Convert ( args *utils.Args ) error {
+
+  // Processa opcions
+  split,cue_name := false,""
+  for _,arg := range args.OpArgs {
+    if arg == "-split" {
+      split= true
+    } else if cue_name == "" {
+      cue_name= arg
+    } else {
+      return fmt.Errorf ( "(CONVERT) invalid arguments: %v", args.OpArgs )
+    }
+  }
+  if cue_name == "" {
+    return errors.New ( "(CONVERT) a CUE file name must be provided" )
+  }
+  if len(args.Files) != 1 {
+    return errors.New ( "(CONVERT) only one image can be converted" )
+  }
+
+  // Obri
+  var file string
+  for _,f := range args.Files {
+    file= f
+  }
+  cd,err := cdread.Open ( file )
+  if err != nil {
+    return fmt.Errorf ( "(CONVERT) '%s' is not a CD image", file )
+  }
+  info := cd.Info ()
+  if info.DVD != nil {
+    return fmt.Errorf ( "(CONVERT) '%s' is a DVD image", file )
+  }
+  tracks,err := getConvertTracks ( info )
+  if err != nil { return err }
+
+  // Escriu
+  cue,err := os.Create ( cue_name )
+  if err != nil { return err }
+  defer cue.Close ()
+  w := bufio.NewWriter ( cue )
+  writeConvertCDText ( w, "", &info.CDText )
+  if info.Catalog != "" {
+    fmt.Fprintf ( w, "CATALOG %s\n", info.Catalog )
+  }
+  base := strings.TrimSuffix ( cue_name, path.Ext ( cue_name ) )
+  var bin *os.File
+  var bin_w *bufio.Writer
+  var pos int64 = 0 // Sector actual dins del BIN
+  for i := range tracks {
+    t := &tracks[i]
+
+    // Sessió
+    if len(info.Sessions) > 1 && (i == 0 || tracks[i-1].sess != t.sess) {
+      fmt.Fprintf ( w, "REM SESSION %02d\n", t.sess+1 )
+    }
+
+    // Obri BIN
+    if bin == nil || split {
+      if bin != nil {
+        if err := bin_w.Flush (); err != nil { return err }
+        bin.Close ()
+      }
+      bin_name := base+".bin"
+      if split {
+        bin_name= fmt.Sprintf ( "%s (Track %02x).bin", base, t.info.Id )
+      }
+      fmt.Printf ( "Writing %s ...\n", bin_name )
+      if bin,err= os.Create ( bin_name ); err != nil { return err }
+      defer bin.Close ()
+      bin_w= bufio.NewWriter ( bin )
+      pos= 0
+      fmt.Fprintf ( w, "FILE \"%s\" BINARY\n", path.Base ( bin_name ) )
+    }
+
+    // Track
+    n,err := writeConvertTrack ( w, bin_w, cd, t, pos )
+    if err != nil { return err }
+    pos+= n
+
+  }
+  if err := bin_w.Flush (); err != nil { return err }
+  if err := w.Flush (); err != nil { return err }
+  fmt.Printf ( "Writing %s ...\n", cue_name )
+
+  return nil
+
+} // end Convert
+
+
+func getConvertTracks ( info *cdread.Info ) ([]_ConvertTrack,error) {
+
+  var ret []_ConvertTrack
+  for s := range info.Sessions {
+    for t := range info.Sessions[s].Tracks {
+      track := &info.Sessions[s].Tracks[t]
+      if track.Type == cdread.TRACK_TYPE_UNK {
+        return nil,fmt.Errorf ( "(CONVERT) track %02x has an unknown type",
+          track.Id )
+      }
+      var index00,index01 int64 = -1,-1
+      for _,ind := range track.Indexes {
+        switch ind.Id {
+        case 0x00:
+          if index00 == -1 {
+            index00= cdread.GetSectorIndex ( ind.Pos )
+          }
+        case 0x01:
+          index01= cdread.GetSectorIndex ( ind.Pos )
+        }
+      }
+      if index01 == -1 {
+        return nil,fmt.Errorf ( "(CONVERT) track %02x without index 01",
+          track.Id )
+      }
+      // Els 2 segons inicials no formen part del BIN.
+      var pregap,skip int64 = 0,0
+      if index00 != -1 {
+        if index00 < 2*75 {
+          skip= 2*75-index00
+          index00= 2*75
+        }
+        if index00 < index01 {
+          pregap= index01-index00
+        }
+      }
+      ret= append(ret,_ConvertTrack{
+        info    : track,
+        sess    : s,
+        track   : t,
+        index01 : index01,
+        pregap  : pregap,
+        skip    : skip,
+      })
+    }
+  }
+
+  return ret,nil
+
+} // end getConvertTracks
+
+
+// Escriu l'entrada TRACK del CUE i les dades del track. pos és el
+// sector dins del BIN on comença. Torna el nombre de sectors escrits.
+func writeConvertTrack (
+
+  w     *bufio.Writer,
+  bin   *bufio.Writer,
+  cd    cdread.CD,
+  track *_ConvertTrack,
+  pos   int64,
+
+) (int64,error) {
+
+  // Capçalera
+  info := track.info
+  var mode string
+  switch info.Type {
+  case cdread.TRACK_TYPE_AUDIO:
+    mode= "AUDIO"
+  case cdread.TRACK_TYPE_MODE1_RAW, cdread.TRACK_TYPE_ISO:
+    mode= "MODE1/2352"
+  default:
+    mode= "MODE2/2352"
+  }
+  fmt.Fprintf ( w, "  TRACK %02x %s\n", info.Id, mode )
+  writeConvertCDText ( w, "    ", &info.CDText )
+  var flags []string
+  if info.Flags&cdread.TRACK_FLAGS_DCP != 0 {
+    flags= append(flags,"DCP")
+  }
+  if info.Flags&cdread.TRACK_FLAGS_4CH != 0 {
+    flags= append(flags,"4CH")
+  }
+  if info.Flags&cdread.TRACK_FLAGS_PRE != 0 {
+    flags= append(flags,"PRE")
+  }
+  if info.Flags&cdread.TRACK_FLAGS_SCMS != 0 {
+    flags= append(flags,"SCMS")
+  }
+  if len(flags) > 0 {
+    fmt.Fprintf ( w, "    FLAGS %s\n", strings.Join ( flags, " " ) )
+  }
+  if info.ISRC != "" {
+    fmt.Fprintf ( w, "    ISRC %s\n", info.ISRC )
+  }
+
+  // Índexs
+  if track.pregap > 0 {
+    fmt.Fprintf ( w, "    INDEX 00 %s\n", convertMSF ( pos ) )
+  }
+  for _,ind := range info.Indexes {
+    if ind.Id == 0x00 { continue }
+    rel := cdread.GetSectorIndex ( ind.Pos )-track.index01
+    fmt.Fprintf ( w, "    INDEX %02x %s\n", ind.Id,
+      convertMSF ( pos+track.pregap+rel ) )
+  }
+
+  // Pregap
+  var sector [cdread.SECTOR_SIZE]byte
+  if err := writeConvertPregap ( bin, cd, track, sector[:] ); err != nil {
+    return 0,err
+  }
+
+  // Dades
+  tr,err := cd.TrackReader ( track.sess, track.track, cdread.MODE_RAW )
+  if err != nil { return 0,err }
+  defer tr.Close ()
+  n,err := writeConvertSectors ( bin, tr, track, track.index01, sector[:] )
+  if err != nil { return 0,err }
+
+  return track.pregap+n,nil
+
+} // end writeConvertTrack
+
+
+// Copia el pregap de la imatge si el conté. Si no, es sintetitzen
+// sectors buits.
+func writeConvertPregap (
+
+  bin    *bufio.Writer,
+  cd     cdread.CD,
+  track  *_ConvertTrack,
+  sector []byte,
+
+) error {
+
+  if track.pregap == 0 { return nil }
+
+  // Còpia
+  first := track.index01-track.pregap
+  if pcd,ok := cd.(cdread.PregapCD); ok {
+    tr,err := pcd.PregapReader ( track.sess, track.track, cdread.MODE_RAW )
+    if err != nil { return err }
+    if tr != nil {
+      defer tr.Close ()
+      if err := tr.Seek ( track.skip ); err != nil { return err }
+      n,err := writeConvertSectors ( bin, tr, track, first, sector )
+      if err != nil { return err }
+      if n != track.pregap {
+        return fmt.Errorf ( "(CONVERT) failed to read the pregap of track"+
+          " %02x: %d sectors expected, %d found", track.info.Id,
+          track.pregap, n )
+      }
+      return nil
+    }
+  }
+
+  // Sectors buits
+  for i := int64(0); i < track.pregap; i++ {
+    fillConvertPregapSector ( sector, first+i, track.info.Type )
+    if _,err := bin.Write ( sector ); err != nil { return err }
+  }
+
+  return nil
+
+} // end writeConvertPregap
+
+
+// Escriu tots els sectors del lector com a sectors RAW. first és
+// l'índex absolut del primer sector.
+func writeConvertSectors (
+
+  bin    *bufio.Writer,
+  tr     cdread.TrackReader,
+  track  *_ConvertTrack,
+  first  int64,
+  sector []byte,
+
+) (int64,error) {
+
+  var err error
+  is_iso := track.info.Type == cdread.TRACK_TYPE_ISO
+  var n int64 = 0
+  for ;; n++ {
+
+    // Llig
+    if is_iso {
+      for i := range sector {
+        sector[i]= 0
+      }
+      _,err= io.ReadFull ( tr, sector[0x10:0x810] )
+    } else {
+      _,err= io.ReadFull ( tr, sector )
+    }
+    if err == io.EOF {
+      break
+    } else if err != nil {
+      return n,fmt.Errorf ( "(CONVERT) failed to read track %02x: %s",
+        track.info.Id, err )
+    }
+
+    // Reconstrueix els sectors ISO com a Mode 1 i l'EDC i l'ECC dels
+    // sectors cuinats (el lector ja els fica la capçalera).
+    if is_iso {
+      cdread.FillRawSector ( sector, first+n, 1,
+        cdread.TRACK_TYPE_MODE1_RAW )
+    } else if track.info.Cooked {
+      cdread.RegenerateRawSector ( sector, track.info.Type )
+    }
+    if _,err := bin.Write ( sector ); err != nil { return n,err }
+
+  }
+
+  return n,nil
+
+} // end writeConvertTrackData
+
+
+// Sector buit del pregap segons el tipus de track. En CD-XA es fa
+// servir Form 2.
+func fillConvertPregapSector ( sector []byte, sec_ind int64, track_type int ) {
+
+  for i := range sector {
+    sector[i]= 0
+  }
+  switch track_type {
+  case cdread.TRACK_TYPE_MODE1_RAW, cdread.TRACK_TYPE_ISO:
+    cdread.FillRawSector ( sector, sec_ind, 1, cdread.TRACK_TYPE_MODE1_RAW )
+  case cdread.TRACK_TYPE_MODE2_RAW:
+    cdread.FillRawSector ( sector, sec_ind, 2, track_type )
+  case cdread.TRACK_TYPE_MODE2_CDXA_RAW:
+    sector[0x12],sector[0x16]= 0x20,0x20
+    cdread.FillRawSector ( sector, sec_ind, 2, track_type )
+  }
+
+} // end fillConvertPregapSector
+
+
+func writeConvertCDText (
+
+  w      *bufio.Writer,
+  prefix string,
+  text   *cdread.CDText,
+
+) {
+
+  if text.Title != "" {
+    fmt.Fprintf ( w, "%sTITLE \"%s\"\n", prefix, text.Title )
+  }
+  if text.Performer != "" {
+    fmt.Fprintf ( w, "%sPERFORMER \"%s\"\n", prefix, text.Performer )
+  }
+  if text.Songwriter != "" {
+    fmt.Fprintf ( w, "%sSONGWRITER \"%s\"\n", prefix, text.Songwriter )
+  }
+
+} // end writeConvertCDText
+
+
+func convertMSF ( sec int64 ) string {
+  return fmt.Sprintf ( "%02d:%02d:%02d", sec/(60*75), (sec/75)%60, sec%75 )
+} // end convertMSF
@@ -0,0 +1,303 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ * datcheck.go - Implementa l'operació DATCHECK. Comprova els
+ *               fitxers de les imatges amb un fitxer DAT (format
+ *               XML de Logiqx, emprat per Redump i No-Intro).
+ */
+
+package ops
+
+import (
+  "crypto/md5"
+  "crypto/sha1"
+  "encoding/hex"
+  "encoding/xml"
+  "errors"
+  "fmt"
+  "hash/crc32"
+  "io"
+  "os"
+  "path"
+  "strconv"
+  "strings"
+
+  "github.com/adriagipas/imgcp/cdread"
+  "github.com/adriagipas/imgcp/utils"
+)
+
+
+/***********/
+/* DAT XML */
+/***********/
+
+type _DatRom struct {
+  Name string `xml:"name,attr"`
+  Size string `xml:"size,attr"`
+  CRC  string `xml:"crc,attr"`
+  MD5  string `xml:"md5,attr"`
+  SHA1 string `xml:"sha1,attr"`
+}
+
+type _DatGame struct {
+  Name string    `xml:"name,attr"`
+  Roms []_DatRom `xml:"rom"`
+}
+
+type _DatFile struct {
+  Name     string     `xml:"header>name"`
+  Version  string     `xml:"header>version"`
+  Games    []_DatGame `xml:"game"`
+  Machines []_DatGame `xml:"machine"`
+}
+
+
+// Referència a una entrada del DAT.
+type _DatRef struct {
+  game int
+  rom  int
+}
+
+
+type _DatIndex struct {
+  dat     *_DatFile
+  by_sha1 map[string][]_DatRef
+  by_md5  map[string][]_DatRef
+  by_crc  map[string][]_DatRef // CRC + grandària
+  by_name map[string][]_DatRef
+}
+
+
+func readDat ( file_name string ) (*_DatIndex,error) {
+
+  // Llig
+  f,err := os.Open ( file_name )
+  if err != nil { return nil,err }
+  defer f.Close ()
+  dat := _DatFile{}
+  if err := xml.NewDecoder ( f ).Decode ( &dat ); err != nil {
+    return nil,fmt.Errorf ( "(DATCHECK) failed to read DAT file '%s': %s",
+      file_name, err )
+  }
+  dat.Games= append(dat.Games,dat.Machines...)
+
+  // Indexa
+  ret := _DatIndex{
+    dat     : &dat,
+    by_sha1 : make(map[string][]_DatRef),
+    by_md5  : make(map[string][]_DatRef),
+    by_crc  : make(map[string][]_DatRef),
+    by_name : make(map[string][]_DatRef),
+  }
+  for g,game := range dat.Games {
+    for r,rom := range game.Roms {
+      ref := _DatRef{g,r}
+      if rom.SHA1 != "" {
+        key := strings.ToLower ( rom.SHA1 )
+        ret.by_sha1[key]= append(ret.by_sha1[key],ref)
+      }
+      if rom.MD5 != "" {
+        key := strings.ToLower ( rom.MD5 )
+        ret.by_md5[key]= append(ret.by_md5[key],ref)
+      }
+      if rom.CRC != "" {
+        key := strings.ToLower ( rom.CRC )+"/"+rom.Size
+        ret.by_crc[key]= append(ret.by_crc[key],ref)
+      }
+      ret.by_name[rom.Name]= append(ret.by_name[rom.Name],ref)
+    }
+  }
+
+  return &ret,nil
+
+} // end readDat
+
+
+func (self *_DatIndex) getRom ( ref _DatRef ) *_DatRom {
+  return &self.dat.Games[ref.game].Roms[ref.rom]
+} // end getRom
+
+
+// Busca una entrada amb els mateixos hashos. Si hi han diverses es
+// prefereix la que té el mateix nom.
+func (self *_DatIndex) find ( h *_DatHashes, name string ) (_DatRef,bool) {
+
+  refs,ok := self.by_sha1[h.sha1]
+  if !ok {
+    refs,ok= self.by_md5[h.md5]
+  }
+  if !ok {
+    refs,ok= self.by_crc[h.crc+"/"+strconv.FormatInt ( h.size, 10 )]
+  }
+  if !ok || len(refs) == 0 { return _DatRef{},false }
+  for _,ref := range refs {
+    if self.getRom ( ref ).Name == name {
+      return ref,true
+    }
+  }
+
+  return refs[0],true
+
+} // end find
+
+
+
+
+/**********/
+/* HASHOS */
+/**********/
+
+type _DatHashes struct {
+  size int64
+  crc  string
+  md5  string
+  sha1 string
+}
+
+
+func computeDatHashes ( file_name string ) (*_DatHashes,error) {
+
+  f,size,err := cdread.OpenDecodedFile ( file_name )
+  if err != nil { return nil,err }
+  defer f.Close ()
+  h_crc,h_md5,h_sha1 := crc32.NewIEEE (),md5.New (),sha1.New ()
+  n,err := io.Copy ( io.MultiWriter ( h_crc, h_md5, h_sha1 ), f )
+  if err != nil { return nil,err }
+  if n != size {
+    return nil,fmt.Errorf ( "(DATCHECK) failed to read '%s'", file_name )
+  }
+  ret := _DatHashes{
+    size : size,
+    crc  : hex.EncodeToString ( h_crc.Sum ( nil ) ),
+    md5  : hex.EncodeToString ( h_md5.Sum ( nil ) ),
+    sha1 : hex.EncodeToString ( h_sha1.Sum ( nil ) ),
+  }
+
+  return &ret,nil
+
+} // end computeDatHashes
+
+
+
+
+/************/
+/* OPERACIÓ */
+/************/
+
+// Torna els fitxers que formen una imatge.
+func getDatCheckFiles ( file_name string ) []string {
+
+  if cd,err := cdread.Open ( file_name ); err == nil {
+    if fl,ok := cd.(cdread.FileLister); ok {
+      return fl.Files ()
+    }
+  }
+
+  return []string{file_name}
+
+} // end getDatCheckFiles
+
+
+func DatCheck ( args *utils.Args ) error {
+
+  // Llig DAT
+  if len(args.OpArgs) != 1 {
+    return errors.New ( "(DATCHECK) a DAT file must be provided" )
+  }
+  dat,err := readDat ( args.OpArgs[0] )
+  if err != nil { return err }
+  fmt.Println("")
+  if dat.dat.Version != "" {
+    fmt.Printf("  DAT: %s (%s)\n",dat.dat.Name,dat.dat.Version)
+  } else {
+    fmt.Printf("  DAT: %s\n",dat.dat.Name)
+  }
+
+  // Comprova fitxers
+  found := make(map[_DatRef]bool)
+  games := make(map[int]bool)
+  matched,mismatched,unknown,missing := 0,0,0,0
+  for _,file := range args.Files {
+    fmt.Println("")
+    fmt.Printf("  \"%s\"\n",file)
+    fmt.Println("")
+    for _,fn := range getDatCheckFiles ( file ) {
+
+      // Calcula hashos
+      h,err := computeDatHashes ( fn )
+      if err != nil { return err }
+      name := path.Base ( fn )
+      if strings.HasSuffix ( strings.ToLower ( name ), ".ecm" ) {
+        name= name[:len(name)-4]
+      }
+
+      // Busca
+      if ref,ok := dat.find ( h, name ); ok {
+        rom := dat.getRom ( ref )
+        fmt.Printf("    [MATCH   ] %s (%s)\n",name,
+          dat.dat.Games[ref.game].Name)
+        if rom.Name != name {
+          fmt.Printf("               rename to: %s\n",rom.Name)
+        }
+        found[ref]= true
+        games[ref.game]= true
+        matched++
+      } else if refs,ok := dat.by_name[name]; ok {
+        rom := dat.getRom ( refs[0] )
+        fmt.Printf("    [MISMATCH] %s (CRC32 %s, expected %s)\n",
+          name, h.crc, strings.ToLower ( rom.CRC ))
+        found[refs[0]]= true // No es torna a informar com que falta
+        games[refs[0].game]= true
+        mismatched++
+      } else {
+        fmt.Printf("    [UNKNOWN ] %s (CRC32 %s)\n",name,h.crc)
+        unknown++
+      }
+
+    }
+  }
+
+  // Entrades que falten dels jocs trobats
+  for g,game := range dat.dat.Games {
+    if !games[g] { continue }
+    for r,rom := range game.Roms {
+      if !found[_DatRef{g,r}] {
+        if missing == 0 {
+          fmt.Println("")
+        }
+        fmt.Printf("    [MISSING ] %s (%s)\n",rom.Name,game.Name)
+        missing++
+      }
+    }
+  }
+
+  // Resum
+  fmt.Println("")
+  fmt.Printf("  %d matched, %d mismatched, %d unknown, %d missing\n",
+    matched, mismatched, unknown, missing)
+  fmt.Println("")
+  if mismatched > 0 || missing > 0 {
+    return fmt.Errorf ( "(DATCHECK) %d mismatched and %d missing entries",
+      mismatched, missing )
+  }
+
+  return nil
+
+} // end DatCheck
@@ -0,0 +1,238 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  mkiso.go - Implementa l'operació MKISO. Crea una imatge ISO 9660
+ *             a partir d'un directori.
+ */
+
+package ops
+
+import (
+  "errors"
+  "fmt"
+  "strconv"
+  "strings"
+  "time"
+
+  "github.com/adriagipas/imgcp/cdread"
+  "github.com/adriagipas/imgcp/imgs"
+  "github.com/adriagipas/imgcp/utils"
+)
+
+
+/************/
+/* OPERACIÓ */
+/************/
+
+func MkIso ( args *utils.Args ) error {
+
+  // Processa opcions
+  opts := cdread.ISO_WriterOptions{
+    Level: 1,
+  }
+  var boot_path []string
+  var paths []string
+  for i := 0; i < len(args.OpArgs); i++ {
+    arg := args.OpArgs[i]
+
+    // Opcions sense argument
+    if arg == "-J" {
+      opts.Joliet= true
+      continue
+    } else if arg == "-R" {
+      opts.RockRidge= true
+      continue
+    } else if arg == "-no-emul-boot" {
+      opts.BootNoEmulation= true
+      continue
+    } else if len(arg) == 0 || arg[0] != '-' {
+      paths= append(paths,arg)
+      continue
+    }
+
+    // Opcions amb argument
+    if i == len(args.OpArgs)-1 {
+      return fmt.Errorf ( "(MKISO) missing value for option '%s'", arg )
+    }
+    i++
+    val := args.OpArgs[i]
+    switch arg {
+    case "-level":
+      level,err := strconv.Atoi ( val )
+      if err != nil || level < 1 || level > 3 {
+        return fmt.Errorf ( "(MKISO) invalid level: %s", val )
+      }
+      opts.Level= level
+    case "-V":
+      opts.VolumeIdentifier= val
+    case "-sysid":
+      opts.SystemIdentifier= val
+    case "-volset":
+      opts.VolumeSetIdentifier= val
+    case "-publisher":
+      opts.PublisherIdentifier= val
+    case "-preparer":
+      opts.DataPreparerIdentifier= val
+    case "-appid":
+      opts.ApplicationIdentifier= val
+    case "-date":
+      date,err := time.ParseInLocation ( "20060102150405", val, time.Local )
+      if err != nil {
+        return fmt.Errorf ( "(MKISO) invalid date: %s", val )
+      }
+      opts.Date= date
+    case "-b":
+      opts.Boot= true
+      boot_path= strings.Split ( strings.Trim ( val, "/" ), "/" )
+    case "-boot-load-size":
+      size,err := strconv.ParseUint ( val, 10, 16 )
+      if err != nil || size == 0 {
+        return fmt.Errorf ( "(MKISO) invalid boot load size: %s", val )
+      }
+      opts.BootLoadSize= uint16(size)
+    default:
+      return fmt.Errorf ( "(MKISO) unknown option: %s", arg )
+    }
+
+  }
+  if len(paths) != 2 {
+    return errors.New ( "(MKISO) a source path and an ISO file name"+
+      " must be provided" )
+  }
+
+  // Obté directori origen
+  path,err := args.GetPath ( paths[0] )
+  if err != nil { return err }
+  img,err := imgs.NewImage ( path.FileName )
+  if err != nil { return err }
+  dir,err := img.GetRootDirectory ()
+  if err != nil { return err }
+  res,err := imgs.FindPath ( dir, path.Paths, true )
+  if err != nil { return err }
+
+  // Crea la imatge
+  w,err := cdread.NewISOWriter ( paths[1], opts )
+  if err != nil { return err }
+  state := _MkIsoState{
+    w: w,
+    boot_path: boot_path,
+  }
+  if err := state.copyDir ( path.Path, res.Dir, w.Root (), []string{} );
+  err != nil {
+    w.Close ()
+    return err
+  }
+  if opts.Boot {
+    if state.boot_file == nil {
+      w.Close ()
+      return fmt.Errorf ( "(MKISO) boot image '%s' not found",
+        strings.Join ( boot_path, "/" ) )
+    }
+    if err := w.SetBootImage ( state.boot_file ); err != nil {
+      w.Close ()
+      return err
+    }
+  }
+
+  return w.Close ()
+
+} // end MkIso
+
+
+// Iteradors que coneixen la data de modificació de les entrades.
+type _MkIsoModTimeIter interface {
+  GetModTime() (time.Time,error)
+}
+
+
+type _MkIsoState struct {
+  w         *cdread.ISO_Writer
+  boot_path []string
+  boot_file *cdread.ISO_WriterFile
+}
+
+
+func (self *_MkIsoState) isBootPath( path []string ) bool {
+
+  if len(path) != len(self.boot_path) { return false }
+  for i,name := range path {
+    if name != self.boot_path[i] {
+      return false
+    }
+  }
+
+  return true
+
+} // end isBootPath
+
+
+func (self *_MkIsoState) copyDir(
+
+  prefix  string,
+  src_dir imgs.Directory,
+  dst_dir *cdread.ISO_WriterDir,
+  path    []string,
+
+) error {
+
+  i,err := src_dir.Begin ()
+  for ; !i.End () && err == nil; err= i.Next () {
+    name := i.GetName ()
+    new_path := append(append([]string{},path...),name)
+    if i.Type () == imgs.DIRECTORY_ITER_TYPE_DIR {
+
+      new_src_dir,err := i.GetDirectory ()
+      if err != nil { return err }
+      new_dst_dir := dst_dir.AddDir ( name )
+      if it,ok := i.(_MkIsoModTimeIter); ok {
+        date,err := it.GetModTime ()
+        if err != nil { return err }
+        new_dst_dir.SetDate ( date )
+      }
+      err= self.copyDir ( prefix + "/" + name, new_src_dir,
+        new_dst_dir, new_path )
+      if err != nil { return err }
+
+    } else if i.Type () == imgs.DIRECTORY_ITER_TYPE_FILE {
+
+      fmt.Printf ( "Adding %s/%s ...\n", prefix, name )
+      f,err := i.GetFileReader ()
+      if err != nil { return err }
+      file,err := dst_dir.AddFile ( name, f )
+      if err != nil {
+        f.Close ()
+        return fmt.Errorf ( "An error occurred while copying '%s/%s': %s",
+          prefix, name, err )
+      }
+      if err := f.Close (); err != nil { return err }
+      if it,ok := i.(_MkIsoModTimeIter); ok {
+        date,err := it.GetModTime ()
+        if err != nil { return err }
+        file.SetDate ( date )
+      }
+      if self.isBootPath ( new_path ) {
+        self.boot_file= file
+      }
+
+    }
+  }
+
+  return err
+
+} // end copyDir
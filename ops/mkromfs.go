@@ -0,0 +1,151 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  mkromfs.go - Implementa l'operació MKROMFS. Crea una imatge RomFS
+ *               de 3DS a partir d'un directori i opcionalment
+ *               reconstrueix un NCCH amb ella.
+ */
+
+package ops
+
+import (
+  "errors"
+  "fmt"
+  "os"
+
+  "github.com/adriagipas/imgcp/citrus"
+  "github.com/adriagipas/imgcp/imgs"
+  "github.com/adriagipas/imgcp/utils"
+)
+
+
+/************/
+/* OPERACIÓ */
+/************/
+
+func MkRomFS ( args *utils.Args ) error {
+
+  // Processa opcions
+  ncch_name := ""
+  var paths []string
+  for i := 0; i < len(args.OpArgs); i++ {
+    arg := args.OpArgs[i]
+    if len(arg) == 0 || arg[0] != '-' {
+      paths= append(paths,arg)
+      continue
+    }
+    if i == len(args.OpArgs)-1 {
+      return fmt.Errorf ( "(MKROMFS) missing value for option '%s'", arg )
+    }
+    i++
+    switch arg {
+    case "-ncch":
+      ncch_name= args.OpArgs[i]
+    default:
+      return fmt.Errorf ( "(MKROMFS) unknown option: %s", arg )
+    }
+  }
+  if len(paths) != 2 {
+    return errors.New ( "(MKROMFS) a source path and an output file name"+
+      " must be provided" )
+  }
+
+  // Obté directori origen
+  path,err := args.GetPath ( paths[0] )
+  if err != nil { return err }
+  img,err := imgs.NewImage ( path.FileName )
+  if err != nil { return err }
+  dir,err := img.GetRootDirectory ()
+  if err != nil { return err }
+  res,err := imgs.FindPath ( dir, path.Paths, true )
+  if err != nil { return err }
+
+  // NCCH original. La RomFS s'escriu en un fitxer temporal.
+  var ncch *citrus.NCCH
+  romfs_name := paths[1]
+  if ncch_name != "" {
+    if ncch,err= citrus.NewNCCH ( ncch_name ); err != nil { return err }
+    tmp,err := os.CreateTemp ( "", "imgcp-romfs-*.bin" )
+    if err != nil { return err }
+    romfs_name= tmp.Name ()
+    tmp.Close ()
+    defer os.Remove ( romfs_name )
+  }
+
+  // Crea la imatge
+  w,err := citrus.NewRomFSWriter ( romfs_name )
+  if err != nil { return err }
+  if err := mkRomFSCopyDir ( path.Path, res.Dir, w.Root () ); err != nil {
+    w.Close ()
+    return err
+  }
+  if err := w.Close (); err != nil { return err }
+
+  // Reconstrueix l'NCCH
+  if ncch != nil {
+    fmt.Printf ( "Rebuilding %s ...\n", paths[1] )
+    if err := ncch.Rebuild ( paths[1], romfs_name ); err != nil {
+      return fmt.Errorf ( "(MKROMFS) %s", err )
+    }
+  }
+
+  return nil
+
+} // end MkRomFS
+
+
+func mkRomFSCopyDir(
+
+  prefix  string,
+  src_dir imgs.Directory,
+  dst_dir *citrus.RomFS_WriterDir,
+
+) error {
+
+  i,err := src_dir.Begin ()
+  for ; !i.End () && err == nil; err= i.Next () {
+    name := i.GetName ()
+    if name == "." || name == ".." { continue }
+    if i.Type () == imgs.DIRECTORY_ITER_TYPE_DIR {
+
+      new_src_dir,err := i.GetDirectory ()
+      if err != nil { return err }
+      err= mkRomFSCopyDir ( prefix + "/" + name, new_src_dir,
+        dst_dir.AddDir ( name ) )
+      if err != nil { return err }
+
+    } else if i.Type () == imgs.DIRECTORY_ITER_TYPE_FILE {
+
+      fmt.Printf ( "Adding %s/%s ...\n", prefix, name )
+      f,err := i.GetFileReader ()
+      if err != nil { return err }
+      if _,err := dst_dir.AddFile ( name, f ); err != nil {
+        f.Close ()
+        return fmt.Errorf ( "An error occurred while copying '%s/%s': %s",
+          prefix, name, err )
+      }
+      if err := f.Close (); err != nil { return err }
+
+    }
+  }
+
+  return err
+
+} // end mkRomFSCopyDir
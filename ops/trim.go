@@ -0,0 +1,94 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  trim.go - Implementa les operacions TRIM i UNTRIM. Elimina i
+ *            restaura el farciment de les imatges CCI.
+ */
+
+package ops
+
+import (
+  "fmt"
+
+  "github.com/adriagipas/imgcp/citrus"
+  "github.com/adriagipas/imgcp/imgs"
+  "github.com/adriagipas/imgcp/utils"
+)
+
+
+/************/
+/* OPERACIÓ */
+/************/
+
+func Trim ( args *utils.Args ) error {
+  return trimImages ( args, "TRIM", "removed",
+    func(cci *citrus.CCI) (int64,error) {
+      return cci.Trim ()
+    })
+} // end Trim
+
+
+func Untrim ( args *utils.Args ) error {
+  return trimImages ( args, "UNTRIM", "added",
+    func(cci *citrus.CCI) (int64,error) {
+      return cci.Untrim ()
+    })
+} // end Untrim
+
+
+func trimImages (
+
+  args *utils.Args,
+  op   string,
+  verb string,
+  f    func(cci *citrus.CCI) (int64,error),
+
+) error {
+
+  if len(args.OpArgs) > 0 {
+    return fmt.Errorf ( "(%s) invalid arguments: %v", op, args.OpArgs )
+  }
+
+  for _,file := range args.Files {
+
+    // Obri
+    img_type,err := imgs.Detect ( file )
+    if err != nil { return err }
+    if img_type != imgs.TYPE_CCI {
+      return fmt.Errorf ( "(%s) '%s' is not a CCI image", op, file )
+    }
+    cci,err := citrus.NewCCI ( file )
+    if err != nil { return err }
+
+    // Processa
+    n,err := f ( cci )
+    if err != nil { return fmt.Errorf ( "(%s) %s", op, err ) }
+    if n == 0 {
+      fmt.Printf ( "%s: nothing to do\n", file )
+    } else {
+      fmt.Printf ( "%s: %s of padding %s\n", file,
+        utils.NumBytesToStr ( uint64(n) ), verb )
+    }
+
+  }
+
+  return nil
+
+} // end trimImages
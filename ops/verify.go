@@ -0,0 +1,325 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ * verify.go - Implementa l'operació VERIFY. Comprova la
+ *             sincronització, la capçalera, l'EDC i l'ECC de tots
+ *             els sectors dels tracks de dades d'una imatge de CD, o
+ *             els hashos SHA-256 de les imatges de 3DS.
+ */
+
+package ops
+
+import (
+  "errors"
+  "fmt"
+  "io"
+  "os"
+  "path"
+  "strings"
+
+  "github.com/adriagipas/imgcp/cdread"
+  "github.com/adriagipas/imgcp/citrus"
+  "github.com/adriagipas/imgcp/imgs"
+  "github.com/adriagipas/imgcp/utils"
+)
+
+
+/************/
+/* OPERACIÓ */
+/************/
+
+func Verify ( args *utils.Args ) error {
+
+  // Processa opcions
+  out_dir := ""
+  for i := 0; i < len(args.OpArgs); i++ {
+    if args.OpArgs[i] == "-o" && i < len(args.OpArgs)-1 {
+      i++
+      out_dir= args.OpArgs[i]
+    } else {
+      return fmt.Errorf ( "(VERIFY) invalid arguments: %v", args.OpArgs )
+    }
+  }
+  if out_dir != "" {
+    if err := os.MkdirAll ( out_dir, 0755 ); err != nil { return err }
+  }
+  if out_dir != "" && len(args.Files) > 1 {
+    return errors.New ( "(VERIFY) -o can only be used with one image" )
+  }
+
+  // Verifica
+  var nerrors,nregions int64 = 0,0
+  print_name := len(args.Files)>1
+  for name,file := range args.Files {
+    fmt.Println("")
+    if print_name {
+      fmt.Printf("  %s) \"%s\"\n",name,file)
+      fmt.Println("")
+    }
+
+    // Imatges de 3DS
+    img_type,err := imgs.Detect ( file )
+    if err != nil { return err }
+    if img_type == imgs.TYPE_NCCH || img_type == imgs.TYPE_CCI ||
+      img_type == imgs.TYPE_CIA {
+      if out_dir != "" {
+        return errors.New ( "(VERIFY) -o can only be used with CD images" )
+      }
+      n,err := verify3DS ( file, img_type )
+      if err != nil { return err }
+      nregions+= n
+      fmt.Println("")
+      continue
+    }
+
+    // Imatges de CD
+    cd,err := cdread.Open ( file )
+    if err != nil {
+      return fmt.Errorf ( "(VERIFY) '%s' is not a CD image", file )
+    }
+    n,err := verifyCD ( cd, out_dir )
+    if err != nil { return err }
+    nerrors+= n
+    fmt.Println("")
+  }
+  if nerrors > 0 {
+    return fmt.Errorf ( "(VERIFY) %d damaged sectors found", nerrors )
+  }
+  if nregions > 0 {
+    return fmt.Errorf ( "(VERIFY) %d corrupted regions found", nregions )
+  }
+
+  return nil
+
+} // end Verify
+
+
+func verifyCD ( cd cdread.CD, out_dir string ) (int64,error) {
+
+  var nerrors int64 = 0
+  info := cd.Info ()
+  for s,sess := range info.Sessions {
+    for t,track := range sess.Tracks {
+
+      // Sols tracks de dades amb sectors RAW
+      switch track.Type {
+      case cdread.TRACK_TYPE_MODE1_RAW,
+        cdread.TRACK_TYPE_MODE2_RAW,
+        cdread.TRACK_TYPE_MODE2_CDXA_RAW:
+      default:
+        continue
+      }
+
+      // Obté primer sector
+      var first int64 = -1
+      for _,ind := range track.Indexes {
+        if ind.Id == 0x01 {
+          first= cdread.GetSectorIndex ( ind.Pos )
+        }
+      }
+      if first == -1 {
+        return nerrors,fmt.Errorf ( "(VERIFY) track %02x without index 01",
+          track.Id )
+      }
+
+      // Verifica
+      var out_file string
+      if out_dir != "" {
+        out_file= path.Join ( out_dir, fmt.Sprintf ( "track%02x.bin",
+          track.Id ) )
+      }
+      n,err := verifyTrack ( cd, s, t, &track, first, out_file )
+      if err != nil { return nerrors,err }
+      nerrors+= n
+
+    }
+  }
+
+  return nerrors,nil
+
+} // end verifyCD
+
+
+func verifyTrack (
+
+  cd       cdread.CD,
+  sess     int,
+  track    int,
+  info     *cdread.TrackInfo,
+  first    int64,
+  out_file string,
+
+) (int64,error) {
+
+  // Obri
+  tr,err := cd.TrackReader ( sess, track, cdread.MODE_RAW )
+  if err != nil { return 0,err }
+  defer tr.Close ()
+  var out *os.File
+  if out_file != "" {
+    if out,err= os.Create ( out_file ); err != nil { return 0,err }
+    defer out.Close ()
+  }
+
+  // Comprova sectors
+  var buf [cdread.SECTOR_SIZE]byte
+  var nsecs,nerrors int64 = 0,0
+  for {
+    if _,err := io.ReadFull ( tr, buf[:] ); err == io.EOF {
+      break
+    } else if err != nil {
+      return nerrors,err
+    }
+    sec_ind := first+nsecs
+    if ret := cdread.CheckRawSector ( buf[:], sec_ind,
+      info.Type ); ret != 0 {
+      pos := cdread.GetPosition ( sec_ind )
+      fmt.Printf ( "    Track %02x  %02x:%02x:%02x (LBA %d): %s\n",
+        info.Id, pos.Minutes, pos.Seconds, pos.Sector, sec_ind-2*75,
+        verifyErrorsToString ( ret ) )
+      nerrors++
+      if out != nil {
+        cdread.RegenerateRawSector ( buf[:], info.Type )
+      }
+    }
+    if out != nil {
+      if _,err := out.Write ( buf[:] ); err != nil { return nerrors,err }
+    }
+    nsecs++
+  }
+  fmt.Printf ( "    Track %02x: %d sectors, %d errors\n",
+    info.Id, nsecs, nerrors )
+
+  return nerrors,nil
+
+} // end verifyTrack
+
+
+func verifyErrorsToString ( errs int ) string {
+
+  var ret []string
+  if errs&cdread.SECTOR_ERR_SYNC != 0 {
+    ret= append(ret,"bad sync")
+  }
+  if errs&cdread.SECTOR_ERR_HEADER != 0 {
+    ret= append(ret,"bad header")
+  }
+  if errs&cdread.SECTOR_ERR_EDC != 0 {
+    ret= append(ret,"EDC mismatch")
+  }
+  if errs&cdread.SECTOR_ERR_ECC != 0 {
+    ret= append(ret,"ECC mismatch")
+  }
+
+  return strings.Join ( ret, ", " )
+
+} // end verifyErrorsToString
+
+
+// Torna el nombre de regions amb hashos incorrectes.
+func verify3DS ( file string, img_type int ) (int64,error) {
+
+  switch img_type {
+
+  case imgs.TYPE_NCCH:
+    ncch,err := citrus.NewNCCH ( file )
+    if err != nil { return 0,err }
+    return verifyNCCH ( ncch, "    " )
+
+  case imgs.TYPE_CCI:
+    cci,err := citrus.NewCCI ( file )
+    if err != nil { return 0,err }
+    var nbad int64 = 0
+    for i,part := range cci.Header.Partitions {
+      if part.Type != citrus.NCSD_PARTITION_TYPE_NCCH { continue }
+      fmt.Printf ( "    Partition %d:\n", i )
+      ncch,err := cci.GetNCCHPartition ( i )
+      if err != nil { return nbad,err }
+      n,err := verifyNCCH ( ncch, "      " )
+      if err != nil { return nbad,err }
+      nbad+= n
+    }
+    return nbad,nil
+
+  default: // CIA
+    cia,err := citrus.NewCIA ( file )
+    if err != nil { return 0,err }
+    var nbad int64 = 0
+    for i := range cia.TMD.Contents {
+      fmt.Printf ( "    Content %d:\n", cia.TMD.Contents[i].Index )
+      // El hash del TMD és de les dades desxifrades
+      if cia.TMD.Contents[i].Type&citrus.CIA_CONTENT_TYPE_ENCRYPTED != 0 {
+        fmt.Printf ( "      %-20s %s\n", "TMD hash",
+          "encrypted, not verified" )
+        continue
+      }
+      ok,err := cia.VerifyContentHash ( i )
+      if err != nil { return nbad,err }
+      fmt.Printf ( "      %-20s %s\n", "TMD hash", verifyStatus ( ok ) )
+      if !ok {
+        nbad++
+      }
+      ncch,err := cia.GetNCCHContent ( i )
+      if err != nil {
+        fmt.Printf ( "      %s\n", err )
+        continue
+      }
+      n,err := verifyNCCH ( ncch, "      " )
+      if err != nil { return nbad,err }
+      nbad+= n
+    }
+    return nbad,nil
+
+  }
+
+} // end verify3DS
+
+
+func verifyNCCH ( ncch *citrus.NCCH, prefix string ) (int64,error) {
+
+  checks,err := ncch.VerifyHashes ()
+  if err != nil { return 0,err }
+  var nbad int64 = 0
+  for _,check := range checks {
+    var status string
+    if check.NBlocks > 1 && !check.Ok () {
+      status= fmt.Sprintf ( "CORRUPTED (%d of %d blocks)",
+        check.BadBlocks, check.NBlocks )
+    } else {
+      status= verifyStatus ( check.Ok () )
+    }
+    fmt.Printf ( "%s%-20s %s\n", prefix, check.Region, status )
+    if !check.Ok () {
+      nbad++
+    }
+  }
+
+  return nbad,nil
+
+} // end verifyNCCH
+
+
+func verifyStatus ( ok bool ) string {
+  if ok {
+    return "OK"
+  } else {
+    return "CORRUPTED"
+  }
+} // end verifyStatus
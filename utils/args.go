@@ -55,13 +55,20 @@ type Args struct {
 /* CONSTANTS */
 /*************/
 
-const OP_NONE   = 0
-const OP_SHOW   = 1
-const OP_LIST   = 2
-const OP_CAT    = 3
-const OP_MKDIR  = 4
-const OP_COPY   = 5
-const OP_REMOVE = 6
+const OP_NONE     = 0
+const OP_SHOW     = 1
+const OP_LIST     = 2
+const OP_CAT      = 3
+const OP_MKDIR    = 4
+const OP_COPY     = 5
+const OP_REMOVE   = 6
+const OP_MKISO    = 7
+const OP_VERIFY   = 8
+const OP_DATCHECK = 9
+const OP_CONVERT  = 10
+const OP_MKROMFS  = 11
+const OP_TRIM     = 12
+const OP_UNTRIM   = 13
 
 
 /*********************/
@@ -79,31 +86,70 @@ func print_usage() {
   P("    <PATH>: <PATH_NONAME> | <NAME>=<PATH_NONAME>")
   P("    <PATH_NONNAME>: A file path separated by '/'")
   P("")
-  P("    <OP>: <OP_CAT> | <OP_COPY> | <OP_LIST> | <OP_MKDIR> |"+
-    " <OP_REMOVE> | <OP_SHOW>")
+  P("    <OP>: <OP_CAT> | <OP_CONVERT> | <OP_COPY> | <OP_DATCHECK> |")
+  P("          <OP_LIST> | <OP_MKDIR> | <OP_MKISO> | <OP_MKROMFS> |")
+  P("          <OP_REMOVE> | <OP_SHOW> | <OP_TRIM> | <OP_UNTRIM> |")
+  P("          <OP_VERIFY>")
   P("")
   P("    <OP_CAT> : cat <PATH> [<PATH>]*")
   P("")
+  P("    <OP_CONVERT> : convert [-split] <CUE file name>")
+  P("")
   P("    <OP_COPY> : (copy | cp) <PATH> [<PATH>]* <PATH>")
   P("")
+  P("    <OP_DATCHECK> : datcheck <DAT file name>")
+  P("")
   P("    <OP_LIST> : (list | ls) <PATH> [<PATH>]*")
   P("")
   P("    <OP_MKDIR> : mkdir <PATH> [<PATH>]*")
   P("")
+  P("    <OP_MKISO> : mkiso [<MKISO_OPT>]* <PATH> <ISO file name>")
+  P("    <MKISO_OPT>: -level (1|2|3) | -J | -R | -V <volume id> |")
+  P("                 -sysid <id> | -volset <id> | -publisher <id> |")
+  P("                 -preparer <id> | -appid <id> |")
+  P("                 -date <YYYYMMDDhhmmss> | -b <boot image path> |")
+  P("                 -no-emul-boot | -boot-load-size <sectors>")
+  P("")
+  P("    <OP_MKROMFS> : mkromfs [-ncch <NCCH file name>] <PATH>")
+  P("                   <output file name>")
+  P("")
   P("    <OP_REMOVE> : (remove | rm) <PATH> [<PATH>]*")
   P("")
   P("    <OP_SHOW>: show | sh")
   P("")
+  P("    <OP_TRIM>: trim")
+  P("")
+  P("    <OP_UNTRIM>: untrim")
+  P("")
+  P("    <OP_VERIFY>: verify [-o <output directory>]")
+  P("")
   P("OPERATIONS:\n")
   P("  cat: Similar to the UNIX cat command, concatenate files and print")
   P("       on the standard output")
   P("")
 
+  P("  convert: Write the CD image as a CUE/BIN with raw 2352-byte")
+  P("           sectors. ISO tracks are rebuilt as Mode 1 sectors")
+  P("           (sync, header, EDC and ECC). Cooked Mode 2 sectors")
+  P("           (2336 bytes, or 2048 bytes of Form 1 data) get their")
+  P("           sync, header, subheader, EDC and ECC rebuilt. Pregaps")
+  P("           are copied from the image, or filled with empty")
+  P("           sectors when the image does not store them, and all")
+  P("           indexes are kept. With -split a BIN file is written")
+  P("           for each track.")
+  P("")
   P("  copy: Copy files from one image (or host) to another image (or host).")
   P("        Destionation path is always the last provided path. Several")
   P("        source paths can be provided. If the source path is a directory")
   P("        then it is copied recursively.")
   P("")
+  P("  datcheck: Check the files of the images (CUE/GDI/CCD/MDS track")
+  P("            files or the image file itself) against a Logiqx XML")
+  P("            DAT file (Redump, No-Intro). CRC32, MD5 and SHA-1 are")
+  P("            computed and matched, mismatched, unknown and missing")
+  P("            entries are reported, suggesting the canonical names")
+  P("            of the matched files.")
+  P("")
   P("  list: Similar to the UNIX ls command, show the content inside")
   P("        the provided PATH. If the PATH is a file show the properties")
   P("        of the provided PATH")
@@ -112,11 +158,47 @@ func print_usage() {
   P("         a provided path. All subdirectories in the path are also")
   P("         created.")
   P("")
+  P("  mkiso: Creates a new ISO 9660 image file with the content of the")
+  P("         provided directory PATH. By default level 1 names are used.")
+  P("         Options: -level selects the interchange level (3 allows")
+  P("         files bigger than 4G), -J adds Joliet names, -R adds")
+  P("         Rock Ridge names and attributes, -V/-sysid/-volset/")
+  P("         -publisher/-preparer/-appid set the volume descriptor")
+  P("         identifiers and -date the creation and modification")
+  P("         dates. -b makes the image bootable (El Torito) using the")
+  P("         provided file path (relative to PATH) as boot image.")
+  P("         The boot image is a floppy image unless -no-emul-boot")
+  P("         is specified.")
+  P("")
+  P("  mkromfs: Creates a new 3DS RomFS image (level 3 with the")
+  P("           directory/file hash tables and the IVFC hash tree)")
+  P("           with the content of the provided directory PATH.")
+  P("           With -ncch the output file is a copy of the provided")
+  P("           NCCH (decrypted if needed) where the RomFS is replaced")
+  P("           by the new one and the header hashes are updated.")
+  P("")
   P("  remove: Remove specified files or directories.")
   P("")
   P("  show: This is the default operation. Show the information")
   P("        of the current files.")
   P("")
+  P("  trim: Remove the 0xFF padding after the last partition of")
+  P("        the CCI images (3DS cartridge dumps). The images are")
+  P("        modified in place and it fails if other data than")
+  P("        padding would be removed.")
+  P("")
+  P("  untrim: Restore the original size of trimmed CCI images,")
+  P("          the media size of the header, adding 0xFF padding.")
+  P("")
+  P("  verify: Check the sync pattern, header, EDC and ECC of all the")
+  P("          raw sectors in the data tracks of the CD images. With -o")
+  P("          each data track is written to the output directory as")
+  P("          trackNN.bin with EDC/ECC regenerated in the damaged")
+  P("          sectors. In 3DS images (3DS/CCI, NCCH/CXI and CIA) the")
+  P("          SHA-256 hashes of the ExHeader, ExeFS, RomFS (IVFC hash")
+  P("          tree) and unencrypted CIA contents are checked")
+  P("          instead, reporting the corrupted or modified regions.")
+  P("")
 }
 
 
@@ -199,6 +281,34 @@ func NewArgs() (*Args,error) {
       args.Op= OP_REMOVE
       args.OpArgs= os.Args[i+1:]
       break
+    } else if os.Args[i]=="mkiso" { // Operació mkiso
+      args.Op= OP_MKISO
+      args.OpArgs= os.Args[i+1:]
+      break
+    } else if os.Args[i]=="mkromfs" { // Operació mkromfs
+      args.Op= OP_MKROMFS
+      args.OpArgs= os.Args[i+1:]
+      break
+    } else if os.Args[i]=="datcheck" { // Operació datcheck
+      args.Op= OP_DATCHECK
+      args.OpArgs= os.Args[i+1:]
+      break
+    } else if os.Args[i]=="convert" { // Operació convert
+      args.Op= OP_CONVERT
+      args.OpArgs= os.Args[i+1:]
+      break
+    } else if os.Args[i]=="trim" { // Operació trim
+      args.Op= OP_TRIM
+      args.OpArgs= os.Args[i+1:]
+      break
+    } else if os.Args[i]=="untrim" { // Operació untrim
+      args.Op= OP_UNTRIM
+      args.OpArgs= os.Args[i+1:]
+      break
+    } else if os.Args[i]=="verify" { // Operació verify
+      args.Op= OP_VERIFY
+      args.OpArgs= os.Args[i+1:]
+      break
     } else { // Filename
       if err := args.register_filename ( os.Args[i] ); err != nil {
         return nil,err
@@ -0,0 +1,277 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  gdf.go - Sistema de fitxers XDVDFS (GDF) dels volums SVOD.
+ */
+
+package x360
+
+import (
+  "errors"
+  "fmt"
+  "io"
+  "os"
+  "time"
+)
+
+
+/*********/
+/* TIPUS */
+/*********/
+
+const _GDF_MAGIC = "MICROSOFT*XBOX*MEDIA"
+
+const _GDF_ATTR_DIRECTORY = 0x10
+
+const _GDF_ENTRY_SIZE = 0xe
+
+// Les taules s'omplin amb 0xFF. Un node amb aquest valor no existeix.
+const _GDF_NODE_NONE = 0xFFFF
+
+// Estat mentre es llig l'arbre de directoris.
+type _GDF_Reader struct {
+
+  mng     *_SvodFileManager
+  entries []STFS_FileEntry
+  date    [4]byte
+  visited map[uint32]bool // Sectors dels directoris ja llegits
+  
+}
+
+
+/************/
+/* FUNCIONS */
+/************/
+
+func _le_u16( v []byte ) uint16 {
+  return uint16(v[0]) | (uint16(v[1])<<8)
+} // end _le_u16
+
+
+func _le_u32( v []byte ) uint32 {
+  return uint32(v[0]) |
+    (uint32(v[1])<<8) |
+    (uint32(v[2])<<16) |
+    (uint32(v[3])<<24)
+} // end _le_u32
+
+
+func _le_u64( v []byte ) uint64 {
+  return uint64(_le_u32 ( v )) | (uint64(_le_u32 ( v[4:] ))<<32)
+} // end _le_u64
+
+
+// Converteix un FILETIME (intervals de 100ns des de 1601) al format
+// de data de FAT emprat en les entrades STFS.
+func filetimeToFAT( filetime uint64 ) [4]byte {
+
+  var ret [4]byte
+  
+  // Des de 1601 a 1970 hi han 11644473600 segons.
+  secs:= int64(filetime/10000000) - 11644473600
+  t:= time.Unix ( secs, 0 ).UTC ()
+  if t.Year () < 1980 || t.Year () > 1980+0x7f { return ret }
+  val:= (uint32(t.Year ()-1980)<<25) |
+    (uint32(t.Month ())<<21) |
+    (uint32(t.Day ())<<16) |
+    (uint32(t.Hour ())<<11) |
+    (uint32(t.Minute ())<<5) |
+    uint32(t.Second ()/2)
+  ret[0]= uint8(val>>24)
+  ret[1]= uint8(val>>16)
+  ret[2]= uint8(val>>8)
+  ret[3]= uint8(val)
+  
+  return ret
+  
+} // end filetimeToFAT
+
+
+func (self *_GDF_Reader) readSectors(
+
+  sector uint32,
+  size   uint32,
+
+) ([]byte,error) {
+
+  num_blocks:= int32((int64(size)+_SVOD_SECTOR_SIZE-1)/_SVOD_SECTOR_SIZE)
+  f,err:= newSvodFile ( self.mng, int32(sector), num_blocks, int64(size) )
+  if err != nil { return nil,err }
+  defer f.Close ()
+  ret:= make([]byte,size)
+  if _,err:= io.ReadFull ( f, ret ); err != nil {
+    return nil,err
+  }
+
+  return ret,nil
+  
+} // end readSectors
+
+
+// Llig la taula d'un directori i afegeix les seues entrades. Les
+// entrades formen un arbre binari on els nodes s'indiquen en
+// paraules de 4 bytes des del principi de la taula.
+func (self *_GDF_Reader) readDirectory(
+
+  sector uint32,
+  size   uint32,
+  parent int,
+  
+) error {
+
+  // Comprovacions
+  if self.visited[sector] {
+    return fmt.Errorf (
+      "Error while reading GDF directory at sector %d: visited twice",
+      sector )
+  }
+  self.visited[sector]= true
+  
+  // Llig la taula
+  table,err:= self.readSectors ( sector, size )
+  if err != nil {
+    return fmt.Errorf ( "Error while reading GDF directory at sector %d: %s",
+      sector, err )
+  }
+
+  // Un directori buit té la taula plena de 0xFF.
+  if len(table) < 4 || _le_u16 ( table ) == _GDF_NODE_NONE {
+    return nil
+  }
+  
+  return self.readEntry ( table, 0, parent, make([]bool,len(table)/4) )
+  
+} // end readDirectory
+
+
+func (self *_GDF_Reader) readEntry(
+
+  table   []byte,
+  ordinal int,
+  parent  int,
+  visited []bool,
+  
+) error {
+
+  // Comprovacions
+  off:= ordinal*4
+  if ordinal >= len(visited) || off+_GDF_ENTRY_SIZE > len(table) {
+    return fmt.Errorf ( "Error while reading GDF entry %d: out of"+
+      " directory boundaries", ordinal )
+  }
+  if visited[ordinal] {
+    return fmt.Errorf ( "Error while reading GDF entry %d: visited twice",
+      ordinal )
+  }
+  visited[ordinal]= true
+  
+  // Entrada
+  entry:= table[off:]
+  node_l:= int(_le_u16 ( entry[0x0:] ))
+  node_r:= int(_le_u16 ( entry[0x2:] ))
+  sector:= _le_u32 ( entry[0x4:] )
+  length:= _le_u32 ( entry[0x8:] )
+  attributes:= entry[0xc]
+  name_len:= int(entry[0xd])
+  if off+_GDF_ENTRY_SIZE+name_len > len(table) {
+    return fmt.Errorf ( "Error while reading GDF entry %d: out of"+
+      " directory boundaries", ordinal )
+  }
+  name:= string(entry[_GDF_ENTRY_SIZE:_GDF_ENTRY_SIZE+name_len])
+
+  // Node esquerre
+  if node_l != 0 && node_l != _GDF_NODE_NONE {
+    if err:= self.readEntry ( table, node_l, parent, visited ); err != nil {
+      return err
+    }
+  }
+
+  // Afegeix
+  e:= STFS_FileEntry{
+    Name : name,
+    IsDirectory : (attributes&_GDF_ATTR_DIRECTORY)!=0,
+    Consecutive : true,
+    StartingBlock : int32(sector),
+    PathIndicator : parent,
+    UpdateDate : self.date,
+    AccessDate : self.date,
+  }
+  if !e.IsDirectory {
+    e.Size= int64(length)
+    e.NumBlocks= int32((int64(length)+_SVOD_SECTOR_SIZE-1)/_SVOD_SECTOR_SIZE)
+  }
+  self.entries= append(self.entries,e)
+  if e.IsDirectory && length > 0 {
+    if err:= self.readDirectory ( sector, length,
+      len(self.entries)-1 ); err != nil {
+      return err
+    }
+  }
+  
+  // Node dret
+  if node_r != 0 && node_r != _GDF_NODE_NONE {
+    if err:= self.readEntry ( table, node_r, parent, visited ); err != nil {
+      return err
+    }
+  }
+  
+  return nil
+  
+} // end readEntry
+
+
+// Les entrades del GDF no tenen data, s'empra la del volum.
+func (self *_SvodFileManager) FileList() ([]STFS_FileEntry,error) {
+
+  // Llig el descriptor del volum.
+  fd,err:= os.Open ( self.file_names[0] )
+  if err != nil { return nil,err }
+  var buf [0x24]byte
+  nbytes,err:= fd.ReadAt ( buf[:], self.magic_offset )
+  fd.Close ()
+  if err != nil {
+    return nil,fmt.Errorf ( "Error while reading GDF volume descriptor: %s",
+      err )
+  } else if nbytes != len(buf) {
+    return nil,errors.New ( "Error while reading GDF volume descriptor:"+
+      " not enough bytes" )
+  }
+  if string(buf[:len(_GDF_MAGIC)]) != _GDF_MAGIC {
+    return nil,errors.New ( "Error while reading GDF volume descriptor:"+
+      " wrong magic number" )
+  }
+  root_sector:= _le_u32 ( buf[0x14:] )
+  root_size:= _le_u32 ( buf[0x18:] )
+
+  // Llig arbre
+  reader:= _GDF_Reader{
+    mng: self,
+    date: filetimeToFAT ( _le_u64 ( buf[0x1c:] ) ),
+    visited: make(map[uint32]bool),
+  }
+  if root_size > 0 {
+    if err:= reader.readDirectory ( root_sector, root_size, -1 ); err != nil {
+      return nil,err
+    }
+  }
+  
+  return reader.entries,nil
+  
+} // end FileList
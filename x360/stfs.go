@@ -151,7 +151,7 @@ type _STFS_FileManager interface {
     block       int32,
     num_blocks  int32,
     consecutive bool,
-    size        int32, // <= 0 vol dir que no es sap (tots els blocs)
+    size        int64, // <= 0 vol dir que no es sap (tots els blocs)
   ) (utils.FileReader,error)
   
 }
@@ -163,6 +163,7 @@ type STFS struct {
 
   // Privat
   mng       _STFS_FileManager
+  svod      *_SvodFileManager // nil si no és SVOD
   
 }
 
@@ -173,7 +174,7 @@ type STFS_FileEntry struct {
   Consecutive     bool // Blocks consecutius
   StartingBlock   int32
   NumBlocks       int32
-  Size            int32
+  Size            int64
   UpdateDate      [4]byte
   AccessDate      [4]byte
   PathIndicator   int
@@ -487,7 +488,10 @@ func NewSTFS( file_name string ) (*STFS,error) {
       return nil,err
     }
   case 1: // SVOD
-    return nil,errors.New("TODO - SvodFileManager")
+    if ret.svod,err= newSvodFileManager ( file_name, &ret ); err != nil {
+      return nil,err
+    }
+    ret.mng= ret.svod
   default:
     return nil,fmt.Errorf (
       "Error while reading STFS file '%s': unknown volume descriptor type %02X",
@@ -626,11 +630,28 @@ func (self *STFS) DescriptorType() string {
 } // end STFS.DescriptorType
 
 
+func (self *STFS) SvodLayout() string {
+  if self.svod == nil {
+    return "None"
+  }
+  switch self.svod.layout {
+  case SVOD_LAYOUT_EGDF:
+    return "Enhanced GDF"
+  case SVOD_LAYOUT_XSF:
+    return "XSF"
+  case SVOD_LAYOUT_SINGLE_FILE:
+    return "Single File"
+  default:
+    return "Unknown"
+  }
+} // end STFS.SvodLayout
+
+
 func (self *STFS) Open(
   block       int32,
   num_blocks  int32,
   consecutive bool,
-  size        int32, // <= 0 vol dir que no es sap (tots els blocs)
+  size        int64, // <= 0 vol dir que no es sap (tots els blocs)
 ) (utils.FileReader,error) {
   return self.mng.Open ( block, num_blocks, consecutive, size )
 } // end STFS.Open
@@ -638,6 +659,11 @@ func (self *STFS) Open(
 
 func (self *STFS) FileList() ([]STFS_FileEntry,error) {
 
+  // Els volums SVOD contenen un sistema de fitxers GDF.
+  if self.svod != nil {
+    return self.svod.FileList ()
+  }
+  
   // Get file list block
   block_count:= int32(int16(_u16(self.Metadata.Volume[2:])))
   tmp:= (uint32(self.Metadata.Volume[4])<<16) |
@@ -687,7 +713,7 @@ func (self *STFS) FileList() ([]STFS_FileEntry,error) {
     e.IsDirectory= (buf[0x28]&0x80)!=0
     e.Consecutive= (buf[0x28]&0x40)!=0
     e.PathIndicator= int(int16(_u16(buf[0x32:])))
-    e.Size= int32(_u32(buf[0x34:]))
+    e.Size= int64(int32(_u32(buf[0x34:])))
     e.StartingBlock= _le_s24(buf[0x2f:])
     e.NumBlocks= _le_s24(buf[0x29:])
     copy ( e.UpdateDate[:], buf[0x38:] )
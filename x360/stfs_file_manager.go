@@ -85,7 +85,7 @@ func (self *_StfsFileManager) Open(
   block       int32,
   num_blocks  int32,
   consecutive bool,
-  size        int32, // <= 0 vol dir que no es sap (tots els blocs)
+  size        int64, // <= 0 vol dir que no es sap (tots els blocs)
   
 ) (utils.FileReader,error) {
   return newStfsFile ( self, block, num_blocks, consecutive, size )
@@ -202,7 +202,7 @@ func newStfsFile(
   block       int32,
   num_blocks  int32,
   consecutive bool,
-  size        int32, // <= 0 vol dir que no es sap (tots els blocs)
+  size        int64, // <= 0 vol dir que no es sap (tots els blocs)
   
 ) (*_StfsFile,error) {
   
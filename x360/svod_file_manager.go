@@ -0,0 +1,358 @@
+/*
+ * Copyright 2025 Adrià Giménez Pastor.
+ *
+ * This file is part of adriagipas/imgcp.
+ *
+ * adriagipas/imgcp is free software: you can redistribute it and/or
+ * modify it under the terms of the GNU General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * adriagipas/imgcp is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU
+ * General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with adriagipas/imgcp.  If not, see
+ * <https://www.gnu.org/licenses/>.
+ */
+/*
+ *  svod_file_manager.go - File manager per a volums de tipus SVOD.
+ */
+
+package x360
+
+import (
+  "errors"
+  "fmt"
+  "io"
+  "os"
+  "path/filepath"
+
+  "github.com/adriagipas/imgcp/utils"
+)
+
+
+/****************/
+/* FILE MANAGER */
+/****************/
+
+const (
+  SVOD_LAYOUT_UNK         = 0
+  SVOD_LAYOUT_EGDF        = 1 // Enhanced GDF
+  SVOD_LAYOUT_XSF         = 2
+  SVOD_LAYOUT_SINGLE_FILE = 3
+)
+
+const _SVOD_SECTOR_SIZE = 0x800
+const _SVOD_HASH_BLOCK_SIZE = 0x1000
+const _SVOD_SECTORS_PER_L0_HASH = 0x198
+const _SVOD_HASHES_PER_L1_HASH = 0xa1c4
+const _SVOD_SECTORS_PER_FILE = 0x14388
+const _SVOD_MAX_FILE_SIZE = 0xa290000
+
+const _SVOD_FLAGS_EGDF = 0x40
+
+type _SvodFileManager struct {
+
+  file_names   []string // Fitxers Data0000, Data0001, ...
+  layout       int
+  base_offset  int64
+  magic_offset int64 // Posició del descriptor GDF en Data0000
+  block_offset int64 // En sectors
+  
+}
+
+
+// Llig el magic del GDF en la posició indicada del primer fitxer de
+// dades.
+func checkGDFMagic( fd *os.File, offset int64, magic string ) bool {
+  
+  buf:= make([]byte,len(magic))
+  if nbytes,err:= fd.ReadAt ( buf, offset ); err != nil || nbytes != len(buf) {
+    return false
+  }
+  
+  return string(buf) == magic
+  
+} // end checkGDFMagic
+
+
+func newSvodFileManager(
+  
+  file_name string,
+  stfs      *STFS,
+  
+) (*_SvodFileManager,error) {
+
+  ret:= _SvodFileManager{}
+
+  // Bites volume descriptor. Els blocks són de 0x1000 però el GDF
+  // empra sectors de 0x800.
+  egdf:= (stfs.Metadata.Volume[0x17]&_SVOD_FLAGS_EGDF)!=0
+  ret.block_offset= int64(_le_s24(stfs.Metadata.Volume[0x1b:]))*2
+
+  // Fitxers de dades. Estan en el directori NOM.data que està al
+  // costat de la capçalera.
+  if stfs.Metadata.DataFileCount <= 0 {
+    return nil,fmt.Errorf (
+      "Error while reading SVOD file '%s': wrong number of data files (%d)",
+      file_name, stfs.Metadata.DataFileCount )
+  }
+  ret.file_names= make([]string,stfs.Metadata.DataFileCount)
+  for i:= range ret.file_names {
+    ret.file_names[i]= filepath.Join ( file_name+".data",
+      fmt.Sprintf ( "Data%04d", i ) )
+    if _,err:= os.Stat ( ret.file_names[i] ); err != nil {
+      return nil,fmt.Errorf ( "Error while opening SVOD data file: %s", err )
+    }
+  }
+
+  // Detecta la disposició buscant el descriptor del GDF.
+  fd,err:= os.Open ( ret.file_names[0] )
+  if err != nil { return nil,err }
+  defer fd.Close ()
+  if egdf {
+    // Just després dels blocs hash. Els índexs dels sectors es
+    // desplacen 0x1000 bytes.
+    if !checkGDFMagic ( fd, 0x2000, _GDF_MAGIC ) {
+      return nil,errors.New ( "Error while reading SVOD file: EGDF layout"+
+        " without GDF volume descriptor" )
+    }
+    ret.layout= SVOD_LAYOUT_EGDF
+    ret.block_offset-= 2
+    ret.base_offset= 0
+    ret.magic_offset= 0x2000
+  } else if checkGDFMagic ( fd, 0x12000, _GDF_MAGIC ) {
+    // Normalment generat amb eines de tercers que empren una
+    // capçalera XSF.
+    if checkGDFMagic ( fd, 0x2000, "XSF" ) {
+      ret.layout= SVOD_LAYOUT_XSF
+    } else {
+      ret.layout= SVOD_LAYOUT_UNK
+    }
+    ret.base_offset= 0x10000
+    ret.magic_offset= 0x12000
+  } else if checkGDFMagic ( fd, 0xd000, _GDF_MAGIC ) {
+    // Un únic fitxer. La capçalera ocupa 0xB000 i la resta són taules
+    // hash.
+    if stfs.Metadata.DataFileCount == 1 {
+      ret.layout= SVOD_LAYOUT_SINGLE_FILE
+    } else {
+      ret.layout= SVOD_LAYOUT_UNK
+    }
+    ret.base_offset= 0xb000
+    ret.magic_offset= 0xd000
+  } else {
+    return nil,errors.New ( "Error while reading SVOD file:"+
+      " GDF volume descriptor not found" )
+  }
+  
+  return &ret,nil
+  
+} // end newSvodFileManager
+
+
+func (self *_SvodFileManager) Open(
+  
+  block       int32,
+  num_blocks  int32,
+  consecutive bool,
+  size        int64, // <= 0 vol dir que no es sap (tots els blocs)
+  
+) (utils.FileReader,error) {
+  return newSvodFile ( self, block, num_blocks, size )
+} // end _SvodFileManager.Open
+
+
+// Torna el fitxer de dades i la posició dins del fitxer d'un sector
+// del GDF.
+func (self *_SvodFileManager) BlockToOffset( block int64 ) (int,int64,error) {
+
+  // Cada taula hash de nivell 0 (un block de 0x1000) va davant dels
+  // sectors als que fa referència, i cada taula de nivell 1 davant
+  // de les de nivell 0. Els sectors no són consecutius entre
+  // fitxers.
+  true_block:= block - self.block_offset
+  if true_block < 0 {
+    return -1,-1,fmt.Errorf ( "sector %d is out of SVOD boundaries", block )
+  }
+  file_block:= true_block%_SVOD_SECTORS_PER_FILE
+  file_index:= int(true_block/_SVOD_SECTORS_PER_FILE)
+  
+  // Taules hash
+  level0:= file_block/_SVOD_SECTORS_PER_L0_HASH + 1
+  offset:= level0*_SVOD_HASH_BLOCK_SIZE
+  level1:= level0/_SVOD_HASHES_PER_L1_HASH + 1
+  offset+= level1*_SVOD_HASH_BLOCK_SIZE
+  if self.layout == SVOD_LAYOUT_SINGLE_FILE {
+    offset+= self.base_offset
+  }
+
+  // Calcula offset
+  ret:= file_block*_SVOD_SECTOR_SIZE + offset
+  if ret >= _SVOD_MAX_FILE_SIZE {
+    file_index++
+    ret%= _SVOD_MAX_FILE_SIZE
+    ret+= 0x2000
+  }
+  if file_index >= len(self.file_names) {
+    return -1,-1,fmt.Errorf ( "sector %d is out of SVOD boundaries", block )
+  }
+
+  return file_index,ret,nil
+  
+} // end BlockToOffset
+
+
+/********/
+/* FILE */
+/********/
+
+// En SVOD els sectors dels fitxers sempre són consecutius.
+type _SvodFile struct {
+
+  mng            *_SvodFileManager
+  fd             *os.File
+  fd_index       int // Índex del fitxer de dades obert
+  v              [_SVOD_SECTOR_SIZE]byte
+  pv             []byte // Punter al buffer
+  remain         int64
+  current_block  int64
+  block_count    int32
+  
+}
+
+
+func newSvodFile(
+
+  mng         *_SvodFileManager,
+  block       int32,
+  num_blocks  int32,
+  size        int64, // <= 0 vol dir que no es sap (tots els blocs)
+  
+) (*_SvodFile,error) {
+  
+  // Comprovacions inicials
+  if block < 0 {
+    return nil,fmt.Errorf (
+      "unable to open SVOD File Reader starting in sector %d",
+      block )
+  }
+  if num_blocks < 0 {
+    return nil,fmt.Errorf (
+      "unable to open SVOD File Reader with block_count %d",
+      num_blocks )
+  }
+
+  // Inicialitza.
+  ret:= _SvodFile{
+    mng: mng,
+    fd_index: -1,
+    current_block: int64(block),
+    block_count: num_blocks,
+  }
+  if size <= 0 {
+    ret.remain= int64(num_blocks)*_SVOD_SECTOR_SIZE
+  } else {
+    ret.remain= size
+  }
+  if ret.remain > int64(num_blocks)*_SVOD_SECTOR_SIZE {
+    return nil,fmt.Errorf (
+      "unable to open SVOD File Reader: %d bytes do not fit in %d sectors",
+      ret.remain, num_blocks )
+  }
+
+  // Carrega el primer sector sols quan cal llegir.
+  ret.current_block--
+  
+  return &ret,nil
+  
+} // end newSvodFile
+
+
+func (self *_SvodFile) loadNextBlock() error {
+
+  // Comprovacions.
+  if self.block_count == 0 {
+    return errors.New (
+      "Error while loading next sector: no more sectors remaining" )
+  }
+  self.block_count--
+  self.current_block++
+
+  // Obri el fitxer de dades
+  index,offset,err:= self.mng.BlockToOffset ( self.current_block )
+  if err != nil {
+    return fmt.Errorf ( "Error while reading sector: %s", err )
+  }
+  if index != self.fd_index {
+    self.Close ()
+    if self.fd,err= os.Open ( self.mng.file_names[index] ); err != nil {
+      return err
+    }
+    self.fd_index= index
+  }
+
+  // Llig
+  self.pv= self.v[:] // Apunta al principi
+  if nbytes,err:= self.fd.ReadAt ( self.pv, offset ); err != nil {
+    return fmt.Errorf ( "Error while reading sector %d: %s",
+      self.current_block, err )
+  } else if nbytes != len(self.pv) {
+    return fmt.Errorf (
+      "Error while reading sector %d: failed to read current sector",
+      self.current_block )
+  }
+
+  return nil
+  
+} // end loadNextBlock
+
+
+func (self *_SvodFile) Read( buf []byte ) (int,error) {
+
+  // Cas especial EOF
+  if self.remain == 0 {
+    return 0,io.EOF
+  }
+
+  // Llig
+  ret:= len(buf)
+  if int64(ret) > self.remain { ret= int(self.remain) }
+  for toread:= ret; toread > 0; {
+
+    // Si el buffer està buit avança al següent
+    if len(self.pv) == 0 {
+      if err:= self.loadNextBlock (); err != nil {
+        return -1,err
+      }
+    }
+
+    // Llig del buffer.
+    n:= copy ( buf, self.pv )
+    if n > toread { n= toread }
+    buf= buf[n:]
+    self.pv= self.pv[n:]
+    self.remain-= int64(n)
+    toread-= n
+    
+  }
+  
+  return ret,nil
+  
+} // end _SvodFile.Read
+
+
+func (self *_SvodFile) Close() error {
+  
+  if self.fd != nil {
+    self.fd.Close ()
+    self.fd= nil
+    self.fd_index= -1
+  }
+
+  return nil
+  
+} // end _SvodFile.Close